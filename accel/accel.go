@@ -0,0 +1,57 @@
+// Package accel provides backends applying the lookup tables of mangaconv's contrast and gamma
+// adjustments with dedicated hardware instructions, for servers converting thousands of volumes.
+// Backends only accelerate lookup tables: all of them scale with imgutil's CacheScaler, the neon
+// backend with its fixed-point arithmetic. Backends other than cpu are only compiled in with their
+// build tag:
+//
+//	neon: NEON SIMD instructions of 64-bit ARM CPUs, such as AWS Graviton. Requires cgo.
+//
+// Pass a backend as mangaconv.Params.Scaler.
+package accel
+
+import (
+	"errors"
+	"fmt"
+	"image"
+
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+// ErrUnavailable is returned by New for backends which aren't compiled in or not supported by the
+// machine.
+var ErrUnavailable = errors.New("acceleration backend unavailable")
+
+// Available returns the sorted names of all compiled in backends.
+func Available() []string {
+	names := []string{"cpu"}
+	if hasNEON {
+		names = append(names, "neon")
+	}
+	return names
+}
+
+// New creates the backend named name. Backends are safe for concurrent use.
+func New(name string) (imgutil.LUTScaler, error) {
+	switch {
+	case name == "cpu":
+		return newCPU()
+	case name == "neon" && hasNEON:
+		return newNEON()
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnavailable, name)
+}
+
+// cpu is the portable backend, running the same code as mangaconv without a backend. It's mostly
+// useful to compare other backends against.
+type cpu struct {
+	*imgutil.CacheScaler
+}
+
+func newCPU() (imgutil.LUTScaler, error) {
+	return cpu{imgutil.NewCacheScaler(imgutil.CatmullRom)}, nil
+}
+
+// ApplyLUT implements the imgutil.LUTScaler interface.
+func (cpu) ApplyLUT(img *image.Gray, lut *imgutil.LUT) {
+	lut.Apply(img)
+}
@@ -0,0 +1,56 @@
+package accel_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"testing"
+
+	"github.com/naisuuuu/mangaconv/accel"
+	"github.com/naisuuuu/mangaconv/imgtest"
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+func TestNew(t *testing.T) {
+	for _, name := range accel.Available() {
+		if _, err := accel.New(name); err != nil {
+			t.Errorf("New(%s) error %v", name, err)
+		}
+	}
+	if _, err := accel.New("abacus"); !errors.Is(err, accel.ErrUnavailable) {
+		t.Errorf("New(abacus) error = %v, want %v", err, accel.ErrUnavailable)
+	}
+}
+
+// TestBackends compares all compiled in backends to the built-in implementations.
+func TestBackends(t *testing.T) {
+	src := imgtest.MustBeGray(imgtest.MustRead("../imgutil/testdata/wikipe-tan-195x239.png"))
+	lut := imgutil.GammaLUT(0.75)
+	for _, name := range accel.Available() {
+		t.Run(name, func(t *testing.T) {
+			b, err := accel.New(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// An odd size leaves pixels after the last full SIMD vector.
+			for _, size := range [][2]int{{100, 123}, {1, 1}, {250, 307}} {
+				want := image.NewGray(image.Rect(0, 0, size[0], size[1]))
+				imgutil.CatmullRom.Scale(want, src)
+				got := image.NewGray(want.Rect)
+				b.Scale(got, src)
+				// Backends may scale with fixed-point arithmetic.
+				if !imgtest.WithinDelta(got.Pix, want.Pix, 2) {
+					t.Errorf("%dx%d: Scale() differs from imgutil.CatmullRom", size[0], size[1])
+				}
+
+				want.Pix = append(want.Pix[:0], got.Pix...)
+				lut.Apply(want)
+				b.ApplyLUT(got, &lut)
+				if !bytes.Equal(got.Pix, want.Pix) {
+					t.Errorf("%dx%d: ApplyLUT() differs from LUT.Apply", size[0], size[1])
+				}
+			}
+		})
+	}
+}
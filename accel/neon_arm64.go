@@ -0,0 +1,68 @@
+//go:build cgo && neon
+// +build cgo,neon
+
+package accel
+
+/*
+#include <arm_neon.h>
+#include <stddef.h>
+#include <stdint.h>
+
+// apply_lut maps each of the n pixels at pix through the 256 entry lut, 16 pixels at a time. A
+// table lookup instruction covers 64 entries, so each quarter of the table is looked up in turn
+// with indices shifted down by 64, leaving pixels out of the quarter's range as they are.
+static void apply_lut(uint8_t *pix, size_t n, const uint8_t *lut) {
+	uint8x16x4_t q[4];
+	for (int i = 0; i < 4; i++) {
+		for (int j = 0; j < 4; j++) {
+			q[i].val[j] = vld1q_u8(lut + 64*i + 16*j);
+		}
+	}
+	const uint8x16_t k64 = vdupq_n_u8(64);
+	size_t i = 0;
+	for (; i + 16 <= n; i += 16) {
+		uint8x16_t idx = vld1q_u8(pix + i);
+		uint8x16_t v = vqtbl4q_u8(q[0], idx);
+		idx = vsubq_u8(idx, k64);
+		v = vqtbx4q_u8(v, q[1], idx);
+		idx = vsubq_u8(idx, k64);
+		v = vqtbx4q_u8(v, q[2], idx);
+		idx = vsubq_u8(idx, k64);
+		v = vqtbx4q_u8(v, q[3], idx);
+		vst1q_u8(pix + i, v);
+	}
+	for (; i < n; i++) {
+		pix[i] = lut[pix[i]];
+	}
+}
+*/
+import "C"
+
+import (
+	"image"
+
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+// hasNEON reports whether the neon backend is compiled in.
+const hasNEON = true
+
+// neon applies lookup tables with NEON table lookups. It scales with imgutil's CacheScaler using
+// fixed-point arithmetic, which is faster than floating point on ARM, but without NEON.
+type neon struct {
+	*imgutil.CacheScaler
+}
+
+func newNEON() (imgutil.LUTScaler, error) {
+	z := imgutil.NewCacheScaler(imgutil.CatmullRom)
+	z.SetFixedPoint(true)
+	return neon{z}, nil
+}
+
+// ApplyLUT implements the imgutil.LUTScaler interface.
+func (neon) ApplyLUT(img *image.Gray, lut *imgutil.LUT) {
+	if len(img.Pix) == 0 {
+		return
+	}
+	C.apply_lut((*C.uint8_t)(&img.Pix[0]), C.size_t(len(img.Pix)), (*C.uint8_t)(&lut[0]))
+}
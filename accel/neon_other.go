@@ -0,0 +1,14 @@
+//go:build !(cgo && neon && arm64)
+// +build !cgo !neon !arm64
+
+package accel
+
+import "github.com/naisuuuu/mangaconv/imgutil"
+
+// hasNEON reports whether the neon backend is compiled in.
+const hasNEON = false
+
+// newNEON is never called without the neon backend compiled in.
+func newNEON() (imgutil.LUTScaler, error) {
+	return nil, ErrUnavailable
+}
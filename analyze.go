@@ -0,0 +1,257 @@
+package mangaconv
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"sort"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+// PageStats describes a single input page, as found by Analyze.
+//
+// Width and Height are the dimensions of the decoded image. Color reports whether the image has
+// noticeably colored pixels, as opposed to grayscale scans. Blank reports whether the page is
+// almost a single color, such as an empty page between chapters. Spread reports whether the page is
+// wider than it's high. Outputs is the number of output pages the page is converted into, which
+// depends on Spreads. Progressive reports whether the page is a progressive JPEG or an interlaced
+// PNG, which decode slower and trip some decoders; Normalize re-encodes such pages.
+type PageStats struct {
+	Index       int
+	Chapter     string
+	Name        string
+	Source      string
+	Width       int
+	Height      int
+	Color       bool
+	Blank       bool
+	Spread      bool
+	Outputs     int
+	Progressive bool
+}
+
+// PlanPage is a single page of a Plan, along with overrides of Params for that page.
+//
+// Skip removes the page from the output. Spreads, if not nil, replaces Params.Spreads, such as to
+// keep a page detected as a spread in one piece. KeepColor keeps the page in color instead of
+// converting it to grayscale; such pages are scaled, adjusted and padded, but not split,
+// rotated or contrast normalized.
+type PlanPage struct {
+	PageStats
+	Skip      bool
+	Spreads   *SpreadPolicy
+	KeepColor bool
+}
+
+// outputs returns the number of output pages of p, taking overrides into account.
+func (p *PlanPage) outputs() int {
+	switch {
+	case p.Skip:
+		return 0
+	case p.KeepColor:
+		return 1
+	case p.Spreads != nil:
+		return spreadOutputs(*p.Spreads, p.Spread)
+	default:
+		return p.Outputs
+	}
+}
+
+// Plan describes how a single input is converted. It's created by Analyze and executed by
+// ConvertPlan, and may be inspected and changed in between, such as to confirm which pages are
+// removed.
+//
+// Metadata is written to ComicInfo.xml instead of the metadata parsed from the input name. Pages
+// are sorted by Index. Pages skipped due to MinPageSize or errors are not listed. Histogram is the
+// sum of histograms of all pages. GlobalContrast normalizes all pages using Histogram, so that
+// they keep their brightness relative to each other, rather than normalizing each one on its own.
+type Plan struct {
+	In             string
+	Metadata       Metadata
+	Pages          []PlanPage
+	Histogram      [256]uint
+	GlobalContrast bool
+}
+
+// OutputPages returns the number of pages written when the plan is executed, excluding skipped
+// pages. It can be used to report progress accurately.
+func (p *Plan) OutputPages() int {
+	n := 0
+	for i := range p.Pages {
+		n += p.Pages[i].outputs()
+	}
+	return n
+}
+
+// page returns the page at index, or nil if the plan has no such page.
+func (p *Plan) page(index int) *PlanPage {
+	i := sort.Search(len(p.Pages), func(i int) bool { return p.Pages[i].Index >= index })
+	if i == len(p.Pages) || p.Pages[i].Index != index {
+		return nil
+	}
+	return &p.Pages[i]
+}
+
+// skip reports whether the page at index isn't converted. Pages missing from the plan, such as
+// files added to the input after it was analyzed, are skipped as well.
+func (p *Plan) skip(index int) bool {
+	pg := p.page(index)
+	return pg == nil || pg.Skip
+}
+
+// pageOptions adjust how a single page is processed, overriding Params. If contrast isn't nil, it's
+// applied instead of normalizing each page's own histogram.
+type pageOptions struct {
+	contrast *imgutil.LUT
+	spreads  SpreadPolicy
+	ltr      bool
+	color    bool
+}
+
+// pageOptions returns options for pg, as overridden by hints and then by plan if they're not nil.
+func (c *Converter) pageOptions(plan *Plan, hints *comicHints, pg page, contrast *imgutil.LUT) pageOptions {
+	opts := pageOptions{contrast: contrast, spreads: c.params.Spreads, ltr: c.params.LeftToRight}
+	hints.apply(&opts, pg.Source)
+	if plan == nil {
+		return opts
+	}
+	if pg := plan.page(pg.Index); pg != nil {
+		if pg.Spreads != nil {
+			opts.spreads = *pg.Spreads
+		}
+		opts.color = pg.KeepColor
+	}
+	return opts
+}
+
+// Analyze reads and decodes all pages of the input at in and returns a Plan for converting it
+// without converting anything. If Errors is ErrorsSkip, pages which can't be read or decoded are
+// left out of the plan and a *PartialError is returned along with it.
+func (c *Converter) Analyze(in string) (*Plan, error) {
+	return c.analyze(context.Background(), in)
+}
+
+// analyze implements Analyze, stopping early when ctx is canceled.
+func (c *Converter) analyze(ctx context.Context, in string) (*Plan, error) {
+	path := LongPath(in)
+	read, err := c.selectReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", in, err)
+	}
+
+	var skipped pageErrors
+	if c.params.Errors == ErrorsSkip {
+		ctx = withPageErrors(ctx, &skipped)
+	}
+
+	hints := c.readHints(path)
+	plan := &Plan{In: in, Metadata: ParseFilename(in)}
+	var mu sync.Mutex
+	errg, ctx := errgroup.WithContext(ctx)
+	pages := make(chan page, c.pageBuffer())
+	errg.Go(func() error {
+		defer close(pages)
+		return read(ctx, pages, path)
+	})
+	for i := 0; i < c.workers(); i++ {
+		errg.Go(func() error {
+			for pg := range pages {
+				if err := c.acquire(ctx); err != nil {
+					return err
+				}
+				stats, hist := c.pageStats(pg, c.pageOptions(nil, hints, pg, nil).spreads)
+				c.release()
+				mu.Lock()
+				plan.Pages = append(plan.Pages, PlanPage{PageStats: stats})
+				for i, v := range hist {
+					plan.Histogram[i] += v
+				}
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	if err := errg.Wait(); err != nil {
+		return nil, err
+	}
+	sort.Slice(plan.Pages, func(i, j int) bool { return plan.Pages[i].Index < plan.Pages[j].Index })
+	return plan, skipped.err()
+}
+
+// pageStats computes statistics of a decoded page converted with spreads, along with its grayscale
+// histogram.
+func (c *Converter) pageStats(pg page, spreads SpreadPolicy) (PageStats, [256]uint) {
+	gray, view := imgutil.Luma(pg.Image)
+	if !view {
+		gray = c.pool.GetFromImage(pg.Image)
+		defer c.pool.Put(gray)
+	}
+	hist := imgutil.Histogram(gray)
+
+	size := pg.Image.Bounds().Size()
+	stats := PageStats{
+		Index:       pg.Index,
+		Chapter:     pg.Chapter,
+		Name:        pg.Name,
+		Source:      pg.Source,
+		Width:       size.X,
+		Height:      size.Y,
+		Color:       isColor(pg.Image),
+		Blank:       isBlank(hist),
+		Spread:      size.X > size.Y,
+		Progressive: pg.Progressive,
+	}
+	stats.Outputs = spreadOutputs(spreads, stats.Spread)
+	return stats, hist
+}
+
+// spreadOutputs returns the number of output pages of a page converted with policy.
+func spreadOutputs(policy SpreadPolicy, spread bool) int {
+	if !spread {
+		return 1
+	}
+	switch policy {
+	case SpreadSplit:
+		return 2
+	case SpreadBoth:
+		return 3
+	default:
+		return 1
+	}
+}
+
+const (
+	// blankRange is the largest difference between the darkest and brightest gray levels of a
+	// blank page, ignoring blankCutoff % of the darkest and brightest pixels.
+	blankRange  = 24
+	blankCutoff = 0.5
+	// colorThreshold is the chroma above which a pixel is colored, as in imgutil.ChromaHistogram.
+	// Pages are colored if more than colorCutoff % of their pixels are.
+	colorThreshold = 24
+	colorCutoff    = 1
+)
+
+// isBlank reports whether an image with histogram hist is almost a single color.
+func isBlank(hist [256]uint) bool {
+	s := imgutil.HistogramStats(hist)
+	return int(s.Percentile(100-blankCutoff))-int(s.Percentile(blankCutoff)) <= blankRange
+}
+
+// isColor reports whether img has noticeably colored pixels. JPEG scans of grayscale pages often
+// have a little color noise, which is ignored.
+func isColor(img image.Image) bool {
+	s := imgutil.HistogramStats(imgutil.ChromaHistogram(img))
+	return s.Percentile(100-colorCutoff) > colorThreshold
+}
+
+// ConvertPlan converts the input of a Plan created by Analyze and writes it to out. The Converter
+// must have the same input related Params, such as Order and Chapters, as the one which created
+// the plan, so that pages are numbered the same way.
+func (c *Converter) ConvertPlan(p *Plan, out io.Writer) error {
+	return convertMulti(context.Background(), p.In, []Output{{Converter: c, Writer: out}}, p)
+}
@@ -0,0 +1,162 @@
+package mangaconv
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAnalyze(t *testing.T) {
+	c := New(Params{Spreads: SpreadSplit})
+	plan, err := c.Analyze("testdata/wikipe-tan.zip")
+	if err != nil {
+		t.Fatalf("Analyze() error %v", err)
+	}
+	want := []PlanPage{
+		{PageStats: PageStats{Index: 0, Name: "wikipe-tan-0", Source: "wikipe-tan-0.png",
+			Width: 195, Height: 239, Color: true, Outputs: 1}},
+		{PageStats: PageStats{Index: 1, Name: "wikipe-tan-1", Source: "wikipe-tan-1.png",
+			Width: 195, Height: 239, Outputs: 1}},
+	}
+	if diff := cmp.Diff(want, plan.Pages); diff != "" {
+		t.Errorf("Analyze() pages mismatch (-want +got):\n%s", diff)
+	}
+	if got := plan.OutputPages(); got != 2 {
+		t.Errorf("OutputPages() = %d, want 2", got)
+	}
+}
+
+func TestConvertPlan(t *testing.T) {
+	c := New(Params{AutoContrast: true, Cutoff: 1, Gamma: 1, Height: 100, Width: 100})
+	plan, err := c.Analyze("testdata/wikipe-tan.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan.Pages[0].Skip = true
+	plan.GlobalContrast = true
+	plan.Metadata.Series = "Renamed"
+
+	var b bytes.Buffer
+	if err := c.ConvertPlan(plan, &b); err != nil {
+		t.Fatalf("ConvertPlan() error %v", err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+	if diff := cmp.Diff([]string{"000000001.jpg"}, names); diff != "" {
+		t.Errorf("ConvertPlan() files mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestConvertPlanKeepColor(t *testing.T) {
+	c := New(Params{AutoContrast: true, Cutoff: 1, Gamma: 1, Height: 100, Width: 100, Margin: 5})
+	plan, err := c.Analyze("testdata/wikipe-tan.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan.Pages[0].KeepColor = true
+
+	var b bytes.Buffer
+	if err := c.ConvertPlan(plan, &b); err != nil {
+		t.Fatalf("ConvertPlan() error %v", err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []bool{true, false} {
+		f, err := r.File[i].Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, _, err := image.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := isColor(img); got != want {
+			t.Errorf("page %d: isColor() = %v, want %v", i, got, want)
+		}
+		if got := img.Bounds().Dy(); got != 100 {
+			t.Errorf("page %d: got height %d, want 100", i, got)
+		}
+	}
+}
+
+func TestPlanOutputPages(t *testing.T) {
+	keep, split := SpreadKeep, SpreadSplit
+	spread := PageStats{Spread: true, Outputs: 3}
+	plan := Plan{Pages: []PlanPage{
+		{PageStats: PageStats{Outputs: 1}},
+		{PageStats: spread},
+		{PageStats: spread, Skip: true},
+		{PageStats: spread, Spreads: &keep},
+		{PageStats: spread, Spreads: &split},
+		{PageStats: spread, KeepColor: true},
+	}}
+	if got, want := plan.OutputPages(), 1+3+0+1+2+1; got != want {
+		t.Errorf("OutputPages() = %d, want %d", got, want)
+	}
+}
+
+func TestIsBlank(t *testing.T) {
+	var blank, noisy, page [256]uint
+	blank[250] = 1000
+	noisy[250], noisy[0] = 1000, 3
+	page[250], page[0] = 1000, 100
+	tests := []struct {
+		name string
+		hist [256]uint
+		want bool
+	}{
+		{"single color", blank, true},
+		{"few dark pixels", noisy, true},
+		{"page", page, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isBlank(tt.hist); got != tt.want {
+				t.Errorf("isBlank() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsColor(t *testing.T) {
+	rect := image.Rect(0, 0, 10, 10)
+	fill := func(img *image.NRGBA, c color.NRGBA, n int) *image.NRGBA {
+		for i := 0; i < n; i++ {
+			img.Set(i%10, i/10, c)
+		}
+		return img
+	}
+	gray := color.NRGBA{100, 100, 100, 255}
+	red := color.NRGBA{200, 50, 50, 255}
+	tests := []struct {
+		name string
+		img  image.Image
+		want bool
+	}{
+		{"gray image", image.NewGray(rect), false},
+		{"gray pixels", fill(image.NewNRGBA(rect), gray, 100), false},
+		{"single colored pixel", fill(fill(image.NewNRGBA(rect), gray, 100), red, 1), false},
+		{"colored pixels", fill(fill(image.NewNRGBA(rect), gray, 100), red, 10), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isColor(tt.img); got != tt.want {
+				t.Errorf("isColor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package mangaconv
+
+import (
+	"image"
+	"image/draw"
+	"time"
+
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+// bandCount returns the number of bands a page of size rect is split into to keep them within
+// MaxPageHeight once scaled, which is 1 for pages which aren't split.
+func (c *Converter) bandCount(rect image.Rectangle) int {
+	max := c.params.MaxPageHeight
+	if max <= 0 {
+		return 1
+	}
+	n := (c.fitRect(rect).Dy() + max - 1) / max
+	// Each band needs at least one row of the original page.
+	if n > rect.Dy() {
+		n = rect.Dy()
+	}
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// band returns the k-th of n horizontal bands of even height of rect.
+func band(rect image.Rectangle, k, n int) image.Rectangle {
+	h := rect.Dy()
+	return image.Rect(rect.Min.X, rect.Min.Y+h*k/n, rect.Max.X, rect.Min.Y+h*(k+1)/n)
+}
+
+// bandOverlap is the number of rows, at the smaller of the page's source and scaled resolutions,
+// each band is scaled with beyond its edges before being cropped, so that rows where bands meet
+// are interpolated from the same source rows as in the whole page instead of leaving seams. It
+// exceeds the support of imgutil's kernels.
+const bandOverlap = 4
+
+// pageBand is the part of a page written as one output page.
+type pageBand struct {
+	rows   image.Rectangle // rows of the scaled page held by the band
+	scaled image.Rectangle // rows of the scaled page scaled along with rows
+	src    image.Rectangle // part of the source page scaled to scaled
+	page   image.Rectangle // bounds of the whole scaled page once padded
+	off    image.Point     // offset of the scaled page within page
+	bounds image.Rectangle // part of page written, margins included
+}
+
+// pageBand returns the k-th of n bands of a page of size rect. Only the first band holds the top
+// margin of the padded page and only the last one its bottom margin. A single band holds the whole
+// page.
+func (c *Converter) pageBand(rect image.Rectangle, k, n int) pageBand {
+	fit := c.fitRect(rect)
+	r := image.Rect(0, 0, fit.Dx(), fit.Dy())
+	w, h := r.Dx(), r.Dy()
+	if c.params.Margin > 0 || c.params.ExactSize {
+		w, h = c.paddedSize(r)
+	}
+	b := pageBand{
+		rows: band(r, k, n),
+		page: image.Rect(0, 0, w, h),
+		off:  image.Pt((w-r.Dx())/2, (h-r.Dy())/2),
+	}
+	b.bounds = image.Rect(0, b.off.Y+b.rows.Min.Y, w, b.off.Y+b.rows.Max.Y)
+	if k == 0 {
+		b.bounds.Min.Y = 0
+	}
+	if k == n-1 {
+		b.bounds.Max.Y = h
+	}
+
+	// Kernels reach further in scaled rows when upscaling.
+	o := bandOverlap
+	if r.Dy() > rect.Dy() {
+		o = (bandOverlap*r.Dy() + rect.Dy() - 1) / rect.Dy()
+	}
+	b.scaled = image.Rect(0, b.rows.Min.Y-o, r.Dx(), b.rows.Max.Y+o).Intersect(r)
+	srcRow := func(y int) int {
+		return rect.Min.Y + (y*rect.Dy()+r.Dy()/2)/r.Dy()
+	}
+	b.src = image.Rect(rect.Min.X, srcRow(b.scaled.Min.Y), rect.Max.X, srcRow(b.scaled.Max.Y))
+	return b
+}
+
+// processBands converts a page into n bands, scaling each band of the page to the matching band of
+// the whole scaled page, and returns them with their timings like process. Only a band of the page
+// is converted at a time, and pages aren't split as spreads nor rotated, as they're taller than
+// wide.
+//
+// If opts.contrast is nil, all grayscale bands share the histogram normalization of the whole
+// page, measured before scaling, so that their tone matches where they meet.
+func (c *Converter) processBands(pg page, n int, opts pageOptions) ([]image.Image, []PageTimings) {
+	bounds := pg.Image.Bounds()
+	contrast := opts.contrast
+	var measure time.Duration
+	if !opts.color && contrast == nil && c.params.AutoContrast {
+		start := time.Now()
+		var hist [256]uint
+		for k := 0; k < n; k++ {
+			src, owned := c.grayBand(pg.Image, band(bounds, k, n))
+			for v, count := range imgutil.Histogram(src) {
+				hist[v] += count
+			}
+			if owned {
+				c.pool.Put(src)
+			}
+		}
+		lut := c.contrastLUT(hist)
+		contrast = &lut
+		measure = time.Since(start)
+	}
+
+	out := make([]image.Image, 0, n)
+	timings := make([]PageTimings, 0, n)
+	for k := 0; k < n; k++ {
+		t := pg.Timings
+		b := c.pageBand(bounds, k, n)
+		if opts.color {
+			out = append(out, c.finishColorBand(subImage(pg.Image, b.src), b, pg.Index, &t))
+			timings = append(timings, t)
+			continue
+		}
+		start := time.Now()
+		src, owned := c.grayBand(pg.Image, b.src)
+		t.Grayscale = time.Since(start)
+		out = append(out, c.finishBand(src, b, pg.Index, contrast, &t))
+		if k == 0 {
+			t.Contrast += measure
+		}
+		timings = append(timings, t)
+		if owned {
+			c.pool.Put(src)
+		}
+	}
+	return out, timings
+}
+
+// finishBand scales src, the part b.src of a grayscale page, and applies all modifications to band
+// b of it like finishRect. Margins, the page number and the watermark are placed on the whole page,
+// so that each of them only appears on the bands it overlaps.
+func (c *Converter) finishBand(src *image.Gray, b pageBand, index int, contrast *imgutil.LUT,
+	t *PageTimings) *image.Gray {
+	scaled := c.pool.Get(b.scaled.Dx(), b.scaled.Dy())
+	c.scaleAdjusted(scaled, src, contrast, t)
+	c.boldenPage(scaled)
+
+	dst := c.pool.Get(b.bounds.Dx(), b.bounds.Dy())
+	dst.Rect = b.bounds
+	rows := b.rows.Add(b.off)
+	fill := c.params.MarginColor
+	for y := dst.Rect.Min.Y; y < dst.Rect.Max.Y; y++ {
+		row := dst.Pix[dst.PixOffset(0, y) : dst.PixOffset(0, y)+dst.Rect.Dx()]
+		for x := range row {
+			row[x] = fill
+		}
+		if y >= rows.Min.Y && y < rows.Max.Y {
+			copy(row[rows.Min.X:rows.Max.X], scaled.Pix[scaled.PixOffset(0, y-b.off.Y-b.scaled.Min.Y):])
+		}
+	}
+	c.pool.Put(scaled)
+	c.overlay(dst, b.page, index)
+	// Pooled images and encoders expect bounds starting at the origin.
+	dst.Rect = dst.Rect.Sub(dst.Rect.Min)
+	return dst
+}
+
+// subImage returns the part r of img, copying it if img can't return sub images.
+func subImage(img image.Image, r image.Rectangle) image.Image {
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(r)
+	}
+	dst := image.NewRGBA(r)
+	draw.Draw(dst, r, img, r.Min, draw.Src)
+	return dst
+}
+
+// grayBand returns the part r of img in grayscale, and reports whether its pixel slice was taken
+// from the pool. Grayscale and YCbCr images are used without copying them.
+func (c *Converter) grayBand(img image.Image, r image.Rectangle) (*image.Gray, bool) {
+	if gray, ok := imgutil.Luma(img); ok {
+		// Luma's bounds start at the origin.
+		return gray.SubImage(r.Sub(img.Bounds().Min).Add(gray.Rect.Min)).(*image.Gray), false
+	}
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return c.pool.GetFromImage(sub.SubImage(r)), true
+	}
+	dst := c.pool.Get(r.Dx(), r.Dy())
+	draw.Draw(dst, dst.Rect, img, r.Min, draw.Src)
+	return dst, true
+}
@@ -0,0 +1,124 @@
+package mangaconv
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/naisuuuu/mangaconv/imgtest"
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+func TestBandCount(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Params
+		rect image.Rectangle
+		want int
+	}{
+		{"disabled", Params{Width: 100, Fit: FitWidth}, image.Rect(0, 0, 100, 10000), 1},
+		{"short", Params{Width: 100, Fit: FitWidth, MaxPageHeight: 1000}, image.Rect(0, 0, 100, 1000), 1},
+		{"tall", Params{Width: 100, Fit: FitWidth, MaxPageHeight: 1000}, image.Rect(0, 0, 100, 1001), 2},
+		{"scaled", Params{Width: 50, Fit: FitWidth, MaxPageHeight: 1000}, image.Rect(0, 0, 100, 10000), 5},
+		{"contained", Params{Width: 100, Height: 1000, MaxPageHeight: 1000}, image.Rect(0, 0, 100, 10000), 1},
+		{"upscaled", Params{Width: 1000, Fit: FitWidth, MaxPageHeight: 10}, image.Rect(0, 0, 10, 3), 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := New(tt.p).bandCount(tt.rect); got != tt.want {
+				t.Errorf("bandCount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessBands(t *testing.T) {
+	// The sub image is the gray page within a larger image.
+	gray := image.NewGray(image.Rect(0, 0, 20, 200))
+	rgba := image.NewNRGBA(gray.Rect)
+	padded := image.NewGray(image.Rect(0, 0, 30, 210))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 20; x++ {
+			v := uint8(y%7*30 + x)
+			gray.SetGray(x, y, color.Gray{v})
+			rgba.SetNRGBA(x, y, color.NRGBA{v, v / 2, 0xff - v, 0xff})
+			padded.SetGray(x+10, y+10, color.Gray{v})
+		}
+	}
+	p := Params{Width: 20, Fit: FitWidth, AutoContrast: true, Cutoff: 1, Gamma: 1, Margin: 5,
+		MarginColor: 0xff, PageNumbers: CornerBottomRight}
+	banded := p
+	banded.MaxPageHeight = 30
+
+	lut := imgutil.IdentityLUT()
+	fixed := pageOptions{contrast: &lut}
+	// Bands share the contrast of the whole page, measured before scaling.
+	measured := New(p).contrastLUT(imgutil.Histogram(gray))
+	tests := []struct {
+		name  string
+		img   image.Image
+		opts  pageOptions
+		whole pageOptions
+	}{
+		{"gray", gray, fixed, fixed},
+		{"nrgba", rgba, fixed, fixed},
+		{"sub image", padded.SubImage(image.Rect(10, 10, 30, 210)), fixed, fixed},
+		{"measured contrast", gray, pageOptions{}, pageOptions{contrast: &measured}},
+		{"color", rgba, pageOptions{color: true}, pageOptions{color: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			whole, _ := New(p).process(page{Image: tt.img}, tt.whole)
+			out, timings := New(banded).process(page{Image: tt.img}, tt.opts)
+			if len(out) != 4 || len(timings) != 4 {
+				t.Fatalf("process() returned %d pages, want 4", len(out))
+			}
+			var heights []int
+			var pix []uint8
+			for _, img := range out {
+				heights = append(heights, img.Bounds().Dy())
+				pix = append(pix, pagePixels(img)...)
+			}
+			// Only the first and last bands hold the page's top and bottom margins.
+			if diff := cmp.Diff([]int{30, 25, 25, 30}, heights); diff != "" {
+				t.Errorf("process() heights mismatch (-want +got):\n%s", diff)
+			}
+			// Bands are scaled along with the rows around them, so they match the whole page
+			// where they meet, and decorations are placed on the whole page.
+			if !imgtest.WithinDelta(pagePixels(whole[0]), pix, 1) {
+				t.Errorf("process() bands differ from the whole page:\n%v\n%v", pagePixels(whole[0]), pix)
+			}
+		})
+	}
+}
+
+// pagePixels returns the pixels of a converted page, row by row.
+func pagePixels(img image.Image) []uint8 {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba.Pix
+	}
+	return pixels(imgtest.MustBeGray(img))
+}
+
+func TestProcessKeepsPage(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 20, 20))
+	tests := []struct {
+		name string
+		img  image.Image
+	}{
+		{"gray", img},
+		{"ycbcr", image.NewYCbCr(img.Rect, image.YCbCrSubsampleRatio420)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(Params{Width: 10, Height: 10, Gamma: 1})
+			c.process(page{Image: tt.img}, pageOptions{})
+			// The page image wasn't taken from the pool, so it must not be put into it.
+			if got := c.Stats().Pool.Puts; got != 0 {
+				t.Errorf("process() put %d images into the pool, want 0", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package mangaconv
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Target is a single input converted by ConvertAll. Out holds an output path for each of the
+// batch's Converters, in the same order.
+//
+// Targets with a higher Priority are started before, and have their pages converted before, targets
+// with a lower one, such as to let a file someone is waiting for jump ahead of a bulk conversion.
+// Targets of equal priority are converted in order.
+type Target struct {
+	In       string
+	Out      []string
+	Priority int
+}
+
+// Result is the outcome of converting a single Target. Outputs lists the paths of all outputs
+// written, which differ from the Target's Out with SplitChapters and lack outputs removed after
+// failing.
+type Result struct {
+	Target  Target
+	Outputs []string
+	Err     error
+}
+
+// BatchOptions adjust how ConvertAll converts targets.
+//
+// Converters convert each target, one for each of its output paths.
+// Jobs is the number of files converted at once, with 0 selecting a default of 2 and negative values
+// one file per CPU. Pages of all files share each Converter's workers, with pages of earlier files
+// converted first, so more jobs don't add parallelism but keep all cores busy at file boundaries.
+// Many small files, such as single chapters, need more jobs to do so, at the cost of memory for
+// PageBuffer pages of each file. If any Converter has LowMemory enabled, one file is converted at a
+// time regardless.
+// MaxInputSize fails targets whose input file, or all files of an input directory, is larger than
+// MaxInputSize bytes with ErrInputTooLarge, before any output is created. 0 disables the limit.
+// Progress, if not nil, is called once for each finished target. Calls are never concurrent.
+// SkipSpaceCheck disables checking for enough free disk space before each target is converted.
+// Timings, if not nil, is called with the stage timings of each page written to a target's i-th
+// output, as described by Output.Timings. Calls for different outputs and targets may be concurrent.
+// Written, if not nil, is called with each page written to a target's output file out, which is the
+// chapter's file with SplitChapters, as described by Output.Written. Calls for different outputs and
+// targets may be concurrent.
+type BatchOptions struct {
+	Converters     []*Converter
+	Jobs           int
+	MaxInputSize   int64
+	Progress       func(Result)
+	SkipSpaceCheck bool
+	Timings        func(t Target, i int, pt PageTimings)
+	Written        func(t Target, out string, wp WrittenPage)
+}
+
+// ConvertAll converts all targets, returning a Result for each of them in the same order. A failed
+// target doesn't stop the conversion of other targets. Once ctx is canceled, targets which haven't
+// finished yet fail with the context's error.
+func ConvertAll(ctx context.Context, targets []Target, opts BatchOptions) []Result {
+	jobs := opts.Jobs
+	switch {
+	case jobs < 0:
+		jobs = runtime.NumCPU()
+	case jobs == 0:
+		jobs = 2
+	}
+	for _, c := range opts.Converters {
+		if c.params.LowMemory {
+			jobs = 1
+		}
+	}
+
+	order := make([]int, len(targets))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return targets[order[i]].Priority > targets[order[j]].Priority
+	})
+
+	results := make([]Result, len(targets))
+	ranks := make(chan int)
+	go func() {
+		defer close(ranks)
+		for rank := range order {
+			ranks <- rank
+		}
+	}()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(jobs)
+	for j := 0; j < jobs; j++ {
+		go func() {
+			defer wg.Done()
+			for rank := range ranks {
+				i := order[rank]
+				r := Result{Target: targets[i], Err: ctx.Err()}
+				if r.Err == nil {
+					// Targets started earlier have precedence, so that they finish in order.
+					r.Outputs, r.Err = convertTarget(withPriority(ctx, rank), targets[i], opts)
+				}
+				results[i] = r
+				if opts.Progress != nil {
+					mu.Lock()
+					opts.Progress(r)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// convertTarget checks for free space, creates all output files of t and converts its input into
+// them, returning the paths of the outputs written. Incomplete outputs are removed according to
+// each Converter's ErrorPolicy.
+func convertTarget(ctx context.Context, t Target, opts BatchOptions) ([]string, error) {
+	converters := opts.Converters
+	if len(t.Out) != len(converters) {
+		return nil, fmt.Errorf("got %d output paths for %d converters", len(t.Out), len(converters))
+	}
+	if opts.MaxInputSize > 0 {
+		if err := checkInputSize(t.In, opts.MaxInputSize); err != nil {
+			return nil, err
+		}
+	}
+	if !opts.SkipSpaceCheck {
+		if err := checkSpace(t.In, t.Out); err != nil {
+			return nil, err
+		}
+	}
+	outputs := make([]Output, 0, len(converters))
+	// Chapter outputs of several converters are created concurrently.
+	var mu sync.Mutex
+	var files []*outputFile
+	closeAll := func(err error) ([]string, error) {
+		var written []string
+		for _, f := range files {
+			kept, closeErr := f.close(err)
+			if kept {
+				written = append(written, f.path)
+			}
+			if err == nil {
+				err = closeErr
+			}
+		}
+		return written, err
+	}
+	for i, c := range converters {
+		out := t.Out[i]
+		var timings func(PageTimings)
+		if opts.Timings != nil {
+			i := i
+			timings = func(pt PageTimings) { opts.Timings(t, i, pt) }
+		}
+		split := c.params.Chapters && c.params.SplitChapters
+		var written func(WrittenPage)
+		if opts.Written != nil {
+			written = func(wp WrittenPage) {
+				if split {
+					opts.Written(t, chapterPath(out, wp.Chapter), wp)
+					return
+				}
+				opts.Written(t, out, wp)
+			}
+		}
+		if split {
+			c := c
+			outputs = append(outputs, Output{Converter: c, Chapters: func(chapter string) (io.Writer, error) {
+				f, err := c.createOutput(chapterPath(out, chapter))
+				if err != nil {
+					return nil, err
+				}
+				mu.Lock()
+				files = append(files, f)
+				mu.Unlock()
+				return f, nil
+			}, Timings: timings, Written: written})
+			continue
+		}
+		f, err := c.createOutput(out)
+		if err != nil {
+			return closeAll(err)
+		}
+		files = append(files, f)
+		outputs = append(outputs, Output{Converter: c, Writer: f, Timings: timings, Written: written})
+	}
+	return closeAll(convertMulti(ctx, t.In, outputs, nil))
+}
+
+// chapterPath returns the output path of a chapter of an input converted to out with
+// SplitChapters, such as "out - vol1 - c001.cbz" for chapter "vol1/c001".
+func chapterPath(out, chapter string) string {
+	if chapter == "" {
+		return out
+	}
+	ext := filepath.Ext(out)
+	if kepub := FormatKEPUB.Ext(); strings.HasSuffix(strings.ToLower(out), kepub) {
+		ext = out[len(out)-len(kepub):]
+	}
+	name := SafeName(strings.ReplaceAll(chapter, "/", " - "), "_")
+	return strings.TrimSuffix(out, ext) + " - " + name + ext
+}
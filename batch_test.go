@@ -0,0 +1,77 @@
+package mangaconv_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/naisuuuu/mangaconv"
+)
+
+func TestConvertAll(t *testing.T) {
+	dir := t.TempDir()
+	targets := []mangaconv.Target{
+		{In: "testdata/wikipe-tan.zip", Out: []string{filepath.Join(dir, "a.cbz"), filepath.Join(dir, "b.cbz")}},
+		{In: "testdata/missing.zip", Out: []string{filepath.Join(dir, "c.cbz"), filepath.Join(dir, "d.cbz")}},
+		{In: "testdata/wikipe-tan.zip", Out: []string{filepath.Join(dir, "e.cbz")}},
+	}
+	p := mangaconv.Params{AutoContrast: true, Cutoff: 1, Gamma: 1, Height: 50, Width: 50}
+	progress := 0
+	results := mangaconv.ConvertAll(context.Background(), targets, mangaconv.BatchOptions{
+		Converters: []*mangaconv.Converter{mangaconv.New(p), mangaconv.New(p)},
+		Progress:   func(mangaconv.Result) { progress++ },
+	})
+
+	if len(results) != len(targets) || progress != len(targets) {
+		t.Fatalf("got %d results and %d progress calls, want %d", len(results), progress, len(targets))
+	}
+	for i, wantErr := range []bool{false, true, true} {
+		if results[i].Target.In != targets[i].In {
+			t.Errorf("result %d: got target %s, want %s", i, results[i].Target.In, targets[i].In)
+		}
+		if (results[i].Err != nil) != wantErr {
+			t.Errorf("result %d: got error %v, want error %t", i, results[i].Err, wantErr)
+		}
+	}
+	for _, name := range []string{"a.cbz", "b.cbz"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("output %s: %v", name, err)
+		}
+	}
+}
+
+func TestConvertAllPriority(t *testing.T) {
+	dir := t.TempDir()
+	var targets []mangaconv.Target
+	for i, prio := range []int{0, 5, 1, 5} {
+		out := filepath.Join(dir, fmt.Sprintf("%d.cbz", i))
+		targets = append(targets, mangaconv.Target{In: "testdata/wikipe-tan.zip", Out: []string{out}, Priority: prio})
+	}
+	var got []string
+	mangaconv.ConvertAll(context.Background(), targets, mangaconv.BatchOptions{
+		Converters: []*mangaconv.Converter{mangaconv.New(mangaconv.Params{Height: 50, Width: 50})},
+		Jobs:       1,
+		Progress:   func(r mangaconv.Result) { got = append(got, filepath.Base(r.Target.Out[0])) },
+	})
+	if diff := cmp.Diff([]string{"1.cbz", "3.cbz", "2.cbz", "0.cbz"}, got); diff != "" {
+		t.Errorf("conversion order mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestConvertAllCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dir := t.TempDir()
+	results := mangaconv.ConvertAll(ctx, []mangaconv.Target{
+		{In: "testdata/wikipe-tan.zip", Out: []string{filepath.Join(dir, "a.cbz")}},
+	}, mangaconv.BatchOptions{
+		Converters: []*mangaconv.Converter{mangaconv.New(mangaconv.Params{Height: 50, Width: 50})},
+	})
+	if results[0].Err != context.Canceled {
+		t.Errorf("got error %v, want %v", results[0].Err, context.Canceled)
+	}
+}
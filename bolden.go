@@ -0,0 +1,42 @@
+package mangaconv
+
+import (
+	"image"
+
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+// boldenShape is the shape dark strokes are grown by. A 3x3 square grows them by a pixel in every
+// direction, which is the most that doesn't fill in screentones at e-reader resolutions.
+var boldenShape = imgutil.Square(1)
+
+// bolden thickens dark strokes of img by blending it with its erosion. strength is the weight of
+// the eroded image, in the range (0, 1]. Pixels within protect are left as is. The temporary image
+// is taken from and returned to the pool.
+func (c *Converter) bolden(img *image.Gray, strength float64, protect []image.Rectangle) {
+	if strength > 1 {
+		strength = 1
+	}
+	eroded := c.pool.Get(img.Rect.Dx(), img.Rect.Dy())
+	defer c.pool.Put(eroded)
+	for y := 0; y < img.Rect.Dy(); y++ {
+		copy(eroded.Pix[y*eroded.Stride:(y+1)*eroded.Stride], img.Pix[y*img.Stride:])
+	}
+	imgutil.Erode(eroded, boldenShape)
+	// Blending with the original pixels is a no-op.
+	for _, r := range protect {
+		r = r.Intersect(img.Rect)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			copy(eroded.Pix[eroded.PixOffset(r.Min.X, y):eroded.PixOffset(r.Max.X, y)], img.Pix[img.PixOffset(r.Min.X, y):])
+		}
+	}
+
+	w := uint32(strength*0xff + 0.5)
+	for y := 0; y < img.Rect.Dy(); y++ {
+		row := img.Pix[y*img.Stride : y*img.Stride+img.Rect.Dx()]
+		er := eroded.Pix[y*eroded.Stride:]
+		for x, v := range row {
+			row[x] = uint8((uint32(v)*(0xff-w) + uint32(er[x])*w + 0x7f) / 0xff)
+		}
+	}
+}
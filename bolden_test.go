@@ -0,0 +1,37 @@
+package mangaconv
+
+import (
+	"image"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBolden(t *testing.T) {
+	tests := []struct {
+		name     string
+		strength float64
+		protect  []image.Rectangle
+		want     []uint8
+	}{
+		{"full", 1, nil, []uint8{0xff, 0x00, 0x00, 0x00, 0xff}},
+		{"half", 0.5, nil, []uint8{0xff, 0x7f, 0x00, 0x7f, 0xff}},
+		{"clamped", 2, nil, []uint8{0xff, 0x00, 0x00, 0x00, 0xff}},
+		{"protected", 1, []image.Rectangle{image.Rect(0, 0, 2, 3)}, []uint8{0xff, 0xff, 0x00, 0x00, 0xff}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A one pixel wide vertical line.
+			img := image.NewGray(image.Rect(0, 0, 5, 3))
+			for y := 0; y < 3; y++ {
+				copy(img.Pix[y*img.Stride:], []uint8{0xff, 0xff, 0x00, 0xff, 0xff})
+			}
+			New(Params{}).bolden(img, tt.strength, tt.protect)
+			for y := 0; y < 3; y++ {
+				if diff := cmp.Diff(tt.want, img.Pix[y*img.Stride:y*img.Stride+5]); diff != "" {
+					t.Errorf("row %d mismatch (-want +got):\n%s", y, diff)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+//go:build js && wasm
+// +build js,wasm
+
+// Command mangaconv-wasm exposes mangaconv to JavaScript, so that a static web page can convert
+// archives fully client-side. Build it with `make wasm` and load it with Go's wasm_exec.js, as
+// shown in index.html.
+//
+// Once started, it defines a global mangaconv object with a single function:
+//
+//	mangaconv.convert(data, params, name) -> Promise<Uint8Array>
+//
+// data is a Uint8Array holding a zip or cbz archive, and the promise resolves to the converted cbz
+// archive. params is an optional object overriding fields of mangaconv.Params by name, such as
+// {Width: 1072, Height: 1448}, on top of mangaconv.DefaultParams. Enumerations are numbers, as in
+// the Go package. name is the optional file name of the archive, which metadata is parsed from.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"syscall/js"
+
+	"github.com/naisuuuu/mangaconv"
+)
+
+func main() {
+	js.Global().Set("mangaconv", map[string]interface{}{
+		"convert": js.FuncOf(convert),
+	})
+	// Keep running, so that the exported functions can be called.
+	select {}
+}
+
+// convert implements mangaconv.convert. Conversion blocks, so it runs on its own goroutine, leaving
+// the event loop to the page.
+func convert(this js.Value, args []js.Value) interface{} {
+	data, params, name := arg(args, 0), arg(args, 1), arg(args, 2)
+	return js.Global().Get("Promise").New(js.FuncOf(func(this js.Value, cb []js.Value) interface{} {
+		resolve, reject := cb[0], cb[1]
+		go func() {
+			out, err := run(data, params, name)
+			if err != nil {
+				reject.Invoke(js.Global().Get("Error").New(err.Error()))
+				return
+			}
+			arr := js.Global().Get("Uint8Array").New(len(out))
+			js.CopyBytesToJS(arr, out)
+			resolve.Invoke(arr)
+		}()
+		return nil
+	}))
+}
+
+// arg returns the i-th argument, or undefined if it wasn't passed.
+func arg(args []js.Value, i int) js.Value {
+	if i < len(args) {
+		return args[i]
+	}
+	return js.Undefined()
+}
+
+// run converts the archive in data with params.
+func run(data, params, name js.Value) ([]byte, error) {
+	if data.Type() != js.TypeObject || !data.InstanceOf(js.Global().Get("Uint8Array")) {
+		return nil, errors.New("data must be a Uint8Array")
+	}
+	in := make([]byte, data.Get("length").Int())
+	js.CopyBytesToGo(in, data)
+
+	p := mangaconv.DefaultParams()
+	if params.Type() == js.TypeObject {
+		s := js.Global().Get("JSON").Call("stringify", params).String()
+		if err := json.Unmarshal([]byte(s), &p); err != nil {
+			return nil, fmt.Errorf("invalid params: %w", err)
+		}
+	}
+	fname := "input.cbz"
+	if name.Type() == js.TypeString {
+		fname = name.String()
+	}
+
+	var out bytes.Buffer
+	if err := mangaconv.New(p).ConvertZipReader(fname, bytes.NewReader(in), int64(len(in)), &out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// authHandler requires requests to h to authenticate with basic auth as user and password, or with
+// token as a bearer token. Without either, all requests are accepted.
+type authHandler struct {
+	h        http.Handler
+	user     string
+	password string
+	token    string
+}
+
+func (a *authHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if a.authorized(r) {
+		a.h.ServeHTTP(w, r)
+		return
+	}
+	if a.user != "" {
+		w.Header().Set("WWW-Authenticate", `Basic realm="mangaconv", charset="UTF-8"`)
+	} else {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="mangaconv"`)
+	}
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+}
+
+// authorized reports whether r carries valid credentials.
+func (a *authHandler) authorized(r *http.Request) bool {
+	if a.user == "" && a.token == "" {
+		return true
+	}
+	if user, password, ok := r.BasicAuth(); ok && a.user != "" {
+		// Both are compared, so that the time taken doesn't tell which one is wrong.
+		userOK := secureEqual(user, a.user)
+		return secureEqual(password, a.password) && userOK
+	}
+	auth := r.Header.Get("Authorization")
+	if token := strings.TrimPrefix(auth, "Bearer "); a.token != "" && token != auth {
+		return secureEqual(token, a.token)
+	}
+	return false
+}
+
+// secureEqual reports whether a and b are equal in time independent of their contents. Both are
+// hashed first, so that neither does their length leak.
+func secureEqual(a, b string) bool {
+	ha, hb := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
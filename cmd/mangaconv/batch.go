@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/naisuuuu/mangaconv"
+)
+
+// runBatch implements the batch command.
+func runBatch(args []string) error {
+	fs := newFlagSet("batch")
+	opts := newOptions(fs)
+	in := fs.String("in", "/in", "Directory scanned for inputs, as with the scan command.")
+	logFormat := fs.String("log-format", "json", "Log format. One of: json, text.")
+	h := newHooks(fs)
+	jobs := fs.Int("jobs", 0, "Number of inputs converted at once. 0 converts 2, -1 one per CPU.")
+	maxSize := fs.Int("max-input-size", 0, "Skip inputs larger than this many MiB. 0 disables the limit.")
+	qa := newQASampler(fs)
+	sched := fs.String("schedule", "", `Cron expression, e.g. "0 3 * * *" or "@daily", on which inputs are converted again.
+Without it, batch exits after converting once. Runs never overlap: times passing while a run is
+still going are skipped.`)
+	spaceCheck := fs.Bool("space-check", true, "Check for enough free disk space before converting each input.")
+	statePath := fs.String("state", "", `File recording converted inputs, such as /out/.mangaconv-state.json.
+Inputs whose contents and output flags didn't change since they were last converted, and whose
+outputs still exist, are skipped. List recorded inputs with the status command.`)
+	fs.Set("outdir", "/out")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	log, ok := loggers[*logFormat]
+	if !ok {
+		return fmt.Errorf("%w for log-format: %s", errInvalidValue, *logFormat)
+	}
+
+	var s *schedule
+	if *sched != "" {
+		var err error
+		if s, err = parseSchedule(*sched); err != nil {
+			return fmt.Errorf("%w for schedule: %v", errInvalidValue, err)
+		}
+	}
+	p, err := opts.profile()
+	if err != nil {
+		return err
+	}
+
+	// Stopping the container cancels the current run and waiting for the next one.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	params := paramsHash(fs)
+	run := func() error {
+		var st *state
+		if *statePath != "" {
+			var err error
+			if st, err = loadState(*statePath); err != nil {
+				return err
+			}
+		}
+		opts := mangaconv.BatchOptions{
+			Jobs:           *jobs,
+			MaxInputSize:   int64(*maxSize) << 20,
+			SkipSpaceCheck: !*spaceCheck,
+		}
+		if !qa.enabled() {
+			return runBatchOnce(ctx, p, *in, log, h, st, params, opts)
+		}
+		opts.Written = qa.add
+		start := time.Now()
+		err := runBatchOnce(ctx, p, *in, log, h, st, params, opts)
+		dir, n, qaErr := qa.export(os.Stderr, start)
+		if qaErr != nil {
+			log(mangaconv.Result{Target: mangaconv.Target{In: *in}, Err: qaErr})
+		} else {
+			fmt.Fprintf(os.Stderr, "Copied %d pages for review to %s\n", n, dir)
+		}
+		return err
+	}
+	if s == nil {
+		return run()
+	}
+	// The next run is only scheduled once the previous one is done.
+	for {
+		timer := time.NewTimer(time.Until(s.next(time.Now())))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		}
+		if err := run(); err != nil {
+			log(mangaconv.Result{Target: mangaconv.Target{In: *in}, Err: err})
+		}
+	}
+}
+
+// runBatchOnce converts all inputs found in dir with profile p and opts until ctx is canceled,
+// logging each result and running hooks. If st is not nil, inputs it records as converted with the
+// same params are skipped, and it's updated with each result.
+func runBatchOnce(ctx context.Context, p *profile, dir string, log func(mangaconv.Result), h *hooks, st *state,
+	params string, opts mangaconv.BatchOptions) error {
+	var targets []mangaconv.Target
+	entries := make(map[string]*stateEntry)
+	err := findInputs(dir, func(path string) {
+		if st != nil {
+			e, err := st.current(path, params)
+			if err != nil {
+				log(mangaconv.Result{Target: mangaconv.Target{In: path}, Err: err})
+				return
+			}
+			if st.upToDate(path, e) {
+				return
+			}
+			entries[path] = e
+		}
+		t, err := newTarget([]*profile{p}, path)
+		if err != nil {
+			log(mangaconv.Result{Target: mangaconv.Target{In: path}, Err: err})
+			return
+		}
+		targets = append(targets, t)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Hooks log their errors while inputs are still being converted.
+	var logMu sync.Mutex
+	logSync := func(r mangaconv.Result) {
+		logMu.Lock()
+		defer logMu.Unlock()
+		log(r)
+	}
+	runHooks, waitHooks := h.start(len(targets), func(r mangaconv.Result, err error) {
+		logSync(mangaconv.Result{Target: r.Target, Err: err})
+	})
+	failed := 0
+	opts.Converters = []*mangaconv.Converter{p.converter}
+	opts.Progress = func(r mangaconv.Result) {
+		var partial *mangaconv.PartialError
+		if r.Err != nil && !errors.As(r.Err, &partial) {
+			failed++
+		}
+		logSync(r)
+		if st != nil {
+			if err := st.record(r, entries[r.Target.In]); err != nil {
+				logSync(mangaconv.Result{Target: r.Target, Err: err})
+			}
+		}
+		runHooks(r)
+	}
+	mangaconv.ConvertAll(ctx, targets, opts)
+	waitHooks()
+	if st != nil {
+		if err := st.save(); err != nil {
+			log(mangaconv.Result{Err: err})
+		}
+	}
+	if err := h.notify(); err != nil {
+		log(mangaconv.Result{Err: err})
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to convert %d of %d inputs", failed, len(targets))
+	}
+	return nil
+}
+
+// logEntry is a single line of JSON logs.
+type logEntry struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Msg     string    `json:"msg"`
+	Input   string    `json:"input,omitempty"`
+	Outputs []string  `json:"outputs,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Skipped int       `json:"skipped,omitempty"`
+}
+
+var loggers = map[string]func(mangaconv.Result){
+	"json": logJSON,
+	"text": logText,
+}
+
+// logJSON logs a result as a line of JSON to stdout.
+func logJSON(r mangaconv.Result) {
+	e := logEntry{
+		Time:    time.Now().UTC(),
+		Level:   "info",
+		Msg:     "converted",
+		Input:   r.Target.In,
+		Outputs: r.Outputs,
+	}
+	var partial *mangaconv.PartialError
+	switch {
+	case errors.As(r.Err, &partial):
+		e.Level, e.Msg, e.Error, e.Skipped = "warn", "converted with errors", r.Err.Error(), len(partial.Pages)
+	case r.Err != nil:
+		e.Level, e.Msg, e.Error, e.Outputs = "error", "failed to convert", r.Err.Error(), nil
+	}
+	json.NewEncoder(os.Stdout).Encode(e)
+}
+
+// logText logs a result in the same format as the convert command.
+func logText(r mangaconv.Result) {
+	var partial *mangaconv.PartialError
+	switch {
+	case errors.As(r.Err, &partial):
+		fmt.Println("Converted", filepath.Base(r.Target.In), "with errors:")
+		for _, p := range partial.Pages {
+			fmt.Println("  Skipped", p)
+		}
+	case r.Err != nil:
+		fmt.Println("Failed to convert", filepath.Base(r.Target.In), r.Err)
+	default:
+		fmt.Println("Converted", filepath.Base(r.Target.In))
+	}
+}
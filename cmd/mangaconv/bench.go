@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"text/tabwriter"
+	"time"
+
+	"github.com/naisuuuu/mangaconv"
+)
+
+// benchConfigs returns the configurations benchmarked when none are given, as extra output
+// specifications applied on top of the other flags.
+func benchConfigs() []string {
+	configs := []string{
+		"scale-workers=1,page-buffer=0",
+		"scale-workers=1,page-buffer=-1",
+	}
+	if cpus := runtime.NumCPU(); cpus > 1 {
+		configs = append(configs,
+			fmt.Sprintf("scale-workers=%d,page-buffer=0", cpus),
+			fmt.Sprintf("scale-workers=%d,page-buffer=-1", cpus))
+	}
+	return append(configs, "scale-workers=1,page-buffer=-1,quantize=16")
+}
+
+// runBench implements the bench command.
+func runBench(args []string) error {
+	fs := newFlagSet("bench")
+	newOptions(fs)
+	var configs extraOutputs
+	fs.Var(&configs, "config", `Configuration to benchmark, as comma separated flag=value overrides
+applied on top of the other flags, e.g. "scale-workers=4,page-buffer=8". Can be repeated.
+(default several worker and buffer configurations)`)
+	runs := fs.Int("runs", 3, "Number of times each configuration is run. The fastest run is reported.")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("bench needs exactly one input")
+	}
+	if *runs < 1 {
+		return fmt.Errorf("%w for runs: %d", errInvalidValue, *runs)
+	}
+	if len(configs) == 0 {
+		configs = benchConfigs()
+	}
+	in := fs.Arg(0)
+
+	// Configurations are parsed like extra outputs, which don't know about bench flags.
+	var base []string
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name != "config" && f.Name != "runs" {
+			base = append(base, fmt.Sprintf("-%s=%s", f.Name, f.Value))
+		}
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CONFIG\tPAGES\tTIME\tPAGES/S\tSIZE")
+	for _, spec := range configs {
+		p, err := extraProfile(base, spec)
+		if err != nil {
+			return err
+		}
+		res, err := bench(p.converter, in, *runs)
+		if err != nil {
+			fmt.Fprintf(w, "%s\terror: %v\n", spec, err)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d\t%v\t%.1f\t%d\n", spec, res.pages, res.elapsed.Round(time.Millisecond),
+			float64(res.pages)/res.elapsed.Seconds(), res.size)
+	}
+	return w.Flush()
+}
+
+// benchResult is the fastest of several conversions of an input.
+type benchResult struct {
+	pages   int
+	elapsed time.Duration
+	size    int64
+}
+
+// bench converts in runs times with c, discarding the output.
+func bench(c *mangaconv.Converter, in string, runs int) (benchResult, error) {
+	info, err := c.Info(in)
+	if err != nil {
+		return benchResult{}, err
+	}
+	res := benchResult{pages: info.Pages}
+	for i := 0; i < runs; i++ {
+		var out countingWriter
+		start := time.Now()
+		if err := c.ConvertToWriter(in, &out); err != nil {
+			return benchResult{}, err
+		}
+		if elapsed := time.Since(start); i == 0 || elapsed < res.elapsed {
+			res.elapsed = elapsed
+		}
+		res.size = out.n
+	}
+	return res, nil
+}
+
+// countingWriter discards and counts bytes written to it.
+type countingWriter struct {
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	cw.n += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/naisuuuu/mangaconv"
+)
+
+// compareGap is the width of the gray bar separating both versions of a page.
+const compareGap = 8
+
+// compareLayouts encode both versions of a page.
+var compareLayouts = map[string]func(w io.Writer, a, b image.Image) error{
+	"side": func(w io.Writer, a, b image.Image) error {
+		return png.Encode(w, joinVersions(a, b, false))
+	},
+	"stacked": func(w io.Writer, a, b image.Image) error {
+		return png.Encode(w, joinVersions(a, b, true))
+	},
+	"flip": flipVersions,
+}
+
+// errCompared stops the conversion once both versions of the compared page are done.
+var errCompared = errors.New("page compared")
+
+// runCompare implements the compare command.
+func runCompare(args []string) error {
+	fs := newFlagSet("compare")
+	newOptions(fs)
+	a := fs.String("a", "", `First version, as comma separated flag=value overrides applied on top of the other flags,
+e.g. "gamma=0.75,bolden=0".`)
+	b := fs.String("b", "", "Second version, like -a.")
+	layout := fs.String("layout", "side", `How both versions are combined.
+One of: side (A left of B), stacked (A above B), both written as PNG images,
+or flip (animated GIF alternating between A and B every second).`)
+	pageNum := fs.Int("page", 1, `Number of the input page compared, starting from 1.
+Split spreads are compared by their first half.`)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("compare needs an input and an output")
+	}
+	encode, ok := compareLayouts[*layout]
+	if !ok {
+		return fmt.Errorf("%w for layout: %s", errInvalidValue, *layout)
+	}
+	if *pageNum < 1 {
+		return fmt.Errorf("%w for page: %d", errInvalidValue, *pageNum)
+	}
+	in, out := fs.Arg(0), fs.Arg(1)
+
+	// Versions are parsed like extra outputs, which don't know about compare flags.
+	var base []string
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "a", "b", "layout", "page":
+		default:
+			base = append(base, fmt.Sprintf("-%s=%s", f.Name, f.Value))
+		}
+	})
+
+	var mu sync.Mutex
+	versions := make([]image.Image, 2)
+	found := 0
+	var outputs []mangaconv.Output
+	for i, spec := range []string{*a, *b} {
+		p, err := extraProfile(base, spec)
+		if err != nil {
+			return err
+		}
+		i := i
+		outputs = append(outputs, mangaconv.Output{Converter: p.converter, Pages: func(pg mangaconv.Page) error {
+			if pg.Index != *pageNum-1 || pg.Sub != 0 {
+				return nil
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			versions[i] = pg.Image
+			if found++; found == len(versions) {
+				return errCompared
+			}
+			return nil
+		}})
+	}
+	if err := mangaconv.ConvertMulti(in, outputs...); err != nil && !errors.Is(err, errCompared) {
+		return err
+	}
+	if versions[0] == nil || versions[1] == nil {
+		return fmt.Errorf("%s has no page %d", in, *pageNum)
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := encode(f, versions[0], versions[1]); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot write %s: %w", out, err)
+	}
+	return f.Close()
+}
+
+// joinVersions places b right of a, or below it if vertical, separated by a gray bar.
+func joinVersions(a, b image.Image, vertical bool) image.Image {
+	ra, rb := a.Bounds(), b.Bounds()
+	var r image.Rectangle
+	var offset image.Point
+	if vertical {
+		offset = image.Pt(0, ra.Dy()+compareGap)
+		r = image.Rect(0, 0, ra.Dx(), offset.Y+rb.Dy())
+		if rb.Dx() > ra.Dx() {
+			r.Max.X = rb.Dx()
+		}
+	} else {
+		offset = image.Pt(ra.Dx()+compareGap, 0)
+		r = image.Rect(0, 0, offset.X+rb.Dx(), ra.Dy())
+		if rb.Dy() > ra.Dy() {
+			r.Max.Y = rb.Dy()
+		}
+	}
+
+	var dst draw.Image = image.NewRGBA(r)
+	if isGray(a) && isGray(b) {
+		dst = image.NewGray(r)
+	}
+	draw.Draw(dst, r, image.NewUniform(color.Gray{0x80}), image.Point{}, draw.Src)
+	draw.Draw(dst, ra.Sub(ra.Min), a, ra.Min, draw.Src)
+	draw.Draw(dst, rb.Sub(rb.Min).Add(offset), b, rb.Min, draw.Src)
+	return dst
+}
+
+// flipVersions writes an endlessly looping GIF showing a and b for a second each. Gray pages keep
+// all their shades, while color pages are dithered to the web palette.
+func flipVersions(w io.Writer, a, b image.Image) error {
+	r := a.Bounds().Sub(a.Bounds().Min).Union(b.Bounds().Sub(b.Bounds().Min))
+	var pal color.Palette = palette.Plan9
+	var drawer draw.Drawer = draw.FloydSteinberg
+	if isGray(a) && isGray(b) {
+		pal, drawer = make(color.Palette, 256), draw.Src
+		for i := range pal {
+			pal[i] = color.Gray{uint8(i)}
+		}
+	}
+
+	anim := &gif.GIF{}
+	for _, img := range []image.Image{a, b} {
+		frame := image.NewPaletted(r, pal)
+		draw.Draw(frame, r, image.White, image.Point{}, draw.Src)
+		drawer.Draw(frame, img.Bounds().Sub(img.Bounds().Min), img, img.Bounds().Min)
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 100)
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// isGray reports whether img is a grayscale image.
+func isGray(img image.Image) bool {
+	_, ok := img.(*image.Gray)
+	return ok
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/naisuuuu/mangaconv"
+)
+
+// convertFlags holds flags of the convert command which don't describe an output profile.
+type convertFlags struct {
+	extra      extraOutputs
+	hooks      *hooks
+	jobs       *int
+	maxSize    *int
+	qa         *qaSampler
+	spaceCheck *bool
+	stats      *bool
+	timings    *bool
+	version    *bool
+}
+
+// newConvertFlags registers convert command flags in fs.
+func newConvertFlags(fs *flag.FlagSet) *convertFlags {
+	f := &convertFlags{}
+	fs.Var(&f.extra, "extra-output", `Additional output profile, written in the same run.
+Comma separated list of flag=value overrides applied on top of the other flags,
+e.g. "outdir=tablet,width=2048,height=2732,gamma=1". Can be repeated.
+Each page is only read and decoded once for all outputs.`)
+	f.hooks = newHooks(fs)
+	f.jobs = fs.Int("jobs", 0, `Number of inputs converted at once. 0 converts 2, -1 one per CPU.
+Pages of earlier inputs are converted first, so more jobs only fill otherwise idle CPUs.
+Use -1 for many small inputs, such as single chapters.`)
+	f.maxSize = fs.Int("max-input-size", 0, "Skip inputs larger than this many MiB. 0 disables the limit.")
+	f.qa = newQASampler(fs)
+	f.spaceCheck = fs.Bool("space-check", true, `Check for enough free disk space before converting each input.
+Disable if the estimate, which assumes outputs as large as their input, is too pessimistic.`)
+	f.stats = fs.Bool("stats", false, `Print memory reuse statistics of each output profile once done.
+Useful for tuning memory usage on low end hardware.`)
+	f.timings = fs.Bool("timings", false, `Print how long decoding, grayscale conversion, scaling, contrast and encoding
+took once done, listing pages much slower than others. Useful to find out why an input converts
+slowly.`)
+	f.version = fs.Bool("version", false, "Print version and build information, then exit.")
+	return f
+}
+
+// runConvert implements the convert command.
+func runConvert(args []string) error {
+	fs := newFlagSet("convert")
+	opts := newOptions(fs)
+	flags := newConvertFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	if *flags.version {
+		fmt.Print(versionInfo())
+		return nil
+	}
+
+	base, err := opts.profile()
+	if err != nil {
+		return err
+	}
+	profiles := []*profile{base}
+	flagArgs := args[:len(args)-fs.NArg()]
+	for _, spec := range flags.extra {
+		p, err := extraProfile(flagArgs, spec)
+		if err != nil {
+			return err
+		}
+		profiles = append(profiles, p)
+	}
+
+	converters := make([]*mangaconv.Converter, len(profiles))
+	for i, p := range profiles {
+		converters[i] = p.converter
+	}
+	var targets []mangaconv.Target
+	for _, in := range fs.Args() {
+		t, err := newTarget(profiles, in)
+		if err != nil {
+			fmt.Println("Failed to name output for", filepath.Base(in), err)
+			continue
+		}
+		targets = append(targets, t)
+	}
+
+	runHooks, waitHooks := flags.hooks.start(len(targets), func(_ mangaconv.Result, err error) {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	})
+	batchOpts := mangaconv.BatchOptions{
+		Converters:     converters,
+		Jobs:           *flags.jobs,
+		MaxInputSize:   int64(*flags.maxSize) << 20,
+		SkipSpaceCheck: !*flags.spaceCheck,
+		Progress: func(r mangaconv.Result) {
+			logText(r)
+			runHooks(r)
+		},
+	}
+	report := newTimingReport()
+	if *flags.timings {
+		batchOpts.Timings = report.add
+	}
+	if flags.qa.enabled() {
+		batchOpts.Written = flags.qa.add
+	}
+	start := time.Now()
+	mangaconv.ConvertAll(context.Background(), targets, batchOpts)
+	waitHooks()
+	if err := flags.hooks.notify(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+	if *flags.stats {
+		for i, c := range converters {
+			printStats(os.Stderr, i, c.Stats())
+		}
+	}
+	if *flags.timings {
+		report.print(os.Stderr, len(converters))
+	}
+	if flags.qa.enabled() {
+		dir, n, err := flags.qa.export(os.Stderr, start)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Copied %d pages for review to %s\n", n, dir)
+	}
+	return nil
+}
+
+// printStats prints statistics of the converter of the i-th output profile to w.
+func printStats(w io.Writer, i int, s mangaconv.Stats) {
+	fmt.Fprintf(w, "Profile %d:\n", i)
+	fmt.Fprintf(w, "  page pool: %d gets (%d reused), %d puts (%d dropped), %d bytes retained\n",
+		s.Pool.Hits+s.Pool.Misses, s.Pool.Hits, s.Pool.Puts, s.Pool.Drops, s.Pool.Retained)
+	fmt.Fprintf(w, "  scalers: %d cached, %d hits, %d misses, %d evictions\n",
+		s.Scaler.Entries, s.Scaler.Hits, s.Scaler.Misses, s.Scaler.Evictions)
+}
+
+// newTarget creates a conversion target for input path in, with an output path for each profile.
+func newTarget(profiles []*profile, in string) (mangaconv.Target, error) {
+	t := mangaconv.Target{In: in}
+	for _, p := range profiles {
+		out, err := p.output(in)
+		if err != nil {
+			return mangaconv.Target{}, err
+		}
+		t.Out = append(t.Out, out)
+	}
+	return t, nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/naisuuuu/mangaconv"
+)
+
+// hooks are run for each converted input, so that library servers like Komga or Kavita can be told
+// to rescan, or outputs can be moved elsewhere. They're run as soon as each input is converted, on
+// a goroutine of their own so that slow hooks don't hold up conversion, and followed by a
+// notification summarizing the run once all inputs are converted, such as to a chat channel.
+type hooks struct {
+	cmd          *string
+	url          *string
+	notifyURL    *string
+	notifyFormat func(runSummary) interface{}
+	summary      runSummary
+}
+
+// newHooks registers hook flags in fs.
+func newHooks(fs *flag.FlagSet) *hooks {
+	h := &hooks{
+		cmd: fs.String("hook-cmd", "", `Shell command run for each input as soon as it's converted.
+The paths of all outputs written, such as each chapter with -split-chapters, are passed as
+arguments ("$@"), except on Windows. The input and output paths are also passed in
+MANGACONV_HOOK_INPUT and MANGACONV_HOOK_OUTPUTS, with outputs separated by the OS path list
+separator.`),
+		url: fs.String("hook-url", "", `URL receiving a JSON POST request for each input as soon as it's converted.
+The body has "input" and "outputs" fields.`),
+	}
+	h.notifyURL = fs.String("notify-url", "", `URL receiving a JSON POST request once all inputs are converted or failed.
+Use with a Discord or Slack webhook to be notified of unattended runs.`)
+	h.notifyFormat = notifyFormats["generic"]
+	fs.Func("notify-format", `Body of -notify-url requests.
+One of: generic (default, counts and failed inputs as JSON), discord, slack.`, func(v string) error {
+		f, ok := notifyFormats[v]
+		if !ok {
+			return errInvalidValue
+		}
+		h.notifyFormat = f
+		return nil
+	})
+	return h
+}
+
+// hookPayload is the body of webhook requests.
+type hookPayload struct {
+	Input   string   `json:"input"`
+	Outputs []string `json:"outputs"`
+	Skipped int      `json:"skipped,omitempty"`
+}
+
+var webhookClient = &http.Client{Timeout: 30 * time.Second}
+
+// start starts running hooks for up to n results as they're passed to the returned add function,
+// in order, which is meant to be ConvertAll's Progress callback. Hooks run on a goroutine of their
+// own, as Progress holds up conversion until it returns. Errors running them are passed to fail.
+// The returned wait function waits for hooks of all added results to be run.
+func (h *hooks) start(n int, fail func(mangaconv.Result, error)) (add func(mangaconv.Result), wait func()) {
+	// Results are buffered, so that adding them never waits for hooks.
+	results := make(chan mangaconv.Result, n)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for r := range results {
+			if err := h.run(r); err != nil {
+				fail(r, err)
+			}
+		}
+	}()
+	add = func(r mangaconv.Result) {
+		results <- r
+	}
+	wait = func() {
+		close(results)
+		<-done
+	}
+	return add, wait
+}
+
+// run runs all hooks for a result and adds it to the run summary. Failed conversions don't run
+// hooks.
+func (h *hooks) run(r mangaconv.Result) error {
+	var partial *mangaconv.PartialError
+	h.summary.add(r)
+	if r.Err != nil && !errors.As(r.Err, &partial) {
+		return nil
+	}
+	if *h.cmd != "" {
+		if err := h.runCmd(r); err != nil {
+			return fmt.Errorf("hook-cmd failed for %s: %w", r.Target.In, err)
+		}
+	}
+	if *h.url != "" {
+		p := hookPayload{Input: r.Target.In, Outputs: r.Outputs}
+		if partial != nil {
+			p.Skipped = len(partial.Pages)
+		}
+		if err := h.post(*h.url, p); err != nil {
+			return fmt.Errorf("hook-url failed for %s: %w", r.Target.In, err)
+		}
+	}
+	return nil
+}
+
+func (h *hooks) runCmd(r mangaconv.Result) error {
+	var c *exec.Cmd
+	if runtime.GOOS == "windows" {
+		c = exec.Command("cmd", "/C", *h.cmd)
+	} else {
+		// The first argument after the command is $0.
+		c = exec.Command("sh", append([]string{"-c", *h.cmd, "sh"}, r.Outputs...)...)
+	}
+	c.Env = append(os.Environ(),
+		"MANGACONV_HOOK_INPUT="+r.Target.In,
+		"MANGACONV_HOOK_OUTPUTS="+strings.Join(r.Outputs, string(os.PathListSeparator)),
+	)
+	c.Stdout, c.Stderr = os.Stderr, os.Stderr
+	return c.Run()
+}
+
+// notify sends the run summary to -notify-url, if set, and starts a new one.
+func (h *hooks) notify() error {
+	s := h.summary
+	h.summary = runSummary{}
+	if *h.notifyURL == "" {
+		return nil
+	}
+	if err := h.post(*h.notifyURL, h.notifyFormat(s)); err != nil {
+		return fmt.Errorf("notify-url failed: %w", err)
+	}
+	return nil
+}
+
+// post sends v as JSON to url.
+func (h *hooks) post(url string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// runSummary counts the results of a run for notifications.
+type runSummary struct {
+	Inputs    int      `json:"inputs"`
+	Converted int      `json:"converted"`
+	Partial   int      `json:"partial"`
+	Failed    []string `json:"failed,omitempty"`
+}
+
+// add counts a single result. Inputs converted with skipped pages are both converted and partial.
+func (s *runSummary) add(r mangaconv.Result) {
+	s.Inputs++
+	var partial *mangaconv.PartialError
+	switch {
+	case r.Err == nil:
+		s.Converted++
+	case errors.As(r.Err, &partial):
+		s.Converted++
+		s.Partial++
+	default:
+		s.Failed = append(s.Failed, r.Target.In)
+	}
+}
+
+// String returns a human readable summary, such as for chat messages.
+func (s runSummary) String() string {
+	msg := fmt.Sprintf("mangaconv converted %d of %d inputs", s.Converted, s.Inputs)
+	if s.Partial > 0 {
+		msg += fmt.Sprintf(", %d with skipped pages", s.Partial)
+	}
+	if len(s.Failed) > 0 {
+		msg += ". Failed: " + strings.Join(s.Failed, ", ")
+	}
+	return msg
+}
+
+// notifyFormats create the body of notification requests for each -notify-format.
+var notifyFormats = map[string]func(runSummary) interface{}{
+	"generic": func(s runSummary) interface{} { return s },
+	"discord": func(s runSummary) interface{} { return map[string]string{"content": s.String()} },
+	"slack":   func(s runSummary) interface{} { return map[string]string{"text": s.String()} },
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+// runInfo implements the info command.
+func runInfo(args []string) error {
+	fs := newFlagSet("info")
+	opts := newOptions(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	p, err := opts.profile()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INPUT\tFORMAT\tPAGES\tSERIES\tVOLUME\tCHAPTER\tOUTPUT")
+	for _, in := range fs.Args() {
+		info, err := p.converter.Info(in)
+		if err != nil {
+			fmt.Fprintf(w, "%s\terror: %v\n", in, err)
+			continue
+		}
+		out, err := p.output(in)
+		if err != nil {
+			out = "error: " + err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n",
+			in, info.Format, info.Pages, info.Series, info.Volume, info.Chapter, out)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/naisuuuu/mangaconv"
+)
+
+// jobState is the state of a job in the queue of the serve command.
+type jobState string
+
+const (
+	jobPending jobState = "pending"
+	jobRunning jobState = "running"
+	jobDone    jobState = "done"
+	jobFailed  jobState = "failed"
+)
+
+// job is an uploaded input converted by the serve command. Jobs are stored in their own directory
+// of the queue directory, holding the input, the job itself as job.json and, unless -outdir is set,
+// the output. Otherwise, the output is written to a directory named after the job's ID in -outdir,
+// so that jobs converting inputs of the same name don't overwrite each other's output.
+type job struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Client   string    `json:"client"`
+	Priority int       `json:"priority"`
+	State    jobState  `json:"state"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error,omitempty"`
+	Skipped  int       `json:"skipped,omitempty"`
+	Output   string    `json:"output,omitempty"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+	// Retry is the earliest time a pending job which failed before is started again.
+	Retry *time.Time `json:"retry,omitempty"`
+
+	// done is closed once the job is done or failed for good.
+	done chan struct{}
+}
+
+// finished reports whether j is done or failed for good.
+func (j *job) finished() bool {
+	return j.State == jobDone || j.State == jobFailed
+}
+
+var (
+	errJobNotFound     = errors.New("job not found")
+	errQueueFull       = errors.New("too many queued jobs")
+	errClientQueueFull = errors.New("too many queued jobs of this client")
+)
+
+// queueLimits limit the jobs of a jobQueue. Zero values disable a limit.
+//
+// Queued limits the number of pending and running jobs, including uploads being added.
+// ClientQueued limits the number of pending and running jobs of a single client.
+// ClientJobs limits the number of jobs of a single client run at once, so that others' jobs are
+// started in between the jobs of a client who submitted many at once.
+type queueLimits struct {
+	Queued       int
+	ClientQueued int
+	ClientJobs   int
+}
+
+// jobQueue is the persistent job queue of the serve command. Pending jobs with a higher priority
+// are started first, and jobs of equal priority in the order they were added. Jobs which fail are
+// retried up to retries times, first after retryDelay, doubled for each further retry, unless their
+// input can't be converted at all.
+type jobQueue struct {
+	dir        string
+	retries    int
+	retryDelay time.Duration
+	limits     queueLimits
+	// log logs results of jobs and errors saving them.
+	log func(mangaconv.Result)
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	jobs map[string]*job
+	// uploads counts the uploads of each client which are being added.
+	uploads map[string]int
+}
+
+// openQueue opens the job queue stored in dir, creating it if needed. Jobs which were running when
+// the queue was last open are pending again. Results of jobs are logged with log.
+func openQueue(dir string, retries int, retryDelay time.Duration, limits queueLimits,
+	log func(mangaconv.Result)) (*jobQueue, error) {
+	if err := os.MkdirAll(mangaconv.LongPath(dir), 0755); err != nil {
+		return nil, fmt.Errorf("cannot create queue: %w", err)
+	}
+	q := &jobQueue{
+		dir:        dir,
+		retries:    retries,
+		retryDelay: retryDelay,
+		limits:     limits,
+		log:        log,
+		jobs:       make(map[string]*job),
+		uploads:    make(map[string]int),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read queue: %w", err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name(), "job.json"))
+		if errors.Is(err, os.ErrNotExist) {
+			// The job was removed, or never fully added.
+			os.RemoveAll(filepath.Join(dir, e.Name()))
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read job: %w", err)
+		}
+		j := &job{done: make(chan struct{})}
+		if err := json.Unmarshal(data, j); err != nil {
+			return nil, fmt.Errorf("cannot read job %s: %w", e.Name(), err)
+		}
+		if j.finished() {
+			close(j.done)
+		}
+		if j.State == jobRunning {
+			j.State = jobPending
+			if err := q.save(j); err != nil {
+				return nil, err
+			}
+		}
+		q.jobs[j.ID] = j
+		// The retry was scheduled by finish while the queue was last open, so next has to be woken
+		// up again once it's due.
+		if j.State == jobPending && j.Retry != nil {
+			if d := time.Until(*j.Retry); d > 0 {
+				time.AfterFunc(d, q.cond.Broadcast)
+			}
+		}
+	}
+	return q, nil
+}
+
+// add adds a pending job converting the input named name read from body, uploaded by client. It
+// fails with errQueueFull or errClientQueueFull before reading body if the queue's limits are
+// reached.
+func (q *jobQueue) add(name, client string, priority int, body io.Reader) (job, error) {
+	if err := q.admit(client); err != nil {
+		return job{}, err
+	}
+	defer func() {
+		q.mu.Lock()
+		q.uploads[client]--
+		if q.uploads[client] == 0 {
+			delete(q.uploads, client)
+		}
+		q.mu.Unlock()
+	}()
+
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return job{}, err
+	}
+	now := time.Now().UTC()
+	j := &job{
+		ID:       hex.EncodeToString(id[:]),
+		Client:   client,
+		Priority: priority,
+		State:    jobPending,
+		Created:  now,
+		Updated:  now,
+		done:     make(chan struct{}),
+	}
+	dir := filepath.Join(q.dir, j.ID)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return job{}, err
+	}
+	in, err := saveUpload(dir, name, body)
+	if err != nil {
+		os.RemoveAll(dir)
+		return job{}, err
+	}
+	j.Name = filepath.Base(in)
+	if err := q.save(j); err != nil {
+		os.RemoveAll(dir)
+		return job{}, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.jobs[j.ID] = j
+	q.cond.Broadcast()
+	return *j, nil
+}
+
+// admit counts an upload by client which is about to be added, unless the queue's limits are
+// reached.
+func (q *jobQueue) admit(client string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	queued, clientQueued := 0, q.uploads[client]
+	for _, n := range q.uploads {
+		queued += n
+	}
+	for _, j := range q.jobs {
+		if j.finished() {
+			continue
+		}
+		queued++
+		if j.Client == client {
+			clientQueued++
+		}
+	}
+	switch {
+	case q.limits.Queued > 0 && queued >= q.limits.Queued:
+		return errQueueFull
+	case q.limits.ClientQueued > 0 && clientQueued >= q.limits.ClientQueued:
+		return errClientQueueFull
+	}
+	q.uploads[client]++
+	return nil
+}
+
+// input returns the path of the input of job j.
+func (q *jobQueue) input(j *job) string {
+	return filepath.Join(q.dir, j.ID, j.Name)
+}
+
+// next waits for a pending job which is due, and whose client doesn't run ClientJobs jobs already,
+// and marks it as running.
+func (q *jobQueue) next() *job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		running := make(map[string]int)
+		for _, j := range q.jobs {
+			if j.State == jobRunning {
+				running[j.Client]++
+			}
+		}
+		var next *job
+		for _, j := range q.jobs {
+			if j.State != jobPending || (j.Retry != nil && time.Now().Before(*j.Retry)) {
+				continue
+			}
+			if q.limits.ClientJobs > 0 && running[j.Client] >= q.limits.ClientJobs {
+				continue
+			}
+			if next == nil || queuedBefore(j, next) {
+				next = j
+			}
+		}
+		if next != nil {
+			next.State, next.Attempts, next.Updated = jobRunning, next.Attempts+1, time.Now().UTC()
+			if err := q.save(next); err != nil {
+				q.log(mangaconv.Result{Target: mangaconv.Target{In: next.Name}, Err: err})
+			}
+			return next
+		}
+		q.cond.Wait()
+	}
+}
+
+// queuedBefore reports whether job a is started before job b.
+func queuedBefore(a, b *job) bool {
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	if !a.Created.Equal(b.Created) {
+		return a.Created.Before(b.Created)
+	}
+	return a.ID < b.ID
+}
+
+// permanentErrors are errors of jobs which fail the same way when retried, as their input can't be
+// converted.
+var permanentErrors = []error{
+	mangaconv.ErrUnsupportedFormat,
+	mangaconv.ErrCannotReadPath,
+	mangaconv.ErrCorruptEntry,
+	mangaconv.ErrImageTooLarge,
+	mangaconv.ErrInputTooLarge,
+	mangaconv.ErrSuspiciousInput,
+	zip.ErrFormat,
+	zip.ErrAlgorithm,
+	zip.ErrChecksum,
+	image.ErrFormat,
+}
+
+// retryable reports whether a job which failed with err may succeed when retried.
+func retryable(err error) bool {
+	for _, e := range permanentErrors {
+		if errors.Is(err, e) {
+			return false
+		}
+	}
+	return true
+}
+
+// finish records the result of running job j, retrying it later if it failed with an error which
+// may not happen again and it has retries left.
+func (q *jobQueue) finish(j *job, r mangaconv.Result) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j.Updated = time.Now().UTC()
+	j.Error, j.Skipped, j.Output, j.Retry = "", 0, "", nil
+	var partial *mangaconv.PartialError
+	switch {
+	case (r.Err == nil || errors.As(r.Err, &partial)) && len(r.Outputs) > 0:
+		j.State, j.Output = jobDone, r.Outputs[0]
+		if partial != nil {
+			j.Error, j.Skipped = r.Err.Error(), len(partial.Pages)
+		}
+	case j.Attempts <= q.retries && retryable(r.Err):
+		delay := q.retryDelay << (j.Attempts - 1)
+		retry := j.Updated.Add(delay)
+		j.State, j.Error, j.Retry = jobPending, r.Err.Error(), &retry
+		time.AfterFunc(delay, q.cond.Broadcast)
+	default:
+		j.State, j.Error = jobFailed, r.Err.Error()
+	}
+	if j.finished() {
+		close(j.done)
+	}
+	// Jobs of j's client may have waited for it to finish.
+	q.cond.Broadcast()
+	if err := q.save(j); err != nil {
+		q.log(mangaconv.Result{Target: mangaconv.Target{In: j.Name}, Err: err})
+	}
+}
+
+// save writes job j to its job.json, replacing the previous one at once so that a crash never
+// leaves a partly written job behind.
+func (q *jobQueue) save(j *job) error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(q.dir, j.ID, "job.json")
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("cannot save job: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("cannot save job: %w", err)
+	}
+	return nil
+}
+
+// get returns the job with the given id.
+func (q *jobQueue) get(id string) (job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return job{}, errJobNotFound
+	}
+	return *j, nil
+}
+
+// list returns all jobs: pending and running ones in the order they're started, followed by
+// finished ones, most recent first.
+func (q *jobQueue) list() []job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := make([]job, 0, len(q.jobs))
+	for _, j := range q.jobs {
+		jobs = append(jobs, *j)
+	}
+	sort.Slice(jobs, func(i, k int) bool {
+		a, b := &jobs[i], &jobs[k]
+		if a.finished() != b.finished() {
+			return !a.finished()
+		}
+		if a.finished() {
+			return a.Updated.After(b.Updated)
+		}
+		return queuedBefore(a, b)
+	})
+	return jobs
+}
+
+var errJobRunning = errors.New("job is running")
+
+// remove removes the job with the given id along with its directory. Outputs written to -outdir are
+// kept. Running jobs can't be removed.
+func (q *jobQueue) remove(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	switch {
+	case !ok:
+		return errJobNotFound
+	case j.State == jobRunning:
+		return errJobRunning
+	}
+	// job.json goes first, so that a job is never loaded again without its input.
+	if err := os.Remove(filepath.Join(q.dir, id, "job.json")); err != nil {
+		return err
+	}
+	delete(q.jobs, id)
+	if !j.finished() {
+		j.State = jobFailed
+		close(j.done)
+	}
+	return os.RemoveAll(filepath.Join(q.dir, id))
+}
+
+// target returns the target converting job j with profile p.
+func (q *jobQueue) target(j *job, p *profile) (mangaconv.Target, error) {
+	t, err := newTarget([]*profile{p}, q.input(j))
+	if err != nil || p.outdir == "" {
+		return t, err
+	}
+	dir := filepath.Join(p.outdir, j.ID)
+	if err := os.MkdirAll(mangaconv.LongPath(dir), 0755); err != nil {
+		return mangaconv.Target{}, fmt.Errorf("cannot create outdir: %w", err)
+	}
+	for i, out := range t.Out {
+		t.Out[i] = filepath.Join(dir, filepath.Base(out))
+	}
+	return t, nil
+}
+
+// work converts jobs with profile p forever.
+func (q *jobQueue) work(p *profile) {
+	for {
+		j := q.next()
+		t, err := q.target(j, p)
+		r := mangaconv.Result{Target: t, Err: err}
+		if err == nil {
+			r = mangaconv.ConvertAll(context.Background(), []mangaconv.Target{t}, mangaconv.BatchOptions{
+				Converters: []*mangaconv.Converter{p.converter},
+			})[0]
+		}
+		q.log(r)
+		q.finish(j, r)
+	}
+}
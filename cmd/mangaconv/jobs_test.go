@@ -0,0 +1,26 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/naisuuuu/mangaconv"
+)
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("connection reset"), true},
+		{mangaconv.ErrInsufficientSpace, true},
+		{fmt.Errorf("cannot read in.cbz: %w", mangaconv.ErrUnsupportedFormat), false},
+		{fmt.Errorf("page 3: %w", mangaconv.ErrCorruptEntry), false},
+	}
+	for _, tt := range tests {
+		if got := retryable(tt.err); got != tt.want {
+			t.Errorf("retryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
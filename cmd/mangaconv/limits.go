@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+var (
+	errRateLimited    = errors.New("too many uploads, try again later")
+	errUploadTooLarge = errors.New("upload too large")
+)
+
+// rateLimiter allows up to rate events per minute for each key, in bursts of up to rate events. A
+// rate of 0 allows all events.
+type rateLimiter struct {
+	rate float64
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// tokenBucket holds the tokens left for a key of a rateLimiter as of last.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// maxBuckets is the number of keys a rateLimiter tracks before forgetting those whose bucket is
+// full again, which behave like new keys.
+const maxBuckets = 1024
+
+func newRateLimiter(rate int) *rateLimiter {
+	return &rateLimiter{rate: float64(rate), buckets: make(map[string]*tokenBucket)}
+}
+
+// allow reports whether an event for key is allowed now. If not, it also returns how long to wait
+// until the next event is.
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	if l.rate == 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if len(l.buckets) >= maxBuckets {
+		for k, b := range l.buckets {
+			if l.refill(b, now) >= l.rate {
+				delete(l.buckets, k)
+			}
+		}
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.rate, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens, b.last = l.refill(b, now), now
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Minute))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// refill returns the tokens of b at now.
+func (l *rateLimiter) refill(b *tokenBucket, now time.Time) float64 {
+	return math.Min(l.rate, b.tokens+now.Sub(b.last).Minutes()*l.rate)
+}
+
+// limitedReader reads from r, failing with errUploadTooLarge once more than n bytes are read.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if lr.n < 0 {
+		return 0, errUploadTooLarge
+	}
+	// One more byte than allowed is read, to tell whether r holds more than n bytes.
+	if int64(len(p)) > lr.n+1 {
+		p = p[:lr.n+1]
+	}
+	n, err := lr.r.Read(p)
+	if lr.n -= int64(n); lr.n < 0 {
+		return 0, errUploadTooLarge
+	}
+	return n, err
+}
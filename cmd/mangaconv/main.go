@@ -1,93 +1,135 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
-	"sync"
-
-	"github.com/naisuuuu/mangaconv"
-)
-
-var (
-	version = "dev"
-	date    = "unknown"
 )
 
-func main() {
-	cutoff := flag.Float64("cutoff", 1, `Autocontrast cutoff.
-This value is the percentage of brightest and darkest pixels ignored when normalizing the histogram.
-Applying a cutoff nets a more perceivable contrast improvement.`)
-	deflate := flag.Bool("deflate", false, `Additionally compress the output cbz files.
-This is usually not worthwhile, as jpg files are already compressed`)
-	gamma := flag.Float64("gamma", 0.75, `Gamma correction value.
-Values < 1 darken the image, > 1 brighten it and 1 disables gamma correction.
-The default will look too dark on your computer screen, but much richer than before on e-ink.`)
-	height := flag.Int("height", 1920, "Maximum height of the image.")
-	width := flag.Int("width", 1920, "Maximum width of the image.")
-	outdir := flag.String("outdir", "", `Path to output directory.
-If provided directory does not exist, mangaconv will attempt to create it. (default input dir)`)
-	ver := flag.Bool("version", false, "Print version information.")
-
-	flag.Parse()
+// command is a mangaconv subcommand. run receives all arguments following the command name.
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(args []string) error
+}
 
-	if *ver {
-		fmt.Printf("mangaconv version %s, built at %s\n", version, date)
+// commands returns all subcommands. The first one is used when no command is named.
+func commands() []command {
+	return []command{
+		{"convert", "[flags] inputs...", "Convert manga files and folders. Default when no command is given.", runConvert},
+		{"info", "[flags] inputs...", "Print metadata and page counts of inputs without converting them.", runInfo},
+		{"scan", "[flags] dirs...", "List inputs found in directories, which can be passed to convert.", runScan},
+		{"batch", "[flags]",
+			"Convert all inputs in a directory. Meant for containers, configured with environment variables.", runBatch},
+		{"status", "[flags]",
+			"List inputs recorded in the state file of the batch command, with their last result.", runStatus},
+		{"serve", "[flags]", "Convert inputs uploaded over HTTP, such as from a phone.", runServe},
+		{"plugin", "[flags] input output",
+			"Convert a single input to an output path, printing a JSON result. Meant to be wrapped by plugins.", runPlugin},
+		{"presets", "export|import|list [flags] [file] [name]",
+			"Share output flags tuned for a device as a preset file, or import presets shared by others.", runPresets},
+		{"validate", "[flags] archives...",
+			"Check converted archives for unreadable pages, wrong sizes and missing metadata.", runValidate},
+		{"bench", "[flags] input",
+			"Convert an input with several configurations, printing throughput and output size of each.", runBench},
+		{"normalize", "[flags] input output",
+			"Re-encode progressive JPEGs and interlaced PNGs of an archive, which decode slower and trip some decoders.",
+			runNormalize},
+		{"compare", "[flags] input output",
+			"Convert a page with two sets of flags and combine both versions into one image, to compare them.", runCompare},
+		{"thumbnail", "[flags] input output",
+			"Write a thumbnail of an input's cover for library apps, cropped around its most detailed area.", runThumbnail},
+		{"version", "", "Print version and build information.", runVersion},
 	}
+}
 
-	// Create outdir if it doesn't exist.
-	if *outdir != "" {
-		if err := os.MkdirAll(*outdir, 0755); err != nil {
-			fmt.Fprintf(os.Stderr, "Could not create outdir: %v\n", err)
-			os.Exit(1)
+func main() {
+	args := os.Args[1:]
+	cmds := commands()
+	cmd := cmds[0]
+	if len(args) > 0 {
+		for _, c := range cmds {
+			if c.name == args[0] {
+				cmd = c
+				args = args[1:]
+				break
+			}
 		}
 	}
-
-	targets := make(chan target, len(flag.Args()))
-	go func() {
-		defer close(targets)
-		for _, in := range flag.Args() {
-			out := filepath.Dir(in)
-			if *outdir != "" {
-				out = *outdir
-			}
-			out = filepath.Join(out, fname(in))
-			targets <- target{in, out}
+	if err := cmd.run(args); err != nil {
+		var exit *exitError
+		if !errors.As(err, &exit) {
+			exit = &exitError{code: 1, err: err}
 		}
-	}()
+		if exit.err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", exit.err)
+		}
+		os.Exit(exit.code)
+	}
+}
 
-	converter := mangaconv.New(mangaconv.Params{
-		Cutoff:  *cutoff,
-		Deflate: *deflate,
-		Gamma:   *gamma,
-		Height:  *height,
-		Width:   *width,
-	})
+// parse sets flags from environment variables and then parses args, so that flags given as
+// arguments take precedence. The variable of flag "page-numbers" is MANGACONV_PAGE_NUMBERS. Flags
+// set by neither are then set from the preset selected with -preset, if any.
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	return applyPreset(fs)
+}
 
-	var wg sync.WaitGroup
-	for i := 0; i < 2; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for t := range targets {
-				if err := converter.Convert(t.in, t.out); err != nil {
-					fmt.Println("Failed to convert", filepath.Base(t.in), err)
-					return
-				}
-				fmt.Println("Converted", filepath.Base(t.in))
-			}
-		}()
+// parseArgs is parse without applying presets.
+func parseArgs(fs *flag.FlagSet, args []string) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		v, ok := os.LookupEnv(envName(f.Name))
+		if !ok || err != nil {
+			return
+		}
+		if e := fs.Set(f.Name, v); e != nil {
+			err = fmt.Errorf("invalid value %q for %s: %w", v, envName(f.Name), e)
+		}
+	})
+	if err != nil {
+		return err
 	}
-	wg.Wait()
+	return fs.Parse(args)
 }
 
-type target struct {
-	in  string
-	out string
+// envName returns the name of the environment variable setting a flag.
+func envName(flag string) string {
+	return "MANGACONV_" + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
 }
 
-func fname(in string) string {
-	return strings.TrimSuffix(filepath.Base(in), filepath.Ext(in)) + ".mc.cbz"
+// newFlagSet creates a flag set for cmd with usage listing its flags and all commands.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		var cmd command
+		var list strings.Builder
+		for _, c := range commands() {
+			if c.name == name {
+				cmd = c
+			}
+			fmt.Fprintf(&list, "  %-10s%s\n", c.name, c.summary)
+		}
+		fmt.Fprintf(out, "Usage: mangaconv %s %s\n\n%s\n\nFlags:\n", cmd.name, cmd.args, cmd.summary)
+		fs.PrintDefaults()
+		fmt.Fprintf(out, "\nFlags can also be set with environment variables, e.g. %s.\n", envName("page-numbers"))
+		fmt.Fprintf(out, "\nCommands:\n%s", list.String())
+	}
+	return fs
+}
+
+// runVersion implements the version command.
+func runVersion(args []string) error {
+	if err := parse(newFlagSet("version"), args); err != nil {
+		return err
+	}
+	fmt.Print(versionInfo())
+	return nil
 }
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/naisuuuu/mangaconv"
+)
+
+// runNormalize implements the normalize command.
+func runNormalize(args []string) error {
+	fs := newFlagSet("normalize")
+	opts := newOptions(fs)
+	check := fs.Bool("check", false, `List progressive JPEGs and interlaced PNGs of all inputs instead of normalizing one,
+taking input related flags into account.`)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *check {
+		return checkProgressive(opts, fs.Args())
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("normalize needs an input and an output")
+	}
+	in, out := fs.Arg(0), fs.Arg(1)
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	normalized, err := mangaconv.Normalize(in, f)
+	if err != nil {
+		f.Close()
+		os.Remove(out)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	for _, name := range normalized {
+		fmt.Printf("%s: re-encoded %s\n", in, name)
+	}
+	fmt.Printf("%s: %d images re-encoded\n", in, len(normalized))
+	return nil
+}
+
+// checkProgressive analyzes inputs and lists their progressive pages.
+func checkProgressive(opts *options, inputs []string) error {
+	p, err := opts.profile()
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, in := range inputs {
+		plan, err := p.converter.Analyze(in)
+		if err != nil {
+			fmt.Printf("%s: %v\n", in, err)
+			failed++
+			continue
+		}
+		n := 0
+		for _, pg := range plan.Pages {
+			if pg.Progressive {
+				fmt.Printf("%s: %s is progressive\n", in, pg.Source)
+				n++
+			}
+		}
+		fmt.Printf("%s: %d of %d pages progressive\n", in, n, len(plan.Pages))
+	}
+	if failed > 0 {
+		return &exitError{code: exitFailed, err: fmt.Errorf("%d of %d inputs failed analysis", failed, len(inputs))}
+	}
+	return nil
+}
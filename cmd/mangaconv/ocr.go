@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// commandOCR is a mangaconv.OCREngine running a shell command, such as "tesseract stdin stdout".
+// Pages are passed to the command as PNG images on stdin, and the recognized text is read from its
+// stdout.
+type commandOCR struct {
+	cmd string
+}
+
+// Recognize implements mangaconv.OCREngine.
+func (o commandOCR) Recognize(ctx context.Context, img image.Image) (string, error) {
+	var in bytes.Buffer
+	if err := png.Encode(&in, img); err != nil {
+		return "", err
+	}
+	var c *exec.Cmd
+	if runtime.GOOS == "windows" {
+		c = exec.CommandContext(ctx, "cmd", "/C", o.cmd)
+	} else {
+		c = exec.CommandContext(ctx, "sh", "-c", o.cmd)
+	}
+	var out strings.Builder
+	c.Stdin, c.Stdout, c.Stderr = &in, &out, os.Stderr
+	if err := c.Run(); err != nil {
+		return "", fmt.Errorf("ocr failed: %w", err)
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,570 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/naisuuuu/mangaconv"
+	"github.com/naisuuuu/mangaconv/accel"
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+// options holds all flags describing a single output profile.
+type options struct {
+	accel            *string
+	autoContrast     *bool
+	autoRotate       *bool
+	bolden           *float64
+	brightness       *float64
+	chapters         *bool
+	comicinfo        *bool
+	comicinfoHints   *bool
+	compression      *string
+	contrast         *float64
+	creditKeywords   *string
+	credits          *string
+	cutoff           *float64
+	deflate          *bool
+	errors           *string
+	exactSize        *bool
+	fit              *string
+	fixedPoint       *bool
+	format           *string
+	gamma            *float64
+	grayRGB          *bool
+	height           *int
+	intermediate16   *bool
+	joinSpreads      *bool
+	keepNames        *bool
+	kernel           *string
+	lowMemory        *bool
+	ltr              *bool
+	manifest         *bool
+	margin           *int
+	marginColor      *string
+	maxOpenFiles     *int
+	maxPageHeight    *int
+	metadata         *string
+	minPageSize      *int
+	name             *string
+	ocr              *string
+	order            *string
+	outdir           *string
+	pageBuffer       *int
+	pageNumbers      *string
+	pageNumberSize   *int
+	pdfDPI           *int
+	pdfRasterizer    *string
+	preserveTone     *bool
+	preset           *string
+	protectText      *bool
+	quantize         *int
+	readRetries      *int
+	retryDelay       *time.Duration
+	safeNames        *bool
+	safeRepl         *string
+	scaleWorkers     *int
+	smoothGamma      *bool
+	splitChapters    *bool
+	splitOffset      *float64
+	splitOverlap     *float64
+	spreads          *string
+	strict           *bool
+	strictNonImages  *float64
+	strictSizeRatio  *float64
+	symlinks         *string
+	titlePages       *bool
+	tmpdir           *string
+	watermark        *string
+	watermarkCorner  *string
+	watermarkOpacity *float64
+	width            *int
+}
+
+// newOptions registers output profile flags in fs.
+func newOptions(fs *flag.FlagSet) *options {
+	d := mangaconv.DefaultParams()
+	return &options{
+		accel: fs.String("accel", "", fmt.Sprintf(`Acceleration backend applying contrast and gamma.
+Pages are still scaled by the built-in scaler. One of: %s. None by default.`, strings.Join(accel.Available(), ", "))),
+		autoContrast: fs.Bool("autocontrast", d.AutoContrast, `Normalize the histogram of grayscale pages.
+Stretches their gray levels to the full range. Disable to only apply -brightness, -contrast and -gamma.`),
+		autoRotate: fs.Bool("auto-rotate", false, `Rotate pages which would be displayed much larger when rotated.
+Useful for wide maps and charts. Independent of -spreads.`),
+		bolden: fs.Float64("bolden", 0, `Thicken thin dark lines which nearly disappear on e-ink screens after downscaling.
+Strength in the range 0 to 1, e.g. 0.5. 0 disables it.`),
+		brightness: fs.Float64("brightness", 0, `Brightness added after autocontrast, as a fraction of white.
+E.g. 0.1 brightens pages by 10%, -0.1 darkens them.`),
+		chapters: fs.Bool("chapters", false, `Treat folders inside inputs as chapters.
+Pages are grouped by folder and each chapter is bookmarked in ComicInfo.xml.`),
+		comicinfo: fs.Bool("comicinfo", d.ComicInfo, `Add a ComicInfo.xml file to the output cbz files.
+Series, volume and chapter are parsed from the input name, e.g. "Series Name v03 c21".`),
+		comicinfoHints: fs.Bool("comicinfo-hints", false, `Follow hints in the input's own ComicInfo.xml and keep its
+bookmarks. Its Manga field overrides -ltr, covers are never split and only double pages are
+spreads, if marked.`),
+		compression: fs.String("compression", "auto", `Compression of entries in the output cbz files.
+One of: auto (store jpg pages, deflate everything else), store (no compression,
+for maximum compatibility), deflate (compress everything).`),
+		contrast: fs.Float64("contrast", 0, `Contrast adjustment applied after autocontrast.
+E.g. 0.2 increases contrast by 20%, -0.2 decreases it.`),
+		creditKeywords: fs.String("credit-keywords", "", `Comma separated keywords of credits pages,
+matched case-insensitively. Pages with at least two of them are credits pages.
+Defaults to common scanlation credits.`),
+		credits: fs.String("credits", "keep", `What to do with scanlation credits and recruitment pages found with -ocr.
+One of: keep, tag (mark them in ComicInfo.xml), drop.`),
+		cutoff: fs.Float64("cutoff", d.Cutoff, `Autocontrast cutoff.
+This value is the percentage of brightest and darkest pixels ignored when normalizing the histogram.
+Applying a cutoff nets a more perceivable contrast improvement.`),
+		deflate: fs.Bool("deflate", false, "Deprecated: use -compression deflate instead."),
+		errors: fs.String("on-error", "abort", `What to do when a page can't be read or decoded.
+One of: abort (fail the input and remove its output), skip (convert all other pages).`),
+		exactSize: fs.Bool("exact-size", false, `Pad every page to exactly -width by -height with -margin-color.
+Avoids slow zooming on readers which don't scale pages to fit the screen, such as Kobo with -format kepub.`),
+		fit: fs.String("fit", "contain", `How pages are scaled to -width and -height.
+One of: contain (fit into width by height), width (scale to width with unconstrained height,
+for readers scrolling vertically through webtoons).`),
+		fixedPoint: fs.Bool("fixed-point", d.FixedPoint, `Scale pages with integer arithmetic instead of
+floating point. Much faster on e-readers without a floating point unit, and enabled by default on
+32-bit ARM.`),
+		format: fs.String("format", "cbz", `File format of the outputs.
+One of: cbz, epub (fixed layout EPUB 3 with each page's viewport set to its size),
+kepub (epub named .kepub.epub, opened by Kobo e-readers with their faster reader).`),
+		gamma: fs.Float64("gamma", d.Gamma, `Gamma correction value.
+Values < 1 darken the image, > 1 brighten it and 1 disables gamma correction.
+The default will look too dark on your computer screen, but much richer than before on e-ink.`),
+		grayRGB: fs.Bool("gray-rgb", false, `Encode grayscale pages as three channel JPEGs, for readers which
+can't display single channel ones. By default, pages without color are encoded as single channel
+grayscale JPEGs, which are smaller.`),
+		height: fs.Int("height", d.Height, "Maximum height of the image."),
+		intermediate16: fs.Bool("intermediate-16", false, `Scale and adjust pages with 16 bits per pixel, dithering them
+down to 8 bits at the end. Avoids banding in gradients of high quality sources, at the cost of
+speed and memory.`),
+		joinSpreads: fs.Bool("join-spreads", false, `Join spreads stored as two files, such as "012a.jpg" and
+"012b.jpg", into one page. The joined page is then handled according to -spreads.`),
+		keepNames: fs.Bool("keep-names", false, `Keep original file names of pages in the output cbz files.
+Names are still prefixed with the page number to preserve page order.`),
+		kernel: fs.String("kernel", "catmull-rom", `Interpolation kernel pages are scaled with.
+One of: catmull-rom (sharp), bilinear (fast, but blurrier), lanczos3 (sharpest and slowest,
+keeps screentones crisp but may ring around edges). Ignored with -accel.`),
+		lowMemory: fs.Bool("low-memory", false, `Convert one page and one input at a time, with uncompressed output
+and few buffers. For devices with 512 MB of memory or less, such as e-readers. Overrides
+-compression, -jobs, -page-buffer and -scale-workers.`),
+		ltr: fs.Bool("ltr", false, `Read left to right, like western comics.
+Affects the order of split spreads. Manga are read right to left.`),
+		manifest: fs.Bool("manifest", false, `Add a manifest.json file with each page's SHA-256 checksum and source file.
+Lets sync tools verify output cbz files without decoding them.`),
+		margin: fs.Int("margin", 0, `Width of a border added around each page, in pixels.
+Pages are scaled down to make room for it. Useful for devices that crop edges when zoomed.`),
+		marginColor: fs.String("margin-color", "white", "Color of the margin. One of: white, black."),
+		maxOpenFiles: fs.Int("max-open-files", 0, `Maximum number of input files open at once per output profile.
+Lower it on systems with a low file descriptor limit. 0 means no limit.`),
+		maxPageHeight: fs.Int("max-page-height", 0, `Split pages taller than this many pixels once scaled into bands
+written as separate pages. Use with -fit width for long webtoon strips. 0 means no limit.`),
+		metadata: fs.String("metadata", "", `Comma separated list of online databases queried for series metadata.
+The summary, genres and more of the first one finding the series are written to ComicInfo.xml.
+Available databases are: anilist, mangaupdates.`),
+		minPageSize: fs.Int("min-page-size", 0, `Skip images smaller than this many pixels in both dimensions.
+Useful to drop thumbnails and logos. 0 keeps all images.`),
+		name: fs.String("name", "{{.Name}}.mc", `Output file name template, without extension.
+Available fields are .Name (input name without extension), .Series, .Volume and .Chapter.`),
+		ocr: fs.String("ocr", "", `Shell command recognizing text for -credits, e.g. "tesseract stdin stdout".
+Pages are passed as PNG images on stdin, and text is read from stdout.`),
+		order: fs.String("order", "archive", `Page order of archive inputs.
+One of: archive (as stored), natural (natural sort by path), folder (by folder, then file name).`),
+		outdir: fs.String("outdir", "", `Path to output directory.
+If provided directory does not exist, mangaconv will attempt to create it. (default input dir)`),
+		pageBuffer: fs.Int("page-buffer", 0, `Number of pages buffered between decoding, converting and writing.
+Higher values improve throughput at the cost of memory. -1 buffers one page per CPU.`),
+		pageNumbers: fs.String("page-numbers", "none", `Burn page numbers into a corner of each page.
+One of: none, top-left, top-right, bottom-left, bottom-right.`),
+		pageNumberSize: fs.Int("page-number-size", 0,
+			"Height of page numbers in pixels. (default relative to page height)"),
+		pdfDPI: fs.Int("pdf-dpi", d.PDFDPI, "Resolution PDF pages are rendered at by -pdf-rasterizer."),
+		pdfRasterizer: fs.String("pdf-rasterizer", "", `Shell command rendering a page of a PDF input as an image on
+stdout, with {file}, {page} and {dpi} replaced by the PDF file, page number and resolution,
+e.g. "pdftoppm -r {dpi} -f {page} -l {page} -png {file}".
+Without it, the largest image embedded in each page is extracted.`),
+		preserveTone: fs.Bool("preserve-tone", false, `Keep each page's median gray level when applying autocontrast.
+Use if autocontrast makes gray washes too bright.`),
+		preset: fs.String("preset", "", `Name of a preset imported with "presets import", or path to a .json preset file.
+Sets output flags to the preset's values, unless they're given explicitly.`),
+		protectText: fs.Bool("protect-text", false, `Don't bolden or dither lettering detected in speech bubbles
+and captions. Use if -bolden fills in small letters or -intermediate-16 leaves noise around them.`),
+		quantize: fs.Int("quantize", 0, `Round page sizes down to multiples of this many pixels.
+Speeds up inputs with pages of slightly different sizes. 0 disables it.`),
+		readRetries: fs.Int("read-retries", d.ReadRetries, `Number of times reading an input file is retried after an
+I/O error. Useful for inputs on network mounts.`),
+		retryDelay: fs.Duration("retry-delay", d.RetryDelay, "Delay before the first retry, doubled after each one."),
+		safeNames: fs.Bool("safe-names", false, `Replace characters not allowed on FAT32 filesystems in output names.
+Use this when copying files to e-reader SD cards.`),
+		safeRepl: fs.String("safe-names-replacement", "_", "Replacement for characters removed by -safe-names."),
+		scaleWorkers: fs.Int("scale-workers", d.ScaleWorkers, `Number of goroutines scaling each page.
+Increase when converting single files with large pages on machines with many cores.`),
+		smoothGamma: fs.Bool("smooth-gamma", false, `Apply contrast and gamma before rounding scaled pixels to 8 bits.
+Avoids banding in dark gradients.`),
+		splitChapters: fs.Bool("split-chapters", false, `Write a separate cbz file for each chapter, e.g. "name - c001.cbz".
+Requires -chapters.`),
+		splitOffset: fs.Float64("split-offset", 0, `Move the seam of split spreads by this % of the spread width.
+Positive values move it right. Use for scans where the seam isn't in the middle.`),
+		splitOverlap: fs.Float64("split-overlap", 0,
+			"Percentage of the spread width each split half extends past the seam."),
+		spreads: fs.String("spreads", "keep", `How to handle double page spreads.
+One of: keep, split (two pages in reading order), rotate (one rotated page),
+both (rotated page followed by split halves).`),
+		strict: fs.Bool("strict", false, `Fail inputs which look mis-packaged instead of converting whatever images they hold:
+inputs without images, with too many other files, or with pages of wildly different heights.`),
+		strictNonImages: fs.Float64("strict-non-images", d.StrictNonImages,
+			"Percentage of files other than images and metadata failing inputs with -strict. 0 allows any."),
+		strictSizeRatio: fs.Float64("strict-size-ratio", d.StrictSizeRatio, `How many times higher or lower than the median
+page a page may be with -strict. 0 allows any height, e.g. for webtoons.`),
+		symlinks: fs.String("symlinks", "files", `How to treat symbolic links in input directories.
+One of: files (follow links to files only), follow (follow all links), ignore (skip all links).`),
+		titlePages: fs.Bool("title-pages", false, `Insert a generated title page before each chapter.
+Requires -chapters.`),
+		tmpdir: fs.String("tmpdir", "", `Path to a directory outputs are written to until they're complete, then moved
+into place. If provided directory does not exist, mangaconv will attempt to create it.
+(default output dir)`),
+		watermark: fs.String("watermark", "", `Path to an image composited onto each page.
+Transparency is preserved; colors are converted to grayscale.`),
+		watermarkCorner: fs.String("watermark-corner", "bottom-right", `Corner of the watermark.
+One of: top-left, top-right, bottom-left, bottom-right.`),
+		watermarkOpacity: fs.Float64("watermark-opacity", d.WatermarkOpacity, "Watermark opacity in the range 0 to 1."),
+		width:            fs.Int("width", d.Width, "Maximum width of the image."),
+	}
+}
+
+// profile is a fully parsed output profile.
+type profile struct {
+	converter *mangaconv.Converter
+	ext       string
+	outdir    string
+	name      *template.Template
+	safeNames bool
+	safeRepl  string
+}
+
+var errInvalidValue = errors.New("invalid value")
+
+// profile validates options and creates a profile from them.
+func (o *options) profile() (*profile, error) {
+	p := mangaconv.Params{
+		AutoContrast:     *o.autoContrast,
+		AutoRotate:       *o.autoRotate,
+		Bolden:           *o.bolden,
+		Brightness:       *o.brightness,
+		Chapters:         *o.chapters,
+		ComicInfo:        *o.comicinfo,
+		ComicInfoHints:   *o.comicinfoHints,
+		Contrast:         *o.contrast,
+		Cutoff:           *o.cutoff,
+		ExactSize:        *o.exactSize,
+		FixedPoint:       *o.fixedPoint,
+		Gamma:            *o.gamma,
+		GrayRGB:          *o.grayRGB,
+		Height:           *o.height,
+		Intermediate16:   *o.intermediate16,
+		JoinSpreads:      *o.joinSpreads,
+		KeepNames:        *o.keepNames,
+		LeftToRight:      *o.ltr,
+		LowMemory:        *o.lowMemory,
+		Manifest:         *o.manifest,
+		Margin:           *o.margin,
+		MaxOpenFiles:     *o.maxOpenFiles,
+		MaxPageHeight:    *o.maxPageHeight,
+		MinPageSize:      *o.minPageSize,
+		PageBuffer:       *o.pageBuffer,
+		PageNumberSize:   *o.pageNumberSize,
+		PDFDPI:           *o.pdfDPI,
+		PreserveTone:     *o.preserveTone,
+		ProtectText:      *o.protectText,
+		Quantize:         *o.quantize,
+		ReadRetries:      *o.readRetries,
+		RetryDelay:       *o.retryDelay,
+		ScaleWorkers:     *o.scaleWorkers,
+		SmoothGamma:      *o.smoothGamma,
+		SplitChapters:    *o.splitChapters,
+		SplitOffset:      *o.splitOffset,
+		SplitOverlap:     *o.splitOverlap,
+		Strict:           *o.strict,
+		StrictNonImages:  *o.strictNonImages,
+		StrictSizeRatio:  *o.strictSizeRatio,
+		TempDir:          *o.tmpdir,
+		TitlePages:       *o.titlePages,
+		WatermarkOpacity: *o.watermarkOpacity,
+		Width:            *o.width,
+	}
+
+	if *o.cutoff < 0 {
+		return nil, fmt.Errorf("%w for cutoff: %v, disable autocontrast with -autocontrast=false", errInvalidValue, *o.cutoff)
+	}
+	var ok bool
+	if p.Compression, ok = compressions[*o.compression]; !ok {
+		return nil, fmt.Errorf("%w for compression: %s", errInvalidValue, *o.compression)
+	}
+	if *o.deflate && p.Compression == mangaconv.CompressionAuto {
+		p.Compression = mangaconv.CompressionDeflate
+	}
+	if *o.creditKeywords != "" {
+		p.CreditKeywords = strings.Split(*o.creditKeywords, ",")
+	}
+	if p.Credits, ok = creditsPolicies[*o.credits]; !ok {
+		return nil, fmt.Errorf("%w for credits: %s", errInvalidValue, *o.credits)
+	}
+	if p.Credits != mangaconv.CreditsKeep && *o.ocr == "" {
+		return nil, fmt.Errorf("%w for credits: %s requires -ocr", errInvalidValue, *o.credits)
+	}
+	if *o.ocr != "" {
+		p.OCR = commandOCR{*o.ocr}
+	}
+	if p.Errors, ok = errorPolicies[*o.errors]; !ok {
+		return nil, fmt.Errorf("%w for on-error: %s", errInvalidValue, *o.errors)
+	}
+	if p.Fit, ok = fitModes[*o.fit]; !ok {
+		return nil, fmt.Errorf("%w for fit: %s", errInvalidValue, *o.fit)
+	}
+	if p.Format, ok = formats[*o.format]; !ok {
+		return nil, fmt.Errorf("%w for format: %s", errInvalidValue, *o.format)
+	}
+	kernel, ok := kernels[*o.kernel]
+	if !ok {
+		return nil, fmt.Errorf("%w for kernel: %s", errInvalidValue, *o.kernel)
+	}
+	if *o.accel != "" {
+		var err error
+		if p.Scaler, err = accel.New(*o.accel); err != nil {
+			return nil, fmt.Errorf("cannot use accel: %w", err)
+		}
+	} else if kernel != imgutil.CatmullRom {
+		scaler := imgutil.NewCacheScaler(kernel)
+		if !p.LowMemory {
+			scaler.SetConcurrency(p.ScaleWorkers)
+		}
+		scaler.SetFixedPoint(p.FixedPoint)
+		p.Scaler = scaler
+	}
+	if p.MarginColor, ok = marginColors[*o.marginColor]; !ok {
+		return nil, fmt.Errorf("%w for margin-color: %s", errInvalidValue, *o.marginColor)
+	}
+	if *o.metadata != "" {
+		for _, name := range strings.Split(*o.metadata, ",") {
+			mp, ok := metadataProviders[name]
+			if !ok {
+				return nil, fmt.Errorf("%w for metadata: %s", errInvalidValue, name)
+			}
+			p.MetadataProviders = append(p.MetadataProviders, mp)
+		}
+	}
+	if p.Order, ok = pageOrders[*o.order]; !ok {
+		return nil, fmt.Errorf("%w for order: %s", errInvalidValue, *o.order)
+	}
+	if p.PageNumbers, ok = corners[*o.pageNumbers]; !ok {
+		return nil, fmt.Errorf("%w for page-numbers: %s", errInvalidValue, *o.pageNumbers)
+	}
+	if *o.pdfRasterizer != "" {
+		p.PDFRasterizer = commandRasterizer{*o.pdfRasterizer}
+	}
+	if p.Spreads, ok = spreadPolicies[*o.spreads]; !ok {
+		return nil, fmt.Errorf("%w for spreads: %s", errInvalidValue, *o.spreads)
+	}
+	if p.Symlinks, ok = symlinkPolicies[*o.symlinks]; !ok {
+		return nil, fmt.Errorf("%w for symlinks: %s", errInvalidValue, *o.symlinks)
+	}
+	if p.WatermarkCorner, ok = corners[*o.watermarkCorner]; !ok || p.WatermarkCorner == mangaconv.CornerNone {
+		return nil, fmt.Errorf("%w for watermark-corner: %s", errInvalidValue, *o.watermarkCorner)
+	}
+	if *o.watermark != "" {
+		var err error
+		if p.Watermark, err = readImage(*o.watermark); err != nil {
+			return nil, fmt.Errorf("cannot read watermark: %w", err)
+		}
+	}
+
+	tmpl, err := template.New("name").Parse(*o.name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name template: %w", err)
+	}
+
+	// Create outdir and tmpdir if they don't exist.
+	if *o.outdir != "" {
+		if err := os.MkdirAll(mangaconv.LongPath(*o.outdir), 0755); err != nil {
+			return nil, fmt.Errorf("cannot create outdir: %w", err)
+		}
+	}
+	if *o.tmpdir != "" {
+		if err := os.MkdirAll(mangaconv.LongPath(*o.tmpdir), 0755); err != nil {
+			return nil, fmt.Errorf("cannot create tmpdir: %w", err)
+		}
+	}
+
+	return &profile{
+		converter: mangaconv.New(p),
+		ext:       p.Format.Ext(),
+		outdir:    *o.outdir,
+		name:      tmpl,
+		safeNames: *o.safeNames,
+		safeRepl:  *o.safeRepl,
+	}, nil
+}
+
+// output returns the output path for input path in.
+func (p *profile) output(in string) (string, error) {
+	out := filepath.Dir(in)
+	if p.outdir != "" {
+		out = p.outdir
+	}
+	n, err := fname(p.name, in)
+	if err != nil {
+		return "", err
+	}
+	if p.safeNames {
+		n = mangaconv.SafeName(n, p.safeRepl)
+	}
+	return filepath.Join(out, n+p.ext), nil
+}
+
+// extraOutputs is a repeatable flag of extra output profiles.
+type extraOutputs []string
+
+func (e *extraOutputs) String() string {
+	return strings.Join(*e, " ")
+}
+
+func (e *extraOutputs) Set(v string) error {
+	*e = append(*e, v)
+	return nil
+}
+
+// extraProfile parses an extra output specification, such as "outdir=tablet,width=2048,gamma=1",
+// as overrides on top of the base flags in args. A preset given in spec replaces the base preset.
+func extraProfile(args []string, spec string) (*profile, error) {
+	fs := flag.NewFlagSet("extra-output", flag.ContinueOnError)
+	o := newOptions(fs)
+	newConvertFlags(fs)
+	if err := parseArgs(fs, args); err != nil {
+		return nil, err
+	}
+	var overrides []string
+	for _, kv := range strings.Split(spec, ",") {
+		if kv != "" {
+			overrides = append(overrides, "-"+kv)
+		}
+	}
+	if err := fs.Parse(overrides); err != nil {
+		return nil, fmt.Errorf("invalid extra output %q: %w", spec, err)
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("invalid extra output %q: unexpected %q", spec, fs.Arg(0))
+	}
+	if err := applyPreset(fs); err != nil {
+		return nil, err
+	}
+	return o.profile()
+}
+
+var compressions = map[string]mangaconv.Compression{
+	"auto":    mangaconv.CompressionAuto,
+	"store":   mangaconv.CompressionStore,
+	"deflate": mangaconv.CompressionDeflate,
+}
+
+var corners = map[string]mangaconv.Corner{
+	"none":         mangaconv.CornerNone,
+	"top-left":     mangaconv.CornerTopLeft,
+	"top-right":    mangaconv.CornerTopRight,
+	"bottom-left":  mangaconv.CornerBottomLeft,
+	"bottom-right": mangaconv.CornerBottomRight,
+}
+
+var creditsPolicies = map[string]mangaconv.CreditsPolicy{
+	"keep": mangaconv.CreditsKeep,
+	"tag":  mangaconv.CreditsTag,
+	"drop": mangaconv.CreditsDrop,
+}
+
+var errorPolicies = map[string]mangaconv.ErrorPolicy{
+	"abort": mangaconv.ErrorsAbort,
+	"skip":  mangaconv.ErrorsSkip,
+}
+
+var fitModes = map[string]mangaconv.FitMode{
+	"contain": mangaconv.FitContain,
+	"width":   mangaconv.FitWidth,
+}
+
+var formats = map[string]mangaconv.Format{
+	"cbz":   mangaconv.FormatCBZ,
+	"epub":  mangaconv.FormatEPUB,
+	"kepub": mangaconv.FormatKEPUB,
+}
+
+var kernels = map[string]*imgutil.Kernel{
+	"bilinear":    imgutil.BiLinear,
+	"catmull-rom": imgutil.CatmullRom,
+	"lanczos3":    imgutil.Lanczos3,
+}
+
+var marginColors = map[string]uint8{
+	"white": 0xff,
+	"black": 0x00,
+}
+
+var metadataProviders = map[string]mangaconv.MetadataProvider{
+	"anilist":      mangaconv.AniList{},
+	"mangaupdates": mangaconv.MangaUpdates{},
+}
+
+var pageOrders = map[string]mangaconv.PageOrder{
+	"archive": mangaconv.OrderArchive,
+	"natural": mangaconv.OrderNatural,
+	"folder":  mangaconv.OrderFolder,
+}
+
+var spreadPolicies = map[string]mangaconv.SpreadPolicy{
+	"keep":   mangaconv.SpreadKeep,
+	"split":  mangaconv.SpreadSplit,
+	"rotate": mangaconv.SpreadRotate,
+	"both":   mangaconv.SpreadBoth,
+}
+
+var symlinkPolicies = map[string]mangaconv.SymlinkPolicy{
+	"files":  mangaconv.SymlinkFiles,
+	"follow": mangaconv.SymlinkFollow,
+	"ignore": mangaconv.SymlinkIgnore,
+}
+
+// readImage reads and decodes an image file.
+func readImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+// nameData is passed to the output name template.
+type nameData struct {
+	mangaconv.Metadata
+	Name string
+}
+
+// fname returns the output file name for input path in, without extension, as described by tmpl.
+func fname(tmpl *template.Template, in string) (string, error) {
+	data := nameData{
+		Metadata: mangaconv.ParseFilename(in),
+		Name:     strings.TrimSuffix(filepath.Base(in), filepath.Ext(in)),
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
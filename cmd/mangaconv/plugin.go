@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/naisuuuu/mangaconv"
+)
+
+// Exit codes of the plugin command. Invalid flags exit with code 2, as with all other commands.
+const (
+	exitOK      = 0
+	exitFailed  = 1
+	exitUsage   = 2
+	exitPartial = 3
+)
+
+// pluginResult is the JSON result of the plugin command. Its fields are a stable interface for
+// Calibre, ComicTagger and similar plugins; only add to them.
+type pluginResult struct {
+	Status  string        `json:"status"`
+	Input   string        `json:"input"`
+	Output  string        `json:"output"`
+	Error   string        `json:"error,omitempty"`
+	Skipped []pluginError `json:"skipped,omitempty"`
+	Version string        `json:"version"`
+}
+
+// pluginError describes a skipped page in pluginResult.
+type pluginError struct {
+	Index  int    `json:"index"`
+	Source string `json:"source"`
+	Error  string `json:"error"`
+}
+
+// exitError makes main exit with a specific code. A nil err isn't printed.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit code %d", e.code)
+	}
+	return e.err.Error()
+}
+
+// runPlugin implements the plugin command.
+func runPlugin(args []string) error {
+	fs := newFlagSet("plugin")
+	opts := newOptions(fs)
+	resultFD := fs.Int("result-fd", 1, "File descriptor the JSON result is written to, e.g. 3 to keep stdout free.")
+	spaceCheck := fs.Bool("space-check", true, "Check for enough free disk space before converting.")
+	if err := parse(fs, args); err != nil {
+		return &exitError{exitUsage, err}
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return &exitError{exitUsage, errors.New("plugin needs exactly one input and one output")}
+	}
+	out := os.NewFile(uintptr(*resultFD), "result")
+	if out == nil {
+		return &exitError{exitUsage, fmt.Errorf("%w for result-fd: %d", errInvalidValue, *resultFD)}
+	}
+
+	t := mangaconv.Target{In: fs.Arg(0), Out: []string{fs.Arg(1)}}
+	res := pluginResult{Status: "ok", Input: t.In, Output: t.Out[0], Version: version}
+	code := exitOK
+	err := func() error {
+		p, err := opts.profile()
+		if err != nil {
+			return err
+		}
+		return mangaconv.ConvertAll(context.Background(), []mangaconv.Target{t}, mangaconv.BatchOptions{
+			Converters:     []*mangaconv.Converter{p.converter},
+			SkipSpaceCheck: !*spaceCheck,
+		})[0].Err
+	}()
+	var partial *mangaconv.PartialError
+	switch {
+	case errors.As(err, &partial):
+		res.Status, res.Error, code = "partial", err.Error(), exitPartial
+		for _, p := range partial.Pages {
+			res.Skipped = append(res.Skipped, pluginError{p.Index, p.Source, p.Err.Error()})
+		}
+	case err != nil:
+		res.Status, res.Error, res.Output, code = "failed", err.Error(), "", exitFailed
+	}
+	if err := json.NewEncoder(out).Encode(res); err != nil {
+		return &exitError{exitFailed, fmt.Errorf("failed to write result: %w", err)}
+	}
+	if code == exitOK {
+		return nil
+	}
+	return &exitError{code: code}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// preset is a shareable set of output flag values, such as those tuned for a device's screen.
+// Flags maps flag names to their values, as given on the command line.
+type preset struct {
+	Flags map[string]string `json:"flags"`
+}
+
+// unsharedFlags are output flags never stored in presets, because they name local paths or run
+// commands, which a preset shared by someone else must not do.
+var unsharedFlags = map[string]bool{
+	"ocr":            true,
+	"outdir":         true,
+	"pdf-rasterizer": true,
+	"preset":         true,
+	"tmpdir":         true,
+	"watermark":      true,
+}
+
+// presetDir returns the directory imported presets are stored in.
+func presetDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot find presets: %w", err)
+	}
+	return filepath.Join(dir, "mangaconv", "presets"), nil
+}
+
+// presetPath returns the path of the preset named name, which is either an imported preset or a
+// path to a .json file.
+func presetPath(name string) (string, error) {
+	if filepath.Ext(name) == ".json" {
+		return name, nil
+	}
+	dir, err := presetDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// readPreset reads and validates the preset file at path.
+func readPreset(path string) (*preset, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read preset: %w", err)
+	}
+	defer f.Close()
+	var p preset
+	if err := json.NewDecoder(f).Decode(&p); err != nil {
+		return nil, fmt.Errorf("invalid preset %s: %w", path, err)
+	}
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("invalid preset %s: %w", path, err)
+	}
+	return &p, nil
+}
+
+// validate checks that all flags of p are shareable output flags with valid values.
+func (p *preset) validate() error {
+	flags := flag.NewFlagSet("preset", flag.ContinueOnError)
+	newOptions(flags)
+	for name, v := range p.Flags {
+		if flags.Lookup(name) == nil || unsharedFlags[name] {
+			return fmt.Errorf("flag %s can't be set by presets", name)
+		}
+		if err := flags.Set(name, v); err != nil {
+			return fmt.Errorf("invalid value %q for %s: %w", v, name, err)
+		}
+	}
+	return nil
+}
+
+// applyPreset sets all flags in fs which haven't been set yet from the preset named by its -preset
+// flag, if any.
+func applyPreset(fs *flag.FlagSet) error {
+	f := fs.Lookup("preset")
+	if f == nil || f.Value.String() == "" {
+		return nil
+	}
+	path, err := presetPath(f.Value.String())
+	if err != nil {
+		return err
+	}
+	p, err := readPreset(path)
+	if err != nil {
+		return err
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for name, v := range p.Flags {
+		if set[name] {
+			continue
+		}
+		if err := fs.Set(name, v); err != nil {
+			return fmt.Errorf("invalid value %q for %s in preset %s: %w", v, name, path, err)
+		}
+	}
+	return nil
+}
+
+// runPresets implements the presets command.
+func runPresets(args []string) error {
+	flags := newFlagSet("presets")
+	if len(args) == 0 {
+		flags.Usage()
+		return &exitError{code: 2}
+	}
+	switch args[0] {
+	case "export":
+		return exportPreset(flags, args[1:])
+	case "import":
+		return importPreset(flags, args[1:])
+	case "list":
+		return listPresets(flags, args[1:])
+	}
+	return fmt.Errorf("unknown presets command %q, want export, import or list", args[0])
+}
+
+// exportPreset writes all output flags set by args, environment variables or -preset, other than
+// unshared ones, as a preset to the file named by the first argument, or stdout if none.
+func exportPreset(flags *flag.FlagSet, args []string) error {
+	newOptions(flags)
+	if err := parse(flags, args); err != nil {
+		return err
+	}
+	p := preset{Flags: make(map[string]string)}
+	flags.Visit(func(f *flag.Flag) {
+		if !unsharedFlags[f.Name] {
+			p.Flags[f.Name] = f.Value.String()
+		}
+	})
+
+	var w io.Writer = os.Stdout
+	if flags.NArg() > 0 {
+		f, err := os.Create(flags.Arg(0))
+		if err != nil {
+			return fmt.Errorf("cannot write preset: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+// importPreset copies the preset file named by the first argument into the presets directory, under
+// the name given as the second argument or the file's name.
+func importPreset(flags *flag.FlagSet, args []string) error {
+	if err := parse(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("no preset file given")
+	}
+	src := flags.Arg(0)
+	name := strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	if flags.NArg() > 1 {
+		name = flags.Arg(1)
+	}
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid preset name %q", name)
+	}
+	p, err := readPreset(src)
+	if err != nil {
+		return err
+	}
+
+	dir, err := presetDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot import preset: %w", err)
+	}
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".json"), append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("cannot import preset: %w", err)
+	}
+	fmt.Printf("Imported preset %s, use it with -preset %s\n", name, name)
+	return nil
+}
+
+// listPresets prints the names and flags of all imported presets.
+func listPresets(flags *flag.FlagSet, args []string) error {
+	if err := parse(flags, args); err != nil {
+		return err
+	}
+	dir, err := presetDir()
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot list presets: %w", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		name := strings.TrimSuffix(e.Name(), ".json")
+		p, err := readPreset(filepath.Join(dir, e.Name()))
+		if err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+			continue
+		}
+		kvs := make([]string, 0, len(p.Flags))
+		for k, v := range p.Flags {
+			kvs = append(kvs, k+"="+v)
+		}
+		sort.Strings(kvs)
+		fmt.Printf("%s: %s\n", name, strings.Join(kvs, " "))
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"archive/zip"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/naisuuuu/mangaconv"
+)
+
+// qaPage is a converted page picked for review, written to the output file out of the input in.
+type qaPage struct {
+	in, out string
+	wp      mangaconv.WrittenPage
+}
+
+// qaSampler picks pages for review uniformly at random among all pages written during a run. It
+// uses reservoir sampling, so that memory use doesn't grow with the size of the run.
+type qaSampler struct {
+	dir   *string
+	n     *int
+	mu    sync.Mutex
+	seen  int
+	pages []qaPage
+	rand  *rand.Rand
+}
+
+// newQASampler registers QA sampling flags in fs.
+func newQASampler(fs *flag.FlagSet) *qaSampler {
+	return &qaSampler{
+		dir: fs.String("qa-dir", "mangaconv-qa", "Folder in which -qa-sample creates a folder for each run."),
+		n: fs.Int("qa-sample", 0, `Copy this many random converted pages and their originals into a new folder in
+-qa-dir once each run is done, to spot-check conversion quality without opening archives.
+0 disables it.`),
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// enabled reports whether pages are sampled at all.
+func (s *qaSampler) enabled() bool {
+	return *s.n > 0
+}
+
+// add offers a page written to the output file out of t for review. Generated pages, which have no
+// original, are never picked. It's safe for concurrent use, and has the signature of
+// mangaconv.BatchOptions.Written.
+func (s *qaSampler) add(t mangaconv.Target, out string, wp mangaconv.WrittenPage) {
+	if wp.Source == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seen++
+	p := qaPage{in: t.In, out: out, wp: wp}
+	if len(s.pages) < *s.n {
+		s.pages = append(s.pages, p)
+		return
+	}
+	if j := s.rand.Intn(s.seen); j < *s.n {
+		s.pages[j] = p
+	}
+}
+
+// export copies all pages picked during the run started at start and their originals into a new
+// folder in -qa-dir, named after the start, and returns its path and the number of copied pages.
+// Pages which can't be copied, such as those of failed outputs, are reported to w and skipped. The
+// next run starts sampling anew.
+func (s *qaSampler) export(w io.Writer, start time.Time) (string, int, error) {
+	s.mu.Lock()
+	pages := s.pages
+	s.pages, s.seen = nil, 0
+	s.mu.Unlock()
+	dir := filepath.Join(*s.dir, start.Format("2006-01-02T15-04-05"))
+	if err := os.MkdirAll(mangaconv.LongPath(dir), 0755); err != nil {
+		return "", 0, fmt.Errorf("cannot create QA folder: %w", err)
+	}
+	copied := 0
+	for k, p := range pages {
+		base := strings.TrimSuffix(filepath.Base(p.out), filepath.Ext(p.out))
+		name := fmt.Sprintf("%03d-%s-p%d", k+1, base, p.wp.Index+1)
+		if p.wp.Sub > 0 {
+			name += fmt.Sprintf("_%d", p.wp.Sub)
+		}
+		converted := filepath.Join(dir, name+"-converted"+path.Ext(p.wp.Entry))
+		if err := copyConverted(converted, p.out, p.wp.Entry); err != nil {
+			fmt.Fprintf(w, "Cannot copy page %d of %s for review: %v\n", p.wp.Index+1, p.out, err)
+			continue
+		}
+		original := filepath.Join(dir, name+"-original"+path.Ext(p.wp.Source))
+		if err := copyOriginal(original, p.in, p.wp.Source); err != nil {
+			fmt.Fprintf(w, "Cannot copy original %s of %s for review: %v\n", p.wp.Source, p.in, err)
+		}
+		copied++
+	}
+	return dir, copied, nil
+}
+
+// copyConverted copies the entry named entry of the output archive out to dst.
+func copyConverted(dst, out, entry string) error {
+	r, err := zip.OpenReader(mangaconv.LongPath(out))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if f.Name == entry {
+			return copyEntry(dst, f)
+		}
+	}
+	return fmt.Errorf("no entry named %s", entry)
+}
+
+// copyOriginal copies the file source of the input in, which is a directory or a zip archive, to
+// dst.
+func copyOriginal(dst, in, source string) error {
+	info, err := os.Stat(mangaconv.LongPath(in))
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		f, err := os.Open(mangaconv.LongPath(filepath.Join(in, filepath.FromSlash(source))))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return copyTo(dst, f)
+	}
+	r, err := zip.OpenReader(mangaconv.LongPath(in))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if f.Name == source {
+			return copyEntry(dst, f)
+		}
+	}
+	return fmt.Errorf("no entry named %s", source)
+}
+
+// copyEntry copies the zip entry f to dst.
+func copyEntry(dst string, f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return copyTo(dst, rc)
+}
+
+// copyTo writes the contents of r to a new file at dst.
+func copyTo(dst string, r io.Reader) error {
+	f, err := os.Create(mangaconv.LongPath(dst))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
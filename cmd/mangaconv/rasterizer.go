@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// commandRasterizer is a mangaconv.PDFRasterizer running a shell command, such as
+// "pdftoppm -r {dpi} -f {page} -l {page} -png {file}". {file}, {page} and {dpi} are replaced by
+// the quoted path of the PDF file, the page number and the resolution, and the rendered page is
+// read from its stdout.
+type commandRasterizer struct {
+	cmd string
+}
+
+// Rasterize implements mangaconv.PDFRasterizer.
+func (r commandRasterizer) Rasterize(ctx context.Context, path string, page, dpi int) (io.ReadCloser, error) {
+	cmd := strings.NewReplacer(
+		"{file}", shellQuote(path),
+		"{page}", strconv.Itoa(page),
+		"{dpi}", strconv.Itoa(dpi),
+	).Replace(r.cmd)
+	var c *exec.Cmd
+	if runtime.GOOS == "windows" {
+		c = exec.CommandContext(ctx, "cmd", "/C", cmd)
+	} else {
+		c = exec.CommandContext(ctx, "sh", "-c", cmd)
+	}
+	var out bytes.Buffer
+	c.Stdout, c.Stderr = &out, os.Stderr
+	if err := c.Run(); err != nil {
+		return nil, fmt.Errorf("rasterizing page %d failed: %w", page, err)
+	}
+	return io.NopCloser(&out), nil
+}
+
+// shellQuote quotes s as a single argument of the shell commands are run with.
+func shellQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + s + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// runScan implements the scan command.
+func runScan(args []string) error {
+	flags := newFlagSet("scan")
+	if err := parse(flags, args); err != nil {
+		return err
+	}
+
+	for _, root := range flags.Args() {
+		err := findInputs(root, func(path string) {
+			fmt.Println(path)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findInputs walks root and calls fn for each archive, each PDF file and each directory directly
+// containing images.
+func findInputs(root string, fn func(path string)) error {
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if hasImages(path) {
+				fn(path)
+			}
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case ".zip", ".cbz", ".pdf":
+			fn(path)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot scan %s: %w", root, err)
+	}
+	return nil
+}
+
+// hasImages reports whether dir directly contains any images.
+func hasImages(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, e := range entries {
+		switch filepath.Ext(e.Name()) {
+		case ".png", ".jpg", ".webp":
+			if !e.IsDir() {
+				return true
+			}
+		}
+	}
+	return false
+}
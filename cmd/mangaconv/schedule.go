@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schedule is a parsed cron expression. Each field is a bitset of the values it matches.
+type schedule struct {
+	minute, hour, dom, month, dow uint64
+	// Days match either field if both are restricted, as in cron.
+	domAll, dowAll bool
+}
+
+// scheduleMacros are shorthands for common expressions.
+var scheduleMacros = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// parseSchedule parses a cron expression of five fields: minute, hour, day of month, month and day
+// of week, with Sunday as 0 or 7. Fields are "*", numbers, ranges like "1-5" and lists of them like
+// "1,15", each optionally followed by a step like "*/15". Macros like "@daily" are accepted too.
+func parseSchedule(expr string) (*schedule, error) {
+	if m, ok := scheduleMacros[expr]; ok {
+		expr = m
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%q has %d fields, want 5", expr, len(fields))
+	}
+	var s schedule
+	var err error
+	parsers := []struct {
+		bits     *uint64
+		min, max int
+	}{
+		{&s.minute, 0, 59},
+		{&s.hour, 0, 23},
+		{&s.dom, 1, 31},
+		{&s.month, 1, 12},
+		{&s.dow, 0, 7},
+	}
+	for i, p := range parsers {
+		if *p.bits, err = parseField(fields[i], p.min, p.max); err != nil {
+			return nil, fmt.Errorf("%q: %w", expr, err)
+		}
+	}
+	// Sunday is both 0 and 7.
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domAll, s.dowAll = fields[2] == "*", fields[4] == "*"
+	if s.next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("%q never matches", expr)
+	}
+	return &s, nil
+}
+
+// parseField parses a single field of values in the range [min, max] into a bitset.
+func parseField(f string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(f, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepStr)
+			}
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			loStr, hiStr, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(loStr); err != nil {
+				return 0, fmt.Errorf("invalid value %q", item)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(hiStr); err != nil {
+					return 0, fmt.Errorf("invalid value %q", item)
+				}
+			} else if hasStep {
+				// "5/15" is short for "5-max/15".
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("value %s out of range %d-%d", item, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// next returns the first time after t matching s, or the zero time if there is none within five
+// years. Times are matched by wall clock in t's location, like cron: times skipped when daylight
+// saving time starts never match, and times repeated when it ends only match once.
+func (s *schedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for limit := t.AddDate(5, 0, 0); t.Before(limit); {
+		y, mon, d, h, m, loc := t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Location()
+		var n time.Time
+		switch {
+		case s.month&(1<<mon) == 0:
+			n = time.Date(y, mon+1, 1, 0, 0, 0, 0, loc)
+		case !s.day(t):
+			n = time.Date(y, mon, d+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<h) == 0:
+			n = time.Date(y, mon, d, h+1, 0, 0, 0, loc)
+		case s.minute&(1<<m) == 0:
+			n = time.Date(y, mon, d, h, m+1, 0, 0, loc)
+		default:
+			return t
+		}
+		// Wall clock times skipped by daylight saving time resolve to times before t, and repeated
+		// ones to their first occurrence, which is before t once past it. Either way, t moves on
+		// by a minute instead.
+		if !n.After(t) {
+			n = t.Add(time.Minute)
+		}
+		t = n
+	}
+	return time.Time{}
+}
+
+// day reports whether the day of t matches s.
+func (s *schedule) day(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<t.Weekday()) != 0
+	if !s.domAll && !s.dowAll {
+		return dom || dow
+	}
+	return dom && dow
+}
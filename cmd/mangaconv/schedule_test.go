@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// bits returns a bitset of the values from lo to hi.
+func bits(lo, hi int) uint64 {
+	var b uint64
+	for v := lo; v <= hi; v++ {
+		b |= 1 << v
+	}
+	return b
+}
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    *schedule
+		wantErr bool
+	}{
+		{expr: "@daily", want: &schedule{
+			minute: 1, hour: 1, dom: bits(1, 31), month: bits(1, 12), dow: bits(0, 7), domAll: true, dowAll: true,
+		}},
+		{expr: "*/15 9-17 * * 1-5", want: &schedule{
+			minute: 1 | 1<<15 | 1<<30 | 1<<45, hour: bits(9, 17), dom: bits(1, 31), month: bits(1, 12), dow: bits(1, 5),
+			domAll: true,
+		}},
+		{expr: "5/20 0,12 1-10/3 * 7", want: &schedule{
+			minute: 1<<5 | 1<<25 | 1<<45, hour: 1 | 1<<12, dom: 1<<1 | 1<<4 | 1<<7 | 1<<10, month: bits(1, 12),
+			dow: 1 | 1<<7, dowAll: false,
+		}},
+		{expr: "* * * *", wantErr: true},
+		{expr: "60 * * * *", wantErr: true},
+		{expr: "5-1 * * * *", wantErr: true},
+		{expr: "*/0 * * * *", wantErr: true},
+		{expr: "a * * * *", wantErr: true},
+		{expr: "0 0 31 2 *", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := parseSchedule(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSchedule() error %v, want error: %t", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got, cmp.AllowUnexported(schedule{})); diff != "" {
+				t.Errorf("parseSchedule() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+	utc := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2024, month, day, hour, min, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		loc  *time.Location
+		t    time.Time
+		want time.Time
+	}{
+		{"step", "*/15 * * * *", time.UTC, utc(1, 1, 10, 7), utc(1, 1, 10, 15)},
+		{"exact minute is after", "*/15 * * * *", time.UTC, utc(1, 1, 10, 15), utc(1, 1, 10, 30)},
+		{"next month", "0 0 1 * *", time.UTC, utc(1, 31, 12, 0), utc(2, 1, 0, 0)},
+		{"leap day", "0 0 29 2 *", time.UTC, utc(1, 1, 0, 0), utc(2, 29, 0, 0)},
+		// Restricting both day fields matches either: the 13th or a Friday.
+		{"day of month or week", "0 0 13 * 5", time.UTC, utc(1, 6, 0, 0), utc(1, 12, 0, 0)},
+		{"weekday range", "30 9 * * 1-5", time.UTC, utc(1, 5, 10, 0), utc(1, 8, 9, 30)},
+		// On March 10, 2024, New York skips from 2:00 EST to 3:00 EDT.
+		{"skipped time", "30 2 * * *", ny, utc(3, 10, 6, 0), utc(3, 11, 6, 30)},
+		{"after skipped hour", "0 * * * *", ny, utc(3, 10, 6, 30), utc(3, 10, 7, 0)},
+		// On November 3, 2024, New York repeats 1:00 to 2:00, first in EDT, then in EST.
+		{"repeated time", "30 1 * * *", ny, utc(11, 3, 4, 0), utc(11, 3, 5, 30)},
+		{"repeated time once", "30 1 * * *", ny, utc(11, 3, 5, 30), utc(11, 4, 6, 30)},
+		{"repeated hour skipped", "*/30 * * * *", ny, utc(11, 3, 5, 45), utc(11, 3, 7, 0)},
+		{"within repeated hour", "*/30 * * * *", ny, utc(11, 3, 6, 10), utc(11, 3, 6, 30)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := parseSchedule(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.next(tt.t.In(tt.loc)); !got.Equal(tt.want) {
+				t.Errorf("next(%v) = %v, want %v", tt.t.In(tt.loc), got, tt.want.In(tt.loc))
+			}
+		})
+	}
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/naisuuuu/mangaconv"
+)
+
+// runServe implements the serve command.
+func runServe(args []string) error {
+	fs := newFlagSet("serve")
+	opts := newOptions(fs)
+	addr := fs.String("listen", "localhost:8080", "Address the HTTP server listens on.")
+	dir := fs.String("dir", "mangaconv-jobs", `Directory the job queue is stored in, holding each job's input and output.
+Jobs which haven't finished when the server stops are resumed when it's started again.`)
+	jobs := fs.Int("jobs", 1, "Number of jobs converted at once.")
+	retries := fs.Int("job-retries", 2, `Number of times a failed job is retried.
+Jobs whose input is unsupported or corrupt aren't retried.`)
+	retryDelay := fs.Duration("job-retry-delay", time.Minute, `Delay before the first retry of a failed job.
+Doubled for each further retry.`)
+	maxUpload := fs.Int("max-upload", 2048, "Reject uploads larger than this many MiB. 0 disables the limit.")
+	var limits queueLimits
+	fs.IntVar(&limits.Queued, "max-queued", 0, `Reject uploads while this many jobs are pending or running.
+0 disables the limit.`)
+	fs.IntVar(&limits.ClientQueued, "client-queued", 0, `Reject uploads of a client while this many of its jobs are
+pending or running. 0 disables the limit. Clients are told apart by IP address.`)
+	fs.IntVar(&limits.ClientJobs, "client-jobs", 0, `Number of jobs of a single client converted at once,
+so that a client submitting many jobs doesn't hold up everyone else's. 0 allows up to -jobs.`)
+	rate := fs.Int("rate", 0, "Number of uploads accepted per minute. 0 disables the limit.")
+	clientRate := fs.Int("client-rate", 0, `Number of uploads accepted per minute from a single client.
+0 disables the limit.`)
+	auth := &authHandler{}
+	fs.StringVar(&auth.user, "auth-user", "", "User name clients authenticate as with basic auth.")
+	fs.StringVar(&auth.password, "auth-password", "", `Password of -auth-user. Prefer setting it with the
+MANGACONV_AUTH_PASSWORD environment variable, which other users can't see in the process list.`)
+	fs.StringVar(&auth.token, "auth-token", "", `Token clients authenticate with as "Authorization: Bearer TOKEN".
+Prefer setting it with the MANGACONV_AUTH_TOKEN environment variable.`)
+	tlsCert := fs.String("tls-cert", "", "Certificate file, to serve HTTPS along with -tls-key.")
+	tlsKey := fs.String("tls-key", "", "Private key file of -tls-cert.")
+	logFormat := fs.String("log-format", "text", "Log format. One of: json, text.")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return fmt.Errorf("serve takes no arguments, got %q", fs.Arg(0))
+	}
+	if (auth.user == "") != (auth.password == "") {
+		return fmt.Errorf("%w for auth-user: needs -auth-password", errInvalidValue)
+	}
+	if (*tlsCert == "") != (*tlsKey == "") {
+		return fmt.Errorf("%w for tls-cert: needs -tls-key", errInvalidValue)
+	}
+	if *opts.splitChapters {
+		// Jobs have a single output to respond with.
+		return fmt.Errorf("%w for split-chapters: not supported by serve", errInvalidValue)
+	}
+	if *jobs < 1 {
+		return fmt.Errorf("%w for jobs: %d", errInvalidValue, *jobs)
+	}
+	log, ok := loggers[*logFormat]
+	if !ok {
+		return fmt.Errorf("%w for log-format: %s", errInvalidValue, *logFormat)
+	}
+	p, err := opts.profile()
+	if err != nil {
+		return err
+	}
+	q, err := openQueue(*dir, *retries, *retryDelay, limits, log)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < *jobs; i++ {
+		go q.work(p)
+	}
+
+	s := &server{
+		queue:      q,
+		maxUpload:  int64(*maxUpload) << 20,
+		rate:       newRateLimiter(*rate),
+		clientRate: newRateLimiter(*clientRate),
+	}
+	auth.h = s
+	// Health checks are answered without authentication, as container runtimes have no credentials.
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Trim(r.URL.Path, "/") == "healthz" {
+			s.healthz(w, r)
+			return
+		}
+		auth.ServeHTTP(w, r)
+	})
+	if *logFormat == "json" {
+		json.NewEncoder(os.Stdout).Encode(logEntry{Time: time.Now().UTC(), Level: "info", Msg: "listening on " + *addr})
+	} else {
+		fmt.Println("Listening on", *addr)
+	}
+	if *tlsCert != "" {
+		return http.ListenAndServeTLS(*addr, *tlsCert, *tlsKey, h)
+	}
+	return http.ListenAndServe(*addr, h)
+}
+
+// Default job priorities. Inputs converted while the client waits jump ahead of submitted jobs.
+const (
+	jobPriority     = 0
+	convertPriority = 10
+)
+
+// server serves the HTTP API of the serve command:
+//
+//	POST /convert?name=&priority=  converts the input sent as the body and responds with its output
+//	POST /jobs?name=&priority=     adds a job converting the input sent as the body
+//	GET /jobs                      lists all jobs
+//	GET /jobs/ID                   describes a job
+//	GET /jobs/ID/output            responds with the output of a done job
+//	DELETE /jobs/ID                removes a job which isn't running
+//	GET /healthz                   reports whether the server is healthy, without authentication
+//
+// The name query parameter is the input's file name, which selects its format and names the
+// output, such as "Series v01.cbz". Priority defaults to convertPriority for /convert and
+// jobPriority for /jobs. Outputs of inputs with skipped pages are sent with the number of skipped
+// pages in the Mangaconv-Skipped header.
+//
+// Uploads larger than maxUpload bytes are rejected, as are uploads past the rate limits or the
+// queue's limits.
+type server struct {
+	queue      *jobQueue
+	maxUpload  int64
+	rate       *rateLimiter
+	clientRate *rateLimiter
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, sub := "", ""
+	path := strings.Trim(r.URL.Path, "/")
+	switch {
+	case path == "convert":
+		if allowMethods(w, r, http.MethodPost) {
+			s.convert(w, r)
+		}
+		return
+	case path == "jobs":
+	case strings.HasPrefix(path, "jobs/"):
+		id = strings.TrimPrefix(path, "jobs/")
+		id, sub = splitFirst(id, "/")
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case id == "":
+		if allowMethods(w, r, http.MethodGet, http.MethodPost) {
+			if r.Method == http.MethodGet {
+				writeJSON(w, http.StatusOK, s.queue.list())
+			} else if j, ok := s.add(w, r, jobPriority); ok {
+				writeJSON(w, http.StatusAccepted, j)
+			}
+		}
+	case sub == "output":
+		if allowMethods(w, r, http.MethodGet) {
+			s.output(w, r, id)
+		}
+	case sub != "":
+		http.NotFound(w, r)
+	case allowMethods(w, r, http.MethodGet, http.MethodDelete):
+		if r.Method == http.MethodGet {
+			j, err := s.queue.get(id)
+			if err != nil {
+				jobError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, j)
+			return
+		}
+		if err := s.queue.remove(id); err != nil {
+			jobError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// add adds a job converting the input sent as the body of r with priority, unless overridden by the
+// priority query parameter. It responds with an error and returns false if the job can't be added.
+func (s *server) add(w http.ResponseWriter, r *http.Request, priority int) (job, bool) {
+	if v := r.URL.Query().Get("priority"); v != "" {
+		var err error
+		if priority, err = strconv.Atoi(v); err != nil {
+			http.Error(w, fmt.Sprintf("%v for priority: %s", errInvalidValue, v), http.StatusBadRequest)
+			return job{}, false
+		}
+	}
+	client := clientName(r)
+	for _, l := range []struct {
+		limiter *rateLimiter
+		key     string
+	}{{s.clientRate, client}, {s.rate, ""}} {
+		if ok, wait := l.limiter.allow(l.key); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			http.Error(w, errRateLimited.Error(), http.StatusTooManyRequests)
+			return job{}, false
+		}
+	}
+	var body io.Reader = r.Body
+	if s.maxUpload > 0 {
+		if r.ContentLength > s.maxUpload {
+			jobError(w, errUploadTooLarge)
+			return job{}, false
+		}
+		body = &limitedReader{r.Body, s.maxUpload}
+	}
+	j, err := s.queue.add(r.URL.Query().Get("name"), client, priority, body)
+	if err != nil {
+		jobError(w, err)
+		return job{}, false
+	}
+	return j, true
+}
+
+// convert adds a job and waits for it to finish, responding with its output. Jobs whose client
+// stopped waiting still finish, and their output can be fetched from /jobs.
+func (s *server) convert(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.add(w, r, convertPriority)
+	if !ok {
+		return
+	}
+	w.Header().Set("Mangaconv-Job", j.ID)
+	select {
+	case <-j.done:
+	case <-r.Context().Done():
+		return
+	}
+	s.output(w, r, j.ID)
+}
+
+// output responds with the output of the job with the given id.
+func (s *server) output(w http.ResponseWriter, r *http.Request, id string) {
+	j, err := s.queue.get(id)
+	if err != nil {
+		jobError(w, err)
+		return
+	}
+	switch j.State {
+	case jobFailed:
+		http.Error(w, j.Error, http.StatusUnprocessableEntity)
+		return
+	case jobPending, jobRunning:
+		http.Error(w, "job is "+string(j.State), http.StatusConflict)
+		return
+	}
+	if j.Skipped > 0 {
+		w.Header().Set("Mangaconv-Skipped", strconv.Itoa(j.Skipped))
+	}
+	sendFile(w, r, j.Output)
+}
+
+// healthz responds to health checks, such as Docker's HEALTHCHECK. The server is healthy as long as
+// its job queue directory can be read.
+func (s *server) healthz(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodHead) {
+		return
+	}
+	if _, err := os.Stat(mangaconv.LongPath(s.queue.dir)); err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "unhealthy", "error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// allowMethods reports whether r uses one of methods, responding with an error if it doesn't.
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+// jobError responds with a job queue error.
+func jobError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errJobNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, errJobRunning):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, errUploadTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, errQueueFull):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case errors.Is(err, errClientQueueFull):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// writeJSON responds with v encoded as JSON.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// clientName returns the name jobs added by r are recorded with, the client's IP address.
+func clientName(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// splitFirst splits s around the first instance of sep, returning s and an empty string if there
+// is none.
+func splitFirst(s, sep string) (string, string) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):]
+	}
+	return s, ""
+}
+
+// saveUpload writes an uploaded input named name to dir and returns its path. Names are reduced to
+// a safe base name, with "input.cbz" used if there is none.
+func saveUpload(dir, name string, body io.Reader) (string, error) {
+	name = mangaconv.SafeName(filepath.Base(name), "_")
+	if name == "" || name == "." || name == ".." || name == "job.json" {
+		name = "input.cbz"
+	}
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		return "", fmt.Errorf("cannot read upload: %w", err)
+	}
+	return path, f.Close()
+}
+
+// sendFile responds with the file at path as an attachment named after it.
+func sendFile(w http.ResponseWriter, r *http.Request, path string) {
+	f, err := os.Open(mangaconv.LongPath(path))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	name := filepath.Base(path)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	http.ServeContent(w, r, name, fi.ModTime(), f)
+}
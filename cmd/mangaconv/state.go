@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/naisuuuu/mangaconv"
+)
+
+// Statuses of state entries.
+const (
+	statusConverted = "converted"
+	statusPartial   = "partial"
+	statusFailed    = "failed"
+)
+
+// state records inputs converted by earlier batch runs, so that unchanged inputs aren't converted
+// again and large collections can be inspected with the status command. It's stored as a JSON file,
+// replaced atomically on each save, and a journal next to it which each result is appended to in
+// between, so that recording a result doesn't rewrite the whole state.
+type state struct {
+	path    string
+	Entries map[string]*stateEntry `json:"entries"`
+	journal *os.File
+	pending int
+}
+
+// stateSaveEvery is the number of results appended to the journal after which the state is saved,
+// so that the journal of a large collection doesn't grow for the whole run.
+const stateSaveEvery = 256
+
+// journalEntry is a single line of the journal.
+type journalEntry struct {
+	Input string `json:"input"`
+	stateEntry
+}
+
+// stateEntry describes the last conversion of a single input.
+//
+// Size and ModTime are those of the input file, or the sum of sizes and latest modification time of
+// all files of an input directory. SHA256 is only computed again once either of them changes. Params
+// is a hash of the output profile flags the input was converted with.
+type stateEntry struct {
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"mod_time"`
+	SHA256    string    `json:"sha256"`
+	Params    string    `json:"params"`
+	Outputs   []string  `json:"outputs,omitempty"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Converted time.Time `json:"converted"`
+}
+
+// loadState reads the state file at path and replays its journal. A missing file is an empty
+// state.
+func loadState(path string) (*state, error) {
+	s := &state{path: path, Entries: make(map[string]*stateEntry)}
+	b, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("cannot read state: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(b, s); err != nil {
+			return nil, fmt.Errorf("cannot read state %s: %w", path, err)
+		}
+		if s.Entries == nil {
+			s.Entries = make(map[string]*stateEntry)
+		}
+	}
+	if err := s.replay(); err != nil {
+		return nil, fmt.Errorf("cannot read state journal: %w", err)
+	}
+	return s, nil
+}
+
+// journalPath returns the path of the journal of s.
+func (s *state) journalPath() string {
+	return s.path + ".journal"
+}
+
+// replay applies the results recorded in the journal to s. A truncated last line, left behind by an
+// interrupted run, is ignored.
+func (s *state) replay() error {
+	f, err := os.Open(s.journalPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var je journalEntry
+		if err := json.Unmarshal(line, &je); err != nil {
+			return err
+		}
+		e := je.stateEntry
+		s.Entries[je.Input] = &e
+	}
+}
+
+// save writes s to its file, through a temporary file so that an interrupted run never leaves a
+// truncated state behind, and removes the journal it now includes.
+func (s *state) save() error {
+	if s.journal != nil {
+		s.journal.Close()
+		s.journal = nil
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return fmt.Errorf("cannot write state: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("cannot write state: %w", err)
+	}
+	// Replaying a journal left behind by a failed removal is harmless, as it holds the same results.
+	if err := os.Remove(s.journalPath()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("cannot remove state journal: %w", err)
+	}
+	s.pending = 0
+	return nil
+}
+
+// current returns a fresh entry for the input at path, reusing the hash of its previous entry if its
+// size and modification time didn't change.
+func (s *state) current(path, params string) (*stateEntry, error) {
+	e := &stateEntry{Params: params}
+	var files []string
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		e.Size += info.Size()
+		if info.ModTime().After(e.ModTime) {
+			e.ModTime = info.ModTime()
+		}
+		files = append(files, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	e.ModTime = e.ModTime.UTC()
+
+	if old := s.Entries[path]; old != nil && old.Size == e.Size && old.ModTime.Equal(e.ModTime) {
+		e.SHA256 = old.SHA256
+		return e, nil
+	}
+	// WalkDir visits files in lexical order, so directory hashes don't depend on the file system.
+	h := sha256.New()
+	for _, f := range files {
+		rel, _ := filepath.Rel(path, f)
+		fmt.Fprintf(h, "%s\x00", filepath.ToSlash(rel))
+		if err := hashFile(h, f); err != nil {
+			return nil, err
+		}
+	}
+	e.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return e, nil
+}
+
+// hashFile writes the contents of the file at path to h.
+func hashFile(h io.Writer, path string) error {
+	f, err := os.Open(mangaconv.LongPath(path))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(h, f)
+	return err
+}
+
+// upToDate reports whether the input at path was converted from the same contents and with the
+// same params as described by e, and all its outputs still exist.
+func (s *state) upToDate(path string, e *stateEntry) bool {
+	old := s.Entries[path]
+	if old == nil || old.Status == statusFailed || old.SHA256 != e.SHA256 || old.Params != e.Params {
+		return false
+	}
+	for _, out := range old.Outputs {
+		if _, err := os.Stat(mangaconv.LongPath(out)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// record updates the entry of the converted target with e and the result r, and appends it to the
+// journal. Every stateSaveEvery results, s is saved.
+func (s *state) record(r mangaconv.Result, e *stateEntry) error {
+	e.Outputs, e.Status, e.Error, e.Converted = r.Outputs, statusConverted, "", time.Now().UTC()
+	var partial *mangaconv.PartialError
+	switch {
+	case errors.As(r.Err, &partial):
+		e.Status, e.Error = statusPartial, r.Err.Error()
+	case r.Err != nil:
+		e.Outputs, e.Status, e.Error = nil, statusFailed, r.Err.Error()
+	}
+	s.Entries[r.Target.In] = e
+
+	if s.journal == nil {
+		f, err := os.OpenFile(s.journalPath(), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("cannot write state journal: %w", err)
+		}
+		if err := truncatePartialLine(f); err != nil {
+			f.Close()
+			return fmt.Errorf("cannot write state journal: %w", err)
+		}
+		s.journal = f
+	}
+	b, err := json.Marshal(journalEntry{Input: r.Target.In, stateEntry: *e})
+	if err != nil {
+		return err
+	}
+	if _, err := s.journal.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("cannot write state journal: %w", err)
+	}
+	if s.pending++; s.pending >= stateSaveEvery {
+		return s.save()
+	}
+	return nil
+}
+
+// truncatePartialLine removes a last line not ending in a newline from the journal f, left behind by
+// an interrupted run, so that results appended to it start on a line of their own instead of being
+// merged with it. replay ignores such a line anyway.
+func truncatePartialLine(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	end := info.Size()
+	buf := make([]byte, 4096)
+	for end > 0 {
+		n := int64(len(buf))
+		if n > end {
+			n = end
+		}
+		if _, err := f.ReadAt(buf[:n], end-n); err != nil {
+			return err
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			end += int64(i) + 1 - n
+			break
+		}
+		end -= n
+	}
+	if end == info.Size() {
+		return nil
+	}
+	return f.Truncate(end)
+}
+
+// paramsHash returns a hash of the values of all output profile flags in flags, which changes
+// whenever inputs would be converted differently.
+func paramsHash(flags *flag.FlagSet) string {
+	profileFlags := flag.NewFlagSet("", flag.ContinueOnError)
+	newOptions(profileFlags)
+	h := sha256.New()
+	profileFlags.VisitAll(func(f *flag.Flag) {
+		fmt.Fprintf(h, "%s=%s\n", f.Name, flags.Lookup(f.Name).Value)
+	})
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// runStatus implements the status command.
+func runStatus(args []string) error {
+	flags := newFlagSet("status")
+	path := flags.String("state", "", "State file written by the batch command.")
+	onlyFailed := flags.Bool("failed", false, "Only list inputs which failed or were converted with errors.")
+	if err := parse(flags, args); err != nil {
+		return err
+	}
+	if *path == "" {
+		return fmt.Errorf("%w for state: a state file is required", errInvalidValue)
+	}
+	s, err := loadState(*path)
+	if err != nil {
+		return err
+	}
+
+	inputs := make([]string, 0, len(s.Entries))
+	counts := make(map[string]int)
+	for in, e := range s.Entries {
+		inputs = append(inputs, in)
+		counts[e.Status]++
+	}
+	sort.Strings(inputs)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INPUT\tSTATUS\tCONVERTED\tOUTPUTS\tERROR")
+	for _, in := range inputs {
+		e := s.Entries[in]
+		if *onlyFailed && e.Status == statusConverted {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n",
+			in, e.Status, e.Converted.Local().Format("2006-01-02 15:04"), len(e.Outputs), e.Error)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d inputs: %d converted, %d with errors, %d failed\n",
+		len(inputs), counts[statusConverted], counts[statusPartial], counts[statusFailed])
+	return nil
+}
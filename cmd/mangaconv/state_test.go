@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/naisuuuu/mangaconv"
+)
+
+func TestStateJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	st, err := loadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := []mangaconv.Result{
+		{Target: mangaconv.Target{In: "a.zip"}, Outputs: []string{"a.cbz"}},
+		{Target: mangaconv.Target{In: "b.zip"}, Err: errors.New("broken")},
+		{Target: mangaconv.Target{In: "a.zip"}, Outputs: []string{"a2.cbz"}},
+	}
+	for _, r := range results {
+		if err := st.record(r, &stateEntry{SHA256: r.Target.In}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// An interrupted run may leave a truncated line behind.
+	f, err := os.OpenFile(path+".journal", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"input":"c.zip","sta`)
+	f.Close()
+
+	summary := func(st *state) map[string]string {
+		got := make(map[string]string)
+		for in, e := range st.Entries {
+			got[in] = e.Status + " " + e.SHA256
+			for _, out := range e.Outputs {
+				got[in] += " " + out
+			}
+		}
+		return got
+	}
+	want := map[string]string{
+		"a.zip": "converted a.zip a2.cbz",
+		"b.zip": "failed b.zip",
+	}
+
+	replayed, err := loadState(path)
+	if err != nil {
+		t.Fatalf("loadState() error %v", err)
+	}
+	if diff := cmp.Diff(want, summary(replayed)); diff != "" {
+		t.Errorf("replayed state mismatch (-want +got):\n%s", diff)
+	}
+
+	// The next run appends to the journal after the truncated line.
+	next := mangaconv.Result{Target: mangaconv.Target{In: "d.zip"}, Outputs: []string{"d.cbz"}}
+	if err := replayed.record(next, &stateEntry{SHA256: "d.zip"}); err != nil {
+		t.Fatal(err)
+	}
+	replayed.journal.Close()
+	appended, err := loadState(path)
+	if err != nil {
+		t.Fatalf("loadState() after append error %v", err)
+	}
+	wantAppended := map[string]string{"d.zip": "converted d.zip d.cbz"}
+	for in, e := range want {
+		wantAppended[in] = e
+	}
+	if diff := cmp.Diff(wantAppended, summary(appended)); diff != "" {
+		t.Errorf("appended state mismatch (-want +got):\n%s", diff)
+	}
+
+	if err := st.save(); err != nil {
+		t.Fatalf("save() error %v", err)
+	}
+	if _, err := os.Stat(path + ".journal"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("journal not removed after save: %v", err)
+	}
+	saved, err := loadState(path)
+	if err != nil {
+		t.Fatalf("loadState() error %v", err)
+	}
+	if diff := cmp.Diff(want, summary(saved)); diff != "" {
+		t.Errorf("saved state mismatch (-want +got):\n%s", diff)
+	}
+}
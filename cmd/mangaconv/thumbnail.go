@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+
+	"github.com/naisuuuu/mangaconv"
+)
+
+// errThumbnail stops the conversion once the cover is converted.
+var errThumbnail = errors.New("cover converted")
+
+// runThumbnail implements the thumbnail command.
+func runThumbnail(args []string) error {
+	fs := newFlagSet("thumbnail")
+	opts := newOptions(fs)
+	size := fs.String("size", "300x450", `Size of the thumbnail, as width x height.
+The cover is cropped to this aspect ratio around its most detailed area.`)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("thumbnail needs an input and an output")
+	}
+	var w, h int
+	if _, err := fmt.Sscanf(*size, "%dx%d", &w, &h); err != nil || w <= 0 || h <= 0 {
+		return fmt.Errorf("%w for size: %s", errInvalidValue, *size)
+	}
+	p, err := opts.profile()
+	if err != nil {
+		return err
+	}
+	in, out := fs.Arg(0), fs.Arg(1)
+
+	var cover image.Image
+	err = mangaconv.ConvertMulti(in, mangaconv.Output{Converter: p.converter, Pages: func(pg mangaconv.Page) error {
+		cover = pg.Image
+		return errThumbnail
+	}})
+	if err != nil && !errors.Is(err, errThumbnail) {
+		return err
+	}
+	if cover == nil {
+		return fmt.Errorf("%s has no pages", in)
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := jpeg.Encode(f, p.converter.Thumbnail(cover, w, h), &jpeg.Options{Quality: 90}); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot write %s: %w", out, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/naisuuuu/mangaconv"
+)
+
+// Pages taking more than slowPageFactor times the median page time of their output are reported as
+// slow, up to maxSlowPages of them per output.
+const (
+	slowPageFactor = 3
+	maxSlowPages   = 10
+)
+
+// timingOutput identifies the i-th output of an input.
+type timingOutput struct {
+	in string
+	i  int
+}
+
+// timingReport collects page timings of all outputs of a run.
+type timingReport struct {
+	mu    sync.Mutex
+	pages map[timingOutput][]mangaconv.PageTimings
+}
+
+func newTimingReport() *timingReport {
+	return &timingReport{pages: make(map[timingOutput][]mangaconv.PageTimings)}
+}
+
+// add records the timings of a page written to the i-th output of t. It's safe for concurrent use.
+func (r *timingReport) add(t mangaconv.Target, i int, pt mangaconv.PageTimings) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := timingOutput{t.In, i}
+	r.pages[key] = append(r.pages[key], pt)
+}
+
+// print writes the time spent in each stage by each output to w, followed by its slowest pages.
+func (r *timingReport) print(w io.Writer, profiles int) {
+	keys := make([]timingOutput, 0, len(r.pages))
+	for k := range r.pages {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].in != keys[j].in {
+			return keys[i].in < keys[j].in
+		}
+		return keys[i].i < keys[j].i
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, k := range keys {
+		pages := r.pages[k]
+		name := filepath.Base(k.in)
+		if profiles > 1 {
+			name = fmt.Sprintf("%s (profile %d)", name, k.i)
+		}
+		var sum mangaconv.PageTimings
+		for _, pt := range pages {
+			sum.Decode += pt.Decode
+			sum.Grayscale += pt.Grayscale
+			sum.Scale += pt.Scale
+			sum.Contrast += pt.Contrast
+			sum.Encode += pt.Encode
+		}
+		fmt.Fprintf(tw, "Timings of %s, %d pages taking %v:\n", name, len(pages), round(sum.Total()))
+		for _, s := range stages(sum) {
+			fmt.Fprintf(tw, "  %s\t%v\t%.0f%%\n", s.name, round(s.d), 100*s.d.Seconds()/sum.Total().Seconds())
+		}
+
+		sort.Slice(pages, func(i, j int) bool { return pages[i].Total() > pages[j].Total() })
+		median := pages[len(pages)/2].Total()
+		var slow []mangaconv.PageTimings
+		for _, pt := range pages {
+			if pt.Total() <= slowPageFactor*median || len(slow) == maxSlowPages {
+				break
+			}
+			slow = append(slow, pt)
+		}
+		if len(slow) == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "  Slow pages, taking over %dx the median of %v:\n", slowPageFactor, round(median))
+		for _, pt := range slow {
+			top := stages(pt)[0]
+			for _, s := range stages(pt) {
+				if s.d > top.d {
+					top = s
+				}
+			}
+			fmt.Fprintf(tw, "    %d\t%s\t%v\tmostly %s\n", pt.Index+1, pt.Source, round(pt.Total()), top.name)
+		}
+	}
+	tw.Flush()
+}
+
+// stage is the time spent in a single conversion stage.
+type stage struct {
+	name string
+	d    time.Duration
+}
+
+// stages returns the stages of pt in pipeline order.
+func stages(pt mangaconv.PageTimings) []stage {
+	return []stage{
+		{"decode", pt.Decode},
+		{"grayscale", pt.Grayscale},
+		{"scale", pt.Scale},
+		{"contrast", pt.Contrast},
+		{"encode", pt.Encode},
+	}
+}
+
+// round rounds d for display.
+func round(d time.Duration) time.Duration {
+	if d < time.Second {
+		return d.Round(100 * time.Microsecond)
+	}
+	return d.Round(time.Millisecond)
+}
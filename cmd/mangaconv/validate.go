@@ -0,0 +1,38 @@
+package main
+
+import "fmt"
+
+// runValidate implements the validate command.
+func runValidate(args []string) error {
+	fs := newFlagSet("validate")
+	opts := newOptions(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	p, err := opts.profile()
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, in := range fs.Args() {
+		problems, err := p.converter.Validate(in)
+		if err != nil {
+			fmt.Printf("%s: %v\n", in, err)
+			failed++
+			continue
+		}
+		if len(problems) == 0 {
+			fmt.Printf("%s: ok\n", in)
+			continue
+		}
+		failed++
+		for _, pr := range problems {
+			fmt.Printf("%s: %s\n", in, pr)
+		}
+	}
+	if failed > 0 {
+		return &exitError{code: exitFailed, err: fmt.Errorf("%d of %d archives failed validation", failed, fs.NArg())}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/naisuuuu/mangaconv/accel"
+)
+
+var (
+	version = "dev"
+	commit  = "none"
+	date    = "unknown"
+)
+
+// features lists optional features compiled into this build: acceleration backends behind build
+// tags.
+func features() []string {
+	var f []string
+	for _, name := range accel.Available() {
+		if name != "cpu" {
+			f = append(f, "accel-"+name)
+		}
+	}
+	return f
+}
+
+// versionInfo describes this build of mangaconv. Values not set at link time are taken from the
+// build info embedded by the go command, if available.
+func versionInfo() string {
+	ver, rev, goVersion, cgo := version, commit, runtime.Version(), "unknown"
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if ver == "dev" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			// Installed with go install module@version.
+			ver = bi.Main.Version
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if rev == "none" {
+					rev = s.Value
+				}
+			case "vcs.modified":
+				if s.Value == "true" && commit == "none" {
+					rev += "-dirty"
+				}
+			case "CGO_ENABLED":
+				cgo = s.Value
+			}
+		}
+	}
+	feat := "none"
+	if f := features(); len(f) > 0 {
+		feat = strings.Join(f, ", ")
+	}
+	return fmt.Sprintf("mangaconv version %s, built at %s\ncommit: %s\ngo: %s %s/%s, cgo: %s\nfeatures: %s\n",
+		ver, date, rev, goVersion, runtime.GOOS, runtime.GOARCH, cgo, feat)
+}
@@ -0,0 +1,89 @@
+package mangaconv
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"time"
+
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+// finishColor scales a page kept in color and applies brightness, contrast and gamma adjustments,
+// margins, page numbers and the watermark to it, like finish does for grayscale pages, recording
+// timings in t like finish. src is left untouched.
+func (c *Converter) finishColor(src image.Image, index int, t *PageTimings) *image.RGBA {
+	return c.finishColorBand(src, c.pageBand(src.Bounds(), 0, 1), index, t)
+}
+
+// finishColorBand is finishColor for band b of a page kept in color, of which src is the part
+// b.src, placing margins, the page number and the watermark like finishBand.
+func (c *Converter) finishColorBand(src image.Image, b pageBand, index int, t *PageTimings) *image.RGBA {
+	start := time.Now()
+	// Each channel is scaled on its own like a grayscale page, with the Converter's scaler and
+	// buffers taken from its pool.
+	sb := src.Bounds()
+	var planes [3]*image.Gray
+	for i := range planes {
+		planes[i] = c.pool.Get(sb.Dx(), sb.Dy())
+	}
+	imgutil.SplitRGB(planes[0], planes[1], planes[2], src)
+	scaled := c.pool.Get(b.scaled.Dx(), b.scaled.Dy())
+
+	dst := image.NewRGBA(b.bounds)
+	rows := b.rows.Add(b.off)
+	if rows != dst.Rect {
+		fill := image.NewUniform(color.Gray{Y: c.params.MarginColor})
+		draw.Draw(dst, dst.Rect, fill, image.Point{}, draw.Src)
+	}
+	var adjust time.Duration
+	for ch, plane := range planes {
+		c.scaler.Scale(scaled, plane)
+		c.pool.Put(plane)
+		adjustStart := time.Now()
+		for y := rows.Min.Y; y < rows.Max.Y; y++ {
+			i := dst.PixOffset(rows.Min.X, y)
+			o := scaled.PixOffset(0, y-b.off.Y-b.scaled.Min.Y)
+			for _, v := range scaled.Pix[o : o+rows.Dx()] {
+				dst.Pix[i+ch] = c.adjust[v]
+				dst.Pix[i+3] = 0xff
+				i += 4
+			}
+		}
+		adjust += time.Since(adjustStart)
+	}
+	c.pool.Put(scaled)
+	t.Scale, t.Contrast = time.Since(start)-adjust, adjust
+
+	if c.params.PageNumbers != CornerNone {
+		drawPageNumber(dst, b.page, index+1, c.params.PageNumbers, c.params.PageNumberSize)
+	}
+	if c.watermark != nil {
+		r := c.watermarkRect(b.page)
+		draw.DrawMask(dst, r, c.watermark, image.Point{}, c.colorWatermarkMask(), image.Point{}, draw.Over)
+	}
+	// Encoders expect bounds starting at the origin.
+	dst.Rect = dst.Rect.Sub(dst.Rect.Min)
+	return dst
+}
+
+// colorWatermarkMask returns the watermark's mask with WatermarkOpacity applied, for drawing it
+// onto color pages with image/draw.
+func (c *Converter) colorWatermarkMask() *image.Alpha {
+	mask := image.NewAlpha(c.watermark.Rect)
+	opacity := c.params.WatermarkOpacity
+	if opacity < 0 {
+		opacity = 0
+	} else if opacity > 1 {
+		opacity = 1
+	}
+	a := uint32(opacity*0xff + 0.5)
+	for i := range mask.Pix {
+		alpha := a
+		if c.watermarkMask != nil {
+			alpha = alpha * uint32(c.watermarkMask.Pix[i]) / 0xff
+		}
+		mask.Pix[i] = uint8(alpha)
+	}
+	return mask
+}
@@ -0,0 +1,95 @@
+package mangaconv
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// comicInfo is a subset of the ComicRack ComicInfo.xml schema understood by most readers and
+// library servers. See https://anansi-project.github.io/docs/comicinfo/intro.
+type comicInfo struct {
+	XMLName   xml.Name    `xml:"ComicInfo"`
+	XMLNSXSI  string      `xml:"xmlns:xsi,attr"`
+	XMLNSXSD  string      `xml:"xmlns:xsd,attr"`
+	Series    string      `xml:"Series,omitempty"`
+	Number    string      `xml:"Number,omitempty"`
+	Volume    int         `xml:"Volume,omitempty"`
+	Summary   string      `xml:"Summary,omitempty"`
+	Year      int         `xml:"Year,omitempty"`
+	Writer    string      `xml:"Writer,omitempty"`
+	Genre     string      `xml:"Genre,omitempty"`
+	Web       string      `xml:"Web,omitempty"`
+	Manga     string      `xml:"Manga,omitempty"`
+	PageCount int         `xml:"PageCount,omitempty"`
+	Pages     []comicPage `xml:"Pages>Page,omitempty"`
+}
+
+// comicPage describes a single page in ComicInfo.xml. Library servers such as Komga and Kavita use
+// image sizes to lay out pages without reading them.
+type comicPage struct {
+	Image       int    `xml:"Image,attr"`
+	Type        string `xml:"Type,attr,omitempty"`
+	DoublePage  bool   `xml:"DoublePage,attr,omitempty"`
+	ImageSize   int64  `xml:"ImageSize,attr,omitempty"`
+	ImageWidth  int    `xml:"ImageWidth,attr,omitempty"`
+	ImageHeight int    `xml:"ImageHeight,attr,omitempty"`
+	Bookmark    string `xml:"Bookmark,attr,omitempty"`
+}
+
+// newComicInfo creates a comicInfo from metadata and information about each written page.
+func newComicInfo(m Metadata, pages []pageInfo) *comicInfo {
+	// Volume is an integer in the schema. Fractional or malformed volumes are dropped.
+	vol, _ := strconv.Atoi(m.Volume)
+
+	cp := make([]comicPage, len(pages))
+	prev := ""
+	for i, p := range pages {
+		cp[i] = comicPage{
+			Image:       i,
+			ImageSize:   p.Size,
+			ImageWidth:  p.Width,
+			ImageHeight: p.Height,
+		}
+		switch {
+		case p.Credits:
+			cp[i].Type = "Other"
+		case i == 0:
+			cp[i].Type = "FrontCover"
+		}
+		// Bookmark the first page of each chapter, unless the page has its own bookmark.
+		switch {
+		case p.Bookmark != "":
+			cp[i].Bookmark = p.Bookmark
+		case p.Chapter != "" && p.Chapter != prev:
+			cp[i].Bookmark = p.Chapter
+		}
+		prev = p.Chapter
+	}
+
+	return &comicInfo{
+		XMLNSXSI:  "http://www.w3.org/2001/XMLSchema-instance",
+		XMLNSXSD:  "http://www.w3.org/2001/XMLSchema",
+		Series:    m.Series,
+		Number:    m.Chapter,
+		Volume:    vol,
+		Summary:   m.Summary,
+		Year:      m.Year,
+		Writer:    m.Writer,
+		Genre:     strings.Join(m.Genres, ", "),
+		Web:       m.Web,
+		PageCount: len(pages),
+		Pages:     cp,
+	}
+}
+
+// encode writes ComicInfo.xml contents to w.
+func (ci *comicInfo) encode(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(ci)
+}
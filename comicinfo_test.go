@@ -0,0 +1,64 @@
+package mangaconv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestComicInfoEncode(t *testing.T) {
+	pages := []pageInfo{
+		{Index: 0, Size: 1000, Width: 800, Height: 1200},
+		{Index: 1, Chapter: "c01", Size: 2000, Width: 800, Height: 1200},
+		{Index: 2, Chapter: "c01", Size: 3000, Width: 1200, Height: 800, Bookmark: "Extra"},
+	}
+	var b strings.Builder
+	if err := newComicInfo(Metadata{Series: "Series", Volume: "03"}, pages).encode(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<ComicInfo xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:xsd="http://www.w3.org/2001/XMLSchema">
+  <Series>Series</Series>
+  <Volume>3</Volume>
+  <PageCount>3</PageCount>
+  <Pages>
+    <Page Image="0" Type="FrontCover" ImageSize="1000" ImageWidth="800" ImageHeight="1200"></Page>
+    <Page Image="1" ImageSize="2000" ImageWidth="800" ImageHeight="1200" Bookmark="c01"></Page>
+    <Page Image="2" ImageSize="3000" ImageWidth="1200" ImageHeight="800" Bookmark="Extra"></Page>
+  </Pages>
+</ComicInfo>`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("encode() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestComicInfoEncodeSeries(t *testing.T) {
+	m := Metadata{
+		Series:  "Series",
+		Chapter: "7",
+		Summary: "A series.",
+		Year:    2019,
+		Writer:  "Author",
+		Genres:  []string{"Action", "Drama"},
+		Web:     "https://anilist.co/manga/1",
+	}
+	var b strings.Builder
+	if err := newComicInfo(m, nil).encode(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<ComicInfo xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:xsd="http://www.w3.org/2001/XMLSchema">
+  <Series>Series</Series>
+  <Number>7</Number>
+  <Summary>A series.</Summary>
+  <Year>2019</Year>
+  <Writer>Author</Writer>
+  <Genre>Action, Drama</Genre>
+  <Web>https://anilist.co/manga/1</Web>
+  <Pages></Pages>
+</ComicInfo>`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("encode() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,97 @@
+package mangaconv
+
+import (
+	"context"
+	"image"
+	"strings"
+
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+// OCREngine recognizes text in page images, such as a wrapper around Tesseract. Recognize returns
+// all text found in img, in any layout. It may be called concurrently.
+type OCREngine interface {
+	Recognize(ctx context.Context, img image.Image) (string, error)
+}
+
+// CreditsPolicy controls what happens to credits pages, such as scanlation group credits and
+// recruitment pages, as detected by Params.OCR.
+type CreditsPolicy int
+
+const (
+	// CreditsKeep converts credits pages like any other page, without running OCR.
+	CreditsKeep CreditsPolicy = iota
+	// CreditsTag converts credits pages, but marks them with the "Other" page type in
+	// ComicInfo.xml and Page.Credits.
+	CreditsTag
+	// CreditsDrop leaves credits pages out of the output.
+	CreditsDrop
+)
+
+// DefaultCreditKeywords are the keywords used when Params.CreditKeywords is empty. They are
+// matched case-insensitively as substrings, so "translat" matches both "translator" and
+// "translation".
+var DefaultCreditKeywords = []string{
+	"scanlat",
+	"translat",
+	"typeset",
+	"proofread",
+	"redraw",
+	"cleaner",
+	"raws",
+	"recruit",
+	"join us",
+	"discord",
+	"patreon",
+	"ko-fi",
+}
+
+// creditsMinKeywords is the number of distinct keywords a page must contain to be a credits page.
+// A single keyword is too easily found in dialogue or misrecognized art.
+const creditsMinKeywords = 2
+
+// ocrHeight is the height pages taller than it are scaled down to before OCR. Lettering stays
+// large enough to be recognized, while OCR of large scans takes much less time and memory.
+const ocrHeight = 1600
+
+// isCredits reports whether img is a credits page. OCR runs on a grayscale copy of img scaled down
+// to ocrHeight, if it's taller.
+func (c *Converter) isCredits(ctx context.Context, img image.Image) (bool, error) {
+	if c.params.Credits == CreditsKeep || c.params.OCR == nil {
+		return false, nil
+	}
+	if b := img.Bounds(); b.Dy() > ocrHeight {
+		src, view := imgutil.Luma(img)
+		if !view {
+			src = c.pool.GetFromImage(img)
+			defer c.pool.Put(src)
+		}
+		r := imgutil.FitHeight(b, ocrHeight)
+		small := c.pool.Get(r.Dx(), r.Dy())
+		defer c.pool.Put(small)
+		c.scaler.Scale(small, src)
+		img = small
+	}
+	text, err := c.params.OCR.Recognize(ctx, img)
+	if err != nil {
+		return false, err
+	}
+	keywords := c.params.CreditKeywords
+	if len(keywords) == 0 {
+		keywords = DefaultCreditKeywords
+	}
+	return matchKeywords(text, keywords) >= creditsMinKeywords, nil
+}
+
+// matchKeywords returns the number of keywords found in text, ignoring case. Runs of whitespace in
+// text match a single space, as OCR often breaks lines in the middle of phrases.
+func matchKeywords(text string, keywords []string) int {
+	text = strings.ToLower(strings.Join(strings.Fields(text), " "))
+	n := 0
+	for _, k := range keywords {
+		if k != "" && strings.Contains(text, strings.ToLower(k)) {
+			n++
+		}
+	}
+	return n
+}
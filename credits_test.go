@@ -0,0 +1,191 @@
+package mangaconv
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"image"
+	"io"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/naisuuuu/mangaconv/imgtest"
+)
+
+// fakeOCR recognizes credits text in a single image and no text elsewhere.
+type fakeOCR struct {
+	credits image.Image
+}
+
+func (o fakeOCR) Recognize(_ context.Context, img image.Image) (string, error) {
+	if reflect.DeepEqual(img, o.credits) {
+		return "Translation: someone\nTypeset-\nting: someone else", nil
+	}
+	return "", nil
+}
+
+func TestMatchKeywords(t *testing.T) {
+	tests := []struct {
+		text     string
+		keywords []string
+		want     int
+	}{
+		{"", DefaultCreditKeywords, 0},
+		{"TRANSLATOR: a\nPROOFREADER: b", DefaultCreditKeywords, 2},
+		{"join\n  us on discord", DefaultCreditKeywords, 2},
+		{"the raws are late", []string{"raws", "", "late"}, 2},
+	}
+	for _, tt := range tests {
+		if got := matchKeywords(tt.text, tt.keywords); got != tt.want {
+			t.Errorf("matchKeywords(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCredits(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.zip")
+	mustWriteZip(t, in, map[string]string{
+		"0.png": "testdata/wikipe-tan-0.png",
+		"1.png": "testdata/wikipe-tan-1.png",
+		"2.png": "testdata/wikipe-tan-0.png",
+	})
+	ocr := fakeOCR{imgtest.MustRead("testdata/wikipe-tan-1.png")}
+
+	tests := []struct {
+		name      string
+		policy    CreditsPolicy
+		wantPages []string
+		wantTypes []string
+	}{
+		{
+			name:      "keep",
+			policy:    CreditsKeep,
+			wantPages: []string{"000000000.jpg", "000000001.jpg", "000000002.jpg"},
+			wantTypes: []string{"FrontCover", "", ""},
+		},
+		{
+			name:      "tag",
+			policy:    CreditsTag,
+			wantPages: []string{"000000000.jpg", "000000001.jpg", "000000002.jpg"},
+			wantTypes: []string{"FrontCover", "Other", ""},
+		},
+		{
+			name:      "drop",
+			policy:    CreditsDrop,
+			wantPages: []string{"000000000.jpg", "000000002.jpg"},
+			wantTypes: []string{"FrontCover", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(dir, tt.name+".cbz")
+			p := Params{AutoContrast: true, Cutoff: 1, Gamma: 1, Width: 50, Height: 50,
+				ComicInfo: true, Credits: tt.policy, OCR: ocr}
+			if err := New(p).Convert(in, out); err != nil {
+				t.Fatalf("Convert() error %v", err)
+			}
+
+			r, err := zip.OpenReader(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			var pages []string
+			var ci comicInfo
+			for _, f := range r.File {
+				if f.Name != "ComicInfo.xml" {
+					pages = append(pages, f.Name)
+					continue
+				}
+				if err := decodeEntry(f, func(r io.Reader) error { return xml.NewDecoder(r).Decode(&ci) }); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var types []string
+			for _, p := range ci.Pages {
+				types = append(types, p.Type)
+			}
+			if diff := cmp.Diff(tt.wantPages, pages); diff != "" {
+				t.Errorf("pages mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantTypes, types); diff != "" {
+				t.Errorf("page types mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// failingOCR fails to recognize any text, recording the largest image height it was given.
+type failingOCR struct {
+	mu     *sync.Mutex
+	height *int
+}
+
+var errOCR = errors.New("ocr failed")
+
+func (o failingOCR) Recognize(_ context.Context, img image.Image) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if h := img.Bounds().Dy(); h > *o.height {
+		*o.height = h
+	}
+	return "", errOCR
+}
+
+func TestCreditsOCRErrors(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.zip")
+	mustWriteZip(t, in, map[string]string{
+		"0.png": "testdata/wikipe-tan-0.png",
+		"1.png": "testdata/wikipe-tan-1.png",
+	})
+
+	tests := []struct {
+		name        string
+		errors      ErrorPolicy
+		wantPartial bool
+	}{
+		{"abort", ErrorsAbort, false},
+		{"skip", ErrorsSkip, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var height int
+			p := Params{AutoContrast: true, Cutoff: 1, Gamma: 1, Width: 50, Height: 50,
+				Credits: CreditsDrop, Errors: tt.errors, OCR: failingOCR{&sync.Mutex{}, &height}}
+			err := New(p).Convert(in, filepath.Join(dir, tt.name+".cbz"))
+			var partial *PartialError
+			if !tt.wantPartial {
+				if errors.As(err, &partial) || !errors.Is(err, errOCR) {
+					t.Fatalf("Convert() error %v, want %v", err, errOCR)
+				}
+				return
+			}
+			if !errors.As(err, &partial) || len(partial.Pages) != 2 {
+				t.Fatalf("Convert() error %v, want both pages listed", err)
+			}
+			for _, pe := range partial.Pages {
+				if !errors.Is(pe, errOCR) {
+					t.Errorf("page %d error %v, want %v", pe.Index, pe.Err, errOCR)
+				}
+			}
+		})
+	}
+}
+
+func TestCreditsDownscale(t *testing.T) {
+	var height int
+	c := New(Params{Credits: CreditsTag, OCR: failingOCR{&sync.Mutex{}, &height}})
+	if _, err := c.isCredits(context.Background(), image.NewGray(image.Rect(0, 0, 1000, 2*ocrHeight))); err == nil {
+		t.Fatal("isCredits() error nil, want OCR error")
+	}
+	if height != ocrHeight {
+		t.Errorf("OCR image height = %d, want %d", height, ocrHeight)
+	}
+}
@@ -1,44 +1,224 @@
 package mangaconv
 
 import (
+	"archive/zip"
+	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"image"
 	"io"
-	"runtime"
+	"syscall"
+	"time"
 
 	// This adds webp support.
 	_ "golang.org/x/image/webp"
-	"golang.org/x/sync/errgroup"
 )
 
-// decode reads a channel of raw pages and emits decoded pages.
-func decode(ctx context.Context, pages chan<- page, raws <-chan rawPage) error {
-	errg, ctx := errgroup.WithContext(ctx)
-	for i := 0; i < runtime.NumCPU(); i++ {
-		errg.Go(func() error {
-			for raw := range raws {
-				img, err := decodeImage(raw.File)
-				if err != nil {
-					return fmt.Errorf("cannot decode image number %d: %w", raw.Index, err)
-				}
-				select {
-				case pages <- page{img, raw.Index}:
-				case <-ctx.Done():
-					return ctx.Err()
-				}
+// ErrCorruptEntry is returned when an input file is truncated or fails checksum verification.
+var ErrCorruptEntry = errors.New("corrupt entry")
+
+// ErrImageTooLarge is returned for images with more than maxImagePixels pixels, which would take
+// too much memory to decode.
+var ErrImageTooLarge = errors.New("image too large")
+
+// maxImagePixels is the maximum number of pixels of an input image, such as 16384x16384. Image
+// headers are checked before decoding, so that a few bytes from a malicious archive can't make
+// mangaconv allocate gigabytes.
+const maxImagePixels = 1 << 28
+
+// headerSize is the number of bytes of a page read before its header is checked. It holds the
+// header of all supported formats, except JPEGs with large metadata, which are read in full.
+const headerSize = 64 << 10
+
+// decode reads a channel of raw pages and emits decoded pages in the same order.
+func (c *Converter) decode(ctx context.Context, pages chan<- page, raws <-chan rawPage) error {
+	return ordered(ctx, c.workers(), pages, func() (job, bool) {
+		raw, ok := <-raws
+		return func() ([]page, error) { return c.decodePage(ctx, raw) }, ok
+	})
+}
+
+// decodePage decodes a raw page, returning no pages if it's skipped.
+func (c *Converter) decodePage(ctx context.Context, raw rawPage) ([]page, error) {
+	img := raw.Image
+	var timings PageTimings
+	var progressive bool
+	if img == nil {
+		if err := c.acquire(ctx); err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		var err error
+		img, progressive, err = c.decodeRaw(ctx, raw)
+		timings.Decode = time.Since(start)
+		c.release()
+		if err != nil {
+			if skipPage(ctx, raw, err) {
+				return nil, nil
 			}
-			return nil
-		})
+			return nil, fmt.Errorf("cannot decode image number %d: %w", raw.Index, err)
+		}
+		if img == nil {
+			return nil, nil
+		}
+	}
+	return []page{{
+		Image:       img,
+		Index:       raw.Index,
+		Chapter:     raw.Chapter,
+		Name:        raw.Name,
+		Source:      raw.Source,
+		Timings:     timings,
+		Progressive: progressive,
+	}}, nil
+}
+
+// decodeRaw opens and decodes a raw page, and reports whether it's a progressive JPEG or an
+// interlaced PNG. It returns a nil image if the page is skipped.
+func (c *Converter) decodeRaw(ctx context.Context, raw rawPage) (image.Image, bool, error) {
+	data, err := c.readRaw(ctx, raw)
+	if err != nil {
+		return nil, false, err
+	}
+	var img image.Image
+	var progressive bool
+	if data != nil {
+		progressive = isProgressive(data)
+		img, err = decodeImage(data, c.keepPage)
+	}
+	if err != nil || raw.Join == nil {
+		return img, progressive, err
+	}
+	second, secondProgressive, err := c.decodeRaw(ctx, *raw.Join)
+	switch {
+	case err != nil:
+		return nil, false, err
+	case img == nil:
+		return second, secondProgressive, nil
+	case second == nil:
+		return img, progressive, nil
+	}
+	if _, firstLeft := spreadHalves(raw.Name, raw.Join.Name, c.params.LeftToRight); !firstLeft {
+		img, second = second, img
+	}
+	return joinImages(img, second), progressive || secondProgressive, nil
+}
+
+// readRaw reads the whole file of a raw page, or nil if the page is skipped, retrying transient
+// errors up to ReadRetries times.
+func (c *Converter) readRaw(ctx context.Context, raw rawPage) ([]byte, error) {
+	delay := c.params.RetryDelay
+	for attempt := 0; ; attempt++ {
+		data, err := c.readRawOnce(ctx, raw)
+		if err == nil || attempt >= c.params.ReadRetries || !isTransient(err) {
+			return data, err
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		delay *= 2
 	}
-	return errg.Wait()
 }
 
-func decodeImage(f io.ReadCloser) (image.Image, error) {
+// readRawOnce reads the whole file of a raw page, keeping at most MaxOpenFiles files open at once.
+// The page's header is checked first, so that pages which are skipped or too large to decode aren't
+// read in full. Such pages return nil data.
+func (c *Converter) readRawOnce(ctx context.Context, raw rawPage) ([]byte, error) {
+	if c.files != nil {
+		select {
+		case c.files <- struct{}{}:
+			defer func() { <-c.files }()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	f, err := raw.Open()
+	if err != nil {
+		return nil, err
+	}
 	defer f.Close()
-	img, _, err := image.Decode(f)
+	br := bufio.NewReaderSize(f, headerSize)
+	// Read errors are returned by br again when reading the rest of the file.
+	header, _ := br.Peek(headerSize)
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(header)); err == nil {
+		if err := checkConfig(cfg); err != nil {
+			return nil, err
+		}
+		if !c.keepPage(cfg) {
+			return nil, nil
+		}
+	}
+	data, err := io.ReadAll(br)
+	// Zip entries are read to the end, which verifies their CRC-32.
+	if errors.Is(err, zip.ErrChecksum) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptEntry, err)
+	}
+	return data, err
+}
+
+// isTransient reports whether a read error may go away when retried: timeouts, interrupted or
+// would-block reads, I/O and stale handle errors and truncated reads, as seen on network mounts
+// dropping a connection. All other errors, such as missing files, corrupt entries and canceled or
+// expired contexts, are permanent.
+func isTransient(err error) bool {
+	if errors.Is(err, ErrCorruptEntry) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var timeout interface{ Timeout() bool }
+	if errors.As(err, &timeout) && timeout.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.EAGAIN) ||
+		errors.Is(err, syscall.EINTR) ||
+		errors.Is(err, syscall.EIO) ||
+		errors.Is(err, syscall.ESTALE) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// decodeImage decodes an image's header first. Only if keep returns true for the image's config is
+// the rest of the image decoded. Otherwise, decodeImage returns a nil image.
+//
+// Image decoders panicking on malformed data are recovered from, returning an error wrapping
+// ErrCorruptEntry, so that such pages are handled by the Errors policy like any other corrupt page.
+func decodeImage(data []byte, keep func(image.Config) bool) (img image.Image, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			img, err = nil, fmt.Errorf("%w: decoder panic: %v", ErrCorruptEntry, r)
+		}
+	}()
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkConfig(cfg); err != nil {
+		return nil, err
+	}
+	if !keep(cfg) {
+		return nil, nil
+	}
+	img, _, err = image.Decode(bytes.NewReader(data))
 	if err != nil {
 		return nil, err
 	}
 	return img, nil
 }
+
+// checkConfig returns an error wrapping ErrImageTooLarge if an image with the given config has more
+// than maxImagePixels pixels.
+func checkConfig(cfg image.Config) error {
+	if cfg.Width < 0 || cfg.Height < 0 || int64(cfg.Width)*int64(cfg.Height) > maxImagePixels {
+		return fmt.Errorf("%w: %dx%d", ErrImageTooLarge, cfg.Width, cfg.Height)
+	}
+	return nil
+}
+
+// keepPage reports whether a page with the given config should be converted. Pages smaller than
+// MinPageSize in both dimensions are skipped.
+func (c *Converter) keepPage(cfg image.Config) bool {
+	size := c.params.MinPageSize
+	return cfg.Width >= size || cfg.Height >= size
+}
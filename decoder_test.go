@@ -0,0 +1,143 @@
+package mangaconv
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// trackedFile is a file which tracks the number of simultaneously open files.
+type trackedFile struct {
+	io.Reader
+	t *openTracker
+}
+
+func (f trackedFile) Close() error {
+	f.t.mu.Lock()
+	f.t.open--
+	f.t.mu.Unlock()
+	return nil
+}
+
+type openTracker struct {
+	mu        sync.Mutex
+	open, max int
+}
+
+func (t *openTracker) opener(data []byte) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		t.mu.Lock()
+		t.open++
+		if t.open > t.max {
+			t.max = t.open
+		}
+		t.mu.Unlock()
+		return trackedFile{bytes.NewReader(data), t}, nil
+	}
+}
+
+func TestDecodeMaxOpenFiles(t *testing.T) {
+	data, err := os.ReadFile("testdata/wikipe-tan-0.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker := &openTracker{}
+	raws := make(chan rawPage, 20)
+	for i := 0; i < cap(raws); i++ {
+		raws <- rawPage{Open: tracker.opener(data), Index: i}
+	}
+	close(raws)
+
+	pages := make(chan page, cap(raws))
+	if err := New(Params{MaxOpenFiles: 1}).decode(context.Background(), pages, raws); err != nil {
+		t.Fatalf("decode() error %v", err)
+	}
+	if len(pages) != cap(raws) {
+		t.Errorf("got %d pages, want %d", len(pages), cap(raws))
+	}
+	if tracker.max != 1 {
+		t.Errorf("got %d files open at once, want 1", tracker.max)
+	}
+}
+
+func TestReadRawRetries(t *testing.T) {
+	tests := []struct {
+		name    string
+		retries int
+		err     error
+		wantErr bool
+	}{
+		{"recovers", 2, &fs.PathError{Op: "read", Path: "page", Err: syscall.EIO}, false},
+		{"timeout", 2, os.ErrDeadlineExceeded, false},
+		{"too few retries", 1, syscall.EIO, true},
+		{"permanent", 2, fs.ErrNotExist, true},
+		{"corrupt", 2, fmt.Errorf("%w: %v", ErrCorruptEntry, zip.ErrChecksum), true},
+		{"context", 2, context.DeadlineExceeded, true},
+		{"unknown", 2, errors.New("unknown"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			raw := rawPage{Open: func() (io.ReadCloser, error) {
+				calls++
+				if calls <= 2 {
+					return nil, tt.err
+				}
+				return io.NopCloser(strings.NewReader("page")), nil
+			}}
+			c := New(Params{ReadRetries: tt.retries, RetryDelay: time.Millisecond})
+			_, err := c.readRaw(context.Background(), raw)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("readRaw() error %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+var errRead = errors.New("read past header")
+
+// failingReader fails every read, standing in for the part of a file which must not be read.
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errRead }
+
+func TestReadRawHeader(t *testing.T) {
+	var b bytes.Buffer
+	if err := png.Encode(&b, image.NewGray(image.Rect(0, 0, 10, 10))); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		p       Params
+		wantErr error
+	}{
+		{"skipped", Params{MinPageSize: 20}, nil},
+		{"kept", Params{MinPageSize: 10}, errRead},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := rawPage{Open: func() (io.ReadCloser, error) {
+				return io.NopCloser(io.MultiReader(bytes.NewReader(b.Bytes()), failingReader{})), nil
+			}}
+			data, err := New(tt.p).readRawOnce(context.Background(), raw)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("readRawOnce() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && data != nil {
+				t.Errorf("readRawOnce() = %d bytes, want nil", len(data))
+			}
+		})
+	}
+}
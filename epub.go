@@ -0,0 +1,306 @@
+package mangaconv
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Format is the file format of outputs.
+type Format int
+
+const (
+	// FormatCBZ writes comic book archives, zip files of page images.
+	FormatCBZ Format = iota
+	// FormatEPUB writes fixed layout EPUB 3 books with a page for each image, whose viewport is set
+	// to the image size.
+	FormatEPUB
+	// FormatKEPUB writes FormatEPUB books named for Kobo e-readers, which open them with their own
+	// faster reader instead of the generic EPUB one.
+	FormatKEPUB
+)
+
+// Ext returns the file extension of outputs in format f, including the leading dot.
+func (f Format) Ext() string {
+	switch f {
+	case FormatEPUB:
+		return ".epub"
+	case FormatKEPUB:
+		return ".kepub.epub"
+	default:
+		return ".cbz"
+	}
+}
+
+// isEPUB reports whether outputs in format f are EPUB books.
+func (f Format) isEPUB() bool {
+	return f == FormatEPUB || f == FormatKEPUB
+}
+
+const (
+	epubMimetype  = "application/epub+zip"
+	epubOPF       = "content.opf"
+	epubNav       = "nav.xhtml"
+	epubContainer = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="` + epubOPF + `" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+)
+
+// writeMimetype writes the mimetype entry, which must come first and be stored uncompressed so
+// that readers can identify EPUB books by their first bytes.
+func writeMimetype(w *zip.Writer) error {
+	f, err := w.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(f, epubMimetype)
+	return err
+}
+
+// writeEPUB writes the files turning an archive holding pages, ComicInfo.xml and manifest.json
+// into a fixed layout EPUB book: the container, a page document for each page showing its image,
+// the navigation document and the package document listing them all.
+func (a *archive) writeEPUB(meta Metadata, pages []pageInfo) error {
+	create := func(name string) (io.Writer, error) {
+		return a.w.CreateHeader(&zip.FileHeader{Name: name, Method: a.c.params.Compression.method(name)})
+	}
+	f, err := create("META-INF/container.xml")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, epubContainer); err != nil {
+		return err
+	}
+
+	pkg := newEPUBPackage(meta, pages, a.c.params)
+	for _, p := range pages {
+		f, err := create(pageDocumentName(p))
+		if err != nil {
+			return err
+		}
+		if err := writePageDocument(f, p); err != nil {
+			return err
+		}
+	}
+	f, err = create(epubNav)
+	if err != nil {
+		return err
+	}
+	if err := writeNav(f, pkg.Metadata.Title, pages); err != nil {
+		return err
+	}
+	if a.c.params.ComicInfo {
+		pkg.addItem("comicinfo", "ComicInfo.xml", "application/xml", "")
+	}
+	if a.c.params.Manifest {
+		pkg.addItem("manifest", manifestName, "application/json", "")
+	}
+	f, err = create(epubOPF)
+	if err != nil {
+		return err
+	}
+	return pkg.encode(f)
+}
+
+// epubPackage is the package document of an EPUB book, describing its metadata and files.
+type epubPackage struct {
+	XMLName  xml.Name     `xml:"package"`
+	XMLNS    string       `xml:"xmlns,attr"`
+	Version  string       `xml:"version,attr"`
+	UniqueID string       `xml:"unique-identifier,attr"`
+	Prefix   string       `xml:"prefix,attr"`
+	Metadata epubMetadata `xml:"metadata"`
+	Manifest []epubItem   `xml:"manifest>item"`
+	Spine    epubSpine    `xml:"spine"`
+}
+
+type epubMetadata struct {
+	XMLNSDC    string     `xml:"xmlns:dc,attr"`
+	Identifier epubID     `xml:"dc:identifier"`
+	Title      string     `xml:"dc:title"`
+	Language   string     `xml:"dc:language"`
+	Creator    string     `xml:"dc:creator,omitempty"`
+	Subjects   []string   `xml:"dc:subject"`
+	Summary    string     `xml:"dc:description,omitempty"`
+	Meta       []epubMeta `xml:"meta"`
+}
+
+type epubID struct {
+	ID    string `xml:"id,attr"`
+	Value string `xml:",chardata"`
+}
+
+// epubMeta is an EPUB 3 meta element with a property, or a legacy one with a name, such as
+// original-resolution read by Kobo and Kindle readers.
+type epubMeta struct {
+	Property string `xml:"property,attr,omitempty"`
+	Name     string `xml:"name,attr,omitempty"`
+	Content  string `xml:"content,attr,omitempty"`
+	Value    string `xml:",chardata"`
+}
+
+type epubItem struct {
+	ID         string `xml:"id,attr"`
+	Href       string `xml:"href,attr"`
+	MediaType  string `xml:"media-type,attr"`
+	Properties string `xml:"properties,attr,omitempty"`
+}
+
+type epubSpine struct {
+	Direction string        `xml:"page-progression-direction,attr"`
+	Items     []epubItemRef `xml:"itemref"`
+}
+
+type epubItemRef struct {
+	IDRef string `xml:"idref,attr"`
+}
+
+// newEPUBPackage creates the package document of a book holding pages, converted with p. The
+// identifier is derived from the page checksums, so that converting an input again doesn't create
+// a new book.
+func newEPUBPackage(m Metadata, pages []pageInfo, p Params) *epubPackage {
+	h := sha256.New()
+	for _, pg := range pages {
+		io.WriteString(h, pg.SHA256)
+	}
+	pkg := &epubPackage{
+		XMLNS:    "http://www.idpf.org/2007/opf",
+		Version:  "3.0",
+		UniqueID: "id",
+		Prefix:   "rendition: http://www.idpf.org/vocab/rendition/#",
+		Metadata: epubMetadata{
+			XMLNSDC:    "http://purl.org/dc/elements/1.1/",
+			Identifier: epubID{"id", "urn:sha256:" + hex.EncodeToString(h.Sum(nil))},
+			Title:      epubTitle(m),
+			Language:   "und",
+			Creator:    m.Writer,
+			Subjects:   m.Genres,
+			Summary:    m.Summary,
+			Meta: []epubMeta{
+				{Property: "dcterms:modified", Value: time.Now().UTC().Format(time.RFC3339)},
+				{Property: "rendition:layout", Value: "pre-paginated"},
+				{Property: "rendition:spread", Value: "none"},
+			},
+		},
+		Spine: epubSpine{Direction: "rtl"},
+	}
+	if p.LeftToRight {
+		pkg.Spine.Direction = "ltr"
+	}
+	if p.ExactSize {
+		pkg.Metadata.Meta = append(pkg.Metadata.Meta, epubMeta{
+			Name:    "original-resolution",
+			Content: fmt.Sprintf("%dx%d", p.Width, p.Height),
+		})
+	}
+	for i, pg := range pages {
+		props := ""
+		if i == 0 {
+			props = "cover-image"
+		}
+		pkg.addItem(fmt.Sprintf("img%d", i), pg.Name, "image/jpeg", props)
+		id := fmt.Sprintf("page%d", i)
+		pkg.addItem(id, pageDocumentName(pg), "application/xhtml+xml", "")
+		pkg.Spine.Items = append(pkg.Spine.Items, epubItemRef{id})
+	}
+	pkg.addItem("nav", epubNav, "application/xhtml+xml", "nav")
+	return pkg
+}
+
+func (pkg *epubPackage) addItem(id, href, mediaType, props string) {
+	pkg.Manifest = append(pkg.Manifest, epubItem{id, href, mediaType, props})
+}
+
+// epubTitle returns the title of a book with metadata m, such as "Series v03 c21".
+func epubTitle(m Metadata) string {
+	title := m.Series
+	if m.Volume != "" {
+		title += " v" + m.Volume
+	}
+	if m.Chapter != "" {
+		title += " c" + m.Chapter
+	}
+	if title = strings.TrimSpace(title); title == "" {
+		return "Untitled"
+	}
+	return title
+}
+
+// pageDocumentName returns the name of the page document showing page p.
+func pageDocumentName(p pageInfo) string {
+	return strings.TrimSuffix(p.Name, ".jpg") + ".xhtml"
+}
+
+// writePageDocument writes the page document of page p, whose viewport is the size of its image so
+// that readers display it without zooming or reflowing.
+func writePageDocument(w io.Writer, p pageInfo) error {
+	_, err := fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head>
+<title>%[1]d</title>
+<meta name="viewport" content="width=%[2]d, height=%[3]d"/>
+<style>body{margin:0}img{display:block;width:%[2]dpx;height:%[3]dpx}</style>
+</head>
+<body><img src="%[4]s" alt=""/></body>
+</html>
+`, p.Index, p.Width, p.Height, xmlEscape(p.Name))
+	return err
+}
+
+// writeNav writes the navigation document of a book, listing the first page of each chapter or
+// bookmark, or just the first page if there are none.
+func writeNav(w io.Writer, title string, pages []pageInfo) error {
+	var toc strings.Builder
+	prev := ""
+	for i, p := range pages {
+		label := p.Bookmark
+		if label == "" && p.Chapter != "" && p.Chapter != prev {
+			label = p.Chapter
+		}
+		prev = p.Chapter
+		if i == 0 && label == "" {
+			label = title
+		}
+		if label != "" {
+			fmt.Fprintf(&toc, "<li><a href=\"%s\">%s</a></li>\n", xmlEscape(pageDocumentName(p)), xmlEscape(label))
+		}
+	}
+	_, err := fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head><title>%s</title></head>
+<body>
+<nav epub:type="toc"><ol>
+%s</ol></nav>
+</body>
+</html>
+`, xmlEscape(title), toc.String())
+	return err
+}
+
+// xmlEscape escapes s for use in XML text and attribute values.
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// encode writes the package document to w.
+func (pkg *epubPackage) encode(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(pkg)
+}
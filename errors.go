@@ -0,0 +1,95 @@
+package mangaconv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrorPolicy controls what happens when a single page of an input can't be read or decoded.
+type ErrorPolicy int
+
+const (
+	// ErrorsAbort stops converting the input on the first error. Output files created by Convert
+	// and ConvertAll are removed, so that no incomplete archives are left behind.
+	ErrorsAbort ErrorPolicy = iota
+	// ErrorsSkip skips pages which can't be read or decoded and converts all other pages. The
+	// conversion returns a *PartialError listing skipped pages, and pages converted without
+	// checking for credits as OCR failed.
+	ErrorsSkip
+)
+
+// PageError describes a page skipped due to an error, or a page whose credits check failed.
+type PageError struct {
+	Index  int
+	Source string
+	Err    error
+}
+
+func (e PageError) Error() string {
+	return fmt.Sprintf("page %d (%s): %v", e.Index, e.Source, e.Err)
+}
+
+func (e PageError) Unwrap() error {
+	return e.Err
+}
+
+// PartialError is returned when an input was converted with ErrorsSkip, but some of its pages were
+// skipped. The output is complete otherwise.
+type PartialError struct {
+	Pages []PageError
+}
+
+func (e *PartialError) Error() string {
+	msgs := make([]string, len(e.Pages))
+	for i, p := range e.Pages {
+		msgs[i] = p.Error()
+	}
+	return fmt.Sprintf("skipped %d pages: %s", len(e.Pages), strings.Join(msgs, "; "))
+}
+
+// pageErrors collects errors of skipped pages during a single conversion.
+type pageErrors struct {
+	mu    sync.Mutex
+	pages []PageError
+}
+
+type pageErrorsKey struct{}
+
+// withPageErrors returns a context under which page errors are collected into pe instead of
+// aborting the conversion.
+func withPageErrors(ctx context.Context, pe *pageErrors) context.Context {
+	return context.WithValue(ctx, pageErrorsKey{}, pe)
+}
+
+// skipPage records err for a page if ctx collects page errors, and reports whether it did. If it
+// returns false, the error should abort the conversion.
+func skipPage(ctx context.Context, raw rawPage, err error) bool {
+	pe, ok := ctx.Value(pageErrorsKey{}).(*pageErrors)
+	if !ok || errors.Is(err, context.Canceled) {
+		return false
+	}
+	pe.mu.Lock()
+	pe.pages = append(pe.pages, PageError{Index: raw.Index, Source: raw.Source, Err: err})
+	pe.mu.Unlock()
+	return true
+}
+
+// err returns a *PartialError if any pages were skipped, or nil otherwise.
+func (pe *pageErrors) err() error {
+	if len(pe.pages) == 0 {
+		return nil
+	}
+	sort.Slice(pe.pages, func(i, j int) bool { return pe.pages[i].Index < pe.pages[j].Index })
+	return &PartialError{Pages: pe.pages}
+}
+
+// keepOutput reports whether an output is kept after converting into it failed with err, which is
+// unless c aborts on errors. Outputs with skipped pages are kept.
+func (c *Converter) keepOutput(err error) bool {
+	var partial *PartialError
+	return err == nil || errors.As(err, &partial) || c.params.Errors != ErrorsAbort
+}
@@ -0,0 +1,69 @@
+package mangaconv_test
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/naisuuuu/mangaconv"
+)
+
+func TestConvertErrorPolicy(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "broken.zip")
+	f, err := os.Create(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	for _, e := range []struct{ name, src string }{{"0.png", "testdata/wikipe-tan-0.png"}, {"1.png", ""}} {
+		b := []byte("not an image")
+		if e.src != "" {
+			if b, err = os.ReadFile(e.src); err != nil {
+				t.Fatal(err)
+			}
+		}
+		zf, err := w.Create(e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := zf.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	tests := []struct {
+		name       string
+		policy     mangaconv.ErrorPolicy
+		wantOutput bool
+	}{
+		{"abort", mangaconv.ErrorsAbort, false},
+		{"skip", mangaconv.ErrorsSkip, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(dir, tt.name+".cbz")
+			c := mangaconv.New(mangaconv.Params{Gamma: 1, Height: 50, Width: 50, Errors: tt.policy})
+			err := c.Convert(in, out)
+			if err == nil {
+				t.Fatal("Convert() succeeded, want error")
+			}
+			var partial *mangaconv.PartialError
+			if got := errors.As(err, &partial); got != tt.wantOutput {
+				t.Errorf("Convert() error %v, want partial error %t", err, tt.wantOutput)
+			}
+			if partial != nil && (len(partial.Pages) != 1 || partial.Pages[0].Source != "1.png") {
+				t.Errorf("got skipped pages %v, want 1.png", partial.Pages)
+			}
+			if _, err := os.Stat(out); (err == nil) != tt.wantOutput {
+				t.Errorf("output exists: %t, want %t", err == nil, tt.wantOutput)
+			}
+		})
+	}
+}
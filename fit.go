@@ -0,0 +1,45 @@
+package mangaconv
+
+import (
+	"image"
+
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+// FitMode controls how pages are scaled to the Width and Height of Params.
+type FitMode int
+
+const (
+	// FitContain scales pages to fit into Width by Height.
+	FitContain FitMode = iota
+	// FitWidth scales pages to Width and leaves their height unconstrained, producing tall pages
+	// for readers which scroll vertically, such as for webtoons.
+	FitWidth
+)
+
+// fitRect returns the size a page of size rect is scaled to, leaving room for margins.
+func (c *Converter) fitRect(rect image.Rectangle) image.Rectangle {
+	m := c.params.Margin
+	var r image.Rectangle
+	switch c.params.Fit {
+	case FitWidth:
+		r = imgutil.FitWidth(rect, c.params.Width-2*m)
+	default:
+		r = imgutil.FitRect(rect, c.params.Width-2*m, c.params.Height-2*m)
+	}
+	return imgutil.QuantizeRect(r, c.params.Quantize)
+}
+
+// paddedSize returns the size of a page scaled to r once margins and ExactSize padding are added.
+// With FitWidth, ExactSize only pads the width, as the height is unconstrained.
+func (c *Converter) paddedSize(r image.Rectangle) (w, h int) {
+	m := c.params.Margin
+	w, h = r.Dx()+2*m, r.Dy()+2*m
+	if c.params.ExactSize {
+		w = c.params.Width
+		if c.params.Fit != FitWidth {
+			h = c.params.Height
+		}
+	}
+	return w, h
+}
@@ -0,0 +1,62 @@
+package mangaconv
+
+import (
+	"image"
+	"testing"
+)
+
+func TestFitRect(t *testing.T) {
+	tests := []struct {
+		name  string
+		p     Params
+		rect  image.Rectangle
+		want  image.Rectangle
+		wantW int
+		wantH int
+	}{
+		{
+			name:  "contain",
+			p:     Params{Width: 100, Height: 100},
+			rect:  image.Rect(0, 0, 200, 400),
+			want:  image.Rect(0, 0, 50, 100),
+			wantW: 50,
+			wantH: 100,
+		},
+		{
+			name:  "contain exact size",
+			p:     Params{Width: 100, Height: 100, Margin: 5, ExactSize: true},
+			rect:  image.Rect(0, 0, 200, 400),
+			want:  image.Rect(0, 0, 45, 90),
+			wantW: 100,
+			wantH: 100,
+		},
+		{
+			name:  "width",
+			p:     Params{Width: 100, Height: 100, Fit: FitWidth},
+			rect:  image.Rect(0, 0, 200, 4000),
+			want:  image.Rect(0, 0, 100, 2000),
+			wantW: 100,
+			wantH: 2000,
+		},
+		{
+			name:  "width exact size",
+			p:     Params{Width: 100, Height: 100, Fit: FitWidth, Margin: 5, ExactSize: true},
+			rect:  image.Rect(0, 0, 200, 4000),
+			want:  image.Rect(0, 0, 90, 1800),
+			wantW: 100,
+			wantH: 1810,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(tt.p)
+			r := c.fitRect(tt.rect)
+			if r != tt.want {
+				t.Errorf("fitRect() = %v, want %v", r, tt.want)
+			}
+			if w, h := c.paddedSize(r); w != tt.wantW || h != tt.wantH {
+				t.Errorf("paddedSize() = %d, %d, want %d, %d", w, h, tt.wantW, tt.wantH)
+			}
+		})
+	}
+}
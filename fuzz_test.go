@@ -0,0 +1,97 @@
+package mangaconv
+
+import (
+	"context"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// fuzzSeeds adds the contents of files to the seed corpus of f.
+func fuzzSeeds(f *testing.F, files ...string) {
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+}
+
+// readAll reads all pages of the input at path, discarding them. Unlike readHelper, it doesn't
+// limit the number of pages.
+func readAll(p Params, path string) error {
+	read, err := New(p).selectReader(path)
+	if err != nil {
+		return err
+	}
+	errg, ctx := errgroup.WithContext(context.Background())
+	pages := make(chan page)
+	errg.Go(func() error {
+		defer close(pages)
+		return read(ctx, pages, path)
+	})
+	errg.Go(func() error {
+		for range pages {
+		}
+		return nil
+	})
+	return errg.Wait()
+}
+
+func FuzzDecodeImage(f *testing.F) {
+	fuzzSeeds(f, "testdata/wikipe-tan-0.png", "imgutil/testdata/wikipe-tan-YCbCr.jpg",
+		"imgutil/testdata/wikipe-tan-Gray.png")
+	f.Fuzz(func(t *testing.T, data []byte) {
+		img, err := decodeImage(data, func(image.Config) bool { return true })
+		if err != nil {
+			return
+		}
+		if size := img.Bounds().Size(); int64(size.X)*int64(size.Y) > maxImagePixels {
+			t.Errorf("decodeImage() decoded a %dx%d image", size.X, size.Y)
+		}
+	})
+}
+
+func FuzzReadZip(f *testing.F) {
+	fuzzSeeds(f, "testdata/wikipe-tan.zip")
+	f.Fuzz(func(t *testing.T, data []byte) {
+		path := filepath.Join(t.TempDir(), "in.zip")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range []Params{{Errors: ErrorsSkip}, {Chapters: true, Order: OrderFolder}} {
+			// Any error is fine, as long as reading neither panics nor hangs.
+			readAll(p, path)
+		}
+	})
+}
+
+func FuzzReadDir(f *testing.F) {
+	data, err := os.ReadFile("testdata/wikipe-tan-0.png")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add("page.png", data)
+	f.Add("c001/page.jpg", data)
+	f.Fuzz(func(t *testing.T, name string, data []byte) {
+		root := t.TempDir()
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if name == "" || strings.Contains(name, "..") || filepath.IsAbs(name) {
+			t.Skip()
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Skip()
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Skip()
+		}
+		for _, p := range []Params{{Errors: ErrorsSkip}, {Chapters: true, Order: OrderFolder}} {
+			readAll(p, root)
+		}
+	})
+}
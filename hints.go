@@ -0,0 +1,143 @@
+package mangaconv
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// comicHints are processing hints read from an input's own ComicInfo.xml.
+//
+// ltr overrides Params.LeftToRight if not nil. covers and spreads hold the sources of pages marked
+// as covers and double page spreads. bookmarks maps sources of bookmarked pages to their bookmark,
+// such as a chapter title.
+type comicHints struct {
+	ltr       *bool
+	covers    map[string]bool
+	spreads   map[string]bool
+	bookmarks map[string]string
+}
+
+// readHints reads hints from the ComicInfo.xml file in the root of the input at path, if
+// ComicInfoHints is enabled. Page numbers in ComicInfo.xml are mapped to pages in page order. A
+// missing or malformed file isn't an error, as it only means there are no hints.
+func (c *Converter) readHints(path string) *comicHints {
+	if !c.params.ComicInfoHints {
+		return nil
+	}
+	var ci comicInfo
+	var sources []string
+	decode := func(r io.Reader) error { return xml.NewDecoder(r).Decode(&ci) }
+
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		f, err := os.Open(filepath.Join(path, "ComicInfo.xml"))
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+		if decode(f) != nil {
+			return nil
+		}
+		paths, err := c.listDir(path)
+		if err != nil {
+			return nil
+		}
+		for _, p := range paths {
+			sources = append(sources, relPath(path, p))
+		}
+	} else {
+		r, err := zip.OpenReader(path)
+		if err != nil {
+			return nil
+		}
+		defer r.Close()
+		return c.readZipHints(&r.Reader)
+	}
+	return newHints(&ci, sources)
+}
+
+// readZipHints reads hints from the ComicInfo.xml entry in the root of the zip archive r, like
+// readHints.
+func (c *Converter) readZipHints(r *zip.Reader) *comicHints {
+	if !c.params.ComicInfoHints {
+		return nil
+	}
+	var ci comicInfo
+	found := false
+	for _, f := range r.File {
+		if strings.EqualFold(f.Name, "ComicInfo.xml") {
+			found = decodeEntry(f, func(r io.Reader) error { return xml.NewDecoder(r).Decode(&ci) }) == nil
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+	var sources []string
+	for _, f := range c.listZip(r) {
+		sources = append(sources, f.Name)
+	}
+	return newHints(&ci, sources)
+}
+
+// newHints creates hints from a parsed ComicInfo.xml, with sources listing the input's pages in
+// page order.
+func newHints(ci *comicInfo, sources []string) *comicHints {
+	h := &comicHints{
+		covers:    make(map[string]bool),
+		spreads:   make(map[string]bool),
+		bookmarks: make(map[string]string),
+	}
+	switch ci.Manga {
+	case "YesAndRightToLeft":
+		ltr := false
+		h.ltr = &ltr
+	case "Yes", "No":
+		ltr := true
+		h.ltr = &ltr
+	}
+	for _, p := range ci.Pages {
+		if p.Image < 0 || p.Image >= len(sources) {
+			continue
+		}
+		src := sources[p.Image]
+		if p.Bookmark != "" {
+			h.bookmarks[src] = p.Bookmark
+		}
+		switch {
+		case p.Type == "FrontCover" || p.Type == "InnerCover" || p.Type == "BackCover":
+			h.covers[src] = true
+		case p.Type == "Spread" || p.DoublePage:
+			h.spreads[src] = true
+		}
+	}
+	return h
+}
+
+// apply overrides opts for the page read from source.
+//
+// Covers are never split or rotated, so that wraparound covers stay in one piece. If any page is
+// marked as a spread, only marked pages are treated as spreads, which keeps wide maps and panels
+// whole.
+func (h *comicHints) apply(opts *pageOptions, source string) {
+	if h == nil {
+		return
+	}
+	if h.ltr != nil {
+		opts.ltr = *h.ltr
+	}
+	if h.covers[source] || len(h.spreads) > 0 && !h.spreads[source] {
+		opts.spreads = SpreadKeep
+	}
+}
+
+// bookmark returns the bookmark of the page read from source, if any.
+func (h *comicHints) bookmark(source string) string {
+	if h == nil {
+		return ""
+	}
+	return h.bookmarks[source]
+}
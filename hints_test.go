@@ -0,0 +1,96 @@
+package mangaconv
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/naisuuuu/mangaconv/imgtest"
+)
+
+func TestNewHints(t *testing.T) {
+	sources := []string{"0.png", "1.png", "2.png"}
+	ltr, rtl := true, false
+	tests := []struct {
+		name string
+		ci   comicInfo
+		want *comicHints
+	}{
+		{
+			name: "empty",
+			want: &comicHints{covers: map[string]bool{}, spreads: map[string]bool{}, bookmarks: map[string]string{}},
+		},
+		{
+			name: "right to left",
+			ci:   comicInfo{Manga: "YesAndRightToLeft"},
+			want: &comicHints{ltr: &rtl, covers: map[string]bool{}, spreads: map[string]bool{}, bookmarks: map[string]string{}},
+		},
+		{
+			name: "left to right",
+			ci:   comicInfo{Manga: "No"},
+			want: &comicHints{ltr: &ltr, covers: map[string]bool{}, spreads: map[string]bool{}, bookmarks: map[string]string{}},
+		},
+		{
+			name: "pages",
+			ci: comicInfo{Manga: "Unknown", Pages: []comicPage{
+				{Image: 0, Type: "FrontCover", DoublePage: true},
+				{Image: 1, Type: "Spread", Bookmark: "Chapter 2"},
+				{Image: 2, Type: "Story", DoublePage: true},
+				{Image: 3, Type: "BackCover"},
+			}},
+			want: &comicHints{
+				covers:    map[string]bool{"0.png": true},
+				spreads:   map[string]bool{"1.png": true, "2.png": true},
+				bookmarks: map[string]string{"1.png": "Chapter 2"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newHints(&tt.ci, sources)
+			if diff := cmp.Diff(tt.want, got, cmp.AllowUnexported(comicHints{})); diff != "" {
+				t.Errorf("newHints() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestComicInfoHints(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"0.png", "1.png", "2.png"} {
+		if err := imgtest.Write(filepath.Join(dir, name), image.NewGray(image.Rect(0, 0, 40, 20))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ci := `<ComicInfo><Pages><Page Image="0" Type="FrontCover"/><Page Image="2" DoublePage="true"/></Pages></ComicInfo>`
+	if err := os.WriteFile(filepath.Join(dir, "ComicInfo.xml"), []byte(ci), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		hints bool
+		want  []int
+	}{
+		{"disabled", false, []int{2, 2, 2}},
+		{"enabled", true, []int{1, 1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := New(Params{Spreads: SpreadSplit, ComicInfoHints: tt.hints}).Analyze(dir)
+			if err != nil {
+				t.Fatalf("Analyze() error %v", err)
+			}
+			var got []int
+			for _, p := range plan.Pages {
+				got = append(got, p.Outputs)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("outputs mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+// Package imgtest provides helpers for regression testing image processing against golden files,
+// as used by mangaconv's own tests.
+//
+// Golden files are PNG images stored alongside tests, usually in testdata. Run tests with
+// -gen_golden_files to (re)generate them from the current output, then inspect them before
+// committing.
+package imgtest
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+
+	// for image decoding.
+	_ "image/jpeg"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+)
+
+var genGoldenFiles = flag.Bool("gen_golden_files", false, "whether to generate the TestXxx golden files.")
+
+// MustRead reads and decodes an image file, panicking on failure.
+func MustRead(path string) image.Image {
+	f, err := os.Open(path)
+	if err != nil {
+		panic(fmt.Sprintf("cannot open %s: %s", path, err))
+	}
+	defer f.Close()
+	i, _, err := image.Decode(f)
+	if err != nil {
+		panic(fmt.Sprintf("cannot decode %s: %s", path, err))
+	}
+	return i
+}
+
+// Write encodes an image as PNG to path.
+func Write(path string, i image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("cannot create: %v", err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, i); err != nil {
+		return fmt.Errorf("cannot encode: %v", err)
+	}
+	return nil
+}
+
+// MustBeGray asserts that an image is grayscale, panicking otherwise.
+func MustBeGray(i image.Image) *image.Gray {
+	v, ok := i.(*image.Gray)
+	if !ok {
+		panic("image is not grayscale")
+	}
+	return v
+}
+
+// IsImageType checks whether image is of type t, such as "RGBA" or "YCbCr". This is kind of a
+// hack, but prevents easy to overlook testing errors. t is case sensitive.
+func IsImageType(img image.Image, t string) bool {
+	c := color.RGBA{}
+	return strings.HasSuffix(fmt.Sprintf("%T", img.ColorModel().Convert(c)), t)
+}
+
+// WithinDelta reports whether a and b have the same length and no two values differ by more than
+// delta.
+func WithinDelta(a, b []uint8, delta uint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := 0; i < len(a); i++ {
+		if abs(int(a[i])-int(b[i])) > delta {
+			return false
+		}
+	}
+	return true
+}
+
+// Golden compares got with the golden image at path, reporting an error if their bounds differ or
+// any pixel differs by more than delta. With -gen_golden_files, the golden image is first written
+// from got.
+func Golden(t testing.TB, path string, got *image.Gray, delta uint) {
+	t.Helper()
+	if *genGoldenFiles {
+		if err := Write(path, got); err != nil {
+			t.Errorf("%s: %v", path, err)
+			return
+		}
+	}
+	want, ok := MustRead(path).(*image.Gray)
+	if !ok {
+		t.Errorf("%s: golden image is not grayscale", path)
+		return
+	}
+	if got.Rect.Size() != want.Rect.Size() {
+		t.Errorf("%s: actual image size %v differs from golden image size %v", path, got.Rect.Size(), want.Rect.Size())
+		return
+	}
+	w, h := want.Rect.Dx(), want.Rect.Dy()
+	for y := 0; y < h; y++ {
+		g := got.Pix[y*got.Stride : y*got.Stride+w]
+		r := want.Pix[y*want.Stride : y*want.Stride+w]
+		if !WithinDelta(g, r, delta) {
+			t.Errorf("%s: actual image differs from golden image in row %d", path, y)
+			return
+		}
+	}
+}
+
+func abs(i int) uint {
+	if i < 0 {
+		return uint(-i)
+	}
+	return uint(i)
+}
@@ -0,0 +1,52 @@
+package imgtest_test
+
+import (
+	"image"
+	"path/filepath"
+	"testing"
+
+	"github.com/naisuuuu/mangaconv/imgtest"
+)
+
+func TestWithinDelta(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  []uint8
+		delta uint
+		want  bool
+	}{
+		{"equal", []uint8{0, 128, 255}, []uint8{0, 128, 255}, 0, true},
+		{"within", []uint8{0, 128, 255}, []uint8{2, 126, 253}, 2, true},
+		{"above", []uint8{0, 128, 255}, []uint8{3, 128, 255}, 2, false},
+		{"length", []uint8{0, 128}, []uint8{0, 128, 255}, 255, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := imgtest.WithinDelta(tt.a, tt.b, tt.delta); got != tt.want {
+				t.Errorf("WithinDelta() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "golden.png")
+	want := image.NewGray(image.Rect(0, 0, 4, 3))
+	for i := range want.Pix {
+		want.Pix[i] = uint8(i * 20)
+	}
+	if err := imgtest.Write(path, want); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sub images with a different origin and stride match too.
+	big := image.NewGray(image.Rect(0, 0, 6, 5))
+	got := big.SubImage(image.Rect(1, 1, 5, 4)).(*image.Gray)
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 4; x++ {
+			got.SetGray(x+1, y+1, want.GrayAt(x, y))
+		}
+	}
+	got.Pix[got.PixOffset(2, 2)]++
+	imgtest.Golden(t, path, got, 1)
+}
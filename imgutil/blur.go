@@ -0,0 +1,73 @@
+package imgutil
+
+import (
+	"image"
+	"math"
+)
+
+// GaussianBlur blurs the image with a Gaussian kernel of standard deviation sigma, in pixels.
+// Pixels outside the image are treated as copies of the nearest edge pixel. Values of sigma <= 0
+// leave the image as is.
+//
+// The blur is applied separably, horizontally and then vertically, so its cost grows linearly with
+// sigma rather than quadratically.
+func GaussianBlur(img *image.Gray, sigma float64) {
+	if sigma <= 0 {
+		return
+	}
+	kernel := gaussianKernel(sigma)
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	tmp := make([]float32, w*h)
+	r := len(kernel) / 2
+
+	concurrentIterate(h, func(y int) {
+		row := img.Pix[y*img.Stride : y*img.Stride+w]
+		out := tmp[y*w : (y+1)*w]
+		for x := range out {
+			var sum float32
+			for k, weight := range kernel {
+				sum += weight * float32(row[clampIndex(x+k-r, w)])
+			}
+			out[x] = sum
+		}
+	})
+	concurrentIterate(h, func(y int) {
+		row := img.Pix[y*img.Stride : y*img.Stride+w]
+		for x := range row {
+			var sum float32
+			for k, weight := range kernel {
+				sum += weight * tmp[clampIndex(y+k-r, h)*w+x]
+			}
+			row[x] = clamp(float64(sum))
+		}
+	})
+}
+
+// gaussianKernel returns a normalized one dimensional Gaussian kernel of standard deviation sigma,
+// covering 3 sigma on each side of its center.
+func gaussianKernel(sigma float64) []float32 {
+	r := int(math.Ceil(3 * sigma))
+	kernel := make([]float32, 2*r+1)
+	var sum float64
+	weights := make([]float64, len(kernel))
+	for i := range weights {
+		d := float64(i - r)
+		weights[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += weights[i]
+	}
+	for i, v := range weights {
+		kernel[i] = float32(v / sum)
+	}
+	return kernel
+}
+
+// clampIndex clamps i to [0, n).
+func clampIndex(i, n int) int {
+	if i < 0 {
+		return 0
+	}
+	if i >= n {
+		return n - 1
+	}
+	return i
+}
@@ -0,0 +1,68 @@
+package imgutil_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+func TestGaussianBlur(t *testing.T) {
+	newImg := func(pix ...uint8) *image.Gray {
+		return &image.Gray{Pix: pix, Stride: len(pix), Rect: image.Rect(0, 0, len(pix), 1)}
+	}
+	tests := []struct {
+		name  string
+		img   *image.Gray
+		sigma float64
+		want  []uint8
+	}{
+		{
+			name:  "zero sigma",
+			img:   newImg(0x00, 0xff, 0x00),
+			sigma: 0,
+			want:  []uint8{0x00, 0xff, 0x00},
+		},
+		{
+			name:  "uniform",
+			img:   newImg(0x80, 0x80, 0x80, 0x80),
+			sigma: 2,
+			want:  []uint8{0x80, 0x80, 0x80, 0x80},
+		},
+		{
+			name:  "impulse",
+			img:   newImg(0x00, 0x00, 0x00, 0xff, 0x00, 0x00, 0x00),
+			sigma: 1,
+			want:  []uint8{0x01, 0x0e, 0x3e, 0x66, 0x3e, 0x0e, 0x01},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imgutil.GaussianBlur(tt.img, tt.sigma)
+			if diff := cmp.Diff(tt.want, tt.img.Pix); diff != "" {
+				t.Errorf("GaussianBlur() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGaussianBlurSeparable(t *testing.T) {
+	// A vertical edge is only blurred horizontally, so every row must stay the same.
+	img := image.NewGray(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 4; x < 8; x++ {
+			img.Pix[y*img.Stride+x] = 0xff
+		}
+	}
+	imgutil.GaussianBlur(img, 1.5)
+	for y := 1; y < 8; y++ {
+		if diff := cmp.Diff(img.Pix[:8], img.Pix[y*8:y*8+8]); diff != "" {
+			t.Errorf("row %d differs from row 0 (-want +got):\n%s", y, diff)
+		}
+	}
+	if img.Pix[0] >= img.Pix[3] || img.Pix[3] >= img.Pix[4] || img.Pix[4] >= img.Pix[7] {
+		t.Errorf("GaussianBlur() edge not smoothed: %v", img.Pix[:8])
+	}
+}
@@ -0,0 +1,79 @@
+package imgutil
+
+import (
+	"image"
+	"image/color"
+	"runtime"
+	"sync"
+)
+
+// ChromaHistogram returns a histogram of the chroma of each pixel of img, the difference between
+// its largest and smallest RGB channel. Gray pixels have a chroma of 0 and fully saturated ones a
+// chroma of 255, regardless of brightness.
+//
+// It works on the original image before it's converted to grayscale, and is the basis of detecting
+// color pages. Gray images are counted as all 0 without looking at their pixels.
+func ChromaHistogram(img image.Image) [256]uint {
+	var hist [256]uint
+	r := img.Bounds()
+	switch img.(type) {
+	case *image.Gray, *image.Gray16:
+		hist[0] = uint(r.Dx() * r.Dy())
+		return hist
+	}
+
+	var mu sync.Mutex
+	parallel(runtime.GOMAXPROCS(0), r.Min.Y, r.Max.Y, func(lo, hi int) {
+		var tmp [256]uint
+		for y := lo; y < hi; y++ {
+			chromaRow(&tmp, img, y)
+		}
+		mu.Lock()
+		for i, v := range tmp {
+			hist[i] += v
+		}
+		mu.Unlock()
+	})
+	return hist
+}
+
+// chromaRow adds the chroma of each pixel in row y of img to hist.
+func chromaRow(hist *[256]uint, img image.Image, y int) {
+	r := img.Bounds()
+	switch img := img.(type) {
+	case *image.YCbCr:
+		for x := r.Min.X; x < r.Max.X; x++ {
+			yi, ci := img.YOffset(x, y), img.COffset(x, y)
+			hist[chroma(color.YCbCrToRGB(img.Y[yi], img.Cb[ci], img.Cr[ci]))]++
+		}
+	case *image.RGBA:
+		row := img.Pix[img.PixOffset(r.Min.X, y):img.PixOffset(r.Max.X, y)]
+		for i := 0; i < len(row); i += 4 {
+			hist[chroma(row[i], row[i+1], row[i+2])]++
+		}
+	case *image.NRGBA:
+		row := img.Pix[img.PixOffset(r.Min.X, y):img.PixOffset(r.Max.X, y)]
+		for i := 0; i < len(row); i += 4 {
+			hist[chroma(row[i], row[i+1], row[i+2])]++
+		}
+	default:
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			hist[chroma(c.R, c.G, c.B)]++
+		}
+	}
+}
+
+// chroma returns the difference between the largest and smallest of r, g and b.
+func chroma(r, g, b uint8) uint8 {
+	lo, hi := r, r
+	for _, v := range [2]uint8{g, b} {
+		if v < lo {
+			lo = v
+		}
+		if v > hi {
+			hi = v
+		}
+	}
+	return hi - lo
+}
@@ -0,0 +1,76 @@
+package imgutil_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+func TestChromaHistogram(t *testing.T) {
+	rect := image.Rect(0, 0, 2, 2)
+	colors := []color.Color{
+		color.Gray{Y: 0x80},
+		color.RGBA{0xff, 0x00, 0x00, 0xff},
+		color.RGBA{0x80, 0x60, 0x70, 0xff},
+		color.White,
+	}
+	fill := func(img interface{ Set(x, y int, c color.Color) }) {
+		for i, c := range colors {
+			img.Set(i%2, i/2, c)
+		}
+	}
+	rgba, nrgba, paletted := image.NewRGBA(rect), image.NewNRGBA(rect), image.NewPaletted(rect, colors)
+	fill(rgba)
+	fill(nrgba)
+	fill(paletted)
+
+	var want [256]uint
+	want[0], want[0x20], want[0xff] = 2, 1, 1
+	var gray [256]uint
+	gray[0] = 4
+	tests := []struct {
+		name string
+		img  image.Image
+		want [256]uint
+	}{
+		{"gray", image.NewGray(rect), gray},
+		{"rgba", rgba, want},
+		{"nrgba", nrgba, want},
+		{"paletted", paletted, want},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, imgutil.ChromaHistogram(tt.img)); diff != "" {
+				t.Errorf("ChromaHistogram() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestChromaHistogramYCbCr(t *testing.T) {
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 4), image.YCbCrSubsampleRatio420)
+	for i := range img.Y {
+		img.Y[i] = 0x80
+	}
+	for i := range img.Cb {
+		img.Cb[i], img.Cr[i] = 0x80, 0x80
+	}
+	// Make the top left 2x2 block red.
+	img.Cb[0], img.Cr[0] = 0x5a, 0xf0
+
+	hist := imgutil.ChromaHistogram(img)
+	if hist[0] != 12 {
+		t.Errorf("got %d gray pixels, want 12", hist[0])
+	}
+	colored := uint(0)
+	for _, v := range hist[100:] {
+		colored += v
+	}
+	if colored != 4 {
+		t.Errorf("got %d colored pixels, want 4", colored)
+	}
+}
@@ -0,0 +1,33 @@
+package imgutil
+
+import "image"
+
+// Composite blends src onto dst with its top left corner at point at.
+//
+// Opacity is in the range [0, 1], where 0 leaves dst unchanged and 1 replaces dst pixels with src
+// pixels. If mask is not nil, it must have the same bounds as src and each pixel's opacity is
+// additionally multiplied by the mask's alpha value. Parts of src outside of dst are ignored.
+func Composite(dst, src *image.Gray, mask *image.Alpha, at image.Point, opacity float64) {
+	if opacity <= 0 {
+		return
+	}
+	if opacity > 1 {
+		opacity = 1
+	}
+	r := src.Rect.Sub(src.Rect.Min).Add(at).Intersect(dst.Rect)
+	a := uint32(opacity*0xff + 0.5)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		sy := y - at.Y + src.Rect.Min.Y
+		for x := r.Min.X; x < r.Max.X; x++ {
+			sx := x - at.X + src.Rect.Min.X
+			alpha := a
+			if mask != nil {
+				alpha = alpha * uint32(mask.Pix[mask.PixOffset(sx, sy)]) / 0xff
+			}
+			di := dst.PixOffset(x, y)
+			d := uint32(dst.Pix[di])
+			s := uint32(src.Pix[src.PixOffset(sx, sy)])
+			dst.Pix[di] = uint8((d*(0xff-alpha) + s*alpha + 0x7f) / 0xff)
+		}
+	}
+}
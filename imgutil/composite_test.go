@@ -0,0 +1,76 @@
+package imgutil_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+func TestComposite(t *testing.T) {
+	newDst := func() *image.Gray {
+		return &image.Gray{
+			Pix: []uint8{
+				0xff, 0xff, 0xff,
+				0xff, 0xff, 0xff,
+			},
+			Stride: 3,
+			Rect:   image.Rect(0, 0, 3, 2),
+		}
+	}
+	src := &image.Gray{
+		Pix:    []uint8{0x00, 0x00},
+		Stride: 2,
+		Rect:   image.Rect(0, 0, 2, 1),
+	}
+	tests := []struct {
+		name    string
+		mask    *image.Alpha
+		at      image.Point
+		opacity float64
+		want    []uint8
+	}{
+		{
+			name:    "opaque",
+			at:      image.Pt(1, 1),
+			opacity: 1,
+			want: []uint8{
+				0xff, 0xff, 0xff,
+				0xff, 0x00, 0x00,
+			},
+		},
+		{
+			name:    "half opacity clipped",
+			at:      image.Pt(2, 0),
+			opacity: 0.5,
+			want: []uint8{
+				0xff, 0xff, 0x7f,
+				0xff, 0xff, 0xff,
+			},
+		},
+		{
+			name: "mask",
+			mask: &image.Alpha{
+				Pix:    []uint8{0xff, 0x00},
+				Stride: 2,
+				Rect:   image.Rect(0, 0, 2, 1),
+			},
+			opacity: 1,
+			want: []uint8{
+				0x00, 0xff, 0xff,
+				0xff, 0xff, 0xff,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := newDst()
+			imgutil.Composite(dst, src, tt.mask, tt.at, tt.opacity)
+			if diff := cmp.Diff(tt.want, dst.Pix); diff != "" {
+				t.Errorf("Composite() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
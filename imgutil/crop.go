@@ -0,0 +1,85 @@
+package imgutil
+
+import "image"
+
+// SalientCrop returns the largest rectangle within img with the aspect ratio of w by h which
+// contains the most detail, such as to crop a cover down to a thumbnail of w by h. Unlike a center
+// crop, it keeps characters and titles drawn near the edges of wide covers and spreads.
+//
+// Detail is measured as the sum of absolute differences between neighboring pixels, so flat
+// backgrounds and gradients count as empty. Of equally detailed rectangles, the one closest to the
+// center wins, which makes SalientCrop a center crop for blank images.
+func SalientCrop(img *image.Gray, w, h int) image.Rectangle {
+	iw, ih := img.Rect.Dx(), img.Rect.Dy()
+	if w <= 0 || h <= 0 || iw == 0 || ih == 0 {
+		return img.Rect
+	}
+	cw, ch := iw, ih
+	if iw*h > ih*w {
+		cw = (ih*w + h/2) / h
+	} else {
+		ch = (iw*h + w/2) / w
+	}
+
+	// Only one axis has room to move the crop, so detail is summed along the other one.
+	horizontal := cw < iw
+	n, size := iw, cw
+	if !horizontal {
+		n, size = ih, ch
+	}
+	energy := make([]uint64, n+1)
+	for y := 0; y < ih; y++ {
+		row := img.Pix[y*img.Stride : y*img.Stride+iw]
+		var below []uint8
+		if y+1 < ih {
+			below = img.Pix[(y+1)*img.Stride : (y+1)*img.Stride+iw]
+		}
+		for x, v := range row {
+			var e uint64
+			if x+1 < iw {
+				e += absDiff(v, row[x+1])
+			}
+			if below != nil {
+				e += absDiff(v, below[x])
+			}
+			if horizontal {
+				energy[x+1] += e
+			} else {
+				energy[y+1] += e
+			}
+		}
+	}
+	// Turn energy into prefix sums, so that the detail of any window is a single subtraction.
+	for i := 1; i <= n; i++ {
+		energy[i] += energy[i-1]
+	}
+
+	center := (n - size) / 2
+	best, bestEnergy := center, energy[center+size]-energy[center]
+	for i := 0; i+size <= n; i++ {
+		e := energy[i+size] - energy[i]
+		if e > bestEnergy || e == bestEnergy && distance(i, center) < distance(best, center) {
+			best, bestEnergy = i, e
+		}
+	}
+
+	min := img.Rect.Min
+	if horizontal {
+		return image.Rect(min.X+best, min.Y, min.X+best+cw, min.Y+ch)
+	}
+	return image.Rect(min.X, min.Y+best, min.X+cw, min.Y+best+ch)
+}
+
+func absDiff(a, b uint8) uint64 {
+	if a > b {
+		return uint64(a - b)
+	}
+	return uint64(b - a)
+}
+
+func distance(a, b int) int {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
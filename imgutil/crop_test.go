@@ -0,0 +1,74 @@
+package imgutil_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+func TestSalientCrop(t *testing.T) {
+	// checkered fills r with a 1px checkerboard, the most detailed pattern possible.
+	checkered := func(img *image.Gray, r image.Rectangle) *image.Gray {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				if (x+y)%2 == 0 {
+					img.Pix[img.PixOffset(x, y)] = 0
+				}
+			}
+		}
+		return img
+	}
+	white := func(r image.Rectangle) *image.Gray {
+		img := image.NewGray(r)
+		for i := range img.Pix {
+			img.Pix[i] = 0xff
+		}
+		return img
+	}
+
+	tests := []struct {
+		name string
+		img  *image.Gray
+		w, h int
+		want image.Rectangle
+	}{
+		{
+			name: "blank centered",
+			img:  white(image.Rect(0, 0, 100, 40)),
+			w:    1, h: 1,
+			want: image.Rect(30, 0, 70, 40),
+		},
+		{
+			name: "detail on the right",
+			img:  checkered(white(image.Rect(0, 0, 100, 40)), image.Rect(80, 10, 95, 30)),
+			w:    1, h: 1,
+			want: image.Rect(55, 0, 95, 40),
+		},
+		{
+			name: "detail at the top",
+			img:  checkered(white(image.Rect(0, 0, 30, 90)), image.Rect(5, 0, 25, 10)),
+			w:    2, h: 3,
+			want: image.Rect(0, 0, 30, 45),
+		},
+		{
+			name: "same aspect ratio",
+			img:  checkered(white(image.Rect(0, 0, 30, 40)), image.Rect(0, 0, 5, 5)),
+			w:    3, h: 4,
+			want: image.Rect(0, 0, 30, 40),
+		},
+		{
+			name: "offset bounds",
+			img:  checkered(white(image.Rect(10, 10, 50, 30)), image.Rect(10, 10, 20, 30)),
+			w:    1, h: 1,
+			want: image.Rect(10, 10, 30, 30),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := imgutil.SalientCrop(tt.img, tt.w, tt.h); got != tt.want {
+				t.Errorf("SalientCrop() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package imgutil
+
+import "image"
+
+// Dither reduces a 16-bit grayscale image to 8 bits with Floyd-Steinberg error diffusion, writing
+// the result to dst, which must have the same size as src. Rounding errors are spread over
+// neighboring pixels, so that gradients keep their fractional gray levels on average instead of
+// forming visible bands.
+func Dither(dst *image.Gray, src *image.Gray16) {
+	DitherExcept(dst, src, nil)
+}
+
+// DitherExcept is like Dither, but pixels within any of except are rounded to the nearest 8-bit
+// level and neither take nor spread rounding errors, such as to keep lettering found by
+// TextRegions free of dither noise.
+func DitherExcept(dst *image.Gray, src *image.Gray16, except []image.Rectangle) {
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	// Errors are in 16-bit units, for the current and next row, with a pixel of padding on both
+	// sides.
+	cur, next := make([]int32, w+2), make([]int32, w+2)
+	kept := make([]bool, w)
+	for y := 0; y < h; y++ {
+		row := src.Pix[y*src.Stride:]
+		out := dst.Pix[y*dst.Stride:]
+		for x := range kept {
+			kept[x] = false
+		}
+		for _, r := range except {
+			min := src.Rect.Min
+			r = r.Intersect(image.Rect(min.X, min.Y+y, min.X+w, min.Y+y+1))
+			for x := r.Min.X; x < r.Max.X; x++ {
+				kept[x-min.X] = true
+			}
+		}
+		for x := 0; x < w; x++ {
+			v := int32(row[x*2])<<8 | int32(row[x*2+1])
+			if kept[x] {
+				out[x] = uint8((v*0xff + 0x7fff) / 0xffff)
+				continue
+			}
+			v += cur[x+1] / 16
+			switch {
+			case v < 0:
+				v = 0
+			case v > 0xffff:
+				v = 0xffff
+			}
+			q := (v*0xff + 0x7fff) / 0xffff
+			out[x] = uint8(q)
+			e := v - q*0x101
+			cur[x+2] += e * 7
+			next[x] += e * 3
+			next[x+1] += e * 5
+			next[x+2] += e
+		}
+		cur, next = next, cur
+		for i := range next {
+			next[i] = 0
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package imgutil_test
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+func TestDither(t *testing.T) {
+	tests := []struct {
+		name  string
+		level float64
+		// want are the only 8-bit levels allowed in the result.
+		want []uint8
+	}{
+		{"black", 0, []uint8{0}},
+		{"white", 255, []uint8{255}},
+		{"exact", 128, []uint8{128}},
+		{"quarter", 128.25, []uint8{128, 129}},
+		{"half", 10.5, []uint8{10, 11}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := image.NewGray16(image.Rect(0, 0, 64, 64))
+			v := uint16(math.Round(tt.level * 0x101))
+			for i := 0; i < len(src.Pix); i += 2 {
+				src.Pix[i], src.Pix[i+1] = uint8(v>>8), uint8(v)
+			}
+			dst := image.NewGray(src.Rect)
+			imgutil.Dither(dst, src)
+
+			var sum float64
+			for i, p := range dst.Pix {
+				allowed := false
+				for _, w := range tt.want {
+					allowed = allowed || p == w
+				}
+				if !allowed {
+					t.Fatalf("pixel %d = %d, want one of %v", i, p, tt.want)
+				}
+				sum += float64(p)
+			}
+			if mean := sum / float64(len(dst.Pix)); math.Abs(mean-tt.level) > 0.05 {
+				t.Errorf("mean level = %.3f, want %.3f", mean, tt.level)
+			}
+		})
+	}
+}
+
+func TestDitherExcept(t *testing.T) {
+	src := image.NewGray16(image.Rect(0, 0, 32, 32))
+	v := uint16(math.Round(10.5 * 0x101))
+	for i := 0; i < len(src.Pix); i += 2 {
+		src.Pix[i], src.Pix[i+1] = uint8(v>>8), uint8(v)
+	}
+	except := image.Rect(8, 8, 24, 24)
+	dst := image.NewGray(src.Rect)
+	imgutil.DitherExcept(dst, src, []image.Rectangle{except})
+
+	dithered := false
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			got := dst.GrayAt(x, y).Y
+			if image.Pt(x, y).In(except) {
+				if got != 11 {
+					t.Fatalf("pixel (%d, %d) = %d, want 11 rounded", x, y, got)
+				}
+				continue
+			}
+			dithered = dithered || got == 10
+		}
+	}
+	if !dithered {
+		t.Errorf("pixels outside of except aren't dithered")
+	}
+}
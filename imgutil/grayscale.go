@@ -2,6 +2,7 @@ package imgutil
 
 import (
 	"image"
+	"image/color"
 	"image/draw"
 )
 
@@ -14,6 +15,29 @@ func Grayscale(img image.Image) *image.Gray {
 	return dst
 }
 
+// Luma returns the luma of an image without copying it, if possible. Grayscale images are returned
+// as is and the returned image of a YCbCr image, such as a decoded JPEG, shares its full resolution
+// Y plane. ok is false for all other images, which must be converted with Grayscale instead.
+//
+// Returned images of YCbCr images have bounds starting at the origin.
+func Luma(img image.Image) (gray *image.Gray, ok bool) {
+	switch i := img.(type) {
+	case *image.Gray:
+		return i, true
+	case *image.YCbCr:
+		w, h := i.Rect.Dx(), i.Rect.Dy()
+		if w == 0 || h == 0 {
+			return &image.Gray{}, true
+		}
+		return &image.Gray{
+			Pix:    i.Y[:(h-1)*i.YStride+w],
+			Stride: i.YStride,
+			Rect:   image.Rect(0, 0, w, h),
+		}, true
+	}
+	return nil, false
+}
+
 func grayscale(dst *image.Gray, src image.Image) {
 	switch i := src.(type) {
 	case *image.Gray:
@@ -156,3 +180,38 @@ func ycbcrToGray(dst *image.Gray, src *image.YCbCr) {
 		copy(dst.Pix[dstH:dstH+dst.Stride], src.Y[srcH:srcH+dst.Stride])
 	}
 }
+
+// SplitRGB copies the alpha premultiplied red, green and blue channels of src into r, g and b, which
+// must be the size of src, so that color images can be processed like grayscale ones, such as by a
+// Scaler.
+func SplitRGB(r, g, b *image.Gray, src image.Image) {
+	bounds := src.Bounds()
+	w := bounds.Dx()
+	switch i := src.(type) {
+	case *image.RGBA:
+		concurrentIterate(bounds.Dy(), func(y int) {
+			s := i.Pix[i.PixOffset(bounds.Min.X, bounds.Min.Y+y):]
+			d := y * r.Stride
+			for x := 0; x < w; x++ {
+				r.Pix[d+x], g.Pix[d+x], b.Pix[d+x] = s[x*4], s[x*4+1], s[x*4+2]
+			}
+		})
+	case *image.YCbCr:
+		concurrentIterate(bounds.Dy(), func(y int) {
+			d := y * r.Stride
+			for x := 0; x < w; x++ {
+				yi := i.YOffset(bounds.Min.X+x, bounds.Min.Y+y)
+				ci := i.COffset(bounds.Min.X+x, bounds.Min.Y+y)
+				r.Pix[d+x], g.Pix[d+x], b.Pix[d+x] = color.YCbCrToRGB(i.Y[yi], i.Cb[ci], i.Cr[ci])
+			}
+		})
+	default:
+		concurrentIterate(bounds.Dy(), func(y int) {
+			d := y * r.Stride
+			for x := 0; x < w; x++ {
+				cr, cg, cb, _ := src.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+				r.Pix[d+x], g.Pix[d+x], b.Pix[d+x] = uint8(cr>>8), uint8(cg>>8), uint8(cb>>8)
+			}
+		})
+	}
+}
@@ -2,10 +2,12 @@ package imgutil_test
 
 import (
 	"image"
+	"image/draw"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 
+	"github.com/naisuuuu/mangaconv/imgtest"
 	"github.com/naisuuuu/mangaconv/imgutil"
 )
 
@@ -133,8 +135,8 @@ func TestGrayscale(t *testing.T) {
 		},
 		{
 			name: "YCbCr",
-			src:  mustReadImg("testdata/wikipe-tan-YCbCr.jpg"),
-			want: mustBeGray(mustReadImg("testdata/wikipe-tan-Gray.png")),
+			src:  imgtest.MustRead("testdata/wikipe-tan-YCbCr.jpg"),
+			want: imgtest.MustBeGray(imgtest.MustRead("testdata/wikipe-tan-Gray.png")),
 		},
 	}
 	for _, tt := range tests {
@@ -159,7 +161,7 @@ func TestGrayscale(t *testing.T) {
 			// If you have a better idea how to test this or know why direct comparisons fail like they do,
 			// please submit an issue/PR!
 			if _, ok := tt.src.(*image.YCbCr); ok {
-				if !isWithinDeltaDiff(tt.want.Pix, got.Pix, 2) {
+				if !imgtest.WithinDelta(tt.want.Pix, got.Pix, 2) {
 					t.Errorf("Grayscale() difference above acceptable delta")
 				}
 				return
@@ -172,20 +174,56 @@ func TestGrayscale(t *testing.T) {
 	}
 }
 
+func TestLuma(t *testing.T) {
+	ycbcr := imgtest.MustRead("testdata/wikipe-tan-YCbCr.jpg").(*image.YCbCr)
+	tests := []struct {
+		name   string
+		src    image.Image
+		wantOK bool
+	}{
+		{"Gray", imgtest.MustRead("testdata/wikipe-tan-Gray.png"), true},
+		{"YCbCr", imgtest.MustRead("testdata/wikipe-tan-YCbCr.jpg"), true},
+		{"YCbCr-sub", ycbcr.SubImage(image.Rect(10, 20, 50, 70)), true},
+		{"RGBA", imgtest.MustRead("testdata/wikipe-tan-RGBA.png"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := imgutil.Luma(tt.src)
+			if ok != tt.wantOK {
+				t.Fatalf("Luma() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			want := imgutil.Grayscale(tt.src)
+			if got.Rect != want.Rect {
+				t.Errorf("Luma() bounds = %v, want %v", got.Rect, want.Rect)
+			}
+			for y := 0; y < want.Rect.Dy(); y++ {
+				for x := 0; x < want.Rect.Dx(); x++ {
+					if g, w := got.GrayAt(x, y), want.GrayAt(x, y); g != w {
+						t.Fatalf("Luma() pixel at (%d, %d) = %d, want %d", x, y, g.Y, w.Y)
+					}
+				}
+			}
+		})
+	}
+}
+
 func BenchmarkGrayscale(b *testing.B) {
 	benchmarks := []struct {
 		name string
 		img  image.Image
 	}{
-		{"RGBA", mustReadImg("testdata/wikipe-tan-RGBA.png")},
-		{"RGBA64", mustReadImg("testdata/wikipe-tan-RGBA64.png")},
-		{"NRGBA", mustReadImg("testdata/wikipe-tan-NRGBA.png")},
-		{"NRGBA64", mustReadImg("testdata/wikipe-tan-NRGBA64.png")},
-		{"YCbCr", mustReadImg("testdata/wikipe-tan-YCbCr.jpg")},
-		{"Gray", mustReadImg("testdata/wikipe-tan-Gray.png")},
+		{"RGBA", imgtest.MustRead("testdata/wikipe-tan-RGBA.png")},
+		{"RGBA64", imgtest.MustRead("testdata/wikipe-tan-RGBA64.png")},
+		{"NRGBA", imgtest.MustRead("testdata/wikipe-tan-NRGBA.png")},
+		{"NRGBA64", imgtest.MustRead("testdata/wikipe-tan-NRGBA64.png")},
+		{"YCbCr", imgtest.MustRead("testdata/wikipe-tan-YCbCr.jpg")},
+		{"Gray", imgtest.MustRead("testdata/wikipe-tan-Gray.png")},
 	}
 	for _, bb := range benchmarks {
-		if !isImageType(bb.img, bb.name) {
+		if !imgtest.IsImageType(bb.img, bb.name) {
 			b.Fatalf("source image is not of type %s", bb.name)
 		}
 		b.Run(bb.name, func(b *testing.B) {
@@ -195,3 +233,58 @@ func BenchmarkGrayscale(b *testing.B) {
 		})
 	}
 }
+
+func TestSplitRGB(t *testing.T) {
+	rgba := &image.RGBA{
+		Pix: []uint8{
+			0x10, 0x20, 0x30, 0xff, 0x40, 0x50, 0x60, 0xff,
+			0x70, 0x80, 0x90, 0xff, 0xa0, 0xb0, 0xc0, 0xff,
+		},
+		Stride: 8,
+		Rect:   image.Rect(0, 0, 2, 2),
+	}
+	want := [3][]uint8{
+		{0x10, 0x40, 0x70, 0xa0},
+		{0x20, 0x50, 0x80, 0xb0},
+		{0x30, 0x60, 0x90, 0xc0},
+	}
+	tests := []struct {
+		name string
+		src  image.Image
+	}{
+		{"RGBA", rgba},
+		{"RGBA sub image", subImage(rgba)},
+		{"NRGBA", nrgba(rgba)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [3][]uint8
+			planes := [3]*image.Gray{}
+			for i := range planes {
+				planes[i] = image.NewGray(image.Rect(0, 0, 2, 2))
+			}
+			imgutil.SplitRGB(planes[0], planes[1], planes[2], tt.src)
+			for i, p := range planes {
+				got[i] = p.Pix
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("SplitRGB() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// subImage returns img with a 1 pixel border around it, as a sub image with the bounds of img.
+func subImage(img *image.RGBA) image.Image {
+	b := img.Rect
+	big := image.NewRGBA(b.Inset(-1))
+	draw.Draw(big, b, img, b.Min, draw.Src)
+	return big.SubImage(b)
+}
+
+// nrgba returns img converted to NRGBA.
+func nrgba(img *image.RGBA) *image.NRGBA {
+	dst := image.NewNRGBA(img.Rect)
+	draw.Draw(dst, dst.Rect, img, img.Rect.Min, draw.Src)
+	return dst
+}
@@ -9,13 +9,6 @@ import (
 	"sync"
 )
 
-// applyLookup applies a lookup table to an image.
-func applyLookup(img *image.Gray, lut *[256]uint8) {
-	for i := 0; i < len(img.Pix); i++ {
-		img.Pix[i] = lut[img.Pix[i]]
-	}
-}
-
 // AdjustGamma applies gamma adjustments.
 //
 // Gamma value of 1 doesn't change the image, < 1 darkens and >1 brightens it.
@@ -23,34 +16,39 @@ func AdjustGamma(img *image.Gray, gamma float64) {
 	if gamma == 1 {
 		return
 	}
-	var lut [256]uint8
-	for i := 0; i < 256; i++ {
-		lut[i] = clamp(math.Pow(float64(i)/255, 1/gamma) * 255)
+	lut := GammaLUT(gamma)
+	lut.Apply(img)
+}
+
+// AdjustBrightnessContrast applies a fixed linear brightness and contrast adjustment.
+//
+// Brightness is added to every pixel as a fraction of white, so that 0.1 brightens by 10% and -0.1
+// darkens by 10%. Contrast scales the distance of every pixel from middle gray by 1 + contrast, so
+// that 0.2 increases contrast by 20% and -1 turns the whole image gray. Values of 0 don't change
+// the image.
+func AdjustBrightnessContrast(img *image.Gray, brightness, contrast float64) {
+	if brightness == 0 && contrast == 0 {
+		return
 	}
-	applyLookup(img, &lut)
+	lut := BrightnessContrastLUT(brightness, contrast)
+	lut.Apply(img)
 }
 
 // Histogram returns a histogram of a grayscale image.
 //
 // Resulting histogram is represented as a fixed length array of 256 unsigned integers,
-// where histogram[i] is the amount of pixels of value i present in the image.
+// where histogram[i] is the amount of pixels of value i present in the image. Only pixels within
+// the image's bounds are counted, so img may be a sub-image sharing pixels with a larger image.
 func Histogram(img *image.Gray) [256]uint {
 	var hist [256]uint
 	var mu sync.Mutex
-	cpus := runtime.NumCPU()
-	height := img.Bounds().Dy()
-	m := 1
-	if height > cpus {
-		m = height / cpus
-	}
-	concurrentIterate(height/m+1, func(y int) {
+	w := img.Rect.Dx()
+	parallel(runtime.GOMAXPROCS(0), 0, img.Rect.Dy(), func(lo, hi int) {
 		var tmp [256]uint
-		for x := 0; x < img.Stride*m; x++ {
-			index := y*img.Stride*m + x
-			if index == len(img.Pix) {
-				break
+		for y := lo; y < hi; y++ {
+			for _, v := range img.Pix[y*img.Stride : y*img.Stride+w] {
+				tmp[v]++
 			}
-			tmp[img.Pix[index]]++
 		}
 		mu.Lock()
 		for i := 0; i < 256; i++ {
@@ -61,73 +59,120 @@ func Histogram(img *image.Gray) [256]uint {
 	return hist
 }
 
-// AutoContrast applies histogram normalization to the image, ignoring specified cutoff % highest
-// and lowest values.
-//
-// This implementation is taken from Pillow's ImageOps.autocontrast method. See:
-// https://pillow.readthedocs.io/en/stable/_modules/PIL/ImageOps.html#autocontrast
-func AutoContrast(img *image.Gray, cutoff float64) {
-	hist := Histogram(img)
-
-	// Cutoff % of lowest/highest samples.
-	if cutoff > 0 {
-		cutl := uint(float64(len(img.Pix)) * cutoff / 100)
-		cuth := cutl
-		for i := 0; i < 256; i++ {
-			if hist[i] >= cutl {
-				hist[i] -= cutl
-				break
-			}
-			cutl -= hist[i]
-			hist[i] = 0
-		}
-		for i := 255; i >= 0; i-- {
-			if hist[i] >= cuth {
-				hist[i] -= cuth
-				break
-			}
-			cuth -= hist[i]
-			hist[i] = 0
-		}
-	}
-
-	// Find lowest/highest samples.
-	var hi, lo int
-	for i := 0; i < 256; i++ {
-		if hist[i] > 0 {
-			lo = i
-			break
-		}
-	}
-	for i := 255; i >= 0; i-- {
-		if hist[i] > 0 {
-			hi = i
-			break
+// Histogram16 returns a histogram of a 16-bit grayscale image, with each pixel counted towards its
+// nearest 8-bit gray level. See Histogram.
+func Histogram16(img *image.Gray16) [256]uint {
+	var hist [256]uint
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		row := img.Pix[(y-img.Rect.Min.Y)*img.Stride:]
+		for x := 0; x < img.Rect.Dx(); x++ {
+			v := uint32(row[x*2])<<8 | uint32(row[x*2+1])
+			hist[(v*0xff+0x7fff)/0xffff]++
 		}
 	}
+	return hist
+}
 
-	if hi <= lo {
-		return
+// AutoContrast applies histogram normalization to the image, ignoring specified cutoff % highest
+// and lowest values. It returns the applied lookup table, which is IdentityLUT if the image can't
+// be normalized, so that it can be reused for other images or combined with further adjustments.
+func AutoContrast(img *image.Gray, cutoff float64) LUT {
+	lut, ok := ContrastLUT(img, cutoff)
+	if ok {
+		lut.Apply(img)
 	}
+	return lut
+}
 
-	// Generate lookup table.
-	var lut [256]uint8
-	scale := 255 / float64(hi-lo)
-	offset := float64(-lo) * scale
-	for i := 0; i < 256; i++ {
-		lut[i] = clamp(float64(i)*scale + offset)
+// AutoContrastPercentile stretches the contrast of the image so that the gray level at the low
+// percentile becomes black and the one at the high percentile becomes white, e.g. 1 and 99. See
+// PercentileLUT.
+func AutoContrastPercentile(img *image.Gray, low, high float64) {
+	if lut, ok := PercentileLUT(Histogram(img), low, high); ok {
+		lut.Apply(img)
 	}
-
-	applyLookup(img, &lut)
 }
 
 // FitRect scales an image.Rectangle to fit into a bounding box of x by y without changing the
 // aspect ratio.
 func FitRect(rect image.Rectangle, x, y int) image.Rectangle {
-	width, height := float64(rect.Dx()), float64(rect.Dy())
-	scale := math.Min(float64(x)/width, float64(y)/height)
+	return scaleRect(rect, math.Min(float64(x)/float64(rect.Dx()), float64(y)/float64(rect.Dy())))
+}
+
+// FitWidth scales an image.Rectangle to a width of x without changing the aspect ratio. The height
+// is unconstrained.
+func FitWidth(rect image.Rectangle, x int) image.Rectangle {
+	return scaleRect(rect, float64(x)/float64(rect.Dx()))
+}
+
+// FitHeight scales an image.Rectangle to a height of y without changing the aspect ratio. The width
+// is unconstrained.
+func FitHeight(rect image.Rectangle, y int) image.Rectangle {
+	return scaleRect(rect, float64(y)/float64(rect.Dy()))
+}
+
+// Cover scales an image.Rectangle to cover a bounding box of x by y without changing the aspect
+// ratio, so that one dimension matches the box and the other one is at least as large. Callers
+// usually crop the scaled image to the box afterwards.
+func Cover(rect image.Rectangle, x, y int) image.Rectangle {
+	return scaleRect(rect, math.Max(float64(x)/float64(rect.Dx()), float64(y)/float64(rect.Dy())))
+}
+
+// scaleRect scales the size of rect by scale. rect is returned as is if scale is 1, otherwise the
+// returned rectangle's origin is at 0, 0.
+func scaleRect(rect image.Rectangle, scale float64) image.Rectangle {
 	if scale == 1 {
 		return rect
 	}
+	width, height := float64(rect.Dx()), float64(rect.Dy())
 	return image.Rect(0, 0, int(math.Round(scale*width)), int(math.Round(scale*height)))
 }
+
+// QuantizeRect rounds the width and height of rect down to multiples of step, so that images of
+// slightly different sizes end up the same size. Dimensions smaller than step are left as is. The
+// returned rectangle's origin is at 0, 0, unless rect is returned unchanged.
+func QuantizeRect(rect image.Rectangle, step int) image.Rectangle {
+	if step <= 1 {
+		return rect
+	}
+	w, h := rect.Dx(), rect.Dy()
+	if w >= step {
+		w -= w % step
+	}
+	if h >= step {
+		h -= h % step
+	}
+	if w == rect.Dx() && h == rect.Dy() {
+		return rect
+	}
+	return image.Rect(0, 0, w, h)
+}
+
+// Pad copies src into the center of dst and fills the remaining area of dst with fill. If src is
+// larger than dst, it's cropped to the center. When the difference d in size is odd, d/2 pixels go
+// before src and d-d/2 after it, so that src doesn't drift by a pixel.
+func Pad(dst, src *image.Gray, fill uint8) {
+	dx, dy := dst.Rect.Dx()-src.Rect.Dx(), dst.Rect.Dy()-src.Rect.Dy()
+	off := image.Pt(dx/2, dy/2)
+	r := src.Rect.Sub(src.Rect.Min).Add(dst.Rect.Min).Add(off).Intersect(dst.Rect)
+	for y := dst.Rect.Min.Y; y < dst.Rect.Max.Y; y++ {
+		row := dst.Pix[dst.PixOffset(dst.Rect.Min.X, y) : dst.PixOffset(dst.Rect.Min.X, y)+dst.Rect.Dx()]
+		if y < r.Min.Y || y >= r.Max.Y {
+			fillRow(row, fill)
+			continue
+		}
+		left := r.Min.X - dst.Rect.Min.X
+		right := r.Max.X - dst.Rect.Min.X
+		fillRow(row[:left], fill)
+		sy := y - dst.Rect.Min.Y - off.Y + src.Rect.Min.Y
+		sx := r.Min.X - dst.Rect.Min.X - off.X + src.Rect.Min.X
+		copy(row[left:right], src.Pix[src.PixOffset(sx, sy):])
+		fillRow(row[right:], fill)
+	}
+}
+
+func fillRow(row []uint8, v uint8) {
+	for i := range row {
+		row[i] = v
+	}
+}
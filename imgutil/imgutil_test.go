@@ -7,6 +7,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 
+	"github.com/naisuuuu/mangaconv/imgtest"
 	"github.com/naisuuuu/mangaconv/imgutil"
 )
 
@@ -91,7 +92,7 @@ func TestAdjustGamma(t *testing.T) {
 }
 
 func BenchmarkAdjustGamma(b *testing.B) {
-	src := mustBeGray(mustReadImg("testdata/wikipe-tan-Gray.png"))
+	src := imgtest.MustBeGray(imgtest.MustRead("testdata/wikipe-tan-Gray.png"))
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		b.StopTimer()
@@ -102,6 +103,34 @@ func BenchmarkAdjustGamma(b *testing.B) {
 	}
 }
 
+func TestAdjustBrightnessContrast(t *testing.T) {
+	tests := []struct {
+		name       string
+		brightness float64
+		contrast   float64
+		want       []uint8
+	}{
+		{"unchanged", 0, 0, []uint8{0x00, 0x40, 0x80, 0xc0, 0xff}},
+		{"brighter", 0.25, 0, []uint8{0x40, 0x80, 0xc0, 0xff, 0xff}},
+		{"darker", -0.25, 0, []uint8{0x00, 0x00, 0x40, 0x80, 0xbf}},
+		{"more contrast", 0, 1, []uint8{0x00, 0x01, 0x81, 0xff, 0xff}},
+		{"no contrast", 0, -1, []uint8{0x80, 0x80, 0x80, 0x80, 0x80}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := &image.Gray{
+				Pix:    []uint8{0x00, 0x40, 0x80, 0xc0, 0xff},
+				Stride: 5,
+				Rect:   image.Rect(0, 0, 5, 1),
+			}
+			imgutil.AdjustBrightnessContrast(img, tt.brightness, tt.contrast)
+			if diff := cmp.Diff(tt.want, img.Pix); diff != "" {
+				t.Errorf("AdjustBrightnessContrast() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 func TestHistogram(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -120,6 +149,19 @@ func TestHistogram(t *testing.T) {
 			},
 			want: [256]uint{0x00: 2, 0x80: 1, 0xff: 1},
 		},
+		{
+			name: "sub image",
+			image: (&image.Gray{
+				Rect:   image.Rect(0, 0, 3, 3),
+				Stride: 3,
+				Pix: []uint8{
+					0x00, 0xff, 0x10,
+					0x80, 0x00, 0x10,
+					0x10, 0x10, 0x10,
+				},
+			}).SubImage(image.Rect(0, 0, 2, 2)).(*image.Gray),
+			want: [256]uint{0x00: 2, 0x80: 1, 0xff: 1},
+		},
 		{
 			name:  "empty",
 			image: &image.Gray{},
@@ -136,8 +178,23 @@ func TestHistogram(t *testing.T) {
 	}
 }
 
+func TestHistogram16(t *testing.T) {
+	img := &image.Gray16{
+		Rect:   image.Rect(-1, -1, 1, 1),
+		Stride: 4,
+		Pix: []uint8{
+			0x00, 0x00, 0xff, 0xff,
+			0x80, 0x80, 0x80, 0x7f,
+		},
+	}
+	want := [256]uint{0x00: 1, 0x80: 2, 0xff: 1}
+	if diff := cmp.Diff(want, imgutil.Histogram16(img)); diff != "" {
+		t.Errorf("Histogram16() mismatch (-want +got):\n%s", diff)
+	}
+}
+
 func BenchmarkHistogram(b *testing.B) {
-	src := mustBeGray(mustReadImg("testdata/wikipe-tan-Gray.png"))
+	src := imgtest.MustBeGray(imgtest.MustRead("testdata/wikipe-tan-Gray.png"))
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		b.StopTimer()
@@ -157,30 +214,121 @@ func TestAutoContrast(t *testing.T) {
 	}{
 		{
 			name:   "1 percent cutoff",
-			image:  mustBeGray(mustReadImg("testdata/wikipe-tan-Gray.png")),
+			image:  imgtest.MustBeGray(imgtest.MustRead("testdata/wikipe-tan-Gray.png")),
 			cutoff: 1,
 			want:   82,
 		},
 		{
 			name:   "0 percent cutoff",
-			image:  mustBeGray(mustReadImg("testdata/wikipe-tan-Gray.png")),
+			image:  imgtest.MustBeGray(imgtest.MustRead("testdata/wikipe-tan-Gray.png")),
 			cutoff: 0,
 			want:   74,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			imgutil.AutoContrast(tt.image, tt.cutoff)
+			orig := cloneGray(tt.image)
+			lut := imgutil.AutoContrast(tt.image, tt.cutoff)
 			got := median(tt.image.Pix)
 			if got != tt.want {
 				t.Errorf("AutoContrast() median = %d, want %d", got, tt.want)
 			}
+			if want, _ := imgutil.AutoContrastLUT(imgutil.Histogram(orig), tt.cutoff); lut != want {
+				t.Errorf("AutoContrast() returned a different LUT than AutoContrastLUT()")
+			}
+			lut.Apply(orig)
+			if diff := cmp.Diff(tt.image.Pix, orig.Pix); diff != "" {
+				t.Errorf("applying returned LUT mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPreserveToneLUT(t *testing.T) {
+	// A washed out page: a light gray wash between dark gray lines and off-white paper.
+	var hist [256]uint
+	hist[40], hist[150], hist[220] = 10, 60, 30
+	lut, ok := imgutil.PreserveToneLUT(hist, 0)
+	if !ok {
+		t.Fatal("PreserveToneLUT() ok = false, want true")
+	}
+	want := map[uint8]uint8{40: 0, 150: 150, 220: 255}
+	for in, out := range want {
+		if lut[in] != out {
+			t.Errorf("PreserveToneLUT() maps %d to %d, want %d", in, lut[in], out)
+		}
+	}
+	for i := 1; i < 256; i++ {
+		if lut[i] < lut[i-1] {
+			t.Fatalf("PreserveToneLUT() isn't monotonic at %d: %d < %d", i, lut[i], lut[i-1])
+		}
+	}
+
+	var single [256]uint
+	single[100] = 10
+	if _, ok := imgutil.PreserveToneLUT(single, 0); ok {
+		t.Error("PreserveToneLUT() of a single color ok = true, want false")
+	}
+}
+
+func TestAutoContrastPercentile(t *testing.T) {
+	ramp := func() *image.Gray {
+		img := image.NewGray(image.Rect(0, 0, 256, 1))
+		for i := range img.Pix {
+			img.Pix[i] = uint8(i)
+		}
+		return img
+	}
+	// A solid black panel covering most of the page.
+	panel := image.NewGray(image.Rect(0, 0, 100, 1))
+	for i := range panel.Pix {
+		if i >= 60 {
+			panel.Pix[i] = uint8(100 + i)
+		}
+	}
+	tests := []struct {
+		name      string
+		image     *image.Gray
+		low, high float64
+		want      map[uint8]uint8
+	}{
+		{
+			name:  "ramp",
+			image: ramp(),
+			low:   10,
+			high:  90,
+			want:  map[uint8]uint8{0: 0, 25: 0, 128: 128, 230: 255, 255: 255},
+		},
+		{
+			name:  "black panel",
+			image: panel,
+			low:   1,
+			high:  99,
+			want:  map[uint8]uint8{0: 0, 160: 206, 198: 255, 199: 255},
+		},
+		{
+			name:  "single color",
+			image: image.NewGray(image.Rect(0, 0, 10, 10)),
+			low:   1,
+			high:  99,
+			want:  map[uint8]uint8{0: 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orig := cloneGray(tt.image)
+			imgutil.AutoContrastPercentile(tt.image, tt.low, tt.high)
+			for i, v := range orig.Pix {
+				if want, ok := tt.want[v]; ok && tt.image.Pix[i] != want {
+					t.Errorf("AutoContrastPercentile() maps %d to %d, want %d", v, tt.image.Pix[i], want)
+				}
+			}
 		})
 	}
 }
 
 func BenchmarkAutoContrast(b *testing.B) {
-	src := mustBeGray(mustReadImg("testdata/wikipe-tan-Gray.png"))
+	src := imgtest.MustBeGray(imgtest.MustRead("testdata/wikipe-tan-Gray.png"))
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		b.StopTimer()
@@ -190,3 +338,193 @@ func BenchmarkAutoContrast(b *testing.B) {
 		imgutil.AutoContrast(img, 1)
 	}
 }
+
+func TestPad(t *testing.T) {
+	src := &image.Gray{
+		Rect:   image.Rect(0, 0, 2, 2),
+		Stride: 2,
+		Pix: []uint8{
+			0x11, 0x22,
+			0x33, 0x44,
+		},
+	}
+	tests := []struct {
+		name string
+		dst  *image.Gray
+		want []uint8
+	}{
+		{
+			name: "pad",
+			dst:  image.NewGray(image.Rect(0, 0, 4, 3)),
+			want: []uint8{
+				0x80, 0x11, 0x22, 0x80,
+				0x80, 0x33, 0x44, 0x80,
+				0x80, 0x80, 0x80, 0x80,
+			},
+		},
+		{
+			name: "odd",
+			dst:  image.NewGray(image.Rect(0, 0, 5, 5)),
+			want: []uint8{
+				0x80, 0x80, 0x80, 0x80, 0x80,
+				0x80, 0x11, 0x22, 0x80, 0x80,
+				0x80, 0x33, 0x44, 0x80, 0x80,
+				0x80, 0x80, 0x80, 0x80, 0x80,
+				0x80, 0x80, 0x80, 0x80, 0x80,
+			},
+		},
+		{
+			name: "same size",
+			dst:  image.NewGray(image.Rect(0, 0, 2, 2)),
+			want: []uint8{
+				0x11, 0x22,
+				0x33, 0x44,
+			},
+		},
+		{
+			name: "crop",
+			dst:  image.NewGray(image.Rect(0, 0, 1, 2)),
+			want: []uint8{
+				0x11,
+				0x33,
+			},
+		},
+		{
+			name: "odd crop",
+			dst:  image.NewGray(image.Rect(0, 0, 1, 1)),
+			want: []uint8{0x11},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imgutil.Pad(tt.dst, src, 0x80)
+			if diff := cmp.Diff(tt.want, tt.dst.Pix); diff != "" {
+				t.Errorf("Pad() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLUTThen(t *testing.T) {
+	src := imgtest.MustBeGray(imgtest.MustRead("testdata/wikipe-tan-Gray.png"))
+	want := cloneGray(src)
+	imgutil.AutoContrast(want, 1)
+	imgutil.AdjustGamma(want, 0.75)
+
+	got := cloneGray(src)
+	contrast, _ := imgutil.ContrastLUT(got, 1)
+	gamma := imgutil.GammaLUT(0.75)
+	lut := contrast.Then(&gamma)
+	lut.Apply(got)
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("LUT.Then() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLUT16(t *testing.T) {
+	gamma := imgutil.GammaLUT(0.75)
+	lut := imgutil.NewLUT16(imgutil.GammaFunc(0.75))
+	img := image.NewGray16(image.Rect(0, 0, 256, 1))
+	for i := 0; i < 256; i++ {
+		img.Pix[i*2], img.Pix[i*2+1] = uint8(i), uint8(i)
+	}
+	lut.Apply(img)
+	for i := 0; i < 256; i++ {
+		v := uint32(img.Pix[i*2])<<8 | uint32(img.Pix[i*2+1])
+		if got := uint8((v*0xff + 0x7fff) / 0xffff); got != gamma[i] {
+			t.Errorf("level %d = %d rounded to 8 bits, want %d", i, got, gamma[i])
+		}
+	}
+}
+
+func TestLUT16Then(t *testing.T) {
+	invert := imgutil.NewLUT16(func(level float64) float64 { return 255 - level })
+	gamma := imgutil.NewLUT16(imgutil.GammaFunc(0.75))
+	got := invert.Then(gamma)
+	for _, v := range []int{0, 0x1234, 0x8000, 0xffff} {
+		if want := gamma[invert[v]]; got[v] != want {
+			t.Errorf("level %#04x = %#04x, want %#04x", v, got[v], want)
+		}
+	}
+}
+
+func TestFitRects(t *testing.T) {
+	tests := []struct {
+		name string
+		fit  func(image.Rectangle) image.Rectangle
+		rect image.Rectangle
+		want image.Rectangle
+	}{
+		{
+			name: "FitRect portrait",
+			fit:  func(r image.Rectangle) image.Rectangle { return imgutil.FitRect(r, 100, 100) },
+			rect: image.Rect(0, 0, 200, 400),
+			want: image.Rect(0, 0, 50, 100),
+		},
+		{
+			name: "FitRect unchanged",
+			fit:  func(r image.Rectangle) image.Rectangle { return imgutil.FitRect(r, 100, 200) },
+			rect: image.Rect(10, 10, 110, 210),
+			want: image.Rect(10, 10, 110, 210),
+		},
+		{
+			name: "FitWidth",
+			fit:  func(r image.Rectangle) image.Rectangle { return imgutil.FitWidth(r, 100) },
+			rect: image.Rect(0, 0, 200, 3000),
+			want: image.Rect(0, 0, 100, 1500),
+		},
+		{
+			name: "FitWidth upscale",
+			fit:  func(r image.Rectangle) image.Rectangle { return imgutil.FitWidth(r, 100) },
+			rect: image.Rect(0, 0, 50, 75),
+			want: image.Rect(0, 0, 100, 150),
+		},
+		{
+			name: "FitHeight",
+			fit:  func(r image.Rectangle) image.Rectangle { return imgutil.FitHeight(r, 100) },
+			rect: image.Rect(0, 0, 400, 200),
+			want: image.Rect(0, 0, 200, 100),
+		},
+		{
+			name: "Cover portrait",
+			fit:  func(r image.Rectangle) image.Rectangle { return imgutil.Cover(r, 100, 100) },
+			rect: image.Rect(0, 0, 200, 400),
+			want: image.Rect(0, 0, 100, 200),
+		},
+		{
+			name: "Cover landscape",
+			fit:  func(r image.Rectangle) image.Rectangle { return imgutil.Cover(r, 100, 100) },
+			rect: image.Rect(0, 0, 300, 200),
+			want: image.Rect(0, 0, 150, 100),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.fit(tt.rect); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuantizeRect(t *testing.T) {
+	tests := []struct {
+		rect image.Rectangle
+		step int
+		want image.Rectangle
+	}{
+		{image.Rect(0, 0, 1357, 1920), 4, image.Rect(0, 0, 1356, 1920)},
+		{image.Rect(0, 0, 1359, 1918), 4, image.Rect(0, 0, 1356, 1916)},
+		{image.Rect(0, 0, 3, 1918), 4, image.Rect(0, 0, 3, 1916)},
+		{image.Rect(10, 10, 1367, 1930), 1, image.Rect(10, 10, 1367, 1930)},
+		{image.Rect(10, 10, 1366, 1930), 4, image.Rect(10, 10, 1366, 1930)},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v/%d", tt.rect, tt.step), func(t *testing.T) {
+			if got := imgutil.QuantizeRect(tt.rect, tt.step); got != tt.want {
+				t.Errorf("QuantizeRect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
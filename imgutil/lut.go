@@ -0,0 +1,279 @@
+package imgutil
+
+import (
+	"image"
+	"math"
+)
+
+// LUT is a lookup table mapping each gray level to a new one. Tables which don't depend on image
+// contents, such as GammaLUT, can be computed once and reused for many images.
+type LUT [256]uint8
+
+// IdentityLUT returns a lookup table which doesn't change the image.
+func IdentityLUT() LUT {
+	var lut LUT
+	for i := range lut {
+		lut[i] = uint8(i)
+	}
+	return lut
+}
+
+// GammaLUT returns a lookup table applying gamma adjustments. See AdjustGamma.
+func GammaLUT(gamma float64) LUT {
+	if gamma == 1 {
+		return IdentityLUT()
+	}
+	return tabulate(GammaFunc(gamma))
+}
+
+// GammaFunc returns the function tabulated by GammaLUT, mapping gray levels in [0, 255] to new ones
+// without rounding them.
+func GammaFunc(gamma float64) func(level float64) float64 {
+	return func(level float64) float64 {
+		return math.Pow(clampLevel(level)/255, 1/gamma) * 255
+	}
+}
+
+// BrightnessContrastLUT returns a lookup table applying a linear brightness and contrast
+// adjustment. See AdjustBrightnessContrast.
+func BrightnessContrastLUT(brightness, contrast float64) LUT {
+	if brightness == 0 && contrast == 0 {
+		return IdentityLUT()
+	}
+	return tabulate(BrightnessContrastFunc(brightness, contrast))
+}
+
+// BrightnessContrastFunc returns the function tabulated by BrightnessContrastLUT, mapping gray
+// levels in [0, 255] to new ones without rounding them. Results are clamped to [0, 255].
+func BrightnessContrastFunc(brightness, contrast float64) func(level float64) float64 {
+	factor := 1 + contrast
+	if factor < 0 {
+		factor = 0
+	}
+	return func(level float64) float64 {
+		return clampLevel((level-127.5)*factor + 127.5 + brightness*255)
+	}
+}
+
+// tabulate returns a lookup table of f at each gray level, rounded.
+func tabulate(f func(level float64) float64) LUT {
+	var lut LUT
+	for i := range lut {
+		lut[i] = clamp(f(float64(i)))
+	}
+	return lut
+}
+
+// clampLevel clamps a fractional gray level to [0, 255].
+func clampLevel(level float64) float64 {
+	return math.Max(0, math.Min(255, level))
+}
+
+// ContrastLUT returns a lookup table applying histogram normalization to img, ignoring specified
+// cutoff % highest and lowest values. If the image can't be normalized, such as when it's a single
+// color, ok is false.
+//
+// This implementation is taken from Pillow's ImageOps.autocontrast method. See:
+// https://pillow.readthedocs.io/en/stable/_modules/PIL/ImageOps.html#autocontrast
+func ContrastLUT(img *image.Gray, cutoff float64) (lut LUT, ok bool) {
+	return AutoContrastLUT(Histogram(img), cutoff)
+}
+
+// AutoContrastLUT returns a lookup table applying histogram normalization to images with histogram
+// hist, as returned by Histogram. Summing the histograms of several images gives a table
+// normalizing all of them alike. See ContrastLUT.
+func AutoContrastLUT(hist [256]uint, cutoff float64) (lut LUT, ok bool) {
+	lo, hi := contrastRange(hist, cutoff)
+	if hi <= lo {
+		return IdentityLUT(), false
+	}
+
+	scale := 255 / float64(hi-lo)
+	offset := float64(-lo) * scale
+	for i := 0; i < 256; i++ {
+		lut[i] = clamp(float64(i)*scale + offset)
+	}
+	return lut, true
+}
+
+// PreserveToneLUT returns a lookup table stretching the contrast of images with histogram hist like
+// AutoContrastLUT, but with a gamma curve anchoring the median gray level at its original tone.
+// Darkest and brightest pixels are still stretched to black and white, while midtones such as gray
+// washes keep their brightness instead of being brightened or darkened along with them.
+func PreserveToneLUT(hist [256]uint, cutoff float64) (lut LUT, ok bool) {
+	lo, hi := contrastRange(hist, cutoff)
+	if hi <= lo {
+		return IdentityLUT(), false
+	}
+	median := float64(HistogramStats(hist).Median())
+	// The median's position in the stretched range, which the curve maps back to the median.
+	t := (median - float64(lo)) / float64(hi-lo)
+	exp := 1.0
+	if t > 0 && t < 1 && median > 0 && median < 255 {
+		exp = math.Log(median/255) / math.Log(t)
+	}
+	for i := 0; i < 256; i++ {
+		v := (float64(i) - float64(lo)) / float64(hi-lo)
+		if v <= 0 {
+			lut[i] = 0
+			continue
+		}
+		lut[i] = clamp(math.Pow(v, exp) * 255)
+	}
+	return lut, true
+}
+
+// contrastRange returns the lowest and highest gray levels of histogram hist after ignoring cutoff
+// % of the darkest and brightest pixels.
+func contrastRange(hist [256]uint, cutoff float64) (lo, hi int) {
+	var n uint
+	for _, v := range hist {
+		n += v
+	}
+
+	// Cutoff % of lowest/highest samples.
+	if cutoff > 0 {
+		cutl := uint(float64(n) * cutoff / 100)
+		cuth := cutl
+		for i := 0; i < 256; i++ {
+			if hist[i] >= cutl {
+				hist[i] -= cutl
+				break
+			}
+			cutl -= hist[i]
+			hist[i] = 0
+		}
+		for i := 255; i >= 0; i-- {
+			if hist[i] >= cuth {
+				hist[i] -= cuth
+				break
+			}
+			cuth -= hist[i]
+			hist[i] = 0
+		}
+	}
+
+	// Find lowest/highest samples.
+	for i := 0; i < 256; i++ {
+		if hist[i] > 0 {
+			lo = i
+			break
+		}
+	}
+	for i := 255; i >= 0; i-- {
+		if hist[i] > 0 {
+			hi = i
+			break
+		}
+	}
+	return lo, hi
+}
+
+// PercentileLUT returns a lookup table stretching the contrast of images with histogram hist, as
+// returned by Histogram, so that the gray level at the low percentile maps to 0 and the one at the
+// high percentile maps to 255. If the levels at both percentiles are the same, ok is false.
+//
+// Unlike the cutoff of AutoContrastLUT, which drops the same number of pixels from both ends, the
+// percentiles are fixed points of the whole histogram. Pages with large solid black panels keep
+// their shadows, rather than having part of the panels counted towards the cutoff.
+func PercentileLUT(hist [256]uint, low, high float64) (lut LUT, ok bool) {
+	stats := HistogramStats(hist)
+	lo, hi := int(stats.Percentile(low)), int(stats.Percentile(high))
+	if hi <= lo {
+		return IdentityLUT(), false
+	}
+	scale := 255 / float64(hi-lo)
+	offset := float64(-lo) * scale
+	for i := 0; i < 256; i++ {
+		lut[i] = clamp(float64(i)*scale + offset)
+	}
+	return lut, true
+}
+
+// Then returns a lookup table equivalent to applying l followed by next.
+func (l *LUT) Then(next *LUT) LUT {
+	var lut LUT
+	for i, v := range l {
+		lut[i] = next[v]
+	}
+	return lut
+}
+
+// At returns the gray level level is mapped to, interpolating linearly between the table's entries
+// for fractional levels. Levels outside of [0, 255] are clamped.
+func (l *LUT) At(level float64) float64 {
+	level = clampLevel(level)
+	i := int(level)
+	if i == 255 {
+		return float64(l[255])
+	}
+	frac := level - float64(i)
+	return float64(l[i])*(1-frac) + float64(l[i+1])*frac
+}
+
+// curveBits is the number of fractional bits of Curve indices.
+const curveBits = 4
+
+// CurveSteps is the number of entries of a Curve per gray level.
+const CurveSteps = 1 << curveBits
+
+// Curve is a lookup table like LUT, but for fractional gray levels, such as those of scaled pixels
+// before they're rounded to 8 bits. Entry i holds the 8-bit gray level that level i/CurveSteps is
+// mapped to. Applying gamma and contrast adjustments to fractional levels avoids the banding they
+// cause in dark gradients when applied to rounded ones.
+type Curve [255*CurveSteps + 1]uint8
+
+// NewCurve returns a curve of f, which maps gray levels in [0, 255] to new ones, rounding its
+// results.
+func NewCurve(f func(level float64) float64) *Curve {
+	var c Curve
+	for i := range c {
+		c[i] = clamp(f(float64(i) / CurveSteps))
+	}
+	return &c
+}
+
+// LUT16 is a lookup table mapping each 16-bit gray level to a new one, such as to adjust 16-bit
+// intermediates of scaled pages before dithering them down to 8 bits.
+type LUT16 [1 << 16]uint16
+
+// NewLUT16 returns a lookup table of f, which maps gray levels in [0, 255] to new ones. Levels are
+// fractional, with 16-bit level v passed to f as v/257.
+func NewLUT16(f func(level float64) float64) *LUT16 {
+	var l LUT16
+	for i := range l {
+		v := f(float64(i)/0x101)*0x101 + 0.5
+		switch {
+		case v < 0:
+			v = 0
+		case v > 0xffff:
+			v = 0xffff
+		}
+		l[i] = uint16(v)
+	}
+	return &l
+}
+
+// Then returns a lookup table which applies l followed by next.
+func (l *LUT16) Then(next *LUT16) *LUT16 {
+	var lut LUT16
+	for i, v := range l {
+		lut[i] = next[v]
+	}
+	return &lut
+}
+
+// Apply applies the lookup table to a 16-bit image.
+func (l *LUT16) Apply(img *image.Gray16) {
+	for i := 0; i+1 < len(img.Pix); i += 2 {
+		v := l[uint16(img.Pix[i])<<8|uint16(img.Pix[i+1])]
+		img.Pix[i], img.Pix[i+1] = uint8(v>>8), uint8(v)
+	}
+}
+
+// Apply applies the lookup table to an image.
+func (l *LUT) Apply(img *image.Gray) {
+	for i := 0; i < len(img.Pix); i++ {
+		img.Pix[i] = l[img.Pix[i]]
+	}
+}
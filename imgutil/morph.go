@@ -0,0 +1,87 @@
+package imgutil
+
+import "image"
+
+// Shape is a structuring element of morphological operations, given as offsets from the center
+// pixel. The center itself should be included.
+type Shape []image.Point
+
+// Square returns a square shape of 2*radius+1 pixels on each side. Square(1) is the common 3x3
+// kernel.
+func Square(radius int) Shape {
+	var k Shape
+	for y := -radius; y <= radius; y++ {
+		for x := -radius; x <= radius; x++ {
+			k = append(k, image.Pt(x, y))
+		}
+	}
+	return k
+}
+
+// Disk returns a shape of all pixels at most radius pixels away from the center.
+func Disk(radius int) Shape {
+	var k Shape
+	for y := -radius; y <= radius; y++ {
+		for x := -radius; x <= radius; x++ {
+			if x*x+y*y <= radius*radius {
+				k = append(k, image.Pt(x, y))
+			}
+		}
+	}
+	return k
+}
+
+// Erode replaces each pixel with the darkest pixel covered by shape k, growing dark areas such as
+// lines on white paper. Pixels outside the image are ignored.
+func Erode(img *image.Gray, k Shape) {
+	morph(img, k, func(a, b uint8) bool { return a < b })
+}
+
+// Dilate replaces each pixel with the brightest pixel covered by shape k, growing bright areas and
+// removing dark specks smaller than the shape. Pixels outside the image are ignored.
+func Dilate(img *image.Gray, k Shape) {
+	morph(img, k, func(a, b uint8) bool { return a > b })
+}
+
+// Open erodes and then dilates the image, removing bright specks smaller than shape k while
+// keeping the size of larger shapes.
+func Open(img *image.Gray, k Shape) {
+	Erode(img, k)
+	Dilate(img, k)
+}
+
+// Close dilates and then erodes the image, removing dark specks smaller than shape k while
+// keeping the size of larger shapes.
+func Close(img *image.Gray, k Shape) {
+	Dilate(img, k)
+	Erode(img, k)
+}
+
+// morph replaces each pixel of img with the pixel covered by shape k which is better than all
+// others according to better.
+func morph(img *image.Gray, k Shape, better func(a, b uint8) bool) {
+	if len(k) == 0 {
+		return
+	}
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	src := make([]uint8, w*h)
+	for y := 0; y < h; y++ {
+		copy(src[y*w:(y+1)*w], img.Pix[y*img.Stride:])
+	}
+	concurrentIterate(h, func(y int) {
+		row := img.Pix[y*img.Stride : y*img.Stride+w]
+		for x := range row {
+			v := src[y*w+x]
+			for _, p := range k {
+				sx, sy := x+p.X, y+p.Y
+				if sx < 0 || sx >= w || sy < 0 || sy >= h {
+					continue
+				}
+				if s := src[sy*w+sx]; better(s, v) {
+					v = s
+				}
+			}
+			row[x] = v
+		}
+	})
+}
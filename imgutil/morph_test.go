@@ -0,0 +1,119 @@
+package imgutil_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+func TestOpen(t *testing.T) {
+	// A dark 2x2 square and a white speck inside a dark 3x3 square, away from the edges.
+	img := image.NewGray(image.Rect(0, 0, 12, 8))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	for y := 2; y < 4; y++ {
+		for x := 2; x < 4; x++ {
+			img.Pix[y*img.Stride+x] = 0x00
+		}
+	}
+	for y := 2; y < 5; y++ {
+		for x := 7; x < 10; x++ {
+			img.Pix[y*img.Stride+x] = 0x00
+		}
+	}
+	want := append([]uint8(nil), img.Pix...)
+	img.Pix[3*img.Stride+8] = 0xff
+
+	imgutil.Open(img, imgutil.Square(1))
+	if diff := cmp.Diff(want, img.Pix); diff != "" {
+		t.Errorf("Open() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestMorphology(t *testing.T) {
+	const (
+		o = 0xff
+		x = 0x00
+	)
+	// A dark 2x2 square and a single dark speck on white.
+	src := []uint8{
+		o, o, o, o, o, o,
+		o, x, x, o, o, o,
+		o, x, x, o, o, o,
+		o, o, o, o, o, o,
+		o, o, o, o, x, o,
+		o, o, o, o, o, o,
+	}
+	tests := []struct {
+		name string
+		fn   func(*image.Gray, imgutil.Shape)
+		k    imgutil.Shape
+		want []uint8
+	}{
+		{
+			name: "erode",
+			fn:   imgutil.Erode,
+			k:    imgutil.Square(1),
+			want: []uint8{
+				x, x, x, x, o, o,
+				x, x, x, x, o, o,
+				x, x, x, x, o, o,
+				x, x, x, x, x, x,
+				o, o, o, x, x, x,
+				o, o, o, x, x, x,
+			},
+		},
+		{
+			name: "dilate",
+			fn:   imgutil.Dilate,
+			k:    imgutil.Square(1),
+			want: []uint8{
+				o, o, o, o, o, o,
+				o, o, o, o, o, o,
+				o, o, o, o, o, o,
+				o, o, o, o, o, o,
+				o, o, o, o, o, o,
+				o, o, o, o, o, o,
+			},
+		},
+		{
+			name: "close removes speck",
+			fn:   imgutil.Close,
+			k:    imgutil.Square(1),
+			want: []uint8{
+				o, o, o, o, o, o,
+				o, o, o, o, o, o,
+				o, o, o, o, o, o,
+				o, o, o, o, o, o,
+				o, o, o, o, o, o,
+				o, o, o, o, o, o,
+			},
+		},
+		{
+			name: "erode with disk",
+			fn:   imgutil.Erode,
+			k:    imgutil.Disk(1),
+			want: []uint8{
+				o, x, x, o, o, o,
+				x, x, x, x, o, o,
+				x, x, x, x, o, o,
+				o, x, x, o, x, o,
+				o, o, o, x, x, x,
+				o, o, o, o, x, o,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := &image.Gray{Pix: append([]uint8(nil), src...), Stride: 6, Rect: image.Rect(0, 0, 6, 6)}
+			tt.fn(img, tt.k)
+			if diff := cmp.Diff(tt.want, img.Pix); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package imgutil
+
+import "image"
+
+const (
+	// gutterLevel is the minimum value of a pixel considered part of a gutter.
+	gutterLevel = 0xe0
+	// gutterNoise is the maximum fraction of non-gutter pixels tolerated in a gutter line, which
+	// allows for scanning artifacts and speech bubbles slightly overlapping gutters.
+	gutterNoise = 0.01
+)
+
+// DetectPanels finds comic panels separated by light gutters and returns their bounds.
+//
+// Panels are found by recursively cutting the page along horizontal and vertical lines consisting
+// of (almost) only light pixels. Panels are returned in top to bottom, then left to right order.
+// Pages without any gutters are returned as a single panel trimmed of its light borders. Blank
+// pages return no panels.
+func DetectPanels(img *image.Gray) []image.Rectangle {
+	minGutter := img.Rect.Dy() / 100
+	if w := img.Rect.Dx() / 100; w < minGutter {
+		minGutter = w
+	}
+	if minGutter < 2 {
+		minGutter = 2
+	}
+	minSize := image.Pt(img.Rect.Dx()/20, img.Rect.Dy()/20)
+
+	var panels []image.Rectangle
+	var cut func(r image.Rectangle)
+	cut = func(r image.Rectangle) {
+		r = trimGutters(img, r)
+		if r.Dx() < minSize.X || r.Dy() < minSize.Y {
+			return
+		}
+		for _, vertical := range []bool{false, true} {
+			if segs := splitGutters(img, r, vertical, minGutter); len(segs) > 1 {
+				for _, s := range segs {
+					cut(s)
+				}
+				return
+			}
+		}
+		panels = append(panels, r)
+	}
+	cut(img.Rect)
+	return panels
+}
+
+// isGutter reports whether row y (or column x, if vertical) within r consists of gutter pixels.
+func isGutter(img *image.Gray, r image.Rectangle, i int, vertical bool) bool {
+	var n, limit int
+	if vertical {
+		limit = int(float64(r.Dy()) * gutterNoise)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			if img.Pix[img.PixOffset(i, y)] < gutterLevel {
+				if n++; n > limit {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	limit = int(float64(r.Dx()) * gutterNoise)
+	row := img.Pix[img.PixOffset(r.Min.X, i):img.PixOffset(r.Max.X, i)]
+	for _, v := range row {
+		if v < gutterLevel {
+			if n++; n > limit {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// trimGutters shrinks r to exclude gutter rows and columns along its edges.
+func trimGutters(img *image.Gray, r image.Rectangle) image.Rectangle {
+	for r.Min.Y < r.Max.Y && isGutter(img, r, r.Min.Y, false) {
+		r.Min.Y++
+	}
+	for r.Max.Y > r.Min.Y && isGutter(img, r, r.Max.Y-1, false) {
+		r.Max.Y--
+	}
+	for r.Min.X < r.Max.X && isGutter(img, r, r.Min.X, true) {
+		r.Min.X++
+	}
+	for r.Max.X > r.Min.X && isGutter(img, r, r.Max.X-1, true) {
+		r.Max.X--
+	}
+	return r
+}
+
+// splitGutters splits r along horizontal (or vertical) gutters at least minGutter pixels wide.
+func splitGutters(img *image.Gray, r image.Rectangle, vertical bool, minGutter int) []image.Rectangle {
+	lo, hi := r.Min.Y, r.Max.Y
+	if vertical {
+		lo, hi = r.Min.X, r.Max.X
+	}
+	var segs []image.Rectangle
+	add := func(from, to int) {
+		s := r
+		if vertical {
+			s.Min.X, s.Max.X = from, to
+		} else {
+			s.Min.Y, s.Max.Y = from, to
+		}
+		segs = append(segs, s)
+	}
+
+	start, run := lo, 0
+	for i := lo; i < hi; i++ {
+		if isGutter(img, r, i, vertical) {
+			run++
+			continue
+		}
+		if run >= minGutter && i-run > start {
+			add(start, i-run)
+			start = i
+		}
+		run = 0
+	}
+	add(start, hi)
+	return segs
+}
@@ -0,0 +1,65 @@
+package imgutil_test
+
+import (
+	"image"
+	"image/draw"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+func TestDetectPanels(t *testing.T) {
+	page := func(panels ...image.Rectangle) *image.Gray {
+		img := image.NewGray(image.Rect(0, 0, 200, 300))
+		draw.Draw(img, img.Rect, image.White, image.Point{}, draw.Src)
+		for _, p := range panels {
+			draw.Draw(img, p, image.Black, image.Point{}, draw.Src)
+		}
+		return img
+	}
+	tests := []struct {
+		name string
+		img  *image.Gray
+		want []image.Rectangle
+	}{
+		{
+			name: "blank",
+			img:  page(),
+		},
+		{
+			name: "single",
+			img:  page(image.Rect(10, 10, 190, 290)),
+			want: []image.Rectangle{image.Rect(10, 10, 190, 290)},
+		},
+		{
+			name: "grid",
+			img: page(
+				image.Rect(10, 10, 190, 100),
+				image.Rect(10, 110, 95, 290),
+				image.Rect(105, 110, 190, 200),
+				image.Rect(105, 210, 190, 290),
+			),
+			want: []image.Rectangle{
+				image.Rect(10, 10, 190, 100),
+				image.Rect(10, 110, 95, 290),
+				image.Rect(105, 110, 190, 200),
+				image.Rect(105, 210, 190, 290),
+			},
+		},
+		{
+			name: "speck ignored",
+			img:  page(image.Rect(10, 10, 190, 290), image.Rect(195, 295, 196, 296)),
+			want: []image.Rectangle{image.Rect(10, 10, 190, 290)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := imgutil.DetectPanels(tt.img)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("DetectPanels() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
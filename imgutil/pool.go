@@ -5,22 +5,58 @@ import (
 	"sync"
 )
 
-// NewImagePool creates an ImagePool.
+// DefaultPoolMaxBytes is the maximum size of pixel slices retained by a pool created with
+// NewImagePool.
+const DefaultPoolMaxBytes = 256 << 20
+
+// NewImagePool creates an ImagePool retaining at most DefaultPoolMaxBytes of pixel slices.
 func NewImagePool() *ImagePool {
+	return NewImagePoolSize(DefaultPoolMaxBytes)
+}
+
+// NewImagePoolSize creates an ImagePool retaining at most maxBytes of pixel slices. If maxBytes is
+// 0, the pool grows without bound.
+func NewImagePoolSize(maxBytes int) *ImagePool {
 	return &ImagePool{
-		cache: make(map[int]*sync.Pool),
+		free:     make(map[image.Point][][]uint8),
+		maxBytes: maxBytes,
 	}
 }
 
-// ImagePool maintains a sync.Pool of pixel arrays for each image resolution gotten from it.
+// ImagePool maintains free lists of pixel slices for each image resolution gotten from it. Pixel
+// slices put back once the pool is full are dropped and left to the garbage collector.
 type ImagePool struct {
-	cache map[int]*sync.Pool
-	mu    sync.Mutex
+	mu       sync.Mutex
+	free     map[image.Point][][]uint8
+	maxBytes int
+	stats    PoolStats
+}
+
+// PoolStats describes the usage of an ImagePool.
+//
+// Hits and Misses count images gotten from the pool which did or didn't reuse a pixel slice, so
+// their sum is the number of calls to Get. Puts counts images put back and Drops those of them
+// which were not retained, because the pool was full or their pixel slice didn't match their
+// bounds. Retained is the total size in bytes of pixel slices held by the pool.
+type PoolStats struct {
+	Hits     uint64
+	Misses   uint64
+	Puts     uint64
+	Drops    uint64
+	Retained int
+}
+
+// Stats returns the pool's usage statistics.
+func (p *ImagePool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
 }
 
 // GetFromImage converts an image into a grayscale image with pixel slice taken from the pool.
+// Images Luma can convert without copying are returned as such, sharing pixels with img.
 func (p *ImagePool) GetFromImage(img image.Image) *image.Gray {
-	if i, ok := img.(*image.Gray); ok {
+	if i, ok := Luma(img); ok {
 		return i
 	}
 	dst := p.Get(img.Bounds().Dx(), img.Bounds().Dy())
@@ -28,33 +64,76 @@ func (p *ImagePool) GetFromImage(img image.Image) *image.Gray {
 	return dst
 }
 
-func (p *ImagePool) getPool(pixLen int) *sync.Pool {
+// Get gets a grayscale image of specified width and height with pixel slice taken from the pool.
+// The pixel slice may contain pixels of an image previously put back into the pool, so callers
+// must overwrite the whole image. Use GetZeroed otherwise.
+func (p *ImagePool) Get(width, height int) *image.Gray {
+	size := image.Pt(width, height)
 	p.mu.Lock()
-	pool, ok := p.cache[pixLen]
-	if !ok {
-		pool = &sync.Pool{
-			New: func() interface{} {
-				tmp := make([]uint8, pixLen)
-				return &tmp
-			},
-		}
-		p.cache[pixLen] = pool
+	var pix []uint8
+	if l := p.free[size]; len(l) > 0 {
+		pix = l[len(l)-1]
+		p.free[size] = l[:len(l)-1]
+		p.stats.Retained -= len(pix)
+		p.stats.Hits++
+	} else {
+		p.stats.Misses++
 	}
 	p.mu.Unlock()
-	return pool
-}
 
-// Get gets a grayscale image of specified width and height with pixel slice taken from the pool.
-func (p *ImagePool) Get(width, height int) *image.Gray {
-	tmp := p.getPool(width * height).Get().(*[]uint8)
+	if pix == nil {
+		pix = make([]uint8, width*height)
+	}
 	return &image.Gray{
-		Pix:    *tmp,
+		Pix:    pix,
 		Stride: width,
 		Rect:   image.Rect(0, 0, width, height),
 	}
 }
 
-// Put puts an images pixel slice back into the pool.
+// GetZeroed is like Get, but the returned image is black.
+func (p *ImagePool) GetZeroed(width, height int) *image.Gray {
+	img := p.Get(width, height)
+	for i := range img.Pix {
+		img.Pix[i] = 0
+	}
+	return img
+}
+
+// Put puts an images pixel slice back into the pool. Images not laid out like those returned by
+// Get, such as sub images, are dropped.
 func (p *ImagePool) Put(img *image.Gray) {
-	p.getPool(len(img.Pix)).Put(&img.Pix)
+	size := img.Rect.Size()
+	n := size.X * size.Y
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stats.Puts++
+	if img.Stride != size.X || len(img.Pix) != n || n == 0 ||
+		(p.maxBytes > 0 && p.stats.Retained+n > p.maxBytes) {
+		p.stats.Drops++
+		return
+	}
+	p.free[size] = append(p.free[size], img.Pix)
+	p.stats.Retained += n
+}
+
+// Get16 is like Get, but for 16-bit grayscale images. Their pixel slices are shared with grayscale
+// images twice as wide.
+func (p *ImagePool) Get16(width, height int) *image.Gray16 {
+	img := p.Get(2*width, height)
+	return &image.Gray16{
+		Pix:    img.Pix,
+		Stride: img.Stride,
+		Rect:   image.Rect(0, 0, width, height),
+	}
+}
+
+// Put16 is like Put for images gotten with Get16.
+func (p *ImagePool) Put16(img *image.Gray16) {
+	size := img.Rect.Size()
+	p.Put(&image.Gray{
+		Pix:    img.Pix,
+		Stride: img.Stride,
+		Rect:   image.Rect(0, 0, 2*size.X, size.Y),
+	})
 }
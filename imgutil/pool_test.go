@@ -0,0 +1,61 @@
+package imgutil_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+func TestImagePool(t *testing.T) {
+	p := imgutil.NewImagePoolSize(100 * 200)
+
+	a := p.Get(100, 200)
+	p.Put(a)
+	if b := p.Get(200, 100); &b.Pix[0] == &a.Pix[0] {
+		t.Error("Get(200, 100) reused pixels of a 100x200 image")
+	}
+	if b := p.Get(100, 200); &b.Pix[0] != &a.Pix[0] {
+		t.Error("Get(100, 200) didn't reuse pixels of a 100x200 image")
+	}
+
+	// Pool is full after the first Put.
+	c, d := p.Get(100, 200), p.Get(100, 200)
+	p.Put(c)
+	p.Put(d)
+	// Sub images don't own their whole pixel slice.
+	p.Put(image.NewGray(image.Rect(0, 0, 10, 10)).SubImage(image.Rect(0, 0, 5, 5)).(*image.Gray))
+
+	want := imgutil.PoolStats{Hits: 1, Misses: 4, Puts: 4, Drops: 2, Retained: 100 * 200}
+	if diff := cmp.Diff(want, p.Stats()); diff != "" {
+		t.Errorf("Stats() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestImagePoolGetZeroed(t *testing.T) {
+	p := imgutil.NewImagePool()
+	img := p.Get(4, 4)
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	p.Put(img)
+
+	got := p.GetZeroed(4, 4)
+	if diff := cmp.Diff(make([]uint8, 16), got.Pix); diff != "" {
+		t.Errorf("GetZeroed() pixels mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestImagePoolGet16(t *testing.T) {
+	p := imgutil.NewImagePool()
+	a := p.Get16(100, 200)
+	if got := a.Bounds(); got != image.Rect(0, 0, 100, 200) {
+		t.Fatalf("Get16(100, 200) bounds = %v", got)
+	}
+	p.Put16(a)
+	if b := p.Get16(100, 200); &b.Pix[0] != &a.Pix[0] {
+		t.Error("Get16(100, 200) didn't reuse pixels of a 100x200 image")
+	}
+}
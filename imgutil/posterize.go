@@ -0,0 +1,59 @@
+package imgutil
+
+import "image"
+
+// Posterize reduces the image to levels evenly spaced gray levels, including black and white. E-ink
+// screens typically display 16 levels. Values of levels outside of [2, 256] are clamped.
+func Posterize(img *image.Gray, levels int) {
+	lut := PosterizeLUT(levels)
+	lut.Apply(img)
+}
+
+// PosterizeLUT returns a lookup table mapping each gray level to the nearest of levels evenly
+// spaced gray levels. See Posterize.
+func PosterizeLUT(levels int) LUT {
+	if levels < 2 {
+		levels = 2
+	}
+	if levels > 256 {
+		levels = 256
+	}
+	palette := make([]uint8, levels)
+	for i := range palette {
+		palette[i] = clamp(float64(i) * 255 / float64(levels-1))
+	}
+	return PaletteLUT(palette)
+}
+
+// QuantizePalette maps each pixel of the image to the nearest gray level in palette, such as the
+// levels a particular e-ink screen can display. An empty palette leaves the image as is.
+func QuantizePalette(img *image.Gray, palette []uint8) {
+	if len(palette) == 0 {
+		return
+	}
+	lut := PaletteLUT(palette)
+	lut.Apply(img)
+}
+
+// PaletteLUT returns a lookup table mapping each gray level to the nearest gray level in palette.
+// Ties are resolved towards the darker level. An empty palette returns IdentityLUT.
+func PaletteLUT(palette []uint8) LUT {
+	if len(palette) == 0 {
+		return IdentityLUT()
+	}
+	var lut LUT
+	for i := range lut {
+		best, dist := palette[0], 256
+		for _, p := range palette {
+			d := i - int(p)
+			if d < 0 {
+				d = -d
+			}
+			if d < dist || d == dist && p < best {
+				best, dist = p, d
+			}
+		}
+		lut[i] = best
+	}
+	return lut
+}
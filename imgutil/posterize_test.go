@@ -0,0 +1,62 @@
+package imgutil_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+func TestPosterize(t *testing.T) {
+	tests := []struct {
+		name   string
+		levels int
+		want   []uint8
+	}{
+		{"2 levels", 2, []uint8{0x00, 0x00, 0x00, 0xff, 0xff, 0xff}},
+		{"clamped", 1, []uint8{0x00, 0x00, 0x00, 0xff, 0xff, 0xff}},
+		{"16 levels", 16, []uint8{0x00, 0x11, 0x77, 0x88, 0xee, 0xff}},
+		{"256 levels", 256, []uint8{0x00, 0x10, 0x7f, 0x80, 0xf0, 0xff}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := &image.Gray{
+				Pix:    []uint8{0x00, 0x10, 0x7f, 0x80, 0xf0, 0xff},
+				Stride: 6,
+				Rect:   image.Rect(0, 0, 6, 1),
+			}
+			imgutil.Posterize(img, tt.levels)
+			if diff := cmp.Diff(tt.want, img.Pix); diff != "" {
+				t.Errorf("Posterize() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestQuantizePalette(t *testing.T) {
+	tests := []struct {
+		name    string
+		palette []uint8
+		want    []uint8
+	}{
+		{"empty", nil, []uint8{0x00, 0x40, 0x80, 0xc0, 0xff}},
+		{"single", []uint8{0x80}, []uint8{0x80, 0x80, 0x80, 0x80, 0x80}},
+		{"unsorted", []uint8{0xff, 0x00, 0x60}, []uint8{0x00, 0x60, 0x60, 0xff, 0xff}},
+		{"tie goes dark", []uint8{0x00, 0x80}, []uint8{0x00, 0x00, 0x80, 0x80, 0x80}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := &image.Gray{
+				Pix:    []uint8{0x00, 0x40, 0x80, 0xc0, 0xff},
+				Stride: 5,
+				Rect:   image.Rect(0, 0, 5, 1),
+			}
+			imgutil.QuantizePalette(img, tt.palette)
+			if diff := cmp.Diff(tt.want, img.Pix); diff != "" {
+				t.Errorf("QuantizePalette() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
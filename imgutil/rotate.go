@@ -0,0 +1,28 @@
+package imgutil
+
+import "image"
+
+// Rotate90 rotates src 90 degrees clockwise into dst. dst must be src's height wide and src's
+// width high.
+func Rotate90(dst, src *image.Gray) {
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	concurrentIterate(h, func(y int) {
+		row := src.Pix[y*src.Stride : y*src.Stride+w]
+		dx := h - 1 - y
+		for x, v := range row {
+			dst.Pix[x*dst.Stride+dx] = v
+		}
+	})
+}
+
+// Rotate270 rotates src 90 degrees counterclockwise into dst. dst must be src's height wide and
+// src's width high.
+func Rotate270(dst, src *image.Gray) {
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	concurrentIterate(h, func(y int) {
+		row := src.Pix[y*src.Stride : y*src.Stride+w]
+		for x, v := range row {
+			dst.Pix[(w-1-x)*dst.Stride+y] = v
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package imgutil_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+func TestRotate(t *testing.T) {
+	src := &image.Gray{
+		Rect:   image.Rect(0, 0, 3, 2),
+		Stride: 3,
+		Pix: []uint8{
+			0x01, 0x02, 0x03,
+			0x04, 0x05, 0x06,
+		},
+	}
+	tests := []struct {
+		name   string
+		rotate func(dst, src *image.Gray)
+		want   []uint8
+	}{
+		{
+			name:   "90",
+			rotate: imgutil.Rotate90,
+			want: []uint8{
+				0x04, 0x01,
+				0x05, 0x02,
+				0x06, 0x03,
+			},
+		},
+		{
+			name:   "270",
+			rotate: imgutil.Rotate270,
+			want: []uint8{
+				0x03, 0x06,
+				0x02, 0x05,
+				0x01, 0x04,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := image.NewGray(image.Rect(0, 0, 2, 3))
+			tt.rotate(dst, src)
+			if diff := cmp.Diff(tt.want, dst.Pix); diff != "" {
+				t.Errorf("rotate mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
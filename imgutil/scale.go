@@ -4,44 +4,152 @@
 package imgutil
 
 import (
+	"container/list"
 	"image"
 	"math"
 	"sync"
 )
 
-type cacheScaler struct {
-	kernel *Kernel
-	cache  map[cacheKey]Scaler
-	mu     sync.Mutex
+// DefaultCacheScalerSize is the maximum number of scalers kept by a CacheScaler created with
+// NewCacheScaler.
+const DefaultCacheScalerSize = 64
+
+// CacheScaler creates, caches and reuses kernel scalers optimized for each unique combination of
+// destination and source width and height. Least recently used scalers are evicted once the cache
+// is full.
+type CacheScaler struct {
+	kernel      *Kernel
+	maxEntries  int
+	concurrency int
+	fixed       bool
+	cache       map[cacheKey]*list.Element
+	lru         *list.List
+	stats       CacheStats
+	mu          sync.Mutex
 }
 
 type cacheKey struct {
 	dw, dh, sw, sh int
 }
 
+type cacheEntry struct {
+	key    cacheKey
+	scaler *kernelScaler
+}
+
+// CacheStats describes the usage of a CacheScaler.
+//
+// Hits and Misses count calls to Scale which did or didn't reuse a cached scaler. Evictions counts
+// scalers removed from a full cache. Entries is the number of currently cached scalers.
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+}
+
 // Scale implements the Scaler interface.
-func (z *cacheScaler) Scale(dst, src *image.Gray) {
-	key := cacheKey{dst.Rect.Dx(), dst.Rect.Dy(), src.Rect.Dx(), src.Rect.Dy()}
+func (z *CacheScaler) Scale(dst, src *image.Gray) {
+	z.ScaleCurve(dst, src, nil)
+}
+
+// ScaleCurve implements the CurveScaler interface.
+func (z *CacheScaler) ScaleCurve(dst, src *image.Gray, curve *Curve) {
+	s := z.scaler(dst.Rect.Dx(), dst.Rect.Dy(), src.Rect.Dx(), src.Rect.Dy())
+	if z.fixedPoint() {
+		s.scaleFixed(dst, src, z.conc(), curve)
+		return
+	}
+	s.scale(dst, src, z.conc(), curve)
+}
+
+// scaler returns a cached scaler for the given sizes, creating it if needed.
+func (z *CacheScaler) scaler(dw, dh, sw, sh int) *kernelScaler {
+	key := cacheKey{dw, dh, sw, sh}
 
 	z.mu.Lock()
-	scaler, ok := z.cache[key]
-	if !ok {
-		scaler = z.kernel.NewScaler(key.dw, key.dh, key.sw, key.sh)
-		z.cache[key] = scaler
+	defer z.mu.Unlock()
+	if e, ok := z.cache[key]; ok {
+		z.lru.MoveToFront(e)
+		z.stats.Hits++
+		return e.Value.(*cacheEntry).scaler
 	}
+	scaler := z.kernel.newScaler(dw, dh, sw, sh, true)
+	z.cache[key] = z.lru.PushFront(&cacheEntry{key, scaler})
+	z.stats.Misses++
+	if z.maxEntries > 0 && z.lru.Len() > z.maxEntries {
+		oldest := z.lru.Remove(z.lru.Back()).(*cacheEntry)
+		delete(z.cache, oldest.key)
+		z.stats.Evictions++
+	}
+	return scaler
+}
+
+// conc returns the number of goroutines scaling a single image.
+func (z *CacheScaler) conc() int {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	return z.concurrency
+}
+
+// fixedPoint reports whether 8-bit images are scaled with fixed-point arithmetic.
+func (z *CacheScaler) fixedPoint() bool {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	return z.fixed
+}
+
+// Scale16 implements the Scaler16 interface.
+func (z *CacheScaler) Scale16(dst *image.Gray, src *image.Gray16) {
+	z.scaler(dst.Rect.Dx(), dst.Rect.Dy(), src.Rect.Dx(), src.Rect.Dy()).scale16(dst, src, z.conc())
+}
+
+// ScaleFractional implements the FractionalScaler interface.
+func (z *CacheScaler) ScaleFractional(dst *image.Gray16, src *image.Gray) {
+	z.scaler(dst.Rect.Dx(), dst.Rect.Dy(), src.Rect.Dx(), src.Rect.Dy()).scaleFractional(dst, src, z.conc())
+}
+
+// SetConcurrency sets the number of goroutines scaling a single image. Values <= 1 scale on the
+// calling goroutine, which is best when many images are scaled at once.
+func (z *CacheScaler) SetConcurrency(n int) {
+	z.mu.Lock()
+	z.concurrency = n
 	z.mu.Unlock()
+}
 
-	scaler.Scale(dst, src)
+// SetFixedPoint sets whether 8-bit images are scaled with integer fixed-point arithmetic instead of
+// floating point. It's much faster on CPUs without a floating point unit, such as those of many
+// e-readers, and differs from floating point results by at most a gray level or two. 16-bit
+// sources and destinations are always scaled with floating point.
+func (z *CacheScaler) SetFixedPoint(fixed bool) {
+	z.mu.Lock()
+	z.fixed = fixed
+	z.mu.Unlock()
+}
+
+// Stats returns the cache's usage statistics.
+func (z *CacheScaler) Stats() CacheStats {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	stats := z.stats
+	stats.Entries = z.lru.Len()
+	return stats
+}
+
+// NewCacheScaler creates a CacheScaler keeping at most DefaultCacheScalerSize scalers. It is mostly
+// useful when scaling a large quantity of images in a few fixed sizes.
+func NewCacheScaler(k *Kernel) *CacheScaler {
+	return NewCacheScalerSize(k, DefaultCacheScalerSize)
 }
 
-// NewCacheScaler creates, caches and reuses kernel scalers optimized for each unique combination
-// of destination and source width and height. It is mostly useful when scaling a large quantity of
-// images in a few fixed sizes.
-func NewCacheScaler(k *Kernel) Scaler {
-	return &cacheScaler{
-		kernel: k,
-		cache:  make(map[cacheKey]Scaler),
-		mu:     sync.Mutex{},
+// NewCacheScalerSize creates a CacheScaler keeping at most maxEntries scalers. If maxEntries is 0,
+// scalers are never evicted.
+func NewCacheScalerSize(k *Kernel, maxEntries int) *CacheScaler {
+	return &CacheScaler{
+		kernel:     k,
+		maxEntries: maxEntries,
+		cache:      make(map[cacheKey]*list.Element),
+		lru:        list.New(),
 	}
 }
 
@@ -50,6 +158,35 @@ type Scaler interface {
 	Scale(dst, src *image.Gray)
 }
 
+// Scaler16 is a Scaler which can also scale 16-bit grayscale sources, such as high bit depth
+// archival scans. Sources are only rounded to 8 bits once scaled, so that no precision is lost to
+// truncation beforehand.
+type Scaler16 interface {
+	Scaler
+	Scale16(dst *image.Gray, src *image.Gray16)
+}
+
+// FractionalScaler is a Scaler which can also scale into 16-bit grayscale destinations, keeping the
+// fractional gray levels of scaled pixels for further adjustments, such as with a LUT16.
+type FractionalScaler interface {
+	Scaler
+	ScaleFractional(dst *image.Gray16, src *image.Gray)
+}
+
+// LUTScaler is a Scaler which can also apply lookup tables, such as on dedicated hardware. Results
+// must match LUT.Apply.
+type LUTScaler interface {
+	Scaler
+	ApplyLUT(img *image.Gray, lut *LUT)
+}
+
+// CurveScaler is a Scaler which can apply a Curve to scaled pixels before rounding them to 8 bits,
+// instead of applying a LUT to the rounded pixels afterwards.
+type CurveScaler interface {
+	Scaler
+	ScaleCurve(dst, src *image.Gray, curve *Curve)
+}
+
 // Kernel is an interpolator that blends source pixels weighted by a symmetric
 // kernel function.
 type Kernel struct {
@@ -66,13 +203,28 @@ func (q *Kernel) Scale(dst, src *image.Gray) {
 	q.newScaler(dst.Rect.Dx(), dst.Rect.Dy(), src.Rect.Dx(), src.Rect.Dy(), false).Scale(dst, src)
 }
 
+// ScaleCurve implements the CurveScaler interface.
+func (q *Kernel) ScaleCurve(dst, src *image.Gray, curve *Curve) {
+	q.newScaler(dst.Rect.Dx(), dst.Rect.Dy(), src.Rect.Dx(), src.Rect.Dy(), false).scale(dst, src, 1, curve)
+}
+
+// Scale16 implements the Scaler16 interface.
+func (q *Kernel) Scale16(dst *image.Gray, src *image.Gray16) {
+	q.newScaler(dst.Rect.Dx(), dst.Rect.Dy(), src.Rect.Dx(), src.Rect.Dy(), false).Scale16(dst, src)
+}
+
+// ScaleFractional implements the FractionalScaler interface.
+func (q *Kernel) ScaleFractional(dst *image.Gray16, src *image.Gray) {
+	q.newScaler(dst.Rect.Dx(), dst.Rect.Dy(), src.Rect.Dx(), src.Rect.Dy(), false).scaleFractional(dst, src, 1)
+}
+
 // NewScaler returns a Scaler that is optimized for scaling multiple times with
 // the same fixed destination and source width and height.
 func (q *Kernel) NewScaler(dw, dh, sw, sh int) Scaler {
 	return q.newScaler(dw, dh, sw, sh, true)
 }
 
-func (q *Kernel) newScaler(dw, dh, sw, sh int, usePool bool) Scaler {
+func (q *Kernel) newScaler(dw, dh, sw, sh int, usePool bool) *kernelScaler {
 	s := &kernelScaler{
 		kernel:     q,
 		dw:         int32(dw),
@@ -87,11 +239,21 @@ func (q *Kernel) newScaler(dw, dh, sw, sh int, usePool bool) Scaler {
 			tmp := s.makeTmpBuf()
 			return &tmp
 		}
+		s.fixedPool.New = func() interface{} {
+			tmp := s.makeFixedTmpBuf()
+			return &tmp
+		}
 	}
 	return s
 }
 
 var (
+	// BiLinear is the tent kernel. It is fast, but usually gives medium quality
+	// results, noticeably blurrier than CatmullRom.
+	BiLinear = &Kernel{1, func(t float64) float64 {
+		return 1 - t
+	}}
+
 	// CatmullRom is the Catmull-Rom kernel. It is very slow, but usually gives
 	// very high quality results.
 	//
@@ -104,6 +266,17 @@ var (
 		}
 		return ((-0.5*t+2.5)*t-4)*t + 2
 	}}
+
+	// Lanczos3 is the Lanczos kernel with a support of 3. It is even slower than
+	// CatmullRom, and keeps fine detail such as screentones sharper at the cost
+	// of slight ringing around edges.
+	Lanczos3 = &Kernel{3, func(t float64) float64 {
+		if t == 0 {
+			return 1
+		}
+		x := math.Pi * t
+		return 3 * math.Sin(x) * math.Sin(x/3) / (x * x)
+	}}
 )
 
 type kernelScaler struct {
@@ -111,12 +284,17 @@ type kernelScaler struct {
 	dw, dh, sw, sh       int32
 	horizontal, vertical distrib
 	pool                 sync.Pool
+	fixedPool            sync.Pool
 }
 
 func (z *kernelScaler) makeTmpBuf() []float64 {
 	return make([]float64, z.dw*z.sh)
 }
 
+func (z *kernelScaler) makeFixedTmpBuf() []int32 {
+	return make([]int32, z.dw*z.sh)
+}
+
 // source is a range of contribs, their inverse total weight, and that ITW
 // divided by 0xffff.
 type source struct {
@@ -125,12 +303,23 @@ type source struct {
 	invTotalWeightFFFF float64
 }
 
-// contrib is the weight of a column or row.
+// contrib is the weight of a column or row. fixed is the weight normalized by the total weight of
+// its source and scaled by 1<<fixedBits, so that the fixed weights of a source sum to exactly
+// 1<<fixedBits.
 type contrib struct {
 	coord  int32
 	weight float64
+	fixed  int32
 }
 
+// fixedBits is the number of fractional bits of fixed-point weights. Horizontally scaled values
+// keep fixedTmpBits of them, so that the vertical sums of products of 8-bit values and weights
+// still fit in 32 bits.
+const (
+	fixedBits    = 14
+	fixedTmpBits = 6
+)
+
 // distrib measures how source pixels are distributed over destination pixels.
 type distrib struct {
 	// sources are what contribs each column or row in the source image owns,
@@ -189,9 +378,10 @@ func newDistrib(q *Kernel, dw, sw int32) distrib {
 				continue
 			}
 			totalWeight += weight
-			contribs = append(contribs, contrib{coord, weight})
+			contribs = append(contribs, contrib{coord, weight, 0})
 		}
 		totalWeight = 1 / totalWeight
+		setFixedWeights(contribs[l:], totalWeight)
 		sources[k] = source{
 			i:                  l,
 			j:                  int32(len(contribs)),
@@ -203,6 +393,24 @@ func newDistrib(q *Kernel, dw, sw int32) distrib {
 	return distrib{sources, contribs}
 }
 
+// setFixedWeights sets the fixed weights of the contribs of a single source with the given inverse
+// total weight. Rounding errors are added to the largest weight, so that the weights sum to exactly
+// 1<<fixedBits and flat areas keep their exact value.
+func setFixedWeights(contribs []contrib, invTotalWeight float64) {
+	if len(contribs) == 0 {
+		return
+	}
+	sum, largest := int32(0), 0
+	for i := range contribs {
+		contribs[i].fixed = int32(math.Round(contribs[i].weight * invTotalWeight * (1 << fixedBits)))
+		sum += contribs[i].fixed
+		if contribs[i].fixed > contribs[largest].fixed {
+			largest = i
+		}
+	}
+	contribs[largest].fixed += 1<<fixedBits - sum
+}
+
 // abs is like math.Abs, but it doesn't care about negative zero, infinities or
 // NaNs.
 func abs(f float64) float64 {
@@ -225,14 +433,88 @@ func ftou(f float64) uint16 {
 }
 
 func (z *kernelScaler) Scale(dst, src *image.Gray) {
-	if z.dw != int32(dst.Rect.Dx()) ||
-		z.dh != int32(dst.Rect.Dy()) ||
-		z.sw != int32(src.Rect.Dx()) ||
-		z.sh != int32(src.Rect.Dy()) {
-		z.kernel.Scale(dst, src)
+	z.scale(dst, src, 1, nil)
+}
+
+func (z *kernelScaler) Scale16(dst *image.Gray, src *image.Gray16) {
+	z.scale16(dst, src, 1)
+}
+
+// fits reports whether the scaler was created for the given destination and source sizes.
+func (z *kernelScaler) fits(dst image.Rectangle, sw, sh int) bool {
+	return z.dw == int32(dst.Dx()) &&
+		z.dh == int32(dst.Dy()) &&
+		z.sw == int32(sw) &&
+		z.sh == int32(sh)
+}
+
+// scale scales src into dst, splitting work between n goroutines. If curve isn't nil, it's applied
+// to scaled pixels before rounding them.
+func (z *kernelScaler) scale(dst, src *image.Gray, n int, curve *Curve) {
+	if !z.fits(dst.Rect, src.Rect.Dx(), src.Rect.Dy()) {
+		z.kernel.newScaler(dst.Rect.Dx(), dst.Rect.Dy(), src.Rect.Dx(), src.Rect.Dy(), false).scale(dst, src, n, curve)
+		return
+	}
+	z.run(dst.Rect, n, func(tmp []float64, y0, y1 int32) {
+		z.scaleX(tmp, src, y0, y1)
+	}, func(tmp []float64, x0, x1 int32) {
+		z.scaleY(dst, tmp, x0, x1, false, curve)
+	})
+}
+
+// scaleFractional scales src into a 16-bit dst, splitting work between n goroutines.
+func (z *kernelScaler) scaleFractional(dst *image.Gray16, src *image.Gray, n int) {
+	if !z.fits(dst.Rect, src.Rect.Dx(), src.Rect.Dy()) {
+		z.kernel.newScaler(dst.Rect.Dx(), dst.Rect.Dy(), src.Rect.Dx(), src.Rect.Dy(), false).scaleFractional(dst, src, n)
+		return
+	}
+	z.run(dst.Rect, n, func(tmp []float64, y0, y1 int32) {
+		z.scaleX(tmp, src, y0, y1)
+	}, func(tmp []float64, x0, x1 int32) {
+		z.scaleYTo16(dst, tmp, x0, x1)
+	})
+}
+
+// scaleFixed is like scale, but only uses integer arithmetic.
+func (z *kernelScaler) scaleFixed(dst, src *image.Gray, n int, curve *Curve) {
+	if !z.fits(dst.Rect, src.Rect.Dx(), src.Rect.Dy()) {
+		z.kernel.newScaler(dst.Rect.Dx(), dst.Rect.Dy(), src.Rect.Dx(), src.Rect.Dy(), false).scaleFixed(dst, src, n, curve)
+		return
+	}
+	var tmp []int32
+	if z.fixedPool.New != nil {
+		tmpp := z.fixedPool.Get().(*[]int32)
+		defer z.fixedPool.Put(tmpp)
+		tmp = *tmpp
+	} else {
+		tmp = z.makeFixedTmpBuf()
+	}
+
+	parallel(n, 0, int(z.sh), func(lo, hi int) {
+		z.scaleXFixed(tmp, src, int32(lo), int32(hi))
+	})
+	parallel(n, dst.Rect.Min.X, dst.Rect.Max.X, func(lo, hi int) {
+		z.scaleYFixed(dst, tmp, int32(lo), int32(hi), curve)
+	})
+}
+
+// scale16 scales a 16-bit src into dst, splitting work between n goroutines.
+func (z *kernelScaler) scale16(dst *image.Gray, src *image.Gray16, n int) {
+	if !z.fits(dst.Rect, src.Rect.Dx(), src.Rect.Dy()) {
+		z.kernel.newScaler(dst.Rect.Dx(), dst.Rect.Dy(), src.Rect.Dx(), src.Rect.Dy(), false).scale16(dst, src, n)
 		return
 	}
+	z.run(dst.Rect, n, func(tmp []float64, y0, y1 int32) {
+		z.scaleX16(tmp, src, y0, y1)
+	}, func(tmp []float64, x0, x1 int32) {
+		z.scaleY(dst, tmp, x0, x1, true, nil)
+	})
+}
 
+// run scales source rows horizontally into a temporary buffer with scaleX and then the temporary
+// buffer vertically into a destination with bounds dst with scaleY, splitting work between n
+// goroutines.
+func (z *kernelScaler) run(dst image.Rectangle, n int, scaleX, scaleY func(tmp []float64, lo, hi int32)) {
 	// Create a temporary buffer:
 	// scaleX distributes the source image's columns over the temporary image.
 	// scaleY distributes the temporary image's rows over the destination image.
@@ -245,13 +527,18 @@ func (z *kernelScaler) Scale(dst, src *image.Gray) {
 		tmp = z.makeTmpBuf()
 	}
 
-	z.scaleX(tmp, src)
-	z.scaleY(dst, tmp)
+	parallel(n, 0, int(z.sh), func(lo, hi int) {
+		scaleX(tmp, int32(lo), int32(hi))
+	})
+	parallel(n, dst.Min.X, dst.Max.X, func(lo, hi int) {
+		scaleY(tmp, int32(lo), int32(hi))
+	})
 }
 
-func (z *kernelScaler) scaleX(tmp []float64, src *image.Gray) {
-	t := 0
-	for y := int32(0); y < z.sh; y++ {
+// scaleX scales source rows [y0, y1) horizontally into tmp.
+func (z *kernelScaler) scaleX(tmp []float64, src *image.Gray, y0, y1 int32) {
+	t := int(y0) * int(z.dw)
+	for y := y0; y < y1; y++ {
 		for _, s := range z.horizontal.sources {
 			var p float64
 			for _, c := range z.horizontal.contribs[s.i:s.j] {
@@ -266,16 +553,126 @@ func (z *kernelScaler) scaleX(tmp []float64, src *image.Gray) {
 	}
 }
 
-func (z *kernelScaler) scaleY(dst *image.Gray, tmp []float64) {
-	for dx := int32(dst.Rect.Min.X); dx < int32(dst.Rect.Max.X); dx++ {
+// scaleX16 scales 16-bit source rows [y0, y1) horizontally into tmp.
+func (z *kernelScaler) scaleX16(tmp []float64, src *image.Gray16, y0, y1 int32) {
+	t := int(y0) * int(z.dw)
+	for y := y0; y < y1; y++ {
+		for _, s := range z.horizontal.sources {
+			var p float64
+			for _, c := range z.horizontal.contribs[s.i:s.j] {
+				pi := int(y)*src.Stride + int(c.coord)*2
+				pru := uint32(src.Pix[pi])<<8 | uint32(src.Pix[pi+1])
+				p += float64(pru) * c.weight
+			}
+			p *= s.invTotalWeightFFFF
+			tmp[t] = p
+			t++
+		}
+	}
+}
+
+// scaleXFixed scales source rows [y0, y1) horizontally into tmp, as values with fixedTmpBits
+// fractional bits.
+func (z *kernelScaler) scaleXFixed(tmp []int32, src *image.Gray, y0, y1 int32) {
+	const shift = fixedBits - fixedTmpBits
+	t := int(y0) * int(z.dw)
+	for y := y0; y < y1; y++ {
+		row := src.Pix[int(y)*src.Stride:]
+		for _, s := range z.horizontal.sources {
+			var p int32
+			for _, c := range z.horizontal.contribs[s.i:s.j] {
+				p += int32(row[c.coord]) * c.fixed
+			}
+			tmp[t] = (p + 1<<(shift-1)) >> shift
+			t++
+		}
+	}
+}
+
+// scaleYFixed scales destination columns [x0, x1) vertically from tmp into dst, mapping scaled
+// values by curve if it isn't nil.
+func (z *kernelScaler) scaleYFixed(dst *image.Gray, tmp []int32, x0, x1 int32, curve *Curve) {
+	const (
+		shift      = fixedBits + fixedTmpBits
+		curveShift = shift - curveBits
+	)
+	for dx := x0; dx < x1; dx++ {
+		d := int(dx)
+		for _, s := range z.vertical.sources[dst.Rect.Min.Y:dst.Rect.Max.Y] {
+			var p int32
+			for _, c := range z.vertical.contribs[s.i:s.j] {
+				p += tmp[c.coord*z.dw+dx] * c.fixed
+			}
+			if curve != nil {
+				dst.Pix[d] = curve[curveIndex((p+1<<(curveShift-1))>>curveShift)]
+				d += dst.Stride
+				continue
+			}
+			p = (p + 1<<(shift-1)) >> shift
+			switch {
+			case p < 0:
+				p = 0
+			case p > 0xff:
+				p = 0xff
+			}
+			dst.Pix[d] = uint8(p)
+			d += dst.Stride
+		}
+	}
+}
+
+// scaleY scales destination columns [x0, x1) vertically from tmp into dst, mapping scaled values by
+// curve if it isn't nil.
+//
+// If round is true, scaled values are rounded to the nearest 8-bit value instead of truncated. 8-bit
+// sources don't need it, as their values map exactly to 16 bits and back.
+func (z *kernelScaler) scaleY(dst *image.Gray, tmp []float64, x0, x1 int32, round bool, curve *Curve) {
+	for dx := x0; dx < x1; dx++ {
 		d := int(dx)
 		for _, s := range z.vertical.sources[dst.Rect.Min.Y:dst.Rect.Max.Y] {
 			var p float64
 			for _, c := range z.vertical.contribs[s.i:s.j] {
 				p += tmp[c.coord*z.dw+dx] * c.weight
 			}
-			dst.Pix[d] = uint8(ftou(p*s.invTotalWeight) >> 8)
+			if curve != nil {
+				dst.Pix[d] = curve[curveIndex(int32(p*s.invTotalWeight*255*CurveSteps+0.5))]
+				d += dst.Stride
+				continue
+			}
+			v := ftou(p * s.invTotalWeight)
+			if round {
+				dst.Pix[d] = uint8((uint32(v)*0xff + 0x7fff) / 0xffff)
+			} else {
+				dst.Pix[d] = uint8(v >> 8)
+			}
+			d += dst.Stride
+		}
+	}
+}
+
+// scaleYTo16 scales destination columns [x0, x1) vertically from tmp into a 16-bit dst.
+func (z *kernelScaler) scaleYTo16(dst *image.Gray16, tmp []float64, x0, x1 int32) {
+	for dx := x0; dx < x1; dx++ {
+		d := int(dx) * 2
+		for _, s := range z.vertical.sources[dst.Rect.Min.Y:dst.Rect.Max.Y] {
+			var p float64
+			for _, c := range z.vertical.contribs[s.i:s.j] {
+				p += tmp[c.coord*z.dw+dx] * c.weight
+			}
+			v := ftou(p * s.invTotalWeight)
+			dst.Pix[d], dst.Pix[d+1] = uint8(v>>8), uint8(v)
 			d += dst.Stride
 		}
 	}
 }
+
+// curveIndex clamps i to the indices of a Curve.
+func curveIndex(i int32) int32 {
+	switch {
+	case i < 0:
+		return 0
+	case i >= int32(len(Curve{})):
+		return int32(len(Curve{})) - 1
+	}
+	return i
+}
@@ -1,17 +1,15 @@
 package imgutil_test
 
 import (
-	"flag"
 	"fmt"
 	"image"
 	"reflect"
 	"testing"
 
+	"github.com/naisuuuu/mangaconv/imgtest"
 	"github.com/naisuuuu/mangaconv/imgutil"
 )
 
-var genGoldenFiles = flag.Bool("gen_golden_files", false, "whether to generate the TestXxx golden files.")
-
 func TestScaler(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -34,6 +32,27 @@ func TestScaler(t *testing.T) {
 			image:  "wikipe-tan-100x123",
 			scaler: imgutil.CatmullRom,
 		},
+		{
+			name:   "BiLinear-downscale",
+			w:      100,
+			h:      100,
+			image:  "wikipe-tan-100x123",
+			scaler: imgutil.BiLinear,
+		},
+		{
+			name:   "Lanczos3-downscale",
+			w:      100,
+			h:      100,
+			image:  "wikipe-tan-100x123",
+			scaler: imgutil.Lanczos3,
+		},
+		{
+			name:   "Lanczos3-upscale",
+			w:      130,
+			h:      150,
+			image:  "wikipe-tan-100x123",
+			scaler: imgutil.Lanczos3,
+		},
 		{
 			name:   "CacheCatmullRom-downscale",
 			w:      100,
@@ -51,23 +70,12 @@ func TestScaler(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			src := mustBeGray(mustReadImg(fmt.Sprintf("testdata/%s.png", tt.image)))
+			src := imgtest.MustBeGray(imgtest.MustRead(fmt.Sprintf("testdata/%s.png", tt.image)))
 			goldenFname := fmt.Sprintf("testdata/%s-%s.png", tt.image, tt.name)
 
 			got := image.NewGray(image.Rect(0, 0, tt.w, tt.h))
 			tt.scaler.Scale(got, src)
-
-			if *genGoldenFiles {
-				if err := writeImg(goldenFname, got); err != nil {
-					t.Error(err)
-					return
-				}
-			}
-
-			want := mustBeGray(mustReadImg(goldenFname))
-			if !reflect.DeepEqual(got, want) {
-				t.Errorf("%s: actual image differs from golden image", goldenFname)
-			}
+			imgtest.Golden(t, goldenFname, got, 0)
 		})
 	}
 }
@@ -100,12 +108,17 @@ func BenchmarkScaler(b *testing.B) {
 			scaler: imgutil.NewCacheScaler(imgutil.CatmullRom),
 			images: []string{"wikipe-tan-195x239.png", "wikipe-tan-100x123.png", "wikipe-tan-82x100.png"},
 		},
+		{
+			name:   "FixedCacheCatmullRom_threeImages",
+			scaler: fixedPointScaler(),
+			images: []string{"wikipe-tan-195x239.png", "wikipe-tan-100x123.png", "wikipe-tan-82x100.png"},
+		},
 	}
 	for _, bb := range benchmarks {
 		b.Run(bb.name, func(b *testing.B) {
 			var images []*image.Gray
 			for _, i := range bb.images {
-				images = append(images, mustBeGray(mustReadImg("testdata/"+i)))
+				images = append(images, imgtest.MustBeGray(imgtest.MustRead("testdata/"+i)))
 			}
 			b.ResetTimer()
 			for i := 0; i < b.N; i++ {
@@ -122,7 +135,7 @@ func BenchmarkScaler(b *testing.B) {
 		b.Run("Pooled"+bb.name, func(b *testing.B) {
 			var images []*image.Gray
 			for _, i := range bb.images {
-				images = append(images, mustBeGray(mustReadImg("testdata/"+i)))
+				images = append(images, imgtest.MustBeGray(imgtest.MustRead("testdata/"+i)))
 			}
 			pool := imgutil.NewImagePool()
 			b.ResetTimer()
@@ -138,3 +151,184 @@ func BenchmarkScaler(b *testing.B) {
 		})
 	}
 }
+
+func TestCacheScalerEviction(t *testing.T) {
+	z := imgutil.NewCacheScalerSize(imgutil.CatmullRom, 2)
+	src := image.NewGray(image.Rect(0, 0, 8, 8))
+	for _, size := range []int{4, 5, 4, 6, 5} {
+		z.Scale(image.NewGray(image.Rect(0, 0, size, size)), src)
+	}
+
+	// 5 is evicted by 6, as 4 was used more recently.
+	want := imgutil.CacheStats{Hits: 1, Misses: 4, Evictions: 2, Entries: 2}
+	if got := z.Stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCacheScalerConcurrency(t *testing.T) {
+	src := imgtest.MustBeGray(imgtest.MustRead("testdata/wikipe-tan-100x123.png"))
+	for _, size := range [][2]int{{100, 100}, {130, 150}} {
+		want := image.NewGray(image.Rect(0, 0, size[0], size[1]))
+		imgutil.CatmullRom.Scale(want, src)
+
+		z := imgutil.NewCacheScaler(imgutil.CatmullRom)
+		z.SetConcurrency(7)
+		got := image.NewGray(want.Rect)
+		z.Scale(got, src)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%dx%d: concurrently scaled image differs from sequentially scaled one", size[0], size[1])
+		}
+	}
+}
+
+func fixedPointScaler() *imgutil.CacheScaler {
+	z := imgutil.NewCacheScaler(imgutil.CatmullRom)
+	z.SetFixedPoint(true)
+	return z
+}
+
+func TestCacheScalerFixedPoint(t *testing.T) {
+	src := imgtest.MustBeGray(imgtest.MustRead("testdata/wikipe-tan-195x239.png"))
+	flat := image.NewGray(image.Rect(0, 0, 40, 40))
+	for i := range flat.Pix {
+		flat.Pix[i] = 0x7f
+	}
+	tests := []struct {
+		name string
+		src  *image.Gray
+		w, h int
+		// maxDiff is the largest allowed difference from floating point results.
+		maxDiff int
+	}{
+		{"downscale", src, 100, 123, 2},
+		{"upscale", src, 300, 368, 2},
+		{"stretch", src, 300, 100, 2},
+		{"flat", flat, 17, 63, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := image.NewGray(image.Rect(0, 0, tt.w, tt.h))
+			imgutil.CatmullRom.Scale(want, tt.src)
+
+			z := fixedPointScaler()
+			z.SetConcurrency(3)
+			got := image.NewGray(want.Rect)
+			z.Scale(got, tt.src)
+			for i := range got.Pix {
+				if d := int(got.Pix[i]) - int(want.Pix[i]); d < -tt.maxDiff || d > tt.maxDiff {
+					t.Fatalf("pixel %d = %d, want %d ± %d", i, got.Pix[i], want.Pix[i], tt.maxDiff)
+				}
+			}
+		})
+	}
+}
+
+func TestScale16(t *testing.T) {
+	src := imgtest.MustBeGray(imgtest.MustRead("testdata/wikipe-tan-100x123.png"))
+	src16 := image.NewGray16(src.Rect)
+	for i, v := range src.Pix {
+		src16.Pix[i*2], src16.Pix[i*2+1] = v, v
+	}
+	for _, size := range [][2]int{{100, 100}, {130, 150}} {
+		want := image.NewGray(image.Rect(0, 0, size[0], size[1]))
+		imgutil.CatmullRom.Scale(want, src)
+
+		for name, z := range map[string]imgutil.Scaler16{
+			"Kernel":      imgutil.CatmullRom,
+			"CacheScaler": imgutil.NewCacheScaler(imgutil.CatmullRom),
+		} {
+			got := image.NewGray(want.Rect)
+			z.Scale16(got, src16)
+			// 16-bit results are rounded instead of truncated, so they may be off by one.
+			for i := range got.Pix {
+				if d := int(got.Pix[i]) - int(want.Pix[i]); d < -1 || d > 1 {
+					t.Errorf("%s %dx%d: pixel %d = %d, want %d", name, size[0], size[1], i, got.Pix[i], want.Pix[i])
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestScale16Rounding(t *testing.T) {
+	tests := []struct {
+		in   uint16
+		want uint8
+	}{
+		{0x0000, 0},
+		{0x0080, 0},
+		{0x01ff, 2},
+		{0x8000, 128},
+		{0xff00, 254},
+		{0xff80, 255},
+		{0xffff, 255},
+	}
+	for _, tt := range tests {
+		src := image.NewGray16(image.Rect(0, 0, 8, 8))
+		for i := 0; i < len(src.Pix); i += 2 {
+			src.Pix[i], src.Pix[i+1] = uint8(tt.in>>8), uint8(tt.in)
+		}
+		dst := image.NewGray(image.Rect(0, 0, 3, 3))
+		imgutil.CatmullRom.Scale16(dst, src)
+		if got := dst.Pix[4]; got != tt.want {
+			t.Errorf("Scale16(%#04x) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestScaleCurve(t *testing.T) {
+	src := imgtest.MustBeGray(imgtest.MustRead("testdata/wikipe-tan-195x239.png"))
+	identity := imgutil.IdentityLUT()
+	gamma := imgutil.GammaLUT(0.75)
+	tests := []struct {
+		name   string
+		scaler imgutil.CurveScaler
+		lut    imgutil.LUT
+		curve  *imgutil.Curve
+		// maxDiff is the largest allowed difference from scaling and applying lut afterwards.
+		// Curves round scaled pixels, while Scale truncates them.
+		maxDiff int
+	}{
+		{"identity", imgutil.CatmullRom, identity, imgutil.NewCurve(identity.At), 1},
+		{"identity cached", imgutil.NewCacheScaler(imgutil.CatmullRom), identity, imgutil.NewCurve(identity.At), 1},
+		{"identity fixed", fixedPointScaler(), identity, imgutil.NewCurve(identity.At), 1},
+		{"gamma", imgutil.CatmullRom, gamma, imgutil.NewCurve(imgutil.GammaFunc(0.75)), 2},
+		{"gamma fixed", fixedPointScaler(), gamma, imgutil.NewCurve(imgutil.GammaFunc(0.75)), 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, size := range [][2]int{{100, 123}, {300, 368}} {
+				want := image.NewGray(image.Rect(0, 0, size[0], size[1]))
+				tt.scaler.Scale(want, src)
+				tt.lut.Apply(want)
+				got := image.NewGray(want.Rect)
+				tt.scaler.ScaleCurve(got, src, tt.curve)
+				for i := range got.Pix {
+					if d := int(got.Pix[i]) - int(want.Pix[i]); d < -tt.maxDiff || d > tt.maxDiff {
+						t.Fatalf("%v: pixel %d = %d, want %d ± %d", size, i, got.Pix[i], want.Pix[i], tt.maxDiff)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestScaleFractional(t *testing.T) {
+	src := imgtest.MustBeGray(imgtest.MustRead("testdata/wikipe-tan-195x239.png"))
+	for _, scaler := range []imgutil.FractionalScaler{imgutil.CatmullRom, imgutil.NewCacheScaler(imgutil.CatmullRom)} {
+		for _, size := range [][2]int{{100, 123}, {300, 368}} {
+			want := image.NewGray(image.Rect(0, 0, size[0], size[1]))
+			scaler.Scale(want, src)
+			got := image.NewGray16(want.Rect)
+			scaler.ScaleFractional(got, src)
+			// Scale truncates scaled pixels to their 8 most significant bits.
+			for i := range want.Pix {
+				if got.Pix[i*2] != want.Pix[i] {
+					t.Fatalf("%T %v: pixel %d = %#04x, want %#02x in the high byte", scaler, size, i,
+						uint16(got.Pix[i*2])<<8|uint16(got.Pix[i*2+1]), want.Pix[i])
+				}
+			}
+		}
+	}
+}
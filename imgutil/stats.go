@@ -0,0 +1,83 @@
+package imgutil
+
+import (
+	"image"
+	"math"
+)
+
+// Stats describes the distribution of gray levels of an image. Histogram is as returned by
+// Histogram and Count is the number of pixels counted in it.
+type Stats struct {
+	Histogram [256]uint
+	Count     uint
+}
+
+// NewStats computes the statistics of a grayscale image.
+func NewStats(img *image.Gray) Stats {
+	return HistogramStats(Histogram(img))
+}
+
+// HistogramStats creates Stats from a histogram, such as the sum of histograms of several images.
+func HistogramStats(hist [256]uint) Stats {
+	s := Stats{Histogram: hist}
+	for _, v := range hist {
+		s.Count += v
+	}
+	return s
+}
+
+// Mean returns the average gray level, or 0 for an empty histogram.
+func (s Stats) Mean() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	var sum float64
+	for i, v := range s.Histogram {
+		sum += float64(i) * float64(v)
+	}
+	return sum / float64(s.Count)
+}
+
+// Median returns the median gray level. It's the same as Percentile(50).
+func (s Stats) Median() uint8 {
+	return s.Percentile(50)
+}
+
+// Percentile returns the lowest gray level at or below which at least p % of the pixels are. For
+// an empty histogram, it returns 0.
+func (s Stats) Percentile(p float64) uint8 {
+	target := float64(s.Count) * p / 100
+	var seen uint
+	for i, v := range s.Histogram {
+		seen += v
+		if seen > 0 && float64(seen) >= target {
+			return uint8(i)
+		}
+	}
+	if s.Count == 0 {
+		return 0
+	}
+	return 255
+}
+
+// Entropy returns the Shannon entropy of the gray levels in bits, ranging from 0 for a single
+// color to 8 for an image using all levels equally. Blank pages and clean line art have a low
+// entropy, while photos and screentones have a high one.
+func (s Stats) Entropy() float64 {
+	var e float64
+	for _, v := range s.Histogram {
+		if v == 0 {
+			continue
+		}
+		p := float64(v) / float64(s.Count)
+		e -= p * math.Log2(p)
+	}
+	return e
+}
+
+// IsLowContrast reports whether the range between the 1st and 99th percentile of gray levels is
+// less than fraction of the full range, e.g. 0.05 for 5%. Such images are blank or washed out.
+func (s Stats) IsLowContrast(fraction float64) bool {
+	spread := float64(s.Percentile(99)) - float64(s.Percentile(1))
+	return spread < fraction*255
+}
@@ -0,0 +1,51 @@
+package imgutil_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+func TestStats(t *testing.T) {
+	var ramp, half, single, empty [256]uint
+	for i := range ramp {
+		ramp[i] = 1
+	}
+	half[0], half[255] = 50, 50
+	single[200] = 10
+	tests := []struct {
+		name        string
+		hist        [256]uint
+		mean        float64
+		median      uint8
+		p10         uint8
+		entropy     float64
+		lowContrast bool
+	}{
+		{"ramp", ramp, 127.5, 127, 25, 8, false},
+		{"black and white", half, 127.5, 0, 0, 1, false},
+		{"single color", single, 200, 200, 200, 0, true},
+		{"empty", empty, 0, 0, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := imgutil.HistogramStats(tt.hist)
+			if got := s.Mean(); got != tt.mean {
+				t.Errorf("Mean() = %v, want %v", got, tt.mean)
+			}
+			if got := s.Median(); got != tt.median {
+				t.Errorf("Median() = %v, want %v", got, tt.median)
+			}
+			if got := s.Percentile(10); got != tt.p10 {
+				t.Errorf("Percentile(10) = %v, want %v", got, tt.p10)
+			}
+			if got := s.Entropy(); math.Abs(got-tt.entropy) > 1e-9 {
+				t.Errorf("Entropy() = %v, want %v", got, tt.entropy)
+			}
+			if got := s.IsLowContrast(0.05); got != tt.lowContrast {
+				t.Errorf("IsLowContrast() = %v, want %v", got, tt.lowContrast)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package imgutil
+
+import (
+	"image"
+	"math"
+)
+
+// Stitch joins two halves of a spread side by side, such as a spread scanned or stored as two
+// pages, hiding the seam between them.
+//
+// The right half is shifted vertically by up to maxOffset pixels, to the offset at which its first
+// column best matches the last column of the left half. Areas not covered by either half are
+// white. Then, the blend columns on each side of the seam are faded towards the average of both
+// halves at the seam, so that slight differences in brightness don't show as a line. A blend of 0
+// disables it.
+func Stitch(left, right *image.Gray, maxOffset, blend int) *image.Gray {
+	lw, lh := left.Rect.Dx(), left.Rect.Dy()
+	rw, rh := right.Rect.Dx(), right.Rect.Dy()
+	off := seamOffset(left, right, maxOffset)
+
+	top := 0
+	if off < 0 {
+		top = -off
+	}
+	h := lh + top
+	if rh+off+top > h {
+		h = rh + off + top
+	}
+	dst := image.NewGray(image.Rect(0, 0, lw+rw, h))
+	for i := range dst.Pix {
+		dst.Pix[i] = 0xff
+	}
+	for y := 0; y < lh; y++ {
+		copy(dst.Pix[(y+top)*dst.Stride:], left.Pix[y*left.Stride:y*left.Stride+lw])
+	}
+	for y := 0; y < rh; y++ {
+		copy(dst.Pix[(y+off+top)*dst.Stride+lw:], right.Pix[y*right.Stride:y*right.Stride+rw])
+	}
+
+	if blend > lw {
+		blend = lw
+	}
+	if blend > rw {
+		blend = rw
+	}
+	if blend <= 0 {
+		return dst
+	}
+	for y := 0; y < h; y++ {
+		row := dst.Pix[y*dst.Stride : y*dst.Stride+lw+rw]
+		avg := (float64(row[lw-1]) + float64(row[lw])) / 2
+		for k := 0; k < blend; k++ {
+			// Columns next to the seam take the most of the average.
+			w := float64(blend-k) / float64(blend+1)
+			l, r := &row[lw-1-k], &row[lw+k]
+			*l = uint8(math.Round(float64(*l) + (avg-float64(*l))*w))
+			*r = uint8(math.Round(float64(*r) + (avg-float64(*r))*w))
+		}
+	}
+	return dst
+}
+
+// seamOffset returns the vertical offset in the range [-maxOffset, maxOffset] at which the first
+// column of right best matches the last column of left, measured as the mean absolute difference
+// of their overlapping pixels. Smaller offsets win ties.
+func seamOffset(left, right *image.Gray, maxOffset int) int {
+	lw, lh := left.Rect.Dx(), left.Rect.Dy()
+	rh := right.Rect.Dy()
+	if lw == 0 || right.Rect.Dx() == 0 {
+		return 0
+	}
+	best, bestDiff := 0, math.Inf(1)
+	for d := 0; d <= maxOffset; d++ {
+		for _, off := range []int{d, -d} {
+			// Row y of right lines up with row y+off of left.
+			y0, y1 := 0, rh
+			if -off > y0 {
+				y0 = -off
+			}
+			if lh-off < y1 {
+				y1 = lh - off
+			}
+			if y1 <= y0 {
+				continue
+			}
+			var sum int
+			for y := y0; y < y1; y++ {
+				a := int(left.Pix[(y+off)*left.Stride+lw-1])
+				b := int(right.Pix[y*right.Stride])
+				if a > b {
+					sum += a - b
+				} else {
+					sum += b - a
+				}
+			}
+			if diff := float64(sum) / float64(y1-y0); diff < bestDiff {
+				best, bestDiff = off, diff
+			}
+			if d == 0 {
+				break
+			}
+		}
+	}
+	return best
+}
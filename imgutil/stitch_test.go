@@ -0,0 +1,126 @@
+package imgutil_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+func TestStitch(t *testing.T) {
+	tests := []struct {
+		name      string
+		left      *image.Gray
+		right     *image.Gray
+		maxOffset int
+		blend     int
+		want      *image.Gray
+	}{
+		{
+			name: "aligned",
+			left: &image.Gray{
+				Pix:    []uint8{0, 10, 0, 20, 0, 30},
+				Stride: 2,
+				Rect:   image.Rect(0, 0, 2, 3),
+			},
+			right: &image.Gray{
+				Pix:    []uint8{10, 0, 20, 0, 30, 0},
+				Stride: 2,
+				Rect:   image.Rect(0, 0, 2, 3),
+			},
+			maxOffset: 2,
+			want: &image.Gray{
+				Pix:    []uint8{0, 10, 10, 0, 0, 20, 20, 0, 0, 30, 30, 0},
+				Stride: 4,
+				Rect:   image.Rect(0, 0, 4, 3),
+			},
+		},
+		{
+			name: "shifted down",
+			left: &image.Gray{
+				Pix:    []uint8{0, 10, 0, 20, 0, 30, 0, 40},
+				Stride: 2,
+				Rect:   image.Rect(0, 0, 2, 4),
+			},
+			right: &image.Gray{
+				Pix:    []uint8{30, 40},
+				Stride: 1,
+				Rect:   image.Rect(0, 0, 1, 2),
+			},
+			maxOffset: 2,
+			want: &image.Gray{
+				Pix:    []uint8{0, 10, 0xff, 0, 20, 0xff, 0, 30, 30, 0, 40, 40},
+				Stride: 3,
+				Rect:   image.Rect(0, 0, 3, 4),
+			},
+		},
+		{
+			name: "shifted up",
+			left: &image.Gray{
+				Pix:    []uint8{30, 40},
+				Stride: 1,
+				Rect:   image.Rect(0, 0, 1, 2),
+			},
+			right: &image.Gray{
+				Pix:    []uint8{10, 20, 30, 40},
+				Stride: 1,
+				Rect:   image.Rect(0, 0, 1, 4),
+			},
+			maxOffset: 2,
+			want: &image.Gray{
+				Pix:    []uint8{0xff, 10, 0xff, 20, 30, 30, 40, 40},
+				Stride: 2,
+				Rect:   image.Rect(0, 0, 2, 4),
+			},
+		},
+		{
+			name: "offset limited",
+			// The best match is 3 pixels down, but only 1 is allowed.
+			left: &image.Gray{
+				Pix:    []uint8{10, 20, 30, 40},
+				Stride: 1,
+				Rect:   image.Rect(0, 0, 1, 4),
+			},
+			right: &image.Gray{
+				Pix:    []uint8{40, 50, 60, 70},
+				Stride: 1,
+				Rect:   image.Rect(0, 0, 1, 4),
+			},
+			maxOffset: 1,
+			want: &image.Gray{
+				Pix:    []uint8{10, 0xff, 20, 40, 30, 50, 40, 60, 0xff, 70},
+				Stride: 2,
+				Rect:   image.Rect(0, 0, 2, 5),
+			},
+		},
+		{
+			name: "blend",
+			left: &image.Gray{
+				Pix:    []uint8{100, 100},
+				Stride: 2,
+				Rect:   image.Rect(0, 0, 2, 1),
+			},
+			right: &image.Gray{
+				Pix:    []uint8{200, 200},
+				Stride: 2,
+				Rect:   image.Rect(0, 0, 2, 1),
+			},
+			blend: 2,
+			want: &image.Gray{
+				Pix:    []uint8{117, 133, 167, 183},
+				Stride: 4,
+				Rect:   image.Rect(0, 0, 4, 1),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := imgutil.Stitch(tt.left, tt.right, tt.maxOffset, tt.blend)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Stitch() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package imgutil
+
+import (
+	"image"
+	"sort"
+)
+
+const (
+	// textLevel is the maximum value of a pixel considered part of lettering.
+	textLevel = 0x80
+	// textBackground is the minimum value of a pixel considered part of the background lettering is
+	// drawn on, such as the inside of a speech bubble.
+	textBackground = 0xc0
+	// textMinGlyphs is the minimum number of glyphs in a block of text. Fewer glyphs are more likely
+	// specks or small details of the art.
+	textMinGlyphs = 3
+)
+
+// component is a connected area of dark pixels.
+type component struct {
+	r image.Rectangle
+	n int
+}
+
+// TextRegions finds lettering, such as dialogue in speech bubbles and captions, and returns the
+// bounds of each block of text in top to bottom, then left to right order.
+//
+// Glyphs are connected areas of dark pixels between 1/200 and 1/15 of the image height, mostly
+// surrounded by light pixels. This excludes screentone dots, lines of the art touching other dark
+// areas and lettering drawn over the art, which is better left alone anyway. Solid areas are only
+// glyphs if narrow, like an "l". Glyphs within half their height of each other are grouped into
+// blocks, and blocks of fewer than 3 glyphs are dropped.
+func TextRegions(img *image.Gray) []image.Rectangle {
+	h := img.Rect.Dy()
+	minH, maxH := h/200, h/15
+	if minH < 3 {
+		minH = 3
+	}
+
+	var glyphs []image.Rectangle
+	for _, c := range darkComponents(img) {
+		if isGlyph(img, c, minH, maxH) {
+			glyphs = append(glyphs, c.r)
+		}
+	}
+
+	// Group glyphs with a union-find over pairs which are close enough.
+	parent := make([]int, len(glyphs))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i, a := range glyphs {
+		ga := a.Inset(-a.Dy() / 2)
+		for j := i + 1; j < len(glyphs); j++ {
+			b := glyphs[j]
+			if ga.Overlaps(b) || b.Inset(-b.Dy()/2).Overlaps(a) {
+				parent[find(i)] = find(j)
+			}
+		}
+	}
+
+	blocks := make(map[int]image.Rectangle)
+	counts := make(map[int]int)
+	for i, g := range glyphs {
+		root := find(i)
+		blocks[root] = blocks[root].Union(g)
+		counts[root]++
+	}
+	var regions []image.Rectangle
+	for root, r := range blocks {
+		if counts[root] >= textMinGlyphs {
+			regions = append(regions, r)
+		}
+	}
+	sort.Slice(regions, func(i, j int) bool {
+		if regions[i].Min.Y != regions[j].Min.Y {
+			return regions[i].Min.Y < regions[j].Min.Y
+		}
+		return regions[i].Min.X < regions[j].Min.X
+	})
+	return regions
+}
+
+// darkComponents returns the bounds and pixel count of each 8-connected area of pixels darker than
+// textLevel.
+func darkComponents(img *image.Gray) []component {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	dark := func(x, y int) bool { return img.Pix[y*img.Stride+x] < textLevel }
+	seen := make([]bool, w*h)
+	var comps []component
+	var stack []int
+	for i := range seen {
+		if seen[i] || !dark(i%w, i/w) {
+			continue
+		}
+		seen[i] = true
+		minX, minY, maxX, maxY := w, h, 0, 0
+		n := 0
+		stack = append(stack[:0], i)
+		for len(stack) > 0 {
+			j := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y := j%w, j/w
+			n++
+			if x < minX {
+				minX = x
+			}
+			if x > maxX {
+				maxX = x
+			}
+			if y < minY {
+				minY = y
+			}
+			if y > maxY {
+				maxY = y
+			}
+			for ny := y - 1; ny <= y+1; ny++ {
+				for nx := x - 1; nx <= x+1; nx++ {
+					if nx < 0 || ny < 0 || nx >= w || ny >= h {
+						continue
+					}
+					if k := ny*w + nx; !seen[k] && dark(nx, ny) {
+						seen[k] = true
+						stack = append(stack, k)
+					}
+				}
+			}
+		}
+		r := image.Rect(minX, minY, maxX+1, maxY+1).Add(img.Rect.Min)
+		comps = append(comps, component{r, n})
+	}
+	return comps
+}
+
+// isGlyph reports whether component c is shaped like a glyph between minH and maxH pixels high and
+// is mostly surrounded by light pixels.
+func isGlyph(img *image.Gray, c component, minH, maxH int) bool {
+	w, h := c.r.Dx(), c.r.Dy()
+	if h < minH || h > maxH || w > 2*maxH {
+		return false
+	}
+	if c.n*100 > w*h*85 && w*2 > h {
+		return false
+	}
+
+	// Count light pixels in the ring around the component's bounds.
+	ring := c.r.Inset(-1).Intersect(img.Rect)
+	var light, total int
+	for y := ring.Min.Y; y < ring.Max.Y; y++ {
+		for x := ring.Min.X; x < ring.Max.X; x++ {
+			if (image.Point{x, y}).In(c.r) {
+				continue
+			}
+			total++
+			if img.Pix[img.PixOffset(x, y)] >= textBackground {
+				light++
+			}
+		}
+	}
+	return total > 0 && light*4 >= total*3
+}
@@ -0,0 +1,97 @@
+package imgutil_test
+
+import (
+	"image"
+	"image/draw"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+func TestTextRegions(t *testing.T) {
+	// glyph draws an 8x10 ring with 2 pixel strokes, roughly shaped like an "o", at x, y.
+	glyph := func(img *image.Gray, x, y int) {
+		draw.Draw(img, image.Rect(x, y, x+8, y+10), image.Black, image.Point{}, draw.Src)
+		draw.Draw(img, image.Rect(x+2, y+2, x+6, y+8), image.White, image.Point{}, draw.Src)
+	}
+	line := func(img *image.Gray, x, y, n int) {
+		for i := 0; i < n; i++ {
+			glyph(img, x+i*11, y)
+		}
+	}
+	page := func(fill func(img *image.Gray)) *image.Gray {
+		img := image.NewGray(image.Rect(0, 0, 200, 300))
+		draw.Draw(img, img.Rect, image.White, image.Point{}, draw.Src)
+		fill(img)
+		return img
+	}
+	tests := []struct {
+		name string
+		img  *image.Gray
+		want []image.Rectangle
+	}{
+		{
+			name: "blank",
+			img:  page(func(*image.Gray) {}),
+		},
+		{
+			name: "line",
+			img:  page(func(img *image.Gray) { line(img, 20, 30, 5) }),
+			want: []image.Rectangle{image.Rect(20, 30, 72, 40)},
+		},
+		{
+			name: "lines grouped",
+			img: page(func(img *image.Gray) {
+				line(img, 20, 30, 5)
+				line(img, 20, 43, 3)
+			}),
+			want: []image.Rectangle{image.Rect(20, 30, 72, 53)},
+		},
+		{
+			name: "separate blocks",
+			img: page(func(img *image.Gray) {
+				line(img, 120, 200, 4)
+				line(img, 20, 30, 5)
+			}),
+			want: []image.Rectangle{image.Rect(20, 30, 72, 40), image.Rect(120, 200, 161, 210)},
+		},
+		{
+			name: "too few glyphs",
+			img:  page(func(img *image.Gray) { line(img, 20, 30, 2) }),
+		},
+		{
+			name: "solid blocks",
+			img: page(func(img *image.Gray) {
+				for i := 0; i < 5; i++ {
+					draw.Draw(img, image.Rect(20+i*11, 30, 28+i*11, 40), image.Black, image.Point{}, draw.Src)
+				}
+			}),
+		},
+		{
+			name: "on dark art",
+			img: page(func(img *image.Gray) {
+				draw.Draw(img, image.Rect(10, 20, 190, 60), image.Black, image.Point{}, draw.Src)
+				line(img, 20, 30, 5)
+			}),
+		},
+		{
+			name: "too large",
+			img: page(func(img *image.Gray) {
+				for i := 0; i < 3; i++ {
+					draw.Draw(img, image.Rect(10+i*60, 30, 60+i*60, 80), image.Black, image.Point{}, draw.Src)
+					draw.Draw(img, image.Rect(20+i*60, 40, 50+i*60, 70), image.White, image.Point{}, draw.Src)
+				}
+			}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := imgutil.TextRegions(tt.img)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("TextRegions() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
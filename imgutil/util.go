@@ -1,6 +1,9 @@
 package imgutil
 
-import "sync"
+import (
+	"runtime"
+	"sync"
+)
 
 func clamp(f float64) uint8 {
 	v := int(f + 0.5)
@@ -13,14 +16,33 @@ func clamp(f float64) uint8 {
 	return 0
 }
 
+// concurrentIterate calls fn for each integer in [0, limit). The range is split into chunks
+// processed by one goroutine per available CPU.
 func concurrentIterate(limit int, fn func(int)) {
+	parallel(runtime.GOMAXPROCS(0), 0, limit, func(lo, hi int) {
+		for i := lo; i < hi; i++ {
+			fn(i)
+		}
+	})
+}
+
+// parallel splits the range [lo, hi) into n roughly equal parts and calls fn for each of them in a
+// separate goroutine, waiting for all of them to finish. If n <= 1, fn is called once, directly.
+func parallel(n, lo, hi int, fn func(lo, hi int)) {
+	if n <= 1 || hi-lo < 2 {
+		fn(lo, hi)
+		return
+	}
+	if n > hi-lo {
+		n = hi - lo
+	}
 	var wg sync.WaitGroup
-	for j := 0; j < limit; j++ {
-		wg.Add(1)
-		go func(k int) {
-			fn(k)
-			wg.Done()
-		}(j)
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(a, b int) {
+			defer wg.Done()
+			fn(a, b)
+		}(lo+(hi-lo)*i/n, lo+(hi-lo)*(i+1)/n)
 	}
 	wg.Wait()
 }
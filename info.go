@@ -0,0 +1,67 @@
+package mangaconv
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/naisuuuu/mangaconv/internal/pdf"
+)
+
+// Info describes an input without converting it. Format is either "zip", "pdf" or "dir". Pages is
+// the number of images in the input, including those which would be skipped when decoding, or the
+// number of pages of PDF inputs.
+type Info struct {
+	Metadata
+	Format string
+	Pages  int
+}
+
+// Info inspects the input at in according to input related Params, such as Symlinks.
+func (c *Converter) Info(in string) (Info, error) {
+	path := LongPath(in)
+	if _, err := c.selectReader(path); err != nil {
+		return Info{}, fmt.Errorf("cannot read %s: %w", in, err)
+	}
+	info := Info{Metadata: ParseFilename(in)}
+	if isArchive(filepath.Ext(path)) {
+		r, err := zip.OpenReader(path)
+		if err != nil {
+			return Info{}, fmt.Errorf("cannot open %s: %w", in, err)
+		}
+		defer r.Close()
+		info.Format = "zip"
+		info.Pages = len(c.listZip(&r.Reader))
+		return info, nil
+	}
+	if filepath.Ext(path) == ".pdf" {
+		f, err := os.Open(path)
+		if err != nil {
+			return Info{}, fmt.Errorf("cannot open %s: %w", in, err)
+		}
+		defer f.Close()
+		st, err := f.Stat()
+		if err != nil {
+			return Info{}, fmt.Errorf("cannot open %s: %w", in, err)
+		}
+		doc, err := pdf.Open(f, st.Size())
+		if err != nil {
+			return Info{}, fmt.Errorf("cannot read %s: %w", in, pdfError(err))
+		}
+		pages, err := doc.Pages()
+		if err != nil {
+			return Info{}, fmt.Errorf("cannot read %s: %w", in, pdfError(err))
+		}
+		info.Format = "pdf"
+		info.Pages = len(pages)
+		return info, nil
+	}
+	paths, err := c.listDir(path)
+	if err != nil {
+		return Info{}, err
+	}
+	info.Format = "dir"
+	info.Pages = len(paths)
+	return info, nil
+}
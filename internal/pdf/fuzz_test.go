@@ -0,0 +1,44 @@
+package pdf
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+func FuzzOpen(f *testing.F) {
+	objects, _ := testPDF(f)
+	f.Add(writePDF(f, objects, "", false))
+	f.Add(writePDF(f, objects, "", true))
+	RegisterFormat()
+	const maxPixels = 1 << 20
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := bytes.NewReader(data)
+		doc, err := Open(r, r.Size())
+		if err != nil {
+			return
+		}
+		pages, err := doc.Pages()
+		if err != nil {
+			return
+		}
+		for _, p := range pages {
+			img := doc.LargestImage(p)
+			if img == nil {
+				continue
+			}
+			rc, err := img.Open(r, maxPixels)
+			if err != nil {
+				continue
+			}
+			cfg, format, err := image.DecodeConfig(rc)
+			rc.Close()
+			if err != nil || format != "mangaconv-pdf-image" {
+				continue
+			}
+			if int64(cfg.Width)*int64(cfg.Height) > maxPixels {
+				t.Errorf("Open() returned a %dx%d image", cfg.Width, cfg.Height)
+			}
+		}
+	})
+}
@@ -0,0 +1,885 @@
+// Package pdf parses PDF files just enough to list their pages and extract the images embedded in
+// them, such as the scans making up a manga volume. Rendering pages is out of its scope.
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Errors returned for PDF files which can't be parsed, which use features that aren't supported,
+// such as encryption, whose streams are corrupt or which decode to too much data.
+var (
+	ErrMalformed   = errors.New("malformed pdf")
+	ErrUnsupported = errors.New("unsupported pdf")
+	ErrCorrupt     = errors.New("corrupt pdf stream")
+	ErrTooLarge    = errors.New("pdf data too large")
+)
+
+// maxStreamBytes limits the decoded size of PDF streams other than images, such as cross
+// reference and object streams, so that a few compressed bytes can't make the parser allocate
+// gigabytes.
+const maxStreamBytes = 64 << 20
+
+// PDF objects are represented by nil, bool, int, float64, string for strings, Name, Keyword,
+// []interface{} for arrays, Dict, Ref and *Stream.
+type (
+	Name    string
+	Keyword string
+	Dict    map[Name]interface{}
+)
+
+// Ref is a reference to an indirect object.
+type Ref struct {
+	num, gen int
+}
+
+// Stream is a stream object, whose data is stored at off in the file.
+type Stream struct {
+	dict Dict
+	off  int64
+}
+
+// xrefEntry is the location of an indirect object: either at off in the file, or the index-th object
+// of the object stream numbered stream.
+type xrefEntry struct {
+	off    int64
+	stream int
+	index  int
+}
+
+// File is a parsed PDF file. Objects are read from r on demand, so that image data is never
+// held in memory longer than needed. It's not safe for concurrent use.
+type File struct {
+	r       io.ReaderAt
+	size    int64
+	xref    map[int]xrefEntry
+	trailer Dict
+	objStms map[int][]interface{}
+}
+
+// Open parses the cross reference sections of the PDF file in r, of the given size.
+func Open(r io.ReaderAt, size int64) (*File, error) {
+	f := &File{r: r, size: size, xref: make(map[int]xrefEntry), objStms: make(map[int][]interface{})}
+	off, err := f.startXref()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[int64]bool)
+	for off >= 0 {
+		if seen[off] {
+			break
+		}
+		seen[off] = true
+		trailer, err := f.readXref(off)
+		if err != nil {
+			return nil, err
+		}
+		if f.trailer == nil {
+			f.trailer = trailer
+		}
+		// Hybrid files keep objects of newer PDF versions in a cross reference stream.
+		if stm, ok := trailer["XRefStm"].(int); ok && !seen[int64(stm)] {
+			seen[int64(stm)] = true
+			if _, err := f.readXref(int64(stm)); err != nil {
+				return nil, err
+			}
+		}
+		off = -1
+		if prev, ok := trailer["Prev"].(int); ok {
+			off = int64(prev)
+		}
+	}
+	if _, ok := f.trailer["Encrypt"]; ok {
+		return nil, fmt.Errorf("%w: encrypted pdf", ErrUnsupported)
+	}
+	return f, nil
+}
+
+// startXref returns the offset of the last cross reference section, as found at the end of the
+// file.
+func (f *File) startXref() (int64, error) {
+	const tail = 1024
+	off := f.size - tail
+	if off < 0 {
+		off = 0
+	}
+	buf := make([]byte, f.size-off)
+	if _, err := f.r.ReadAt(buf, off); err != nil && err != io.EOF {
+		return 0, err
+	}
+	i := bytes.LastIndex(buf, []byte("startxref"))
+	if i < 0 {
+		return 0, fmt.Errorf("%w: no startxref", ErrMalformed)
+	}
+	l := newLexer(bytes.NewReader(buf[i+len("startxref"):]), 0)
+	tok, err := l.token()
+	if err != nil {
+		return 0, err
+	}
+	xref, ok := tok.(int)
+	if !ok || xref < 0 || int64(xref) >= f.size {
+		return 0, fmt.Errorf("%w: invalid startxref", ErrMalformed)
+	}
+	return int64(xref), nil
+}
+
+// readXref reads the cross reference table or stream at off and returns its trailer. Entries
+// already known from newer sections are kept.
+func (f *File) readXref(off int64) (Dict, error) {
+	l := f.lexerAt(off)
+	tok, err := l.token()
+	if err != nil {
+		return nil, err
+	}
+	if tok != Keyword("xref") {
+		l.unread(tok)
+		return f.readXrefStream(l)
+	}
+	for {
+		tok, err := l.token()
+		if err != nil {
+			return nil, err
+		}
+		if tok == Keyword("trailer") {
+			trailer, err := l.object()
+			if err != nil {
+				return nil, err
+			}
+			d, ok := trailer.(Dict)
+			if !ok {
+				return nil, fmt.Errorf("%w: invalid trailer", ErrMalformed)
+			}
+			return d, nil
+		}
+		start, ok1 := tok.(int)
+		count, err := l.token()
+		if err != nil {
+			return nil, err
+		}
+		n, ok2 := count.(int)
+		if !ok1 || !ok2 || start < 0 || n < 0 {
+			return nil, fmt.Errorf("%w: invalid xref subsection", ErrMalformed)
+		}
+		for i := 0; i < n; i++ {
+			var entry [3]interface{}
+			for j := range entry {
+				if entry[j], err = l.token(); err != nil {
+					return nil, err
+				}
+			}
+			offset, ok := entry[0].(int)
+			if !ok {
+				return nil, fmt.Errorf("%w: invalid xref entry", ErrMalformed)
+			}
+			if _, known := f.xref[start+i]; !known && entry[2] == Keyword("n") {
+				f.xref[start+i] = xrefEntry{off: int64(offset), stream: -1}
+			}
+		}
+	}
+}
+
+// readXrefStream reads the cross reference stream object l is positioned at and returns its
+// dictionary, which doubles as trailer.
+func (f *File) readXrefStream(l *lexer) (Dict, error) {
+	obj, err := f.indirect(l)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := obj.(*Stream)
+	if !ok || s.dict["Type"] != Name("XRef") {
+		return nil, fmt.Errorf("%w: invalid xref stream", ErrMalformed)
+	}
+	data, err := f.streamData(s, maxStreamBytes)
+	if err != nil {
+		return nil, err
+	}
+	w, err := f.ints(s.dict["W"])
+	if err != nil || len(w) != 3 {
+		return nil, fmt.Errorf("%w: invalid xref stream widths", ErrMalformed)
+	}
+	size, _ := f.resolve(s.dict["Size"]).(int)
+	index := []int{0, size}
+	if v, ok := s.dict["Index"]; ok {
+		if index, err = f.ints(v); err != nil || len(index)%2 != 0 {
+			return nil, fmt.Errorf("%w: invalid xref stream index", ErrMalformed)
+		}
+	}
+	entry := w[0] + w[1] + w[2]
+	for _, n := range w {
+		if n < 0 || n > 8 {
+			return nil, fmt.Errorf("%w: invalid xref stream widths", ErrMalformed)
+		}
+	}
+	field := func(b []byte) int {
+		v := 0
+		for _, c := range b {
+			v = v<<8 | int(c)
+		}
+		return v
+	}
+	for i := 0; i+1 < len(index); i += 2 {
+		for num := index[i]; num < index[i]+index[i+1]; num++ {
+			if len(data) < entry {
+				return s.dict, nil
+			}
+			typ := 1
+			if w[0] > 0 {
+				typ = field(data[:w[0]])
+			}
+			a, b := field(data[w[0]:w[0]+w[1]]), field(data[w[0]+w[1]:entry])
+			data = data[entry:]
+			if _, known := f.xref[num]; known {
+				continue
+			}
+			switch typ {
+			case 1:
+				f.xref[num] = xrefEntry{off: int64(a), stream: -1}
+			case 2:
+				f.xref[num] = xrefEntry{stream: a, index: b}
+			}
+		}
+	}
+	return s.dict, nil
+}
+
+// lexerAt returns a lexer reading the file from off.
+func (f *File) lexerAt(off int64) *lexer {
+	return newLexer(io.NewSectionReader(f.r, off, f.size-off), off)
+}
+
+// object returns the indirect object numbered num, or nil if it doesn't exist.
+func (f *File) object(num int) (interface{}, error) {
+	x, ok := f.xref[num]
+	if !ok {
+		return nil, nil
+	}
+	if x.stream < 0 {
+		return f.indirect(f.lexerAt(x.off))
+	}
+	objs, ok := f.objStms[x.stream]
+	if !ok {
+		var err error
+		if objs, err = f.readObjStm(x.stream); err != nil {
+			return nil, err
+		}
+		f.objStms[x.stream] = objs
+	}
+	if x.index < 0 || x.index >= len(objs) {
+		return nil, fmt.Errorf("%w: object %d not in its object stream", ErrMalformed, num)
+	}
+	return objs[x.index], nil
+}
+
+// indirect reads an indirect object definition, such as "12 0 obj << >> endobj", from l.
+func (f *File) indirect(l *lexer) (interface{}, error) {
+	for i := 0; i < 3; i++ {
+		tok, err := l.token()
+		if err != nil {
+			return nil, err
+		}
+		if _, isInt := tok.(int); i < 2 && !isInt || i == 2 && tok != Keyword("obj") {
+			return nil, fmt.Errorf("%w: invalid object definition at %d", ErrMalformed, l.pos)
+		}
+	}
+	obj, err := l.object()
+	if err != nil {
+		return nil, err
+	}
+	d, ok := obj.(Dict)
+	if !ok {
+		return obj, nil
+	}
+	tok, err := l.token()
+	if err != nil || tok != Keyword("stream") {
+		return d, nil
+	}
+	// Stream data starts after the end of line following the keyword.
+	b, err := l.readByte()
+	if err == nil && b == '\r' {
+		b, err = l.readByte()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if b != '\n' {
+		l.unreadByte()
+	}
+	return &Stream{dict: d, off: l.pos}, nil
+}
+
+// readObjStm reads all objects of the object stream numbered num.
+func (f *File) readObjStm(num int) ([]interface{}, error) {
+	if x, ok := f.xref[num]; !ok || x.stream >= 0 {
+		return nil, fmt.Errorf("%w: invalid object stream %d", ErrMalformed, num)
+	}
+	obj, err := f.object(num)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := obj.(*Stream)
+	if !ok {
+		return nil, fmt.Errorf("%w: object stream %d is not a stream", ErrMalformed, num)
+	}
+	data, err := f.streamData(s, maxStreamBytes)
+	if err != nil {
+		return nil, err
+	}
+	n, _ := f.resolve(s.dict["N"]).(int)
+	first, _ := f.resolve(s.dict["First"]).(int)
+	if n < 0 || first < 0 || first > len(data) {
+		return nil, fmt.Errorf("%w: invalid object stream %d", ErrMalformed, num)
+	}
+	l := newLexer(bytes.NewReader(data), 0)
+	offsets := make([]int, n)
+	for i := range offsets {
+		if _, err := l.token(); err != nil {
+			return nil, err
+		}
+		tok, err := l.token()
+		if err != nil {
+			return nil, err
+		}
+		if offsets[i], ok = tok.(int); !ok || first+offsets[i] > len(data) {
+			return nil, fmt.Errorf("%w: invalid object stream %d", ErrMalformed, num)
+		}
+	}
+	objs := make([]interface{}, n)
+	for i, off := range offsets {
+		if objs[i], err = newLexer(bytes.NewReader(data[first+off:]), 0).object(); err != nil {
+			return nil, err
+		}
+	}
+	return objs, nil
+}
+
+// resolve follows references until v is a direct object. Missing objects and references which
+// can't be read resolve to nil.
+func (f *File) resolve(v interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		ref, ok := v.(Ref)
+		if !ok {
+			return v
+		}
+		var err error
+		if v, err = f.object(ref.num); err != nil {
+			return nil
+		}
+	}
+	return nil
+}
+
+// dict resolves v to a dictionary, or the dictionary of a stream. It returns nil otherwise.
+func (f *File) dict(v interface{}) Dict {
+	switch v := f.resolve(v).(type) {
+	case Dict:
+		return v
+	case *Stream:
+		return v.dict
+	}
+	return nil
+}
+
+// ints resolves v to an array of integers.
+func (f *File) ints(v interface{}) ([]int, error) {
+	arr, ok := f.resolve(v).([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: not an array", ErrMalformed)
+	}
+	ints := make([]int, len(arr))
+	for i, e := range arr {
+		if ints[i], ok = f.resolve(e).(int); !ok {
+			return nil, fmt.Errorf("%w: not an integer", ErrMalformed)
+		}
+	}
+	return ints, nil
+}
+
+// filters returns the names of the filters of s, along with their parameters.
+func (f *File) filters(s *Stream) ([]Name, []Dict) {
+	var names []Name
+	var parms []Dict
+	switch v := f.resolve(s.dict["Filter"]).(type) {
+	case Name:
+		names = []Name{v}
+		parms = []Dict{f.dict(s.dict["DecodeParms"])}
+	case []interface{}:
+		arr, _ := f.resolve(s.dict["DecodeParms"]).([]interface{})
+		for i, e := range v {
+			name, _ := f.resolve(e).(Name)
+			names = append(names, name)
+			var p Dict
+			if i < len(arr) {
+				p = f.dict(arr[i])
+			}
+			parms = append(parms, p)
+		}
+	}
+	return names, parms
+}
+
+// rawStream returns a reader of the undecoded data of s.
+func (f *File) rawStream(s *Stream) (*io.SectionReader, error) {
+	n, ok := f.resolve(s.dict["Length"]).(int)
+	if !ok || n < 0 || s.off+int64(n) > f.size {
+		return nil, fmt.Errorf("%w: invalid stream length", ErrMalformed)
+	}
+	return io.NewSectionReader(f.r, s.off, int64(n)), nil
+}
+
+// streamData returns the decoded data of s, failing if it's larger than max bytes. Only
+// FlateDecode, with or without PNG predictors, is supported.
+func (f *File) streamData(s *Stream, max int64) ([]byte, error) {
+	raw, err := f.rawStream(s)
+	if err != nil {
+		return nil, err
+	}
+	names, parms := f.filters(s)
+	return decodeStream(raw, names, parms, max)
+}
+
+// decodeStream decodes stream data read from r with the given filters, failing if the result
+// is larger than max bytes.
+func decodeStream(r io.Reader, names []Name, parms []Dict, max int64) ([]byte, error) {
+	var data []byte
+	switch {
+	case len(names) == 0:
+		var err error
+		if data, err = readLimited(r, max); err != nil {
+			return nil, err
+		}
+	case len(names) == 1 && (names[0] == "FlateDecode" || names[0] == "Fl"):
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+		defer zr.Close()
+		data, err = readLimited(zr, max)
+		// Some writers truncate the final checksum, which doesn't affect the data.
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, zlib.ErrChecksum) {
+			return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+		return unpredict(data, parms[0])
+	default:
+		return nil, fmt.Errorf("%w: pdf filters %v", ErrUnsupported, names)
+	}
+	return data, nil
+}
+
+// readLimited reads all of r, failing if it holds more than max bytes.
+func readLimited(r io.Reader, max int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, max+1))
+	if int64(len(data)) > max {
+		return nil, fmt.Errorf("%w: pdf stream larger than %d bytes", ErrTooLarge, max)
+	}
+	return data, err
+}
+
+// unpredict reverses the PNG predictors of FlateDecode data, as described by parms.
+func unpredict(data []byte, parms Dict) ([]byte, error) {
+	predictor, _ := parms["Predictor"].(int)
+	if predictor < 10 {
+		if predictor > 1 {
+			return nil, fmt.Errorf("%w: pdf predictor %d", ErrUnsupported, predictor)
+		}
+		return data, nil
+	}
+	param := func(key Name, def int) int {
+		if v, ok := parms[key].(int); ok && v > 0 {
+			return v
+		}
+		return def
+	}
+	colors, bpc, columns := param("Colors", 1), param("BitsPerComponent", 8), param("Columns", 1)
+	bpp := (colors*bpc + 7) / 8
+	row := (columns*colors*bpc + 7) / 8
+	if row <= 0 || colors > 32 || bpc > 16 {
+		return nil, fmt.Errorf("%w: invalid predictor parameters", ErrMalformed)
+	}
+	out := make([]byte, 0, len(data)/(row+1)*row)
+	prev := make([]byte, row)
+	for len(data) > row {
+		filter, cur := data[0], data[1:row+1]
+		data = data[row+1:]
+		for i := range cur {
+			var left, upLeft byte
+			if i >= bpp {
+				left, upLeft = cur[i-bpp], prev[i-bpp]
+			}
+			up := prev[i]
+			switch filter {
+			case 0:
+			case 1:
+				cur[i] += left
+			case 2:
+				cur[i] += up
+			case 3:
+				cur[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				cur[i] += paeth(left, up, upLeft)
+			default:
+				return nil, fmt.Errorf("%w: invalid png predictor %d", ErrCorrupt, filter)
+			}
+		}
+		out = append(out, cur...)
+		prev = cur
+	}
+	return out, nil
+}
+
+// paeth is the Paeth predictor of PNG.
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := absInt(p-int(a)), absInt(p-int(b)), absInt(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// lexer tokenizes and parses PDF objects. pos is the offset in the file of the next byte read.
+type lexer struct {
+	r      *bufio.Reader
+	pos    int64
+	tokens []interface{}
+}
+
+func newLexer(r io.Reader, pos int64) *lexer {
+	return &lexer{r: bufio.NewReader(r), pos: pos}
+}
+
+func (l *lexer) readByte() (byte, error) {
+	b, err := l.r.ReadByte()
+	if err == io.EOF {
+		return 0, fmt.Errorf("%w: unexpected end of file", ErrMalformed)
+	}
+	if err == nil {
+		l.pos++
+	}
+	return b, err
+}
+
+func (l *lexer) unreadByte() {
+	l.r.UnreadByte()
+	l.pos--
+}
+
+// unread pushes back a token, which is returned by the next call to token.
+func (l *lexer) unread(tok interface{}) {
+	l.tokens = append(l.tokens, tok)
+}
+
+// maxNesting limits the depth of nested arrays and dictionaries.
+const maxNesting = 64
+
+// object parses the next object, resolving "num gen R" to a Ref.
+func (l *lexer) object() (interface{}, error) {
+	return l.nested(0)
+}
+
+func (l *lexer) nested(depth int) (interface{}, error) {
+	if depth > maxNesting {
+		return nil, fmt.Errorf("%w: objects nested too deeply", ErrMalformed)
+	}
+	tok, err := l.token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok := tok.(type) {
+	case int:
+		gen, err := l.token()
+		if err != nil {
+			return tok, nil
+		}
+		if g, ok := gen.(int); ok {
+			r, err := l.token()
+			if err == nil && r == Keyword("R") {
+				return Ref{tok, g}, nil
+			}
+			if err == nil {
+				l.unread(r)
+			}
+		}
+		l.unread(gen)
+		return tok, nil
+	case Keyword:
+		switch tok {
+		case "<<":
+			d := make(Dict)
+			for {
+				key, err := l.token()
+				if err != nil {
+					return nil, err
+				}
+				if key == Keyword(">>") {
+					return d, nil
+				}
+				name, ok := key.(Name)
+				if !ok {
+					return nil, fmt.Errorf("%w: dictionary key is not a name", ErrMalformed)
+				}
+				if d[name], err = l.nested(depth + 1); err != nil {
+					return nil, err
+				}
+			}
+		case "[":
+			var arr []interface{}
+			for {
+				next, err := l.token()
+				if err != nil {
+					return nil, err
+				}
+				if next == Keyword("]") {
+					return arr, nil
+				}
+				l.unread(next)
+				v, err := l.nested(depth + 1)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, v)
+			}
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%w: unexpected %q", ErrMalformed, string(tok))
+	}
+	return tok, nil
+}
+
+// token returns the next token: a number, string, name or keyword, including delimiters such as
+// "<<" and "[" as keywords.
+func (l *lexer) token() (interface{}, error) {
+	if n := len(l.tokens); n > 0 {
+		tok := l.tokens[n-1]
+		l.tokens = l.tokens[:n-1]
+		return tok, nil
+	}
+	b, err := l.skipSpace()
+	if err != nil {
+		return nil, err
+	}
+	switch b {
+	case '[', ']', '{', '}':
+		return Keyword(b), nil
+	case '<':
+		next, err := l.readByte()
+		if err != nil {
+			return nil, err
+		}
+		if next == '<' {
+			return Keyword("<<"), nil
+		}
+		l.unreadByte()
+		return l.hexString()
+	case '>':
+		next, err := l.readByte()
+		if err != nil || next != '>' {
+			return nil, fmt.Errorf("%w: unexpected '>'", ErrMalformed)
+		}
+		return Keyword(">>"), nil
+	case '(':
+		return l.literalString()
+	case '/':
+		name, err := l.regular()
+		if err != nil {
+			return nil, err
+		}
+		return Name(unescapeName(name)), nil
+	}
+	l.unreadByte()
+	word, err := l.regular()
+	if err != nil {
+		return nil, err
+	}
+	if word == "" {
+		return nil, fmt.Errorf("%w: unexpected %q", ErrMalformed, b)
+	}
+	if c := word[0]; c == '+' || c == '-' || c == '.' || c >= '0' && c <= '9' {
+		if n, err := strconv.Atoi(word); err == nil {
+			return n, nil
+		}
+		if f, err := strconv.ParseFloat(word, 64); err == nil {
+			return f, nil
+		}
+	}
+	return Keyword(word), nil
+}
+
+// skipSpace skips white space and comments, returning the first byte following them.
+func (l *lexer) skipSpace() (byte, error) {
+	for {
+		b, err := l.readByte()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case b == '%':
+			for b != '\r' && b != '\n' {
+				if b, err = l.readByte(); err != nil {
+					return 0, err
+				}
+			}
+		case !isSpace(b):
+			return b, nil
+		}
+	}
+}
+
+// regular reads a run of regular characters, up to the next white space or delimiter.
+func (l *lexer) regular() (string, error) {
+	var sb []byte
+	for {
+		b, err := l.r.ReadByte()
+		if err == io.EOF {
+			return string(sb), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if isSpace(b) || isDelimiter(b) {
+			l.r.UnreadByte()
+			return string(sb), nil
+		}
+		l.pos++
+		sb = append(sb, b)
+	}
+}
+
+// literalString reads a string in parentheses, after the opening one.
+func (l *lexer) literalString() (string, error) {
+	var sb []byte
+	depth := 1
+	for {
+		b, err := l.readByte()
+		if err != nil {
+			return "", err
+		}
+		switch b {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return string(sb), nil
+			}
+		case '\\':
+			if b, err = l.readByte(); err != nil {
+				return "", err
+			}
+			switch b {
+			case 'n':
+				b = '\n'
+			case 'r':
+				b = '\r'
+			case 't':
+				b = '\t'
+			case 'b':
+				b = '\b'
+			case 'f':
+				b = '\f'
+			case '\r', '\n':
+				continue
+			default:
+				if b >= '0' && b <= '7' {
+					v := int(b - '0')
+					for i := 0; i < 2; i++ {
+						next, err := l.readByte()
+						if err != nil {
+							return "", err
+						}
+						if next < '0' || next > '7' {
+							l.unreadByte()
+							break
+						}
+						v = v*8 + int(next-'0')
+					}
+					b = byte(v)
+				}
+			}
+		}
+		sb = append(sb, b)
+	}
+}
+
+// hexString reads a string in angle brackets, after the opening one.
+func (l *lexer) hexString() (string, error) {
+	var sb []byte
+	var digits []byte
+	for {
+		b, err := l.readByte()
+		if err != nil {
+			return "", err
+		}
+		if b == '>' {
+			break
+		}
+		if !isSpace(b) {
+			digits = append(digits, b)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	for i := 0; i < len(digits); i += 2 {
+		v, err := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("%w: invalid hex string", ErrMalformed)
+		}
+		sb = append(sb, byte(v))
+	}
+	return string(sb), nil
+}
+
+// unescapeName replaces #xx escapes of a name.
+func unescapeName(name string) string {
+	if !bytes.ContainsRune([]byte(name), '#') {
+		return name
+	}
+	var sb []byte
+	for i := 0; i < len(name); i++ {
+		if name[i] == '#' && i+2 < len(name) {
+			if v, err := strconv.ParseUint(name[i+1:i+3], 16, 8); err == nil {
+				sb = append(sb, byte(v))
+				i += 2
+				continue
+			}
+		}
+		sb = append(sb, name[i])
+	}
+	return string(sb)
+}
+
+func isSpace(b byte) bool {
+	switch b {
+	case 0, '\t', '\n', '\f', '\r', ' ':
+		return true
+	}
+	return false
+}
+
+func isDelimiter(b byte) bool {
+	switch b {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,318 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Page is a page of a PDF file, along with its own or inherited resources.
+type Page struct {
+	dict      Dict
+	resources Dict
+}
+
+// maxDepth limits the depth of page trees and nested form XObjects followed.
+const maxDepth = 32
+
+// Pages returns all pages of the file in order.
+func (f *File) Pages() ([]Page, error) {
+	root := f.dict(f.trailer["Root"])
+	tree := f.dict(root["Pages"])
+	if tree == nil {
+		return nil, fmt.Errorf("%w: no page tree", ErrMalformed)
+	}
+	var pages []Page
+	visited := make(map[int]bool)
+	var walk func(node Dict, resources Dict, depth int)
+	walk = func(node Dict, resources Dict, depth int) {
+		if r := f.dict(node["Resources"]); r != nil {
+			resources = r
+		}
+		kids, ok := f.resolve(node["Kids"]).([]interface{})
+		if node["Type"] == Name("Page") || !ok {
+			pages = append(pages, Page{node, resources})
+			return
+		}
+		if depth >= maxDepth {
+			return
+		}
+		for _, kid := range kids {
+			if ref, ok := kid.(Ref); ok {
+				if visited[ref.num] {
+					continue
+				}
+				visited[ref.num] = true
+			}
+			if d := f.dict(kid); d != nil {
+				walk(d, resources, depth+1)
+			}
+		}
+	}
+	walk(tree, nil, 0)
+	return pages, nil
+}
+
+// Image is an image XObject of a PDF file.
+//
+// Images with filters other than DCTDecode are decoded by Open into raw samples of comps components
+// of bpc bits each, with the first component inverted if invert is true.
+type Image struct {
+	width, height int
+	comps, bpc    int
+	invert        bool
+	filters       []Name
+	parms         []Dict
+	off, length   int64
+}
+
+// area returns the number of pixels of img.
+func (img *Image) area() int64 {
+	return int64(img.width) * int64(img.height)
+}
+
+// LargestImage returns the largest image drawn on page p, or nil if there's none.
+func (f *File) LargestImage(p Page) *Image {
+	return f.largestImage(p.resources, 0)
+}
+
+// largestImage returns the largest image drawn with resources, including those of form XObjects
+// nested up to maxDepth deep, or nil if there's none. Manga pages hold a single image, possibly
+// along with small logos or invisible text.
+func (f *File) largestImage(resources Dict, depth int) *Image {
+	xobjects := f.dict(resources["XObject"])
+	names := make([]string, 0, len(xobjects))
+	for name := range xobjects {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	var largest *Image
+	for _, name := range names {
+		s, ok := f.resolve(xobjects[Name(name)]).(*Stream)
+		if !ok {
+			continue
+		}
+		var img *Image
+		switch s.dict["Subtype"] {
+		case Name("Image"):
+			img = f.image(s)
+		case Name("Form"):
+			if depth < maxDepth {
+				img = f.largestImage(f.dict(s.dict["Resources"]), depth+1)
+			}
+		}
+		if img != nil && (largest == nil || img.area() > largest.area()) {
+			largest = img
+		}
+	}
+	return largest
+}
+
+// image describes the image XObject s, or returns nil if it's a stencil mask or invalid.
+func (f *File) image(s *Stream) *Image {
+	d := s.dict
+	if mask, _ := f.resolve(d["ImageMask"]).(bool); mask {
+		return nil
+	}
+	raw, err := f.rawStream(s)
+	if err != nil {
+		return nil
+	}
+	img := &Image{off: s.off, length: raw.Size()}
+	img.width, _ = f.resolve(d["Width"]).(int)
+	img.height, _ = f.resolve(d["Height"]).(int)
+	img.bpc, _ = f.resolve(d["BitsPerComponent"]).(int)
+	if img.width <= 0 || img.height <= 0 {
+		return nil
+	}
+	img.comps = f.components(d["ColorSpace"])
+	if decode, err := f.ints(d["Decode"]); err == nil && len(decode) >= 2 {
+		img.invert = decode[0] == 1 && decode[1] == 0
+	}
+	img.filters, img.parms = f.filters(s)
+	// Parameters are read concurrently once decoded, so they must not need the file anymore.
+	for i, p := range img.parms {
+		resolved := make(Dict, len(p))
+		for k, v := range p {
+			resolved[k] = f.resolve(v)
+		}
+		img.parms[i] = resolved
+	}
+	return img
+}
+
+// components returns the number of color components of the color space cs, or 0 if it's not
+// supported.
+func (f *File) components(cs interface{}) int {
+	switch cs := f.resolve(cs).(type) {
+	case Name:
+		switch cs {
+		case "DeviceGray", "G", "CalGray":
+			return 1
+		case "DeviceRGB", "RGB", "CalRGB":
+			return 3
+		case "DeviceCMYK", "CMYK":
+			return 4
+		}
+	case []interface{}:
+		if len(cs) == 0 {
+			return 0
+		}
+		switch f.resolve(cs[0]) {
+		case Name("ICCBased"):
+			if len(cs) > 1 {
+				n, _ := f.resolve(f.dict(cs[1])["N"]).(int)
+				return n
+			}
+		case Name("CalGray"):
+			return 1
+		case Name("CalRGB"):
+			return 3
+		}
+	}
+	return 0
+}
+
+// Open returns the image's data read from r, the file it was parsed from, as a JPEG file or raw
+// samples, which image.Decode decodes once RegisterFormat is called. Images of more than maxPixels
+// pixels fail with ErrTooLarge before they're decoded. It's safe for concurrent use.
+func (img *Image) Open(r io.ReaderAt, maxPixels int64) (io.ReadCloser, error) {
+	data := io.NewSectionReader(r, img.off, img.length)
+	if len(img.filters) == 1 && (img.filters[0] == "DCTDecode" || img.filters[0] == "DCT") {
+		return io.NopCloser(data), nil
+	}
+	switch {
+	case img.comps != 1 && img.comps != 3 && img.comps != 4:
+		return nil, fmt.Errorf("%w: pdf image with %d color components", ErrUnsupported, img.comps)
+	case img.comps == 1 && img.bpc != 1 && img.bpc != 2 && img.bpc != 4 && img.bpc != 8 && img.bpc != 16,
+		img.comps > 1 && img.bpc != 8:
+		return nil, fmt.Errorf("%w: pdf image with %d bits per component", ErrUnsupported, img.bpc)
+	case img.area() > maxPixels:
+		return nil, fmt.Errorf("%w: %dx%d", ErrTooLarge, img.width, img.height)
+	}
+	size := int64(rowSize(img.width, img.comps, img.bpc)) * int64(img.height)
+	// Rows stored with PNG predictors start with an extra byte.
+	samples, err := decodeStream(data, img.filters, img.parms, size+int64(img.height))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(samples)) < size {
+		return nil, fmt.Errorf("%w: pdf image data too short", ErrCorrupt)
+	}
+	var b bytes.Buffer
+	b.WriteString(imageMagic)
+	binary.Write(&b, binary.BigEndian, imageHeader{
+		Width:  uint32(img.width),
+		Height: uint32(img.height),
+		Comps:  uint8(img.comps),
+		BPC:    uint8(img.bpc),
+		Invert: img.invert,
+	})
+	b.Write(samples)
+	return io.NopCloser(&b), nil
+}
+
+// rowSize returns the size in bytes of a row of raw samples.
+func rowSize(width, comps, bpc int) int {
+	return (width*comps*bpc + 7) / 8
+}
+
+// imageMagic starts raw samples of PDF images, which are registered as an image format so that
+// they're decoded like any other image.
+const imageMagic = "\x00mangaconv-pdf-image\x00"
+
+// imageHeader follows imageMagic.
+type imageHeader struct {
+	Width, Height uint32
+	Comps, BPC    uint8
+	Invert        bool
+}
+
+var registerOnce sync.Once
+
+// RegisterFormat registers the raw samples returned by Image.Open as an image format, so that
+// image.Decode decodes them. It's safe to call more than once.
+func RegisterFormat() {
+	registerOnce.Do(func() {
+		image.RegisterFormat("mangaconv-pdf-image", imageMagic, decodeImage, decodeImageConfig)
+	})
+}
+
+// readImageHeader reads the magic and header of raw samples.
+func readImageHeader(r io.Reader) (imageHeader, error) {
+	var h imageHeader
+	if _, err := io.ReadFull(r, make([]byte, len(imageMagic))); err != nil {
+		return h, err
+	}
+	err := binary.Read(r, binary.BigEndian, &h)
+	return h, err
+}
+
+func decodeImageConfig(r io.Reader) (image.Config, error) {
+	h, err := readImageHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	model := color.GrayModel
+	switch {
+	case h.Comps == 3:
+		model = color.RGBAModel
+	case h.Comps == 4:
+		model = color.CMYKModel
+	case h.BPC == 16:
+		model = color.Gray16Model
+	}
+	return image.Config{ColorModel: model, Width: int(h.Width), Height: int(h.Height)}, nil
+}
+
+func decodeImage(r io.Reader) (image.Image, error) {
+	h, err := readImageHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	w, ht := int(h.Width), int(h.Height)
+	row := rowSize(w, int(h.Comps), int(h.BPC))
+	samples := make([]byte, row*ht)
+	if _, err := io.ReadFull(r, samples); err != nil {
+		return nil, err
+	}
+	rect := image.Rect(0, 0, w, ht)
+	switch {
+	case h.Comps == 3:
+		img := image.NewRGBA(rect)
+		for i, j := 0, 0; i < len(samples); i, j = i+3, j+4 {
+			img.Pix[j], img.Pix[j+1], img.Pix[j+2], img.Pix[j+3] = samples[i], samples[i+1], samples[i+2], 0xff
+		}
+		return img, nil
+	case h.Comps == 4:
+		return &image.CMYK{Pix: samples, Stride: row, Rect: rect}, nil
+	case h.BPC == 16:
+		img := &image.Gray16{Pix: samples, Stride: row, Rect: rect}
+		if h.Invert {
+			for i := range samples {
+				samples[i] = ^samples[i]
+			}
+		}
+		return img, nil
+	}
+	img := image.NewGray(rect)
+	bpc := int(h.BPC)
+	max := 1<<bpc - 1
+	for y := 0; y < ht; y++ {
+		src, dst := samples[y*row:], img.Pix[y*img.Stride:]
+		for x := 0; x < w; x++ {
+			bit := x * bpc
+			v := int(src[bit/8]>>(8-bpc-bit%8)) & max
+			if h.Invert {
+				v = max - v
+			}
+			dst[x] = uint8(v * 0xff / max)
+		}
+	}
+	return img, nil
+}
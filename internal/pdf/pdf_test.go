@@ -0,0 +1,261 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// writePDF returns a PDF file holding objects, numbered from 1. Object 1 must be the document
+// catalog, and trailer holds any other trailer entries. If xrefStream is true, the cross reference
+// table is stored in a compressed stream instead.
+func writePDF(t testing.TB, objects []string, trailer string, xrefStream bool) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	start := b.Len()
+	if xrefStream {
+		var entries bytes.Buffer
+		entries.Write([]byte{0, 0, 0, 0, 0xff})
+		for _, off := range offsets {
+			entries.Write([]byte{1, byte(off >> 16), byte(off >> 8), byte(off), 0})
+		}
+		entries.Write([]byte{1, byte(start >> 16), byte(start >> 8), byte(start), 0})
+		data := deflate(t, entries.Bytes())
+		fmt.Fprintf(&b, "%d 0 obj\n<< /Type /XRef /Size %d /W [1 3 1] /Root 1 0 R %s ", len(objects)+1, len(objects)+2,
+			trailer)
+		fmt.Fprintf(&b, "/Filter /FlateDecode /Length %d >>\n", len(data))
+		b.WriteString("stream\n")
+		b.Write(data)
+		b.WriteString("\nendstream\nendobj\n")
+	} else {
+		fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+		for _, off := range offsets {
+			fmt.Fprintf(&b, "%010d 00000 n \n", off)
+		}
+		fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R %s >>\n", len(objects)+1, trailer)
+	}
+	fmt.Fprintf(&b, "startxref\n%d\n%%%%EOF\n", start)
+	return b.Bytes()
+}
+
+// deflate returns data compressed with zlib.
+func deflate(t testing.TB, data []byte) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	zw := zlib.NewWriter(&b)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// imageStream returns an image XObject holding data.
+func imageStream(dict string, data []byte) string {
+	return fmt.Sprintf("<< /Type /XObject /Subtype /Image %s /Length %d >>\nstream\n%s\nendstream",
+		dict, len(data), data)
+}
+
+// testPDF returns the objects of a PDF file with four pages: a JPEG image, a compressed grayscale
+// image stored with a PNG predictor, a page of text only and an inverted 1-bit image drawn by a
+// form XObject, along with the images of the first, second and fourth pages.
+func testPDF(t testing.TB) ([]string, []image.Image) {
+	t.Helper()
+	gray := image.NewGray(image.Rect(0, 0, 16, 12))
+	for i := range gray.Pix {
+		gray.Pix[i] = uint8(i * 7)
+	}
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, gray, nil); err != nil {
+		t.Fatal(err)
+	}
+	fromJPEG, err := jpeg.Decode(bytes.NewReader(jpg.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each row is stored with the PNG Up filter, as the difference to the row above.
+	var predicted []byte
+	for y := 0; y < 12; y++ {
+		predicted = append(predicted, 2)
+		for x := 0; x < 16; x++ {
+			v := gray.GrayAt(x, y).Y
+			if y > 0 {
+				v -= gray.GrayAt(x, y-1).Y
+			}
+			predicted = append(predicted, v)
+		}
+	}
+
+	// Set bits are black, as the first value of Decode is the shade of 0 bits.
+	bits := image.NewGray(image.Rect(0, 0, 10, 2))
+	for i := range bits.Pix {
+		bits.Pix[i] = 0xff
+	}
+	bits.SetGray(0, 0, color.Gray{})
+	bits.SetGray(9, 0, color.Gray{})
+	bits.SetGray(3, 1, color.Gray{})
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R 5 0 R] /Count 4 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /XObject << /Im0 6 0 R /Logo 7 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /XObject << /Im0 8 0 R >> >> >>",
+		"<< /Type /Pages /Parent 2 0 R /Kids [9 0 R 10 0 R] /Count 2 /Resources << /XObject << /Fm0 11 0 R >> >> >>",
+		imageStream("/Width 16 /Height 12 /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /DCTDecode", jpg.Bytes()),
+		imageStream("/Width 2 /Height 2 /ColorSpace /DeviceGray /BitsPerComponent 8", []byte{0, 0, 0, 0}),
+		imageStream("/Width 16 /Height 12 /ColorSpace [/ICCBased 12 0 R] /BitsPerComponent 8 "+
+			"/Filter [/FlateDecode] /DecodeParms [<< /Predictor 15 /Columns 16 >>]", deflate(t, predicted)),
+		"<< /Type /Page /Parent 5 0 R /Resources << /Font << >> >> >>",
+		"<< /Type /Page /Parent 5 0 R >>",
+		"<< /Type /XObject /Subtype /Form /Resources << /XObject << /Im0 13 0 R >> >> /Length 0 >>\n" +
+			"stream\n\nendstream",
+		"<< /N 1 /Length 0 >>\nstream\n\nendstream",
+		imageStream("/Width 10 /Height 2 /ColorSpace /DeviceGray /BitsPerComponent 1 /Decode [1 0]",
+			[]byte{0x80, 0x40, 0x10, 0x00}),
+	}
+	return objects, []image.Image{fromJPEG, gray, bits}
+}
+
+func TestImages(t *testing.T) {
+	RegisterFormat()
+	objects, images := testPDF(t)
+	want := [][]uint8{images[0].(*image.Gray).Pix, images[1].(*image.Gray).Pix, nil, images[2].(*image.Gray).Pix}
+
+	for _, xrefStream := range []bool{false, true} {
+		t.Run(fmt.Sprintf("xref stream %v", xrefStream), func(t *testing.T) {
+			data := writePDF(t, objects, "", xrefStream)
+			r := bytes.NewReader(data)
+			f, err := Open(r, r.Size())
+			if err != nil {
+				t.Fatal(err)
+			}
+			pages, err := f.Pages()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got [][]uint8
+			for _, p := range pages {
+				img := f.LargestImage(p)
+				if img == nil {
+					got = append(got, nil)
+					continue
+				}
+				rc, err := img.Open(r, 1<<20)
+				if err != nil {
+					t.Fatal(err)
+				}
+				decoded, _, err := image.Decode(rc)
+				rc.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				gray, ok := decoded.(*image.Gray)
+				if !ok {
+					t.Fatalf("decoded a %T, want *image.Gray", decoded)
+				}
+				got = append(got, gray.Pix)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("page images mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		objects   []string
+		trailer   string
+		maxPixels int64
+		err       error
+	}{
+		{
+			name: "encrypted",
+			objects: []string{
+				"<< /Type /Catalog /Pages 2 0 R >>",
+				"<< /Type /Pages /Kids [] /Count 0 >>",
+			},
+			trailer: "/Encrypt << /Filter /Standard >>",
+			err:     ErrUnsupported,
+		},
+		{
+			name: "unsupported filter",
+			objects: []string{
+				"<< /Type /Catalog /Pages 2 0 R >>",
+				"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+				"<< /Type /Page /Parent 2 0 R /Resources << /XObject << /Im0 4 0 R >> >> >>",
+				imageStream("/Width 1 /Height 1 /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /JPXDecode",
+					[]byte{0}),
+			},
+			maxPixels: 1,
+			err:       ErrUnsupported,
+		},
+		{
+			name: "too large",
+			objects: []string{
+				"<< /Type /Catalog /Pages 2 0 R >>",
+				"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+				"<< /Type /Page /Parent 2 0 R /Resources << /XObject << /Im0 4 0 R >> >> >>",
+				imageStream("/Width 2 /Height 2 /ColorSpace /DeviceGray /BitsPerComponent 8", []byte{0, 0, 0, 0}),
+			},
+			maxPixels: 3,
+			err:       ErrTooLarge,
+		},
+		{
+			name:    "no page tree",
+			objects: []string{"<< /Type /Catalog >>"},
+			err:     ErrMalformed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := openAll(writePDF(t, tt.objects, tt.trailer, false), tt.maxPixels); !errors.Is(got, tt.err) {
+				t.Errorf("error = %v, want %v", got, tt.err)
+			}
+		})
+	}
+}
+
+// openAll opens the largest image of each page of the PDF file data, limited to maxPixels pixels,
+// and returns the first error.
+func openAll(data []byte, maxPixels int64) error {
+	r := bytes.NewReader(data)
+	f, err := Open(r, r.Size())
+	if err != nil {
+		return err
+	}
+	pages, err := f.Pages()
+	if err != nil {
+		return err
+	}
+	for _, p := range pages {
+		img := f.LargestImage(p)
+		if img == nil {
+			continue
+		}
+		rc, err := img.Open(r, maxPixels)
+		if err != nil {
+			return err
+		}
+		rc.Close()
+	}
+	return nil
+}
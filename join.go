@@ -0,0 +1,112 @@
+package mangaconv
+
+import (
+	"context"
+	"image"
+	"strings"
+
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+// stitchBlend is the number of columns on each side of the seam blended when joining spreads.
+const stitchBlend = 2
+
+// spreadHalf splits the name of a page stored as one half of a spread, such as "012a" or "p12_R",
+// into the page number it shares with the other half and a lowercase half marker: 'a' and 'b' for
+// halves named in reading order, 'l' and 'r' for left and right halves. ok is false for names not
+// ending in a marker preceded by a digit.
+func spreadHalf(name string) (prefix string, half byte, ok bool) {
+	if len(name) < 2 {
+		return "", 0, false
+	}
+	half = name[len(name)-1] | 0x20
+	if !strings.ContainsRune("ablr", rune(half)) {
+		return "", 0, false
+	}
+	prefix = strings.TrimRight(name[:len(name)-1], "-_ ")
+	if prefix == "" || !isDigit(prefix[len(prefix)-1]) {
+		return "", 0, false
+	}
+	return prefix, half, true
+}
+
+// spreadHalves reports whether pages named first and second, in reading order, are the two halves
+// of a single spread, and whether first is its left half.
+func spreadHalves(first, second string, ltr bool) (ok, firstLeft bool) {
+	p1, h1, ok1 := spreadHalf(first)
+	p2, h2, ok2 := spreadHalf(second)
+	if !ok1 || !ok2 || p1 != p2 {
+		return false, false
+	}
+	switch {
+	case h1 == 'a' && h2 == 'b':
+		return true, ltr
+	case h1 == 'l' && h2 == 'r':
+		return true, true
+	case h1 == 'r' && h2 == 'l':
+		return true, false
+	}
+	return false, false
+}
+
+// joinHalves reads raw pages in page order and emits them with spreads stored as two files joined
+// into a single raw page. Pages are renumbered to account for joined halves.
+func (c *Converter) joinHalves(ctx context.Context, out chan<- rawPage, in <-chan rawPage) error {
+	i := 0
+	emit := func(p rawPage) error {
+		p.Index = i
+		i++
+		select {
+		case out <- p:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	var prev *rawPage
+	for p := range in {
+		p := p
+		if prev != nil && prev.Open != nil && p.Open != nil && prev.Chapter == p.Chapter {
+			if ok, _ := spreadHalves(prev.Name, p.Name, c.params.LeftToRight); ok {
+				prev.Join = &p
+				if err := emit(*prev); err != nil {
+					return err
+				}
+				prev = nil
+				continue
+			}
+		}
+		if prev != nil {
+			if err := emit(*prev); err != nil {
+				return err
+			}
+		}
+		prev = &p
+	}
+	if prev != nil {
+		return emit(*prev)
+	}
+	return nil
+}
+
+// joinStage starts joining spread halves read from raw if JoinSpreads is enabled, returning the
+// channel joined pages are emitted to. Otherwise, raw is returned as is.
+func (c *Converter) joinStage(ctx context.Context, start func(func() error), raw <-chan rawPage) <-chan rawPage {
+	if !c.params.JoinSpreads {
+		return raw
+	}
+	joined := make(chan rawPage)
+	start(func() error {
+		defer close(joined)
+		return c.joinHalves(ctx, joined, raw)
+	})
+	return joined
+}
+
+// joinImages joins the decoded halves of a spread side by side into a grayscale page with
+// imgutil.Stitch. Halves may be misaligned by up to 1% of their height.
+func joinImages(left, right image.Image) *image.Gray {
+	l, r := imgutil.Grayscale(left), imgutil.Grayscale(right)
+	return imgutil.Stitch(l, r, l.Rect.Dy()/100, stitchBlend)
+}
@@ -0,0 +1,85 @@
+package mangaconv
+
+import (
+	"image"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/naisuuuu/mangaconv/imgtest"
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+func TestSpreadHalves(t *testing.T) {
+	tests := []struct {
+		first, second string
+		ltr           bool
+		ok, left      bool
+	}{
+		{"012a", "012b", false, true, false},
+		{"012a", "012b", true, true, true},
+		{"p12_A", "p12_B", false, true, false},
+		{"012-l", "012-r", false, true, true},
+		{"012R", "012L", true, true, false},
+		{"012b", "012a", false, false, false},
+		{"012a", "013b", false, false, false},
+		{"extra", "extrb", false, false, false},
+		{"a", "b", false, false, false},
+		{"012", "013", false, false, false},
+	}
+	for _, tt := range tests {
+		ok, left := spreadHalves(tt.first, tt.second, tt.ltr)
+		if ok != tt.ok || left != tt.left {
+			t.Errorf("spreadHalves(%q, %q, %v) = %v, %v, want %v, %v",
+				tt.first, tt.second, tt.ltr, ok, left, tt.ok, tt.left)
+		}
+	}
+}
+
+func TestReaderJoinSpreads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "halves.zip")
+	mustWriteZip(t, path, map[string]string{
+		"000.png":  "testdata/wikipe-tan-0.png",
+		"001a.png": "testdata/wikipe-tan-0.png",
+		"001b.png": "testdata/wikipe-tan-1.png",
+		"002.png":  "testdata/wikipe-tan-1.png",
+	})
+
+	got, err := readHelper(Params{JoinSpreads: true, Order: OrderNatural}, path)
+	if err != nil {
+		t.Fatalf("reader error %v", err)
+	}
+	img0 := imgtest.MustRead("testdata/wikipe-tan-0.png")
+	img1 := imgtest.MustRead("testdata/wikipe-tan-1.png")
+	// Manga are read right to left, so the first half is on the right.
+	want := []page{
+		{Image: img0, Index: 0, Name: "000", Source: "000.png"},
+		{Image: joinImages(img1, img0), Index: 1, Name: "001a", Source: "001a.png"},
+		{Image: img1, Index: 2, Name: "002", Source: "002.png"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("reader mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestJoinImages(t *testing.T) {
+	left := image.NewGray(image.Rect(0, 0, 2, 2))
+	right := image.NewGray(image.Rect(0, 0, 1, 3))
+	for i := range right.Pix {
+		right.Pix[i] = 0x80
+	}
+	want := &image.Gray{
+		// The seam is blended, and the left half padded with white.
+		Pix: []uint8{
+			0x00, 0x20, 0x60,
+			0x00, 0x20, 0x60,
+			0xff, 0xdf, 0xa0,
+		},
+		Stride: 3,
+		Rect:   image.Rect(0, 0, 3, 3),
+	}
+	if diff := cmp.Diff(want, joinImages(left, imgutil.Grayscale(right))); diff != "" {
+		t.Errorf("joinImages() mismatch (-want +got):\n%s", diff)
+	}
+}
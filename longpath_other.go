@@ -0,0 +1,10 @@
+//go:build !windows
+// +build !windows
+
+package mangaconv
+
+// LongPath returns path in a form that is not subject to the MAX_PATH limit on Windows. On other
+// platforms it returns path unchanged.
+func LongPath(path string) string {
+	return path
+}
@@ -0,0 +1,29 @@
+package mangaconv
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// maxPath is the length above which Windows APIs may refuse a path. It's MAX_PATH minus room for
+// an 8.3 file name, which is the limit for directories.
+const maxPath = 248
+
+// LongPath returns path in a form that is not subject to the MAX_PATH limit on Windows, by
+// converting it to an absolute extended-length (\\?\) path. Paths which are still short once
+// made absolute are returned unchanged.
+func LongPath(path string) string {
+	if strings.HasPrefix(path, `\\?\`) {
+		return path
+	}
+	// Relative paths are resolved against the working directory, which may make them too long.
+	abs, err := filepath.Abs(path)
+	if err != nil || len(abs) < maxPath {
+		return path
+	}
+	if strings.HasPrefix(abs, `\\`) {
+		// UNC path, e.g. \\server\share\dir.
+		return `\\?\UNC\` + abs[2:]
+	}
+	return `\\?\` + abs
+}
@@ -3,13 +3,16 @@
 package mangaconv
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
 	"fmt"
 	"image"
 	"io"
-	"os"
+	"path/filepath"
 	"runtime"
 	"sync"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 
@@ -18,104 +21,870 @@ import (
 
 // Params adjust how each page of a manga is transformed. For sane defaults, see cmd/mangaconv.
 //
-// Cutoff is the % of brightest and darkest pixels ignored when applying histogram normalization.
-// Deflate controls whether or not an image should be additionally compressed when saved to a cbz
-// file.
+// AutoContrast applies histogram normalization to grayscale pages, stretching their gray levels
+// to the full range while ignoring Cutoff % of the brightest and darkest pixels.
+// AutoRotate rotates pages which would be displayed considerably larger when rotated, such as wide
+// maps and charts. Pages already rotated due to Spreads are left as is.
+// Bolden thickens dark strokes after scaling, so that thin lines don't disappear on e-ink screens.
+// It's the weight in the range [0, 1] of a copy of the page with strokes grown by a pixel, with 0
+// disabling it. Pages kept in color aren't boldened.
+// Brightness and Contrast apply a fixed linear adjustment after histogram normalization, if any, as
+// described by imgutil.AdjustBrightnessContrast. Values of 0 disable it.
+// Chapters enables grouping of pages by the folder they are stored in, with each folder treated as
+// a chapter. Chapter boundaries are recorded as bookmarks in ComicInfo.xml.
+// ComicInfo controls whether a ComicInfo.xml file with metadata parsed from the input name is
+// added to the output cbz file.
+// ComicInfoHints reads the input's own ComicInfo.xml, if any, and follows its hints: Manga sets the
+// reading direction instead of LeftToRight, pages typed as covers are never split or rotated, and
+// if any page is marked as a double page, only marked pages are handled as spreads. Bookmarks are
+// carried over to the output's ComicInfo.xml, taking precedence over chapter bookmarks.
+// Compression selects the compression method of entries in the output cbz file. By default,
+// already compressed images are stored and everything else is deflated.
+// Credits controls what happens to credits pages, such as scanlation group credits and recruitment
+// pages. They are detected by recognizing each page's text with OCR and looking for at least two
+// of CreditKeywords, or DefaultCreditKeywords if empty. Detection is disabled if OCR is nil.
+// Cutoff is the % of brightest and darkest pixels ignored by AutoContrast.
+// Deflate deflates all entries of the output cbz file, like CompressionDeflate, unless Compression
+// is set. It's deprecated, as documented on the field.
+// Errors controls whether pages which can't be read or decoded abort the conversion or are skipped.
+// ExactSize pads every page with MarginColor to exactly Width by Height, for readers which zoom or
+// reflow pages not matching the screen resolution.
+// Fit controls how pages are scaled to Width and Height. AutoRotate has no effect with FitWidth.
+// FixedPoint scales pages with integer fixed-point arithmetic, which is much faster on CPUs without
+// a floating point unit, such as the 32-bit ARM CPUs of many e-readers. Results differ from floating
+// point scaling by at most a couple of gray levels.
+// Format is the file format of outputs. EPUB books are fixed layout, with each page's viewport
+// set to its image size; combined with ExactSize, every page matches the screen resolution, which
+// keeps readers such as Kobo from slowly zooming or reflowing mismatched pages.
 // Gamma is the multiplier by which an image is darkened or brightened. Values > 1 brighten and
 // values < 1 darken it, with 1 leaving the image as is.
+// GrayRGB encodes grayscale pages as three channel JPEGs, for readers which can't display single
+// channel ones. By default, pages without noticeable color, including those kept in color, are
+// encoded as single channel grayscale JPEGs, which are considerably smaller.
 // Height and Width describe a bounding box in which the output image will be fit.
+// Intermediate16 scales grayscale pages into 16-bit intermediates, applies histogram normalization,
+// Brightness, Contrast and Gamma to them and only then reduces them to 8 bits with Floyd-Steinberg
+// dithering, for high quality sources whose gradients visibly band with 8-bit intermediates. It's
+// slower and takes more memory, and takes precedence over SmoothGamma and FixedPoint. It has no
+// effect with a Scaler not implementing imgutil.FractionalScaler.
+// JoinSpreads joins spreads stored as two consecutive files back into a single page, before Spreads
+// applies. Halves are named like "012a.jpg" and "012b.jpg" in reading order, or "012l.jpg" and
+// "012r.jpg" for left and right. Joined pages are grayscale.
+// LeftToRight sets the reading direction used when splitting spreads. Manga are read right to left.
+// LowMemory converts a single page at a time without buffering pages between stages, keeps fewer
+// pixel buffers for reuse and stores pages uncompressed, for devices with little memory such as
+// e-readers. It overrides Compression, PageBuffer and ScaleWorkers, and plans' GlobalContrast,
+// which needs all pages analyzed first.
+// KeepNames appends the original file name to each page's name in the output archive. Pages are
+// still prefixed with their number, so that the page order is preserved.
+// Manifest adds a manifest.json file listing each page's name, SHA-256 checksum and source file
+// to the output cbz file.
+// Margin adds a border of Margin pixels of MarginColor around each page after scaling. The bounding
+// box is reduced accordingly, so that pages with margins still fit in Height by Width.
+// MaxOpenFiles limits the number of input files and archive entries open at once across all
+// conversions sharing a Converter, with 0 leaving it unlimited. Pages are bounded by the number of
+// CPUs regardless, so this is mostly useful on systems with a low file descriptor limit.
+// MaxPageHeight splits pages taller than MaxPageHeight pixels once scaled, such as webtoon strips
+// with FitWidth, into bands of even height written as separate pages, including pages kept in
+// color. Bands are converted one at a time, so that no buffer holding the whole page is allocated.
+// Margins, page numbers and the watermark are applied once to the whole page, so that the first
+// band holds the top margin and the last one the bottom margin. 0 never splits pages.
+// MetadataProviders are queried in order for series metadata written to ComicInfo.xml, such as a
+// summary and genres. The first provider finding the series parsed from the input name is used,
+// filling in fields not already known. Each series is looked up once per Converter. Failed lookups
+// fall back to the parsed metadata.
+// MinPageSize skips input images smaller than MinPageSize pixels in both dimensions, such as
+// thumbnails and scanner group logos. Images are skipped before being fully decoded.
+// OCR recognizes text for Credits. Running it on every page is slow. OCR errors are handled by the
+// Errors policy, except that pages OCR fails on are converted as regular pages when skipping.
+// Order controls how entries of archive inputs are mapped to page order.
+// PageBuffer is the number of pages buffered between the decode, convert and write stages. With
+// unbuffered stages, a slow page stalls the others; larger buffers keep all stages busy at the cost
+// of holding up to PageBuffer more decoded and converted pages in memory. Negative values buffer
+// one page per CPU.
+// PageNumbers burns the page number into the given corner of each page. PageNumberSize is the
+// height of the page number in pixels, with 0 selecting a size relative to the page height.
+// PDFDPI is the resolution PDF pages are rendered at by PDFRasterizer, 300 if not set.
+// PDFRasterizer renders each page of PDF inputs. Without it, the largest image embedded in each page
+// is extracted instead, which suits scanned manga but drops pages made of text or vector drawings.
+// PreserveTone keeps the median gray level of each page at its original tone when applying
+// histogram normalization, for pages where full normalization makes gray washes too bright.
+// ProtectText leaves lettering found by imgutil.TextRegions, such as dialogue in speech bubbles, as
+// is when applying Bolden, which otherwise fills in small letters, and rounds it instead of
+// dithering it with Intermediate16, which otherwise leaves noise around letters.
+// Quantize rounds scaled page dimensions down to multiples of Quantize pixels, which greatly
+// improves reuse of scalers and pooled images for inputs with pages of slightly different sizes.
+// Values <= 1 disable it.
+// ReadRetries is the number of times reading an input file is retried after a transient error, such
+// as an I/O error on a network mount. RetryDelay is the delay before the first retry, doubled after
+// each one.
+// Scaler replaces the built-in scaler, an imgutil.CacheScaler with imgutil.CatmullRom, such as with
+// another kernel or a backend of package accel. It must be safe for concurrent use, and also applies
+// lookup tables if it implements imgutil.LUTScaler. FixedPoint and ScaleWorkers have no effect when
+// it's set. 16-bit grayscale pages, such as high bit depth PNGs, are only rounded to 8 bits once
+// scaled if it implements imgutil.Scaler16, as the built-in scaler does, unless they're split or
+// rotated. SmoothGamma and Intermediate16 don't apply to such pages.
+// ScaleWorkers is the number of goroutines scaling a single page. Values > 1 help when converting
+// few large pages at once, such as a single file, on machines with many cores.
+// SmoothGamma applies histogram normalization, Brightness, Contrast and Gamma to scaled pixels of
+// grayscale pages before rounding them to 8 bits, avoiding banding in dark gradients. Pages
+// normalized on their own histogram are measured before scaling.
+// It has no effect with a Scaler not implementing imgutil.CurveScaler.
+// Spreads controls how double page spreads are handled.
+// SplitChapters writes a separate archive for each chapter when converting to a file, named after
+// the output with the chapter folder appended, e.g. "out - c001.cbz". Pages in the input root are
+// written to the output itself, which isn't written at all if there are none; Result.Outputs lists
+// the archives written. It has no effect unless Chapters is enabled.
+// SplitOffset moves the seam along which spreads are split by the given % of the spread's width,
+// with positive values moving it to the right. SplitOverlap is the % of the spread's width each
+// half extends past the seam.
+// Strict fails inputs which look mis-packaged instead of converting whatever images they hold: inputs
+// without images, with more than StrictNonImages % of files other than images and metadata, or with
+// a page more than StrictSizeRatio times higher or lower than the median page. Zero values disable
+// the latter two checks. Failures wrap ErrSuspiciousInput.
+// Symlinks controls whether symbolic links are followed when reading directories.
+// TempDir is the directory outputs are written to until they're complete, with an empty string
+// writing them next to their final path. Outputs are moved into place once complete.
+// TitlePages inserts a generated title page before the first page of each chapter. It has no
+// effect unless Chapters is enabled.
+// Watermark is an image composited onto WatermarkCorner of each page with WatermarkOpacity in the
+// range [0, 1]. It's converted to grayscale, with transparency preserved.
 type Params struct {
-	Cutoff  float64
-	Deflate bool
-	Gamma   float64
-	Height  int
-	Width   int
+	AutoContrast   bool
+	AutoRotate     bool
+	Bolden         float64
+	Brightness     float64
+	Chapters       bool
+	ComicInfo      bool
+	ComicInfoHints bool
+	Compression    Compression
+	Contrast       float64
+	CreditKeywords []string
+	Credits        CreditsPolicy
+	Cutoff         float64
+	// Deprecated: use Compression instead. Deflate will be removed in the next release.
+	Deflate           bool
+	Errors            ErrorPolicy
+	ExactSize         bool
+	Fit               FitMode
+	FixedPoint        bool
+	Format            Format
+	Gamma             float64
+	GrayRGB           bool
+	Height            int
+	Intermediate16    bool
+	JoinSpreads       bool
+	KeepNames         bool
+	LeftToRight       bool
+	LowMemory         bool
+	Manifest          bool
+	Margin            int
+	MarginColor       uint8
+	MaxOpenFiles      int
+	MaxPageHeight     int
+	MetadataProviders []MetadataProvider
+	MinPageSize       int
+	OCR               OCREngine
+	Order             PageOrder
+	PageBuffer        int
+	PageNumbers       Corner
+	PageNumberSize    int
+	PDFDPI            int
+	PDFRasterizer     PDFRasterizer
+	PreserveTone      bool
+	ProtectText       bool
+	Quantize          int
+	ReadRetries       int
+	RetryDelay        time.Duration
+	Scaler            imgutil.Scaler
+	ScaleWorkers      int
+	SmoothGamma       bool
+	SplitChapters     bool
+	SplitOffset       float64
+	SplitOverlap      float64
+	Spreads           SpreadPolicy
+	Strict            bool
+	StrictNonImages   float64
+	StrictSizeRatio   float64
+	Symlinks          SymlinkPolicy
+	TempDir           string
+	TitlePages        bool
+	Watermark         image.Image
+	WatermarkCorner   Corner
+	WatermarkOpacity  float64
+	Width             int
+}
+
+// DefaultParams returns the defaults of cmd/mangaconv, which suit most e-readers.
+func DefaultParams() Params {
+	return Params{
+		AutoContrast:     true,
+		ComicInfo:        true,
+		Cutoff:           1,
+		FixedPoint:       runtime.GOARCH == "arm",
+		Gamma:            0.75,
+		Height:           1920,
+		MarginColor:      0xff,
+		PDFDPI:           300,
+		ReadRetries:      3,
+		RetryDelay:       time.Second,
+		ScaleWorkers:     1,
+		StrictNonImages:  20,
+		StrictSizeRatio:  3,
+		WatermarkCorner:  CornerBottomRight,
+		WatermarkOpacity: 0.3,
+		Width:            1920,
+	}
 }
 
 // New creates a new Converter with the provided Params.
 func New(p Params) *Converter {
-	return &Converter{
-		params: p,
-		scaler: imgutil.NewCacheScaler(imgutil.CatmullRom),
-		pool:   imgutil.NewImagePool(),
+	if p.Deflate && p.Compression == CompressionAuto {
+		p.Compression = CompressionDeflate
+	}
+	if p.LowMemory {
+		p.Compression, p.PageBuffer, p.ScaleWorkers = CompressionStore, 0, 1
+	}
+	c := &Converter{
+		params:  p,
+		scaler:  p.Scaler,
+		pool:    imgutil.NewImagePool(),
+		lookups: newLookupCache(),
+	}
+	if c.scaler == nil {
+		scaler := imgutil.NewCacheScaler(imgutil.CatmullRom)
+		scaler.SetConcurrency(p.ScaleWorkers)
+		scaler.SetFixedPoint(p.FixedPoint)
+		c.scaler = scaler
+	}
+	if p.LowMemory {
+		c.pool = imgutil.NewImagePoolSize(lowMemoryPoolBytes)
+	}
+	c.slots = newScheduler(c.workers())
+	bc := imgutil.BrightnessContrastLUT(p.Brightness, p.Contrast)
+	gamma := imgutil.GammaLUT(p.Gamma)
+	c.adjust = bc.Then(&gamma)
+	if p.SmoothGamma || p.Intermediate16 {
+		bcFunc, gammaFunc := imgutil.BrightnessContrastFunc(p.Brightness, p.Contrast), imgutil.GammaFunc(p.Gamma)
+		c.adjustFunc = func(level float64) float64 { return gammaFunc(bcFunc(level)) }
+	}
+	if p.Intermediate16 {
+		c.adjust16 = imgutil.NewLUT16(c.adjustFunc)
+	}
+	if p.MaxOpenFiles > 0 {
+		c.files = make(chan struct{}, p.MaxOpenFiles)
 	}
+	if p.Watermark != nil && p.WatermarkCorner != CornerNone {
+		c.watermark, c.watermarkMask = newWatermark(p.Watermark)
+	}
+	return c
 }
 
 // Converter converts manga for reading on an e-reader. It's safe to use concurrently.
+//
+// Decoding and converting pages is bounded to one page per CPU across all conversions sharing a
+// Converter, so converting several files at once keeps all cores busy without oversubscribing them.
+// Pages of files started earlier by ConvertAll take precedence.
 type Converter struct {
-	params Params
-	scaler imgutil.Scaler
-	pool   *imgutil.ImagePool
+	params        Params
+	scaler        imgutil.Scaler
+	pool          *imgutil.ImagePool
+	slots         *scheduler
+	files         chan struct{}
+	lookups       *lookupCache
+	adjust        imgutil.LUT
+	adjustFunc    func(level float64) float64
+	adjust16      *imgutil.LUT16
+	watermark     *image.Gray
+	watermarkMask *image.Alpha
 }
 
-// Convert reads a file from in, converts it, and writes to out.
-func (c *Converter) Convert(in, out string) error {
-	f, err := os.Create(out)
-	if err != nil {
-		return err
+// Stats describes how well a Converter reuses memory across pages. Pool describes the pool of
+// page images and Scaler the cache of scalers for each page size.
+//
+// Few Hits compared to Misses mean pages are mostly allocated anew. Pages come in many sizes when
+// scaled, so setting Quantize or ExactSize lets more of them share pixel slices and scalers.
+type Stats struct {
+	Pool   imgutil.PoolStats
+	Scaler imgutil.CacheStats
+}
+
+// Stats returns usage statistics of the Converter's pools, accumulated over all conversions.
+func (c *Converter) Stats() Stats {
+	s := Stats{Pool: c.pool.Stats()}
+	if z, ok := c.scaler.(*imgutil.CacheScaler); ok {
+		s.Scaler = z.Stats()
 	}
-	defer f.Close()
+	return s
+}
 
-	return c.ConvertToWriter(in, f)
+// acquire blocks until a worker slot is available or ctx is done.
+func (c *Converter) acquire(ctx context.Context) error {
+	return c.slots.acquire(ctx)
+}
+
+// release frees a worker slot taken by acquire.
+func (c *Converter) release() {
+	c.slots.release()
+}
+
+// Convert reads a file from in, converts it, and writes to out. If conversion fails and Errors is
+// ErrorsAbort, out is removed.
+func (c *Converter) Convert(in, out string) error {
+	_, err := convertTarget(context.Background(), Target{In: in, Out: []string{out}}, BatchOptions{
+		Converters:     []*Converter{c},
+		SkipSpaceCheck: true,
+	})
+	return err
 }
 
 // Convert reads a file from in, converts it, and writes to an io.Writer.
 func (c *Converter) ConvertToWriter(in string, out io.Writer) error {
-	read, err := selectReader(in)
+	return ConvertMulti(in, Output{Converter: c, Writer: out})
+}
+
+// ConvertToFunc reads a file from in, converts it, and passes each converted page to fn instead of
+// encoding it. This lets viewers display pages without writing an archive. See Output.Pages.
+func (c *Converter) ConvertToFunc(in string, fn func(Page) error) error {
+	return ConvertMulti(in, Output{Converter: c, Pages: fn})
+}
+
+// ConvertZipReader reads a zip archive of size bytes from r, converts it, and writes to out, without
+// touching the file system, such as when converting in a browser. name is the archive's file name,
+// which metadata is parsed from as with ParseFilename.
+func (c *Converter) ConvertZipReader(name string, r io.ReaderAt, size int64, out io.Writer) error {
+	return c.convertZipReader(context.Background(), name, r, size, out)
+}
+
+// ConvertBytes converts the archive in input and returns the converted cbz archive, without
+// touching the file system, such as in serverless functions. name is the input's file name, whose
+// extension selects the input format and which metadata is parsed from as with ParseFilename.
+// Conversion stops early when ctx is canceled.
+func ConvertBytes(ctx context.Context, input []byte, name string, p Params) ([]byte, error) {
+	if !isArchive(filepath.Ext(name)) {
+		return nil, fmt.Errorf("cannot read %s: %w", name, ErrUnsupportedFormat)
+	}
+	var out bytes.Buffer
+	if err := New(p).convertZipReader(ctx, name, bytes.NewReader(input), int64(len(input)), &out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// convertZipReader implements ConvertZipReader, stopping early when ctx is canceled.
+func (c *Converter) convertZipReader(ctx context.Context, name string, r io.ReaderAt, size int64,
+	out io.Writer) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("cannot open %s: %w", name, err)
+	}
+	return c.convertZip(ctx, zr, ParseFilename(name), out)
+}
+
+// ProcessPage converts a single image with p, as ProcessPage of a Converter created with p does.
+// Use a Converter to process many images, which reuses scalers and pixel slices.
+func ProcessPage(img image.Image, p Params) *image.Gray {
+	return New(p).ProcessPage(img)
+}
+
+// ProcessPage converts a single image exactly like pages of an archive: it's converted to
+// grayscale, fit and scaled to Width and Height, and its contrast, brightness and gamma are
+// adjusted, followed by boldening, margins and watermarks if enabled. Params which only make sense
+// for whole archives, such as Spreads, AutoRotate, Credits and MaxPageHeight, are ignored, and page
+// numbers are drawn as if img was the first page. img is left untouched.
+func (c *Converter) ProcessPage(img image.Image) *image.Gray {
+	var t PageTimings
+	if src, ok := img.(*image.Gray16); ok {
+		if s, ok := c.scaler.(imgutil.Scaler16); ok {
+			return c.finish16(src, s, 0, nil, &t)
+		}
+	}
+	src, view := imgutil.Luma(img)
+	if !view {
+		src = c.pool.GetFromImage(img)
+		defer c.pool.Put(src)
+	}
+	return c.finish(src, 0, nil, &t)
+}
+
+// convertZip converts the opened zip archive r, described by meta, and writes to out.
+func (c *Converter) convertZip(ctx context.Context, r *zip.Reader, meta Metadata, out io.Writer) error {
+	read := func(ctx context.Context, pages chan<- page) error {
+		return c.readZipReader(ctx, pages, r)
+	}
+	return convertPages(ctx, read, meta, c.readZipHints(r), []Output{{Converter: c, Writer: out}}, nil)
+}
+
+// Output is a single output of a conversion.
+//
+// If Pages is not nil, converted pages are passed to it instead of being written to Writer. Pages
+// is called in reading order, and calls are never concurrent. If it returns an error, the
+// conversion stops and returns that error.
+//
+// If Chapters is not nil, a separate archive is written for each chapter instead, to the writer
+// Chapters returns for the chapter's folder. Pages in the input root have an empty chapter. Closing
+// writers is left to the caller. Chapters are only known with Params.Chapters enabled.
+//
+// If Timings is not nil, it's called with the stage timings of each page once it's written or
+// passed to Pages, such as to find pages which are slow to convert. Calls are never concurrent.
+//
+// If Written is not nil, it's called with each page once it's written to an archive, such as to
+// read it back later. It's not called for pages passed to Pages. Calls are never concurrent.
+type Output struct {
+	Converter *Converter
+	Writer    io.Writer
+	Pages     func(Page) error
+	Chapters  func(chapter string) (io.Writer, error)
+	Timings   func(PageTimings)
+	Written   func(WrittenPage)
+}
+
+// Page is a converted page passed to Output.Pages. The image belongs to the callee, which may keep
+// it after returning.
+//
+// Index is the page number in reading order, starting from 0, and Sub orders multiple pages created
+// from a single input page, such as the halves of a split spread. Chapter, Name and Source describe
+// where the page was read from, as in PageStats. Credits reports whether the page was detected as a
+// credits page with CreditsTag.
+type Page struct {
+	Image   image.Image
+	Index   int
+	Sub     int
+	Chapter string
+	Name    string
+	Source  string
+	Credits bool
+}
+
+// ConvertMulti reads a file from in once and converts it with each output's Converter, writing the
+// result to its Writer. Each page is decoded only once and shared between all outputs.
+//
+// Input related Params, such as Order, Chapters, Symlinks and Errors, are taken from the first
+// output.
+func ConvertMulti(in string, outputs ...Output) error {
+	return convertMulti(context.Background(), in, outputs, nil)
+}
+
+// convertMulti implements ConvertMulti, stopping early when ctx is canceled. If plan isn't nil, it's
+// executed as described by ConvertPlan.
+func convertMulti(ctx context.Context, in string, outputs []Output, plan *Plan) error {
+	if len(outputs) == 0 {
+		return nil
+	}
+	path := LongPath(in)
+	read, err := outputs[0].Converter.selectReader(path)
 	if err != nil {
 		return fmt.Errorf("cannot read %s: %w", in, err)
 	}
+	meta := ParseFilename(in)
+	if plan != nil {
+		meta = plan.Metadata
+	}
+	hints := outputs[0].Converter.readHints(path)
+	return convertPages(ctx, func(ctx context.Context, pages chan<- page) error {
+		return read(ctx, pages, path)
+	}, meta, hints, outputs, plan)
+}
+
+// convertPages converts the pages emitted by read with each output's Converter. meta and hints
+// describe the input.
+func convertPages(ctx context.Context, read func(ctx context.Context, pages chan<- page) error,
+	meta Metadata, hints *comicHints, outputs []Output, plan *Plan) error {
+	var skipped pageErrors
+	if outputs[0].Converter.params.Errors == ErrorsSkip {
+		ctx = withPageErrors(ctx, &skipped)
+	}
 
-	errg, ctx := errgroup.WithContext(context.Background())
-	pages := make(chan page)
+	errg, ctx := errgroup.WithContext(ctx)
+	pages := make(chan page, outputs[0].Converter.pageBuffer())
 	errg.Go(func() error {
 		defer close(pages)
-		return read(ctx, pages, in)
+		return read(ctx, pages)
 	})
 
-	converted := make(chan page)
+	// Series metadata is looked up once for all outputs writing ComicInfo.xml, by the Converter of
+	// the first of them.
+	var lookupOnce sync.Once
+	var found Metadata
+	lookup := func(c *Converter) Metadata {
+		if !c.params.ComicInfo {
+			return meta
+		}
+		lookupOnce.Do(func() {
+			for _, o := range outputs {
+				if o.Converter.params.ComicInfo {
+					found = o.Converter.lookupMetadata(ctx, meta)
+					return
+				}
+			}
+		})
+		return found
+	}
+
+	branches := make([]chan page, len(outputs))
+	for i, o := range outputs {
+		o := o
+		branch := make(chan page, o.Converter.pageBuffer())
+		branches[i] = branch
+
+		converted := make(chan page, o.Converter.pageBuffer())
+		errg.Go(func() error {
+			defer close(converted)
+			return o.Converter.convert(ctx, converted, branch, plan, hints)
+		})
+
+		errg.Go(func() error {
+			switch {
+			case o.Pages != nil:
+				return sendPages(o.Pages, o.Timings, converted)
+			case o.Chapters != nil:
+				return o.Converter.writeChapters(o, lookup(o.Converter), converted)
+			}
+			return o.Converter.writeZip(o, lookup(o.Converter), converted)
+		})
+	}
+
 	errg.Go(func() error {
-		defer close(converted)
-		c.convert(ctx, converted, pages)
+		defer func() {
+			for _, b := range branches {
+				close(b)
+			}
+		}()
+		for pg := range pages {
+			if plan != nil && plan.skip(pg.Index) {
+				continue
+			}
+			for _, b := range branches {
+				select {
+				case b <- pg:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
 		return nil
 	})
 
-	errg.Go(func() error {
-		return c.writeZip(out, c.params.Deflate, converted)
-	})
+	if err := errg.Wait(); err != nil {
+		return err
+	}
+	return skipped.err()
+}
 
-	return errg.Wait()
+// lowMemoryPoolBytes is the maximum size of pixel slices retained for reuse with LowMemory, enough
+// for a large source page and a converted page.
+const lowMemoryPoolBytes = 32 << 20
+
+// workers returns the number of pages each pipeline stage works on at once.
+func (c *Converter) workers() int {
+	if c.params.LowMemory {
+		return 1
+	}
+	return runtime.NumCPU()
+}
+
+// pageBuffer returns the capacity of channels between pipeline stages.
+func (c *Converter) pageBuffer() int {
+	if c.params.PageBuffer < 0 {
+		return runtime.NumCPU()
+	}
+	return c.params.PageBuffer
+}
+
+// sendPages passes each converted page to fn until it returns an error, and its timings to timings
+// if it's not nil.
+func sendPages(fn func(Page) error, timings func(PageTimings), converted <-chan page) error {
+	for p := range converted {
+		reportTimings(timings, p, 0)
+		err := fn(Page{
+			Image:   p.Image,
+			Index:   p.Index,
+			Sub:     p.Sub,
+			Chapter: p.Chapter,
+			Name:    p.Name,
+			Source:  p.Source,
+			Credits: p.Credits,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // page represents a single manga page.
+//
+// Sub orders multiple output pages created from a single input page, such as the halves of a
+// split spread. Chapter is the slash separated path of the folder the page was read from, relative
+// to the input root. It's empty for pages in the root and when chapter detection is disabled.
+// Name is the original file name without extension and Source is the slash separated path of the
+// file relative to the input root. Both are empty for generated pages. Credits reports whether the
+// page was detected as a credits page. Bookmark is the page's bookmark carried over from the input's
+// ComicInfo.xml, if any. Timings holds the durations of the stages the page went through so far.
+// Progressive reports whether the page was decoded from a progressive JPEG or an interlaced PNG.
 type page struct {
-	Image image.Image
-	Index int
+	Image       image.Image
+	Index       int
+	Sub         int
+	Chapter     string
+	Name        string
+	Source      string
+	Credits     bool
+	Bookmark    string
+	Timings     PageTimings
+	Progressive bool
 }
 
 // convert reads a channel of pages, applies modifications as adjusted by params and emits converted
-// pages.
-func (c *Converter) convert(ctx context.Context, converted chan<- page, pages <-chan page) {
-	var wg sync.WaitGroup
-	wg.Add(runtime.NumCPU())
-	for i := 0; i < runtime.NumCPU(); i++ {
-		go func() {
-			defer wg.Done()
-			for pg := range pages {
-				src := c.pool.GetFromImage(pg.Image)
-				r := imgutil.FitRect(src.Bounds(), c.params.Width, c.params.Height)
-				dst := c.pool.Get(r.Dx(), r.Dy())
-				c.scaler.Scale(dst, src)
-				c.pool.Put(src)
-				imgutil.AutoContrast(dst, c.params.Cutoff)
-				imgutil.AdjustGamma(dst, c.params.Gamma)
-				select {
-				case converted <- page{dst, pg.Index}:
-				case <-ctx.Done():
-					return
+// pages in the same order. hints and plan, if not nil, may override params for each page.
+func (c *Converter) convert(ctx context.Context, converted chan<- page, pages <-chan page, plan *Plan,
+	hints *comicHints) error {
+	var contrast *imgutil.LUT
+	if plan != nil && plan.GlobalContrast && c.params.AutoContrast && !c.params.LowMemory {
+		lut := c.contrastLUT(plan.Histogram)
+		contrast = &lut
+	}
+	return ordered(ctx, c.workers(), converted, func() (job, bool) {
+		pg, ok := <-pages
+		return func() ([]page, error) {
+			if err := c.acquire(ctx); err != nil {
+				return nil, err
+			}
+			credits, err := c.isCredits(ctx, pg.Image)
+			if err != nil {
+				// Pages which can't be checked are still converted like regular pages.
+				err = fmt.Errorf("cannot recognize text of image number %d: %w", pg.Index, err)
+				if !skipPage(ctx, rawPage{Index: pg.Index, Source: pg.Source}, err) {
+					c.release()
+					return nil, err
 				}
 			}
-		}()
+			if credits && c.params.Credits == CreditsDrop {
+				c.release()
+				return nil, nil
+			}
+			out, timings := c.process(pg, c.pageOptions(plan, hints, pg, contrast))
+			c.release()
+			converted := make([]page, len(out))
+			for sub, dst := range out {
+				converted[sub] = page{
+					Image:    dst,
+					Index:    pg.Index,
+					Sub:      sub,
+					Chapter:  pg.Chapter,
+					Name:     pg.Name,
+					Source:   pg.Source,
+					Credits:  credits,
+					Bookmark: hints.bookmark(pg.Source),
+					Timings:  timings[sub],
+				}
+			}
+			return converted, nil
+		}, ok
+	})
+}
+
+// process applies modifications as adjusted by params to a single page, returning one or more
+// output pages and their timings. Returned images' pixel slices are taken from the pool. The page
+// image is left untouched, as it may be shared with other outputs. Pages taller than MaxPageHeight
+// once scaled are split into bands by processBands, and other pages kept in color are only scaled
+// by finishColor.
+func (c *Converter) process(pg page, opts pageOptions) ([]image.Image, []PageTimings) {
+	if n := c.bandCount(pg.Image.Bounds()); n > 1 {
+		return c.processBands(pg, n, opts)
+	}
+	if opts.color {
+		t := pg.Timings
+		return []image.Image{c.finishColor(pg.Image, pg.Index, &t)}, []PageTimings{t}
+	}
+	if src, ok := pg.Image.(*image.Gray16); ok && c.wholePage(src.Rect, opts.spreads) {
+		if s, ok := c.scaler.(imgutil.Scaler16); ok {
+			t := pg.Timings
+			return []image.Image{c.finish16(src, s, pg.Index, opts.contrast, &t)}, []PageTimings{t}
+		}
+	}
+	// Grayscale and YCbCr pages are used without copying them.
+	start := time.Now()
+	src, view := imgutil.Luma(pg.Image)
+	if !view {
+		src = c.pool.GetFromImage(pg.Image)
 	}
-	wg.Wait()
+	pg.Timings.Grayscale = time.Since(start)
+	var out []image.Image
+	var timings []PageTimings
+	for _, v := range c.spreadViews(src, opts.spreads, opts.ltr) {
+		v = c.autoRotate(v)
+		t := pg.Timings
+		out = append(out, c.finish(v.img, pg.Index, opts.contrast, &t))
+		timings = append(timings, t)
+		if v.owned {
+			c.pool.Put(v.img)
+		}
+	}
+	// Only copies are returned to the pool, never the page image itself.
+	if !view {
+		c.pool.Put(src)
+	}
+	return out, timings
+}
+
+// scaleAdjusted scales src into dst and applies histogram normalization, brightness, contrast and
+// gamma to it, recording the time spent in t like finish. If contrast isn't nil, it's applied
+// instead of normalizing the page's own histogram.
+func (c *Converter) scaleAdjusted(dst, src *image.Gray, contrast *imgutil.LUT, t *PageTimings) {
+	start := time.Now()
+	measure := contrast == nil && c.params.AutoContrast
+	// Adjustments don't depend on the page, so only the contrast part of the lookup table is
+	// computed.
+	lut := imgutil.IdentityLUT()
+	if contrast != nil {
+		lut = *contrast
+	}
+	adjusted := func(level float64) float64 { return c.adjustFunc(lut.At(level)) }
+
+	if frac, ok := c.scaler.(imgutil.FractionalScaler); ok && c.params.Intermediate16 {
+		dst16 := c.pool.Get16(dst.Rect.Dx(), dst.Rect.Dy())
+		defer c.pool.Put16(dst16)
+		frac.ScaleFractional(dst16, src)
+		t.Scale = time.Since(start)
+		if measure {
+			lut = c.contrastLUT(imgutil.Histogram16(dst16))
+		}
+		adjust := c.adjust16
+		if measure || contrast != nil {
+			adjust = imgutil.NewLUT16(lut.At).Then(adjust)
+		}
+		adjust.Apply(dst16)
+		var text []image.Rectangle
+		if c.params.ProtectText {
+			// Lettering is found on a rounded copy of the page, excepting all of it from dithering.
+			imgutil.DitherExcept(dst, dst16, []image.Rectangle{dst16.Rect})
+			text = imgutil.TextRegions(dst)
+		}
+		imgutil.DitherExcept(dst, dst16, text)
+		t.Contrast = time.Since(start) - t.Scale
+		return
+	}
+
+	if curves, ok := c.scaler.(imgutil.CurveScaler); ok && c.params.SmoothGamma {
+		// Pages are normalized on the histogram of the unscaled page, as bands are, so that they're
+		// only scaled once, with all adjustments applied before rounding.
+		if measure {
+			lut = c.contrastLUT(imgutil.Histogram(src))
+		}
+		t.Contrast = time.Since(start)
+		curves.ScaleCurve(dst, src, imgutil.NewCurve(adjusted))
+		t.Scale = time.Since(start) - t.Contrast
+		return
+	}
+
+	c.scaler.Scale(dst, src)
+	t.Scale = time.Since(start)
+	if measure {
+		lut = c.contrastLUT(imgutil.Histogram(dst))
+	}
+	lut = lut.Then(&c.adjust)
+	c.applyLUT(dst, &lut)
+	t.Contrast = time.Since(start) - t.Scale
+}
+
+// contrastLUT returns the histogram normalization lookup table of a page with histogram hist.
+func (c *Converter) contrastLUT(hist [256]uint) imgutil.LUT {
+	if c.params.PreserveTone {
+		lut, _ := imgutil.PreserveToneLUT(hist, c.params.Cutoff)
+		return lut
+	}
+	lut, _ := imgutil.AutoContrastLUT(hist, c.params.Cutoff)
+	return lut
+}
+
+// applyLUT applies lut to img, with the scaler if it implements imgutil.LUTScaler.
+func (c *Converter) applyLUT(img *image.Gray, lut *imgutil.LUT) {
+	if s, ok := c.scaler.(imgutil.LUTScaler); ok {
+		s.ApplyLUT(img, lut)
+		return
+	}
+	lut.Apply(img)
+}
+
+// finish scales a grayscale page and applies all further modifications to it, recording the time
+// spent scaling and adjusting contrast in t. The returned image's pixel slice is taken from the
+// pool. src is left untouched. If contrast isn't nil, it's applied instead of normalizing the page's
+// own histogram.
+func (c *Converter) finish(src *image.Gray, index int, contrast *imgutil.LUT, t *PageTimings) *image.Gray {
+	return c.finishRect(src, c.fitRect(src.Bounds()), index, contrast, t)
+}
+
+// finish16 is like finish for 16-bit grayscale pages, which are only rounded to 8 bits by s once
+// scaled.
+func (c *Converter) finish16(src *image.Gray16, s imgutil.Scaler16, index int, contrast *imgutil.LUT,
+	t *PageTimings) *image.Gray {
+	r := c.fitRect(src.Rect)
+	dst := c.pool.Get(r.Dx(), r.Dy())
+	start := time.Now()
+	s.Scale16(dst, src)
+	t.Scale = time.Since(start)
+	lut := imgutil.IdentityLUT()
+	switch {
+	case contrast != nil:
+		lut = *contrast
+	case c.params.AutoContrast:
+		lut = c.contrastLUT(imgutil.Histogram(dst))
+	}
+	lut = lut.Then(&c.adjust)
+	c.applyLUT(dst, &lut)
+	t.Contrast = time.Since(start) - t.Scale
+	return c.decorate(dst, r, index)
+}
+
+// finishRect is like finish, but scales src to r instead of fitting it to Width and Height.
+func (c *Converter) finishRect(src *image.Gray, r image.Rectangle, index int, contrast *imgutil.LUT,
+	t *PageTimings) *image.Gray {
+	// Scale and Pad overwrite every pixel, so buffers from the pool don't need to be zeroed.
+	dst := c.pool.Get(r.Dx(), r.Dy())
+	c.scaleAdjusted(dst, src, contrast, t)
+	return c.decorate(dst, r, index)
+}
+
+// decorate applies all modifications following scaling and adjustments to dst, a page scaled to
+// r, and returns the result. dst is returned to the pool if it's replaced.
+func (c *Converter) decorate(dst *image.Gray, r image.Rectangle, index int) *image.Gray {
+	c.boldenPage(dst)
+	if c.params.Margin > 0 || c.params.ExactSize {
+		padded := c.pool.Get(c.paddedSize(r))
+		imgutil.Pad(padded, dst, c.params.MarginColor)
+		c.pool.Put(dst)
+		dst = padded
+	}
+	c.overlay(dst, dst.Rect, index)
+	return dst
+}
+
+// boldenPage applies Bolden to dst, leaving text regions as is with ProtectText.
+func (c *Converter) boldenPage(dst *image.Gray) {
+	if c.params.Bolden <= 0 {
+		return
+	}
+	var text []image.Rectangle
+	if c.params.ProtectText {
+		text = imgutil.TextRegions(dst)
+	}
+	c.bolden(dst, c.params.Bolden, text)
+}
+
+// overlay draws the page number and watermark of the page of bounds page, margins included, onto
+// dst, which holds page or a band of it.
+func (c *Converter) overlay(dst *image.Gray, page image.Rectangle, index int) {
+	if c.params.PageNumbers != CornerNone {
+		drawPageNumber(dst, page, index+1, c.params.PageNumbers, c.params.PageNumberSize)
+	}
+	if c.watermark != nil {
+		r := c.watermarkRect(page)
+		imgutil.Composite(dst, c.watermark, c.watermarkMask, r.Min, c.params.WatermarkOpacity)
+	}
+}
+
+// watermarkRect returns where the watermark is drawn on a page of bounds page.
+func (c *Converter) watermarkRect(page image.Rectangle) image.Rectangle {
+	return cornerRect(page, c.watermark.Rect.Size(), c.params.WatermarkCorner, page.Dy()/60)
 }
@@ -1,10 +1,32 @@
 package mangaconv_test
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"image/png"
 	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+
 	"github.com/naisuuuu/mangaconv"
+	"github.com/naisuuuu/mangaconv/imgtest"
+	"github.com/naisuuuu/mangaconv/imgutil"
 )
 
 func BenchmarkConverter(b *testing.B) {
@@ -18,10 +40,11 @@ func BenchmarkConverter(b *testing.B) {
 	for _, bb := range benchmarks {
 		b.Run(bb.name, func(b *testing.B) {
 			c := mangaconv.New(mangaconv.Params{
-				Cutoff: 1,
-				Gamma:  0.75,
-				Height: bb.h,
-				Width:  bb.w,
+				AutoContrast: true,
+				Cutoff:       1,
+				Gamma:        0.75,
+				Height:       bb.h,
+				Width:        bb.w,
 			})
 			for i := 0; i < b.N; i++ {
 				c.ConvertToWriter(bb.file, io.Discard)
@@ -29,3 +52,529 @@ func BenchmarkConverter(b *testing.B) {
 		})
 	}
 }
+
+func TestConvertMulti(t *testing.T) {
+	sizes := []int{100, 50}
+	var bufs []*bytes.Buffer
+	var outputs []mangaconv.Output
+	for _, s := range sizes {
+		b := &bytes.Buffer{}
+		bufs = append(bufs, b)
+		outputs = append(outputs, mangaconv.Output{
+			Converter: mangaconv.New(mangaconv.Params{AutoContrast: true, Cutoff: 1, Gamma: 1, Height: s, Width: s}),
+			Writer:    b,
+		})
+	}
+
+	if err := mangaconv.ConvertMulti("testdata/wikipe-tan.zip", outputs...); err != nil {
+		t.Fatalf("ConvertMulti() error %v", err)
+	}
+
+	for i, b := range bufs {
+		r, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+		if err != nil {
+			t.Fatalf("output %d: cannot open zip: %v", i, err)
+		}
+		if len(r.File) != 2 {
+			t.Fatalf("output %d: got %d files, want 2", i, len(r.File))
+		}
+		f, err := r.File[0].Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, _, err := image.DecodeConfig(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Height != sizes[i] {
+			t.Errorf("output %d: got page height %d, want %d", i, cfg.Height, sizes[i])
+		}
+	}
+}
+
+func TestConvertExactSize(t *testing.T) {
+	c := mangaconv.New(mangaconv.Params{ExactSize: true, Gamma: 1, Height: 100, Width: 150, MarginColor: 0xff})
+	var b bytes.Buffer
+	if err := c.ConvertToWriter("testdata/wikipe-tan.zip", &b); err != nil {
+		t.Fatalf("ConvertToWriter() error %v", err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, zf := range r.File {
+		f, err := zf.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, _, err := image.DecodeConfig(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Width != 150 || cfg.Height != 100 {
+			t.Errorf("%s: got %dx%d, want 150x100", zf.Name, cfg.Width, cfg.Height)
+		}
+	}
+}
+
+func TestConvertEPUB(t *testing.T) {
+	c := mangaconv.New(mangaconv.Params{
+		ExactSize: true, Format: mangaconv.FormatKEPUB, Gamma: 1, Height: 100, Width: 150, ComicInfo: true,
+	})
+	var b bytes.Buffer
+	if err := c.ConvertToWriter("testdata/wikipe-tan.zip", &b); err != nil {
+		t.Fatalf("ConvertToWriter() error %v", err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first := r.File[0]; first.Name != "mimetype" || first.Method != zip.Store {
+		t.Errorf("first entry is %s with method %d, want stored mimetype", first.Name, first.Method)
+	}
+	var pkg struct {
+		Meta []struct {
+			Name    string `xml:"name,attr"`
+			Content string `xml:"content,attr"`
+		} `xml:"metadata>meta"`
+		Items []struct {
+			Href string `xml:"href,attr"`
+		} `xml:"manifest>item"`
+		Spine struct {
+			Direction string `xml:"page-progression-direction,attr"`
+		} `xml:"spine"`
+	}
+	entries := make(map[string]string)
+	for _, zf := range r.File {
+		f, err := zf.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[zf.Name] = string(data)
+	}
+	if err := xml.Unmarshal([]byte(entries["content.opf"]), &pkg); err != nil {
+		t.Fatalf("cannot parse content.opf: %v", err)
+	}
+	var pages int
+	for _, item := range pkg.Items {
+		data, ok := entries[item.Href]
+		if !ok {
+			t.Errorf("manifest item %s missing", item.Href)
+		}
+		if path.Ext(item.Href) == ".xhtml" && item.Href != "nav.xhtml" {
+			pages++
+			if !strings.Contains(data, `content="width=150, height=100"`) {
+				t.Errorf("%s: viewport not set to page size:\n%s", item.Href, data)
+			}
+		}
+	}
+	if pages != 2 {
+		t.Errorf("got %d page documents, want 2", pages)
+	}
+	wantMeta := false
+	for _, m := range pkg.Meta {
+		wantMeta = wantMeta || m.Name == "original-resolution" && m.Content == "150x100"
+	}
+	if !wantMeta {
+		t.Errorf("content.opf lacks original-resolution 150x100:\n%s", entries["content.opf"])
+	}
+	if pkg.Spine.Direction != "rtl" {
+		t.Errorf("page-progression-direction = %q, want rtl", pkg.Spine.Direction)
+	}
+}
+
+func TestConvertLowMemory(t *testing.T) {
+	c := mangaconv.New(mangaconv.Params{Gamma: 1, Height: 50, Width: 50, LowMemory: true})
+	var b bytes.Buffer
+	if err := c.ConvertToWriter("testdata/wikipe-tan.zip", &b); err != nil {
+		t.Fatalf("ConvertToWriter() error %v", err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pages []string
+	for _, zf := range r.File {
+		if zf.Method != zip.Store {
+			t.Errorf("%s: got method %d, want %d", zf.Name, zf.Method, zip.Store)
+		}
+		if path.Ext(zf.Name) == ".jpg" {
+			pages = append(pages, zf.Name)
+		}
+	}
+	if len(pages) != 2 {
+		t.Errorf("got pages %v, want 2", pages)
+	}
+}
+
+func TestConvertManifest(t *testing.T) {
+	c := mangaconv.New(mangaconv.Params{Gamma: 1, Height: 50, Width: 50, Manifest: true})
+	var b bytes.Buffer
+	if err := c.ConvertToWriter("testdata/wikipe-tan.zip", &b); err != nil {
+		t.Fatalf("ConvertToWriter() error %v", err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sums := make(map[string]string)
+	var m struct {
+		Pages []struct{ Name, SHA256, Source string }
+	}
+	for _, zf := range r.File {
+		f, err := zf.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if zf.Name == "manifest.json" {
+			err = json.NewDecoder(f).Decode(&m)
+		} else {
+			h := sha256.New()
+			_, err = io.Copy(h, f)
+			sums[zf.Name] = hex.EncodeToString(h.Sum(nil))
+		}
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(m.Pages) != 2 {
+		t.Fatalf("got %d manifest pages, want 2", len(m.Pages))
+	}
+	for i, p := range m.Pages {
+		if want := fmt.Sprintf("wikipe-tan-%d.png", i); p.Source != want {
+			t.Errorf("page %d: got source %s, want %s", i, p.Source, want)
+		}
+		if p.SHA256 != sums[p.Name] {
+			t.Errorf("page %d: got checksum %s, want %s", i, p.SHA256, sums[p.Name])
+		}
+	}
+}
+
+func TestConvertToFunc(t *testing.T) {
+	c := mangaconv.New(mangaconv.Params{AutoContrast: true, Cutoff: 1, Gamma: 1, Height: 100, Width: 100})
+	var sources []string
+	err := c.ConvertToFunc("testdata/wikipe-tan.zip", func(p mangaconv.Page) error {
+		if got := p.Image.Bounds().Dy(); got != 100 {
+			t.Errorf("page %d: got height %d, want 100", p.Index, got)
+		}
+		sources = append(sources, p.Source)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ConvertToFunc() error %v", err)
+	}
+	sort.Strings(sources)
+	if diff := cmp.Diff([]string{"wikipe-tan-0.png", "wikipe-tan-1.png"}, sources); diff != "" {
+		t.Errorf("ConvertToFunc() sources mismatch (-want +got):\n%s", diff)
+	}
+
+	errStop := errors.New("stop")
+	err = c.ConvertToFunc("testdata/wikipe-tan.zip", func(mangaconv.Page) error { return errStop })
+	if !errors.Is(err, errStop) {
+		t.Errorf("ConvertToFunc() error = %v, want %v", err, errStop)
+	}
+}
+
+func TestConvertZipReader(t *testing.T) {
+	c := mangaconv.New(mangaconv.Params{AutoContrast: true, ComicInfo: true, Cutoff: 1, Gamma: 1, Height: 100, Width: 100})
+	var want bytes.Buffer
+	if err := c.ConvertToWriter("testdata/wikipe-tan.zip", &want); err != nil {
+		t.Fatalf("ConvertToWriter() error %v", err)
+	}
+	in, err := os.ReadFile("testdata/wikipe-tan.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got bytes.Buffer
+	if err := c.ConvertZipReader("testdata/wikipe-tan.zip", bytes.NewReader(in), int64(len(in)), &got); err != nil {
+		t.Fatalf("ConvertZipReader() error %v", err)
+	}
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Error("ConvertZipReader() output differs from ConvertToWriter()")
+	}
+
+	if err := c.ConvertZipReader("bad.zip", bytes.NewReader(in[:10]), 10, io.Discard); err == nil {
+		t.Error("ConvertZipReader() of a truncated archive succeeded, want error")
+	}
+}
+
+func TestConvertBytes(t *testing.T) {
+	p := mangaconv.Params{AutoContrast: true, Cutoff: 1, Gamma: 1, Height: 100, Width: 100, ComicInfo: true}
+	in, err := os.ReadFile("testdata/wikipe-tan.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"Wikipe-tan v01.cbz", "Wikipe-tan v01.zip", "WIKIPE-TAN V01.ZIP"} {
+		var want bytes.Buffer
+		if err := mangaconv.New(p).ConvertZipReader(name, bytes.NewReader(in), int64(len(in)), &want); err != nil {
+			t.Fatalf("ConvertZipReader(%q) error %v", name, err)
+		}
+		got, err := mangaconv.ConvertBytes(context.Background(), in, name, p)
+		if err != nil {
+			t.Fatalf("ConvertBytes(%q) error %v", name, err)
+		}
+		if !bytes.Equal(got, want.Bytes()) {
+			t.Errorf("ConvertBytes(%q) output differs from ConvertZipReader()", name)
+		}
+		if ci := readEntry(t, got, "ComicInfo.xml"); !bytes.Contains(ci, []byte("<Volume>1</Volume>")) {
+			t.Errorf("ConvertBytes(%q) ComicInfo.xml lacks the volume parsed from its name:\n%s", name, ci)
+		}
+	}
+
+	_, err = mangaconv.ConvertBytes(context.Background(), in, "in.rar", p)
+	if !errors.Is(err, mangaconv.ErrUnsupportedFormat) {
+		t.Errorf("ConvertBytes(in.rar) error = %v, want %v", err, mangaconv.ErrUnsupportedFormat)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := mangaconv.ConvertBytes(ctx, in, "in.cbz", p); !errors.Is(err, context.Canceled) {
+		t.Errorf("ConvertBytes() with canceled context error = %v, want %v", err, context.Canceled)
+	}
+}
+
+// readEntry returns the contents of the entry called name of the archive in data.
+func readEntry(t *testing.T, data []byte, name string) []byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := zr.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestProcessPage(t *testing.T) {
+	p := mangaconv.Params{AutoContrast: true, Bolden: 0.5, Cutoff: 1, Gamma: 0.75, Height: 100, Margin: 4, Width: 100}
+	want := make(map[string]image.Image)
+	err := mangaconv.New(p).ConvertToFunc("testdata/wikipe-tan.zip", func(pg mangaconv.Page) error {
+		want[pg.Source] = pg.Image
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ConvertToFunc() error %v", err)
+	}
+
+	c := mangaconv.New(p)
+	for source, w := range want {
+		img := imgtest.MustRead("testdata/" + source)
+		got := c.ProcessPage(img)
+		if !cmp.Equal(w, got) {
+			t.Errorf("ProcessPage(%s) differs from the converted page", source)
+		}
+		if got2 := mangaconv.ProcessPage(img, p); !cmp.Equal(got, got2) {
+			t.Errorf("ProcessPage(%s) differs from Converter.ProcessPage", source)
+		}
+	}
+}
+
+func TestProcessPage16(t *testing.T) {
+	// 0x40ff is rounded to 65, but truncated to 64 if the page is reduced to 8 bits before scaling.
+	src := image.NewGray16(image.Rect(0, 0, 8, 8))
+	for i := 0; i < len(src.Pix); i += 2 {
+		src.Pix[i], src.Pix[i+1] = 0x40, 0xff
+	}
+	want := image.NewGray(image.Rect(0, 0, 2, 2))
+	imgutil.NewCacheScaler(imgutil.CatmullRom).Scale16(want, src)
+
+	p := mangaconv.Params{Gamma: 1, Height: 2, Width: 2}
+	if got := mangaconv.New(p).ProcessPage(src); !cmp.Equal(want, got) {
+		t.Errorf("ProcessPage() = %v, want %v", got.Pix, want.Pix)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+	in := filepath.Join(t.TempDir(), "in.zip")
+	f, err := os.Create(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	w, err := zw.Create("0.png")
+	if err == nil {
+		_, err = w.Write(buf.Bytes())
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mangaconv.New(p).ConvertToFunc(in, func(pg mangaconv.Page) error {
+		if !cmp.Equal(image.Image(want), pg.Image) {
+			t.Errorf("converted page = %v, want %v", pg.Image, want.Pix)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ConvertToFunc() error %v", err)
+	}
+}
+
+func TestSmoothGamma(t *testing.T) {
+	// A dark gradient, stretched to the full range by histogram normalization.
+	src := image.NewGray(image.Rect(0, 0, 16, 4))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i % 16)
+	}
+	p := mangaconv.Params{AutoContrast: true, Cutoff: 0, Gamma: 0.75, Height: 256, Width: 256}
+	levels := func(img *image.Gray) int {
+		seen := make(map[uint8]bool)
+		for _, v := range img.Pix {
+			seen[v] = true
+		}
+		return len(seen)
+	}
+
+	banded := levels(mangaconv.ProcessPage(src, p))
+	p.SmoothGamma = true
+	smooth := mangaconv.ProcessPage(src, p)
+	if got := levels(smooth); got < 4*banded {
+		t.Errorf("SmoothGamma page has %d gray levels, want at least %d", got, 4*banded)
+	}
+	if b := smooth.Bounds(); b.Dx() != 256 || b.Dy() != 64 {
+		t.Errorf("SmoothGamma page is %v, want 256x64", b.Size())
+	}
+}
+
+func TestIntermediate16(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 16, 4))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i % 16)
+	}
+	p := mangaconv.Params{AutoContrast: true, Cutoff: 0, Gamma: 0.75, Height: 256, SmoothGamma: true, Width: 256}
+	smooth := mangaconv.ProcessPage(src, p)
+	p.Intermediate16 = true
+	got := mangaconv.ProcessPage(src, p)
+	if !got.Bounds().Eq(smooth.Bounds()) {
+		t.Fatalf("Intermediate16 page is %v, want %v", got.Bounds(), smooth.Bounds())
+	}
+	// Dithering only moves pixels to a neighboring level of their unrounded value.
+	var sum, smoothSum int
+	for i := range got.Pix {
+		if d := int(got.Pix[i]) - int(smooth.Pix[i]); d < -2 || d > 2 {
+			t.Fatalf("pixel %d = %d, want %d ± 2", i, got.Pix[i], smooth.Pix[i])
+		}
+		sum += int(got.Pix[i])
+		smoothSum += int(smooth.Pix[i])
+	}
+	if d := float64(sum-smoothSum) / float64(len(got.Pix)); d < -0.5 || d > 0.5 {
+		t.Errorf("mean level differs by %.2f from SmoothGamma, want at most 0.5", d)
+	}
+}
+
+// countingScaler counts the images it scales and the lookup tables it applies.
+type countingScaler struct {
+	mu   sync.Mutex
+	n    int
+	luts int
+}
+
+func (z *countingScaler) ApplyLUT(img *image.Gray, lut *imgutil.LUT) {
+	z.mu.Lock()
+	z.luts++
+	z.mu.Unlock()
+	lut.Apply(img)
+}
+
+func (z *countingScaler) Scale(dst, src *image.Gray) {
+	z.mu.Lock()
+	z.n++
+	z.mu.Unlock()
+	imgutil.BiLinear.Scale(dst, src)
+}
+
+func TestConvertScaler(t *testing.T) {
+	z := &countingScaler{}
+	c := mangaconv.New(mangaconv.Params{Gamma: 0.75, Height: 50, Scaler: z, Width: 50})
+	if err := c.ConvertToWriter("testdata/wikipe-tan.zip", io.Discard); err != nil {
+		t.Fatalf("ConvertToWriter() error %v", err)
+	}
+	if z.n != 2 || z.luts != 2 {
+		t.Errorf("Scaler scaled %d pages and applied %d lookup tables, want 2 each", z.n, z.luts)
+	}
+	if got := c.Stats().Scaler; got != (imgutil.CacheStats{}) {
+		t.Errorf("Stats().Scaler = %+v, want none for a custom scaler", got)
+	}
+}
+
+func TestConvertTimings(t *testing.T) {
+	c := mangaconv.New(mangaconv.Params{AutoContrast: true, Cutoff: 1, Gamma: 1, Height: 100, Width: 100})
+	var got []mangaconv.PageTimings
+	err := mangaconv.ConvertMulti("testdata/wikipe-tan.zip", mangaconv.Output{
+		Converter: c,
+		Writer:    io.Discard,
+		Timings:   func(pt mangaconv.PageTimings) { got = append(got, pt) },
+	})
+	if err != nil {
+		t.Fatalf("ConvertMulti() error %v", err)
+	}
+	var sources []string
+	for _, pt := range got {
+		if pt.Decode <= 0 || pt.Scale <= 0 || pt.Encode <= 0 {
+			t.Errorf("page %d: got %+v, want decode, scale and encode timings", pt.Index, pt)
+		}
+		sources = append(sources, pt.Source)
+	}
+	sort.Strings(sources)
+	if diff := cmp.Diff([]string{"wikipe-tan-0.png", "wikipe-tan-1.png"}, sources); diff != "" {
+		t.Errorf("timings sources mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestConverterStats(t *testing.T) {
+	c := mangaconv.New(mangaconv.Params{AutoContrast: true, Cutoff: 1, Gamma: 1, Height: 100, Width: 100, ExactSize: true})
+	for i := 0; i < 2; i++ {
+		if err := c.ConvertToWriter("testdata/wikipe-tan.zip", io.Discard); err != nil {
+			t.Fatalf("ConvertToWriter() error %v", err)
+		}
+	}
+	s := c.Stats()
+	// Both pages have the same size, so all but the first conversion reuse scalers and pixels.
+	if s.Scaler.Entries != 1 || s.Scaler.Misses != 1 || s.Scaler.Hits != 3 {
+		t.Errorf("Stats().Scaler = %+v, want 1 entry, 1 miss and 3 hits", s.Scaler)
+	}
+	if s.Pool.Hits == 0 || s.Pool.Puts == 0 || s.Pool.Retained == 0 {
+		t.Errorf("Stats().Pool = %+v, want pixels reused", s.Pool)
+	}
+}
+
+func TestPageBuffer(t *testing.T) {
+	for _, buf := range []int{-1, 0, 1, 16} {
+		c := mangaconv.New(mangaconv.Params{AutoContrast: true, Cutoff: 1, Gamma: 1, Height: 100, Width: 100,
+			PageBuffer: buf})
+		n := 0
+		err := c.ConvertToFunc("testdata/wikipe-tan.zip", func(mangaconv.Page) error {
+			n++
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("PageBuffer %d: ConvertToFunc() error %v", buf, err)
+		}
+		if n != 2 {
+			t.Errorf("PageBuffer %d: got %d pages, want 2", buf, n)
+		}
+	}
+}
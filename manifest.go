@@ -0,0 +1,39 @@
+package mangaconv
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// manifestName is the archive entry name of the manifest.
+const manifestName = "manifest.json"
+
+// manifest lists the pages of an output archive with their checksums, so that sync tools can verify
+// an archive's integrity without decoding its pages.
+type manifest struct {
+	Pages []manifestPage `json:"pages"`
+}
+
+// manifestPage describes a single page in the manifest. Source is the input file the page was
+// converted from, empty for generated pages.
+type manifestPage struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+	Source string `json:"source,omitempty"`
+}
+
+// newManifest creates a manifest from information about each written page.
+func newManifest(pages []pageInfo) *manifest {
+	mp := make([]manifestPage, len(pages))
+	for i, p := range pages {
+		mp[i] = manifestPage{Name: p.Name, SHA256: p.SHA256, Source: p.Source}
+	}
+	return &manifest{Pages: mp}
+}
+
+// encode writes manifest contents to w.
+func (m *manifest) encode(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
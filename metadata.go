@@ -0,0 +1,71 @@
+package mangaconv
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Metadata describes a single manga volume or chapter.
+//
+// Series is the name of the series. Volume and Chapter are kept exactly as written in the source,
+// including any leading zeros, and are empty when unknown.
+// Summary, Year, Writer, Genres and Web describe the series. They are never parsed from names, but
+// can be filled in by a MetadataProvider.
+type Metadata struct {
+	Series  string
+	Volume  string
+	Chapter string
+	Summary string
+	Year    int
+	Writer  string
+	Genres  []string
+	Web     string
+}
+
+var (
+	// bracketRe matches scanlation group tags and similar, e.g. "[Group]" or "(2019)".
+	bracketRe = regexp.MustCompile(`\[[^\]]*\]|\([^)]*\)|\{[^}]*\}`)
+	volumeRe  = regexp.MustCompile(`(?i)(?:^|[\s_\-.])(?:v|vol|volume)\.?[\s_]*(\d+(?:\.\d+)?)`)
+	chapterRe = regexp.MustCompile(`(?i)(?:^|[\s_\-.])(?:c|ch|chap|chapter)\.?[\s_]*(\d+(?:\.\d+)?)`)
+)
+
+// ParseFilename extracts series, volume and chapter information from a file or directory name,
+// such as "Series Name v03 c21.cbz".
+//
+// Everything before the first volume or chapter marker is treated as the series name. If no
+// markers are found, the whole name (without extension and bracketed tags) is used.
+func ParseFilename(name string) Metadata {
+	name = filepath.Base(name)
+	if ext := filepath.Ext(name); isArchive(ext) || strings.EqualFold(ext, ".pdf") {
+		name = strings.TrimSuffix(name, ext)
+	}
+	name = bracketRe.ReplaceAllString(name, " ")
+
+	var m Metadata
+	end := len(name)
+	if loc := volumeRe.FindStringSubmatchIndex(name); loc != nil {
+		m.Volume = name[loc[2]:loc[3]]
+		end = loc[0]
+	}
+	if loc := chapterRe.FindStringSubmatchIndex(name); loc != nil {
+		m.Chapter = name[loc[2]:loc[3]]
+		if loc[0] < end {
+			end = loc[0]
+		}
+	}
+
+	series := strings.ReplaceAll(name[:end], "_", " ")
+	m.Series = strings.Join(strings.Fields(strings.Trim(series, " -.")), " ")
+	return m
+}
+
+// isArchive reports whether ext is a file extension of a supported archive format.
+func isArchive(ext string) bool {
+	switch strings.ToLower(ext) {
+	case ".zip", ".cbz":
+		return true
+	default:
+		return false
+	}
+}
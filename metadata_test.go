@@ -0,0 +1,49 @@
+package mangaconv_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/naisuuuu/mangaconv"
+)
+
+func TestParseFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		want mangaconv.Metadata
+	}{
+		{
+			name: "Series Name v03 c21.cbz",
+			want: mangaconv.Metadata{Series: "Series Name", Volume: "03", Chapter: "21"},
+		},
+		{
+			name: "path/to/Series Name - Vol. 12.zip",
+			want: mangaconv.Metadata{Series: "Series Name", Volume: "12"},
+		},
+		{
+			name: "[Group] Series_Name_ch010.5 (2019).cbz",
+			want: mangaconv.Metadata{Series: "Series Name", Chapter: "010.5"},
+		},
+		{
+			name: "Series Name Chapter 7",
+			want: mangaconv.Metadata{Series: "Series Name", Chapter: "7"},
+		},
+		{
+			name: "Series Name",
+			want: mangaconv.Metadata{Series: "Series Name"},
+		},
+		{
+			name: "some/dir/",
+			want: mangaconv.Metadata{Series: "dir"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mangaconv.ParseFilename(tt.name)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("ParseFilename() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package mangaconv
+
+import "strings"
+
+// SafeName makes a single file name component safe to store on FAT32 and exFAT filesystems, as
+// used by most e-reader SD cards.
+//
+// Characters FAT32 does not allow (control characters and `"*/:<>?\|`) are replaced with repl.
+// Trailing dots and spaces are trimmed and reserved device names such as "CON" or "LPT1" get an
+// underscore appended, as Windows refuses to create them. An empty result is replaced by "_".
+func SafeName(name, repl string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`"*/:<>?\|`, r) {
+			b.WriteString(repl)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	safe := strings.TrimRight(b.String(), ". ")
+
+	base := safe
+	if i := strings.IndexByte(base, '.'); i >= 0 {
+		base = base[:i]
+	}
+	if isReservedName(base) {
+		safe = base + "_" + safe[len(base):]
+	}
+	if safe == "" {
+		safe = "_"
+	}
+	return safe
+}
+
+// isReservedName reports whether name is a reserved DOS device name.
+func isReservedName(name string) bool {
+	switch strings.ToUpper(name) {
+	case "CON", "PRN", "AUX", "NUL":
+		return true
+	}
+	if len(name) == 4 && name[3] >= '1' && name[3] <= '9' {
+		switch strings.ToUpper(name[:3]) {
+		case "COM", "LPT":
+			return true
+		}
+	}
+	return false
+}
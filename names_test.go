@@ -0,0 +1,30 @@
+package mangaconv_test
+
+import (
+	"testing"
+
+	"github.com/naisuuuu/mangaconv"
+)
+
+func TestSafeName(t *testing.T) {
+	tests := []struct {
+		name string
+		repl string
+		want string
+	}{
+		{"Re:Zero? v01", "_", "Re_Zero_ v01"},
+		{`a/b\c*d"e<f>g|h`, "", "abcdefgh"},
+		{"Trailing dots...", "_", "Trailing dots"},
+		{"con", "_", "con_"},
+		{"LPT1.mc", "_", "LPT1_.mc"},
+		{"Console", "_", "Console"},
+		{"??", "", "_"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mangaconv.SafeName(tt.name, tt.repl); got != tt.want {
+				t.Errorf("SafeName(%q, %q) = %q, want %q", tt.name, tt.repl, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package mangaconv
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// PageOrder controls how archive entries are mapped to page order.
+type PageOrder int
+
+const (
+	// OrderArchive keeps pages in the order they are stored in the archive.
+	OrderArchive PageOrder = iota
+	// OrderNatural sorts pages by their full path, comparing runs of digits numerically, so that
+	// "page2.jpg" comes before "page10.jpg".
+	OrderNatural
+	// OrderFolder sorts pages by folder first and file name second, both in natural order. Files
+	// in the root of the archive come before any folders.
+	OrderFolder
+)
+
+// less returns a function comparing slash separated names according to o, or nil if names
+// should keep their original order.
+func (o PageOrder) less() func(a, b string) bool {
+	switch o {
+	case OrderNatural:
+		return naturalLess
+	case OrderFolder:
+		return folderLess
+	default:
+		return nil
+	}
+}
+
+// folderLess compares slash separated paths folder by folder, then by file name.
+func folderLess(a, b string) bool {
+	da, fa := path.Split(a)
+	db, fb := path.Split(b)
+	if da != db {
+		return chapterLess(strings.TrimSuffix(da, "/"), strings.TrimSuffix(db, "/"))
+	}
+	return naturalLess(fa, fb)
+}
+
+// chapterLess compares slash separated folder paths component by component in natural order. The
+// root folder, represented by an empty string, comes first.
+func chapterLess(a, b string) bool {
+	if a == b {
+		return false
+	}
+	var pa, pb []string
+	if a != "" {
+		pa = strings.Split(a, "/")
+	}
+	if b != "" {
+		pb = strings.Split(b, "/")
+	}
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		if pa[i] != pb[i] {
+			return naturalLess(pa[i], pb[i])
+		}
+	}
+	return len(pa) < len(pb)
+}
+
+// zipChapter returns the chapter folder of a zip entry name.
+func zipChapter(name string) string {
+	dir := path.Dir(name)
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
+// dirChapter returns the chapter folder of a file at path within the root directory.
+func dirChapter(root, file string) string {
+	dir, err := filepath.Rel(root, filepath.Dir(file))
+	if err != nil || dir == "." {
+		return ""
+	}
+	return filepath.ToSlash(dir)
+}
+
+// naturalLess compares strings case-insensitively, treating runs of digits as numbers.
+func naturalLess(a, b string) bool {
+	x, y := a, b
+	for x != "" && y != "" {
+		cx, cy := chunk(x), chunk(y)
+		x, y = x[len(cx):], y[len(cy):]
+		if isDigit(cx[0]) && isDigit(cy[0]) {
+			nx, ny := strings.TrimLeft(cx, "0"), strings.TrimLeft(cy, "0")
+			if len(nx) != len(ny) {
+				return len(nx) < len(ny)
+			}
+			if nx != ny {
+				return nx < ny
+			}
+			continue
+		}
+		if lx, ly := strings.ToLower(cx), strings.ToLower(cy); lx != ly {
+			return lx < ly
+		}
+	}
+	if len(x) != len(y) {
+		return len(x) < len(y)
+	}
+	return a < b
+}
+
+// chunk returns the leading run of either digits or non-digits of a non-empty string.
+func chunk(s string) string {
+	digit := isDigit(s[0])
+	i := 1
+	for i < len(s) && isDigit(s[i]) == digit {
+		i++
+	}
+	return s[:i]
+}
+
+func isDigit(b byte) bool {
+	return '0' <= b && b <= '9'
+}
@@ -0,0 +1,45 @@
+package mangaconv
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPageOrder(t *testing.T) {
+	names := []string{
+		"c10/p1.jpg",
+		"cover.jpg",
+		"c2/p10.jpg",
+		"c2/P2.jpg",
+		"c2.jpg",
+		"c2/p1.jpg",
+	}
+	tests := []struct {
+		order PageOrder
+		want  []string
+	}{
+		{
+			order: OrderArchive,
+			want:  names,
+		},
+		{
+			order: OrderNatural,
+			want:  []string{"c2.jpg", "c2/p1.jpg", "c2/P2.jpg", "c2/p10.jpg", "c10/p1.jpg", "cover.jpg"},
+		},
+		{
+			order: OrderFolder,
+			want:  []string{"c2.jpg", "cover.jpg", "c2/p1.jpg", "c2/P2.jpg", "c2/p10.jpg", "c10/p1.jpg"},
+		},
+	}
+	for _, tt := range tests {
+		got := append([]string(nil), names...)
+		if less := tt.order.less(); less != nil {
+			sort.SliceStable(got, func(i, j int) bool { return less(got[i], got[j]) })
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("order %d mismatch (-want +got):\n%s", tt.order, diff)
+		}
+	}
+}
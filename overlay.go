@@ -0,0 +1,74 @@
+package mangaconv
+
+import (
+	"image"
+	"image/draw"
+	"strconv"
+
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+// Corner is a corner of a page, used to position overlays.
+type Corner int
+
+// Page corners. CornerNone disables an overlay.
+const (
+	CornerNone Corner = iota
+	CornerTopLeft
+	CornerTopRight
+	CornerBottomLeft
+	CornerBottomRight
+)
+
+// cornerRect returns a rectangle of size placed in corner of bounds, margin pixels away from its
+// edges.
+func cornerRect(bounds image.Rectangle, size image.Point, corner Corner, margin int) image.Rectangle {
+	min := image.Pt(bounds.Min.X+margin, bounds.Min.Y+margin)
+	switch corner {
+	case CornerTopRight, CornerBottomRight:
+		min.X = bounds.Max.X - margin - size.X
+	}
+	switch corner {
+	case CornerBottomLeft, CornerBottomRight:
+		min.Y = bounds.Max.Y - margin - size.Y
+	}
+	return image.Rectangle{min, min.Add(size)}
+}
+
+// drawPageNumber draws page number num of size pixels high into corner of page, clipped to img,
+// which holds page or part of it. If size is 0, a size relative to the page height is used.
+func drawPageNumber(img draw.Image, page image.Rectangle, num int, corner Corner, size int) {
+	if size <= 0 {
+		size = page.Dy() / 60
+		if size < 13 {
+			size = 13
+		}
+	}
+	t := renderText(strconv.Itoa(num), size)
+	r := cornerRect(page, t.Rect.Size(), corner, size/2)
+	draw.Draw(img, r, t, image.Point{}, draw.Src)
+}
+
+// newWatermark converts a watermark image to grayscale. If the image is not opaque, it also returns
+// its alpha channel as a mask.
+func newWatermark(img image.Image) (*image.Gray, *image.Alpha) {
+	gray := imgutil.Grayscale(img)
+	if o, ok := img.(interface{ Opaque() bool }); ok && o.Opaque() {
+		return gray, nil
+	}
+
+	b := img.Bounds()
+	mask := image.NewAlpha(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(mask, mask.Rect, img, b.Min, draw.Src)
+	// Grayscale premultiplies alpha, which would darken semi-transparent pixels once blended.
+	for i, a := range mask.Pix {
+		if a > 0 && a < 0xff {
+			v := uint32(gray.Pix[i]) * 0xff / uint32(a)
+			if v > 0xff {
+				v = 0xff
+			}
+			gray.Pix[i] = uint8(v)
+		}
+	}
+	return gray, mask
+}
@@ -0,0 +1,25 @@
+package mangaconv
+
+import (
+	"image"
+	"testing"
+)
+
+func TestCornerRect(t *testing.T) {
+	bounds := image.Rect(0, 0, 100, 200)
+	size := image.Pt(10, 20)
+	tests := []struct {
+		corner Corner
+		want   image.Rectangle
+	}{
+		{CornerTopLeft, image.Rect(5, 5, 15, 25)},
+		{CornerTopRight, image.Rect(85, 5, 95, 25)},
+		{CornerBottomLeft, image.Rect(5, 175, 15, 195)},
+		{CornerBottomRight, image.Rect(85, 175, 95, 195)},
+	}
+	for _, tt := range tests {
+		if got := cornerRect(bounds, size, tt.corner, 5); got != tt.want {
+			t.Errorf("cornerRect(%v) = %v, want %v", tt.corner, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package mangaconv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/naisuuuu/mangaconv/internal/pdf"
+)
+
+// defaultPDFDPI is the resolution PDF pages are rasterized at when Params.PDFDPI isn't set.
+const defaultPDFDPI = 300
+
+// PDFRasterizer renders pages of PDF files, such as a wrapper around pdftoppm or MuPDF. Rasterize
+// returns page number page, starting from 1, of the PDF file at path rendered at dpi dots per inch,
+// encoded in any format mangaconv decodes, such as PNG. It may be called concurrently.
+type PDFRasterizer interface {
+	Rasterize(ctx context.Context, path string, page, dpi int) (io.ReadCloser, error)
+}
+
+// readPDF reads a PDF file and emits a page for each of its pages: either its largest embedded
+// image, or the whole page rendered by PDFRasterizer if it's set.
+func (c *Converter) readPDF(ctx context.Context, pages chan<- page, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot open %s: %w", path, err)
+	}
+	defer f.Close()
+	raws, err := c.listPDF(ctx, f, path)
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %w", path, err)
+	}
+
+	errg, ctx := errgroup.WithContext(ctx)
+	raw := make(chan rawPage)
+	errg.Go(func() error {
+		defer close(raw)
+		for _, r := range raws {
+			select {
+			case raw <- r:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+	joined := c.joinStage(ctx, errg.Go, raw)
+
+	errg.Go(func() error {
+		return c.decode(ctx, pages, joined)
+	})
+
+	return errg.Wait()
+}
+
+// listPDF returns a raw page for each page of the PDF file f at path, in page order, which reads
+// from f once decoded. Pages without images are skipped unless they're rasterized.
+func (c *Converter) listPDF(ctx context.Context, f *os.File, path string) ([]rawPage, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	doc, err := pdf.Open(f, info.Size())
+	if err != nil {
+		return nil, pdfError(err)
+	}
+	pdfPages, err := doc.Pages()
+	if err != nil {
+		return nil, pdfError(err)
+	}
+	// Embedded images which aren't JPEG files are decoded from raw samples.
+	pdf.RegisterFormat()
+
+	var raws []rawPage
+	var images []inputFile
+	for n, p := range pdfPages {
+		n := n + 1
+		raw := rawPage{Index: len(raws), Name: fmt.Sprintf("%04d", n), Source: fmt.Sprintf("page %d", n)}
+		if r := c.params.PDFRasterizer; r != nil {
+			dpi := c.params.PDFDPI
+			if dpi <= 0 {
+				dpi = defaultPDFDPI
+			}
+			raw.Open = func() (io.ReadCloser, error) { return r.Rasterize(ctx, path, n, dpi) }
+			raws = append(raws, raw)
+			continue
+		}
+		img := doc.LargestImage(p)
+		if img == nil {
+			continue
+		}
+		raw.Open = func() (io.ReadCloser, error) {
+			rc, err := img.Open(f, maxImagePixels)
+			return rc, pdfError(err)
+		}
+		raws = append(raws, raw)
+		images = append(images, inputFile{raw.Source, raw.Open})
+	}
+	if c.params.Strict && c.params.PDFRasterizer == nil {
+		if err := c.checkInput(images, len(pdfPages)-len(images)); err != nil {
+			return nil, err
+		}
+	}
+	return raws, nil
+}
+
+// pdfError wraps errors of package pdf with the matching errors of this package, so that they're
+// handled like those of any other input.
+func pdfError(err error) error {
+	switch {
+	case errors.Is(err, pdf.ErrUnsupported):
+		return fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	case errors.Is(err, pdf.ErrCorrupt):
+		return fmt.Errorf("%w: %v", ErrCorruptEntry, err)
+	case errors.Is(err, pdf.ErrTooLarge):
+		return fmt.Errorf("%w: %v", ErrImageTooLarge, err)
+	}
+	return err
+}
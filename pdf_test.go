@@ -0,0 +1,90 @@
+package mangaconv
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadPDF(t *testing.T) {
+	// The file has four pages: a JPEG image, a compressed grayscale image stored with a PNG
+	// predictor, a page of text only and an inverted 1-bit image drawn by a form XObject. Their
+	// pixels are checked by package internal/pdf.
+	type result struct {
+		Name, Source string
+		Size         image.Point
+	}
+	want := []result{
+		{"0001", "page 1", image.Pt(16, 12)},
+		{"0002", "page 2", image.Pt(16, 12)},
+		{"0004", "page 4", image.Pt(10, 2)},
+	}
+	pages, err := readHelper(Params{}, "testdata/pages.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []result
+	for _, p := range pages {
+		got = append(got, result{p.Name, p.Source, p.Image.Bounds().Size()})
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("readPDF() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// pageRasterizer renders page n as an n by n white image.
+type pageRasterizer struct{}
+
+func (pageRasterizer) Rasterize(ctx context.Context, path string, page, dpi int) (io.ReadCloser, error) {
+	if dpi != defaultPDFDPI {
+		return nil, fmt.Errorf("unexpected dpi %d", dpi)
+	}
+	img := image.NewGray(image.Rect(0, 0, page, page))
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		return nil, err
+	}
+	return io.NopCloser(&b), nil
+}
+
+func TestReadPDFRasterizer(t *testing.T) {
+	pages, err := readHelper(Params{PDFRasterizer: pageRasterizer{}}, "testdata/pages.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []image.Point
+	for _, p := range pages {
+		got = append(got, p.Image.Bounds().Size())
+	}
+	want := []image.Point{{1, 1}, {2, 2}, {3, 3}, {4, 4}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("readPDF() sizes mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestReadPDFErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		params Params
+		err    error
+	}{
+		{"encrypted", "testdata/encrypted.pdf", Params{}, ErrUnsupportedFormat},
+		{"unsupported filter", "testdata/jpx.pdf", Params{}, ErrUnsupportedFormat},
+		{"strict without images", "testdata/text.pdf", Params{Strict: true}, ErrSuspiciousInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := readHelper(tt.params, tt.path); !errors.Is(err, tt.err) {
+				t.Errorf("readPDF() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package mangaconv
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path/filepath"
+)
+
+// normalizeJPEGQuality is the quality progressive JPEGs are re-encoded with by Normalize, high
+// enough that the loss is invisible after conversion.
+const normalizeJPEGQuality = 95
+
+// progressiveHeaderSize is the number of bytes Normalize reads from each image to tell whether it's
+// progressive. JPEG frame headers follow metadata segments of up to 64 KiB each, such as Exif and
+// ICC profiles.
+const progressiveHeaderSize = 256 << 10
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// isProgressive reports whether data is a progressive JPEG or an interlaced PNG, which decode
+// slower than baseline and non-interlaced images and occasionally trip decoders. Only the image's
+// header is inspected.
+func isProgressive(data []byte) bool {
+	switch {
+	case bytes.HasPrefix(data, pngSignature):
+		// The IHDR chunk always comes first, with the interlace method as its last byte.
+		const interlace = 8 + 8 + 12
+		return len(data) > interlace && bytes.Equal(data[12:16], []byte("IHDR")) && data[interlace] != 0
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		return jpegProgressive(data[2:])
+	}
+	return false
+}
+
+// jpegProgressive walks the JPEG segments following the start of image marker in data, up to the
+// start of frame marker, which tells whether the image is progressive.
+func jpegProgressive(data []byte) bool {
+	for len(data) >= 4 {
+		if data[0] != 0xff {
+			return false
+		}
+		marker := data[1]
+		switch {
+		case marker == 0xff:
+			// Fill byte.
+			data = data[1:]
+			continue
+		case marker == 0x01 || marker >= 0xd0 && marker <= 0xd8:
+			// Markers without a payload.
+			data = data[2:]
+			continue
+		case marker == 0xda:
+			// Start of scan, without a frame header before it.
+			return false
+		case marker >= 0xc0 && marker <= 0xcf && marker != 0xc4 && marker != 0xc8 && marker != 0xcc:
+			// Start of frame, with odd markers among them being progressive.
+			return marker == 0xc2 || marker == 0xc6 || marker == 0xca || marker == 0xce
+		}
+		n := int(binary.BigEndian.Uint16(data[2:4]))
+		if n < 2 || 2+n > len(data) {
+			return false
+		}
+		data = data[2+n:]
+	}
+	return false
+}
+
+// Normalize copies the zip archive at in to out, re-encoding progressive JPEGs as baseline JPEGs
+// and interlaced PNGs as non-interlaced PNGs, and returns the names of re-encoded entries. All other
+// entries are copied as is, without decompressing them, and only the headers of other images are
+// read. PNGs are re-encoded losslessly, while JPEGs are re-encoded with a high
+// quality, so that the loss is invisible once converted.
+//
+// Normalized archives decode faster on every later conversion and avoid decoders which don't
+// handle progressive images well, such as those of some e-readers.
+func Normalize(in string, out io.Writer) ([]string, error) {
+	path := LongPath(in)
+	if !isArchive(filepath.Ext(path)) {
+		return nil, fmt.Errorf("cannot normalize %s: %w", in, ErrUnsupportedFormat)
+	}
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open %s: %w", in, err)
+	}
+	defer r.Close()
+
+	w := zip.NewWriter(out)
+	var normalized []string
+	for _, f := range r.File {
+		progressive := false
+		if isImage(f.Name) {
+			header, err := readHeader(f, progressiveHeaderSize)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read %s: %w", f.Name, err)
+			}
+			progressive = isProgressive(header)
+		}
+		if !progressive {
+			if err := w.Copy(f); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		data, err := readEntry(f)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %s: %w", f.Name, err)
+		}
+		img, format, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode %s: %w", f.Name, err)
+		}
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: f.Name, Method: f.Method, Modified: f.Modified})
+		if err != nil {
+			return nil, err
+		}
+		if format == "png" {
+			err = png.Encode(fw, img)
+		} else {
+			err = jpeg.Encode(fw, img, &jpeg.Options{Quality: normalizeJPEGQuality})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot encode %s: %w", f.Name, err)
+		}
+		normalized = append(normalized, f.Name)
+	}
+	return normalized, w.Close()
+}
+
+// readHeader reads up to the first n bytes of the zip entry f.
+func readHeader(f *zip.File, n int64) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, n))
+}
+
+// readEntry reads the whole zip entry f.
+func readEntry(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
@@ -0,0 +1,141 @@
+package mangaconv
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// interlacedPNG returns a 1x1 PNG image of shade v flagged as interlaced. A single pixel only
+// appears in the first Adam7 pass, so its data is the same as when not interlaced.
+func interlacedPNG(t *testing.T, v uint8) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, 1, 1))
+	img.SetGray(0, 0, color.Gray{v})
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	data := b.Bytes()
+	data[28] = 1
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+func TestIsProgressive(t *testing.T) {
+	var baselinePNG, baselineJPEG bytes.Buffer
+	img := image.NewGray(image.Rect(0, 0, 8, 8))
+	if err := png.Encode(&baselinePNG, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(&baselineJPEG, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	// SOI, a JFIF APP0 segment and the start of a progressive frame header.
+	progressiveJPEG := []byte{
+		0xff, 0xd8,
+		0xff, 0xe0, 0x00, 0x10, 'J', 'F', 'I', 'F', 0, 1, 1, 0, 0, 1, 0, 1, 0, 0,
+		0xff, 0xc2, 0x00, 0x0b, 8, 0, 8, 0, 8, 1, 1, 0x11, 0,
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"baseline png", baselinePNG.Bytes(), false},
+		{"interlaced png", interlacedPNG(t, 0), true},
+		{"baseline jpeg", baselineJPEG.Bytes(), false},
+		{"progressive jpeg", progressiveJPEG, true},
+		{"truncated jpeg", progressiveJPEG[:12], false},
+		{"not an image", []byte("not an image"), false},
+		{"empty", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isProgressive(tt.data); got != tt.want {
+				t.Errorf("isProgressive() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	wikipe, err := os.ReadFile("testdata/wikipe-tan-0.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := []entry{
+		{name: "1.png", data: interlacedPNG(t, 0x80)},
+		{name: "2.png", data: wikipe},
+		{name: "notes.txt", data: []byte("not an image")},
+	}
+	in := writeEntries(t, filepath.Join(t.TempDir(), "in.cbz"), entries)
+
+	plan, err := New(Params{}).Analyze(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var progressive []string
+	for _, pg := range plan.Pages {
+		if pg.Progressive {
+			progressive = append(progressive, pg.Source)
+		}
+	}
+	if diff := cmp.Diff([]string{"1.png"}, progressive); diff != "" {
+		t.Errorf("Analyze() progressive pages mismatch (-want +got):\n%s", diff)
+	}
+
+	var out bytes.Buffer
+	normalized, err := Normalize(in, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"1.png"}, normalized); diff != "" {
+		t.Errorf("Normalize() mismatch (-want +got):\n%s", diff)
+	}
+	r, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.File) != len(entries) {
+		t.Fatalf("Normalize() wrote %d entries, want %d", len(r.File), len(entries))
+	}
+	for i, f := range r.File {
+		if f.Name != entries[i].name {
+			t.Errorf("entry %d is %s, want %s", i, f.Name, entries[i].name)
+		}
+		data, err := readEntry(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if isProgressive(data) {
+			t.Errorf("%s is still progressive", f.Name)
+		}
+		if i > 0 && !bytes.Equal(data, entries[i].data) {
+			t.Errorf("%s was changed", f.Name)
+		}
+	}
+	rc, err := r.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	img, err := png.Decode(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := color.GrayModel.Convert(img.At(0, 0)).(color.Gray).Y; got != 0x80 {
+		t.Errorf("normalized pixel = %#x, want 0x80", got)
+	}
+}
@@ -0,0 +1,269 @@
+package mangaconv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by a MetadataProvider when no series matches a search.
+var ErrNotFound = errors.New("series not found")
+
+// MetadataProvider looks up series metadata, such as a summary and genres, in an online database.
+//
+// Lookup searches for the series of m and returns m with all fields known to the provider filled
+// in. Volume and Chapter are left as is.
+type MetadataProvider interface {
+	Lookup(ctx context.Context, m Metadata) (Metadata, error)
+}
+
+// lookupMetadata fills in the empty fields of m using the first of the Converter's
+// MetadataProviders which finds its series. Fields already known, such as from the input's
+// ComicInfo.xml, are kept. Series are looked up once, so that all volumes of a series share a
+// single lookup. Lookup errors aren't fatal, as the metadata parsed from the input name is still
+// valid.
+func (c *Converter) lookupMetadata(ctx context.Context, m Metadata) Metadata {
+	if !c.params.ComicInfo || m.Series == "" || len(c.params.MetadataProviders) == 0 {
+		return m
+	}
+	found, ok := c.lookups.lookup(ctx, m, c.params.MetadataProviders)
+	if !ok {
+		return m
+	}
+	if m.Summary == "" {
+		m.Summary = found.Summary
+	}
+	if m.Year == 0 {
+		m.Year = found.Year
+	}
+	if m.Writer == "" {
+		m.Writer = found.Writer
+	}
+	if len(m.Genres) == 0 {
+		m.Genres = found.Genres
+	}
+	if m.Web == "" {
+		m.Web = found.Web
+	}
+	return m
+}
+
+// lookupCache caches the metadata found by MetadataProviders for each series name.
+type lookupCache struct {
+	mu     sync.Mutex
+	series map[string]*seriesLookup
+}
+
+// seriesLookup is a lookup of a series, which is done once done is closed. ok reports whether
+// the series was found.
+type seriesLookup struct {
+	done chan struct{}
+	m    Metadata
+	ok   bool
+}
+
+func newLookupCache() *lookupCache {
+	return &lookupCache{series: make(map[string]*seriesLookup)}
+}
+
+// lookup returns the metadata found for the series of m by the first of providers which finds it,
+// waiting for a lookup of the same series which is already under way. Lookups failing for reasons
+// other than ErrNotFound, such as network errors, aren't cached.
+func (lc *lookupCache) lookup(ctx context.Context, m Metadata, providers []MetadataProvider) (Metadata, bool) {
+	lc.mu.Lock()
+	l, ok := lc.series[m.Series]
+	if ok {
+		lc.mu.Unlock()
+		select {
+		case <-l.done:
+			return l.m, l.ok
+		case <-ctx.Done():
+			return Metadata{}, false
+		}
+	}
+	l = &seriesLookup{done: make(chan struct{})}
+	lc.series[m.Series] = l
+	lc.mu.Unlock()
+
+	cache := true
+	for _, p := range providers {
+		found, err := p.Lookup(ctx, m)
+		if err == nil {
+			l.m, l.ok = found, true
+			break
+		}
+		if !errors.Is(err, ErrNotFound) {
+			cache = false
+		}
+	}
+	if !cache && !l.ok {
+		lc.mu.Lock()
+		delete(lc.series, m.Series)
+		lc.mu.Unlock()
+	}
+	close(l.done)
+	return l.m, l.ok
+}
+
+var defaultHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// postJSON posts req as JSON to url and decodes the JSON response into resp.
+func postJSON(ctx context.Context, client *http.Client, url string, req, resp interface{}) error {
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Accept", "application/json")
+	res, err := client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, res.Body)
+		return fmt.Errorf("%s: unexpected status %s", url, res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(resp)
+}
+
+// AniList looks up metadata using the AniList GraphQL API. The zero value is ready to use.
+//
+// Endpoint defaults to https://graphql.anilist.co and Client to an http.Client with a timeout.
+type AniList struct {
+	Endpoint string
+	Client   *http.Client
+}
+
+const aniListQuery = `query ($search: String) {
+  Media(search: $search, type: MANGA) {
+    title { romaji english }
+    description(asHtml: false)
+    genres
+    startDate { year }
+    siteUrl
+    staff(sort: RELEVANCE) { edges { role node { name { full } } } }
+  }
+}`
+
+// Lookup implements MetadataProvider.
+func (a AniList) Lookup(ctx context.Context, m Metadata) (Metadata, error) {
+	url := a.Endpoint
+	if url == "" {
+		url = "https://graphql.anilist.co"
+	}
+	req := map[string]interface{}{
+		"query":     aniListQuery,
+		"variables": map[string]string{"search": m.Series},
+	}
+	var resp struct {
+		Data struct {
+			Media *struct {
+				Title struct {
+					Romaji  string
+					English string
+				}
+				Description string
+				Genres      []string
+				StartDate   struct{ Year int }
+				SiteURL     string `json:"siteUrl"`
+				Staff       struct {
+					Edges []struct {
+						Role string
+						Node struct{ Name struct{ Full string } }
+					}
+				}
+			}
+		}
+	}
+	if err := postJSON(ctx, a.Client, url, req, &resp); err != nil {
+		return m, fmt.Errorf("anilist: %w", err)
+	}
+	media := resp.Data.Media
+	if media == nil {
+		return m, fmt.Errorf("anilist: %w: %s", ErrNotFound, m.Series)
+	}
+
+	if media.Title.English != "" {
+		m.Series = media.Title.English
+	} else if media.Title.Romaji != "" {
+		m.Series = media.Title.Romaji
+	}
+	m.Summary = media.Description
+	m.Genres = media.Genres
+	m.Year = media.StartDate.Year
+	m.Web = media.SiteURL
+	var writers []string
+	for _, e := range media.Staff.Edges {
+		// Roles are e.g. "Story", "Story & Art" or "Original Story".
+		if strings.Contains(e.Role, "Story") {
+			writers = append(writers, e.Node.Name.Full)
+		}
+	}
+	m.Writer = strings.Join(writers, ", ")
+	return m, nil
+}
+
+// MangaUpdates looks up metadata using the MangaUpdates API. The zero value is ready to use.
+//
+// Endpoint defaults to https://api.mangaupdates.com/v1 and Client to an http.Client with a
+// timeout.
+type MangaUpdates struct {
+	Endpoint string
+	Client   *http.Client
+}
+
+// Lookup implements MetadataProvider. MangaUpdates search results don't include authors, so
+// Writer is left as is.
+func (mu MangaUpdates) Lookup(ctx context.Context, m Metadata) (Metadata, error) {
+	url := mu.Endpoint
+	if url == "" {
+		url = "https://api.mangaupdates.com/v1"
+	}
+	req := map[string]interface{}{"search": m.Series, "perpage": 1}
+	var resp struct {
+		Results []struct {
+			Record struct {
+				Title       string
+				URL         string
+				Description string
+				Year        string
+				Genres      []struct{ Genre string }
+			}
+		}
+	}
+	if err := postJSON(ctx, mu.Client, url+"/series/search", req, &resp); err != nil {
+		return m, fmt.Errorf("mangaupdates: %w", err)
+	}
+	if len(resp.Results) == 0 {
+		return m, fmt.Errorf("mangaupdates: %w: %s", ErrNotFound, m.Series)
+	}
+
+	r := resp.Results[0].Record
+	if r.Title != "" {
+		m.Series = r.Title
+	}
+	m.Summary = r.Description
+	m.Genres = nil
+	for _, g := range r.Genres {
+		m.Genres = append(m.Genres, g.Genre)
+	}
+	// Year is a string, which is empty or malformed for some series.
+	fmt.Sscan(r.Year, &m.Year)
+	m.Web = r.URL
+	return m, nil
+}
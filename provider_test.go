@@ -0,0 +1,182 @@
+package mangaconv_test
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/naisuuuu/mangaconv"
+)
+
+func TestMetadataProviders(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider func(url string) mangaconv.MetadataProvider
+		path     string
+		search   string
+		resp     string
+		want     mangaconv.Metadata
+		wantErr  error
+	}{
+		{
+			name:     "anilist",
+			provider: func(url string) mangaconv.MetadataProvider { return mangaconv.AniList{Endpoint: url} },
+			path:     "/",
+			search:   `"search":"Series Name"`,
+			resp: `{"data": {"Media": {
+				"title": {"romaji": "Shirizu", "english": "Series"},
+				"description": "A series.",
+				"genres": ["Action", "Drama"],
+				"startDate": {"year": 2019},
+				"siteUrl": "https://anilist.co/manga/1",
+				"staff": {"edges": [
+					{"role": "Story & Art", "node": {"name": {"full": "Author"}}},
+					{"role": "Translator", "node": {"name": {"full": "Someone"}}}
+				]}
+			}}}`,
+			want: mangaconv.Metadata{
+				Series:  "Series",
+				Volume:  "03",
+				Summary: "A series.",
+				Year:    2019,
+				Writer:  "Author",
+				Genres:  []string{"Action", "Drama"},
+				Web:     "https://anilist.co/manga/1",
+			},
+		},
+		{
+			name:     "anilist not found",
+			provider: func(url string) mangaconv.MetadataProvider { return mangaconv.AniList{Endpoint: url} },
+			path:     "/",
+			resp:     `{"data": {"Media": null}}`,
+			want:     mangaconv.Metadata{Series: "Series Name", Volume: "03"},
+			wantErr:  mangaconv.ErrNotFound,
+		},
+		{
+			name:     "mangaupdates",
+			provider: func(url string) mangaconv.MetadataProvider { return mangaconv.MangaUpdates{Endpoint: url} },
+			path:     "/series/search",
+			search:   `"search":"Series Name"`,
+			resp: `{"results": [{"record": {
+				"title": "Series",
+				"url": "https://www.mangaupdates.com/series/1",
+				"description": "A series.",
+				"year": "2019",
+				"genres": [{"genre": "Action"}, {"genre": "Drama"}]
+			}}]}`,
+			want: mangaconv.Metadata{
+				Series:  "Series",
+				Volume:  "03",
+				Summary: "A series.",
+				Year:    2019,
+				Genres:  []string{"Action", "Drama"},
+				Web:     "https://www.mangaupdates.com/series/1",
+			},
+		},
+		{
+			name:     "mangaupdates not found",
+			provider: func(url string) mangaconv.MetadataProvider { return mangaconv.MangaUpdates{Endpoint: url} },
+			path:     "/series/search",
+			resp:     `{"results": []}`,
+			want:     mangaconv.Metadata{Series: "Series Name", Volume: "03"},
+			wantErr:  mangaconv.ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if r.Method != http.MethodPost || r.URL.Path != tt.path || !strings.Contains(string(body), tt.search) {
+					t.Errorf("unexpected request %s %s: %s", r.Method, r.URL.Path, body)
+				}
+				io.WriteString(w, tt.resp)
+			}))
+			defer srv.Close()
+
+			in := mangaconv.Metadata{Series: "Series Name", Volume: "03"}
+			got, err := tt.provider(srv.URL).Lookup(context.Background(), in)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Lookup() error = %v, want %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Lookup() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// countingProvider finds every series, counting its lookups.
+type countingProvider struct {
+	lookups int32
+}
+
+func (p *countingProvider) Lookup(ctx context.Context, m mangaconv.Metadata) (mangaconv.Metadata, error) {
+	atomic.AddInt32(&p.lookups, 1)
+	m.Series, m.Summary, m.Year = "Other Series", "A series.", 2019
+	return m, nil
+}
+
+func TestLookupMetadata(t *testing.T) {
+	dir := t.TempDir()
+	provider := &countingProvider{}
+	p := mangaconv.Params{AutoContrast: true, Cutoff: 1, Gamma: 1, Width: 50, Height: 50, ComicInfo: true,
+		MetadataProviders: []mangaconv.MetadataProvider{provider}}
+	converters := []*mangaconv.Converter{mangaconv.New(p), mangaconv.New(p)}
+	var targets []mangaconv.Target
+	for _, name := range []string{"Series v01", "Series v02"} {
+		in := filepath.Join(dir, name+".zip")
+		data, err := os.ReadFile("testdata/wikipe-tan.zip")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(in, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		targets = append(targets, mangaconv.Target{In: in, Out: []string{
+			filepath.Join(dir, name+" a.cbz"),
+			filepath.Join(dir, name+" b.cbz"),
+		}})
+	}
+	for _, r := range mangaconv.ConvertAll(context.Background(), targets, mangaconv.BatchOptions{Converters: converters}) {
+		if r.Err != nil {
+			t.Fatalf("ConvertAll() error %v", r.Err)
+		}
+	}
+
+	// Once, shared by all volumes and outputs.
+	if got := atomic.LoadInt32(&provider.lookups); got != 1 {
+		t.Errorf("got %d lookups, want 1", got)
+	}
+	for _, tt := range targets {
+		for _, out := range tt.Out {
+			zr, err := zip.OpenReader(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f, err := zr.Open("ComicInfo.xml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(f)
+			zr.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range []string{"<Series>Series</Series>", "<Summary>A series.</Summary>", "<Year>2019</Year>"} {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("%s: ComicInfo.xml lacks %s:\n%s", filepath.Base(out), want, data)
+				}
+			}
+		}
+	}
+}
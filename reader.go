@@ -5,10 +5,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"image"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"golang.org/x/sync/errgroup"
 )
@@ -18,18 +21,43 @@ var (
 	ErrCannotReadPath    = errors.New("cannot read path")
 )
 
+// SymlinkPolicy controls how symbolic links are treated when reading directories.
+type SymlinkPolicy int
+
+const (
+	// SymlinkFiles follows links to files, but not to directories.
+	SymlinkFiles SymlinkPolicy = iota
+	// SymlinkFollow follows links to both files and directories. Each directory is read at most
+	// once, which protects against cycles.
+	SymlinkFollow
+	// SymlinkIgnore skips all symbolic links.
+	SymlinkIgnore
+)
+
 // reader reads provided path's contents and emits a page for each image in it.
 type reader func(ctx context.Context, pages chan<- page, path string) error
 
 // rawPage represents a page before decoding.
+//
+// Open opens the page's file. It's only called right before decoding, so that files waiting in
+// channels don't hold file descriptors. Image is set instead of Open for generated pages, which need
+// no decoding. Name is the original file name without extension and Source is the slash separated
+// path of the file relative to the input root, or "page N" for page N of PDF files. Both are empty
+// for generated pages. Join is the other half of a spread stored as two files, joined to the page
+// once both are decoded.
 type rawPage struct {
-	File  io.ReadCloser
-	Index int
+	Open    func() (io.ReadCloser, error)
+	Image   image.Image
+	Index   int
+	Chapter string
+	Name    string
+	Source  string
+	Join    *rawPage
 }
 
 // selectReader returns an appropriate reader for the file format at path, or error if path cannot
 // be read or the file format is not supported.
-func selectReader(path string) (reader, error) {
+func (c *Converter) selectReader(path string) (reader, error) {
 	f, err := os.Stat(path)
 	if err != nil {
 		return nil, ErrCannotReadPath
@@ -38,93 +66,191 @@ func selectReader(path string) (reader, error) {
 	switch filepath.Ext(path) {
 	case "":
 		if f.IsDir() {
-			return readDir, nil
+			return c.readDir, nil
 		}
 	case ".zip", ".cbz":
-		return readZip, nil
+		return c.readZip, nil
+	case ".pdf":
+		return c.readPDF, nil
 	}
 
 	return nil, ErrUnsupportedFormat
 }
 
 // readDir reads a directory and emits a page for each image in it.
-func readDir(ctx context.Context, pages chan<- page, path string) error {
+func (c *Converter) readDir(ctx context.Context, pages chan<- page, path string) error {
+	if err := c.checkDir(path); err != nil {
+		return err
+	}
 	errg, ctx := errgroup.WithContext(ctx)
 	raw := make(chan rawPage)
 	errg.Go(func() error {
 		defer close(raw)
-		return readDirFiles(ctx, raw, path)
+		return c.readDirFiles(ctx, raw, path)
 	})
+	joined := c.joinStage(ctx, errg.Go, raw)
 
 	errg.Go(func() error {
-		return decode(ctx, pages, raw)
+		return c.decode(ctx, pages, joined)
 	})
 
 	return errg.Wait()
 }
 
-func readDirFiles(ctx context.Context, pages chan<- rawPage, root string) error {
+// readDirFiles walks root in lexical order and emits a raw page for each image in it. All paths
+// are collected before the first page is emitted and files are only opened when decoded, so large
+// directories don't exhaust file descriptors.
+func (c *Converter) readDirFiles(ctx context.Context, pages chan<- rawPage, root string) error {
+	paths, err := c.listDir(root)
+	if err != nil {
+		return err
+	}
+
 	i := 0
-	return filepath.WalkDir(root, func(path string, e fs.DirEntry, err error) error {
-		if err != nil {
-			return fmt.Errorf("cannot walk %s: %w", root, err)
+	prev := ""
+	for _, path := range paths {
+		var chapter string
+		if c.params.Chapters {
+			// walkDir visits each folder's files contiguously, so pages are already grouped.
+			chapter = dirChapter(root, path)
 		}
-		if !isImage(path) {
-			return nil
+		if err := c.emitTitle(ctx, pages, &i, prev, chapter); err != nil {
+			return err
 		}
-		file, err := os.Open(path)
-		if err != nil {
-			return fmt.Errorf("cannot open %s: %w", path, err)
+		prev = chapter
+		path := path
+		open := func() (io.ReadCloser, error) {
+			f, err := os.Open(path)
+			if err != nil {
+				return nil, fmt.Errorf("cannot open %s: %w", path, err)
+			}
+			return f, nil
 		}
 		select {
-		case pages <- rawPage{file, i}:
+		case pages <- rawPage{Open: open, Index: i, Chapter: chapter, Name: baseName(path), Source: relPath(root, path)}:
 		case <-ctx.Done():
-			// Don't forget to close any open files.
-			file.Close()
 			return ctx.Err()
 		}
 		i++
+	}
+	return nil
+}
+
+// listDir returns paths of all images in root in lexical order.
+func (c *Converter) listDir(root string) ([]string, error) {
+	var paths []string
+	err := c.walkDir(root, make(map[string]bool), func(path string) error {
+		if isImage(path) {
+			paths = append(paths, path)
+		}
 		return nil
 	})
+	return paths, err
+}
+
+// walkDir walks dir in lexical order and calls fn for each non-directory entry. Symbolic links are
+// handled according to the SymlinkPolicy in params. visited holds real paths of directories
+// already walked, which protects against symlink cycles.
+func (c *Converter) walkDir(dir string, visited map[string]bool, fn func(path string) error) error {
+	if c.params.Symlinks == SymlinkFollow {
+		real, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return fmt.Errorf("cannot resolve %s: %w", dir, err)
+		}
+		if visited[real] {
+			return nil
+		}
+		visited[real] = true
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("cannot walk %s: %w", dir, err)
+	}
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		isDir := e.IsDir()
+		if e.Type()&fs.ModeSymlink != 0 {
+			switch c.params.Symlinks {
+			case SymlinkIgnore:
+				continue
+			case SymlinkFollow:
+				info, err := os.Stat(path)
+				if err != nil {
+					// Dangling link.
+					continue
+				}
+				isDir = info.IsDir()
+			}
+		}
+		if isDir {
+			err = c.walkDir(path, visited, fn)
+		} else {
+			err = fn(path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // readZip reads a zip file and emtis a page for each image in it.
-func readZip(ctx context.Context, pages chan<- page, path string) error {
+func (c *Converter) readZip(ctx context.Context, pages chan<- page, path string) error {
 	r, err := zip.OpenReader(path)
 	if err != nil {
 		return fmt.Errorf("cannot open %s: %w", path, err)
 	}
 	defer r.Close()
+	return c.readZipReader(ctx, pages, &r.Reader)
+}
 
+// readZipReader reads an opened zip archive and emits a page for each image in it.
+func (c *Converter) readZipReader(ctx context.Context, pages chan<- page, r *zip.Reader) error {
+	if err := c.checkZip(r); err != nil {
+		return err
+	}
 	errg, ctx := errgroup.WithContext(ctx)
 	raw := make(chan rawPage)
 	errg.Go(func() error {
 		defer close(raw)
-		return readZipFiles(ctx, raw, r)
+		return c.readZipFiles(ctx, raw, r)
 	})
+	joined := c.joinStage(ctx, errg.Go, raw)
 
 	errg.Go(func() error {
-		return decode(ctx, pages, raw)
+		return c.decode(ctx, pages, joined)
 	})
 
 	return errg.Wait()
 }
 
-func readZipFiles(ctx context.Context, pages chan<- rawPage, r *zip.ReadCloser) error {
+// readZipFiles emits a raw page for each image in r, in page order. Entries are only opened when
+// decoded.
+func (c *Converter) readZipFiles(ctx context.Context, pages chan<- rawPage, r *zip.Reader) error {
+	files := c.listZip(r)
 	i := 0
-	for _, f := range r.File {
-		if !isImage(f.Name) {
-			continue
+	prev := ""
+	for _, f := range files {
+		var chapter string
+		if c.params.Chapters {
+			chapter = zipChapter(f.Name)
 		}
-		file, err := f.Open()
-		if err != nil {
-			return fmt.Errorf("cannot open %s: %w", f.Name, err)
+		if err := c.emitTitle(ctx, pages, &i, prev, chapter); err != nil {
+			return err
+		}
+		prev = chapter
+		f := f
+		open := func() (io.ReadCloser, error) {
+			file, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("cannot open %s: %w", f.Name, err)
+			}
+			return file, nil
 		}
 		select {
-		case pages <- rawPage{file, i}:
+		case pages <- rawPage{Open: open, Index: i, Chapter: chapter, Name: baseName(f.Name), Source: f.Name}:
 		case <-ctx.Done():
-			// Don't forget to close any open files.
-			file.Close()
 			return ctx.Err()
 		}
 		i++
@@ -132,6 +258,56 @@ func readZipFiles(ctx context.Context, pages chan<- rawPage, r *zip.ReadCloser)
 	return nil
 }
 
+// listZip returns all image entries of r in page order.
+func (c *Converter) listZip(r *zip.Reader) []*zip.File {
+	var files []*zip.File
+	for _, f := range r.File {
+		if isImage(f.Name) {
+			files = append(files, f)
+		}
+	}
+	if less := c.params.Order.less(); less != nil {
+		sort.SliceStable(files, func(i, j int) bool { return less(files[i].Name, files[j].Name) })
+	}
+	if c.params.Chapters {
+		sort.SliceStable(files, func(i, j int) bool {
+			return chapterLess(zipChapter(files[i].Name), zipChapter(files[j].Name))
+		})
+	}
+	return files
+}
+
+// emitTitle emits a generated title page with index *i and increments it, if title pages are
+// enabled and chapter starts a new chapter after prev.
+func (c *Converter) emitTitle(ctx context.Context, pages chan<- rawPage, i *int, prev, chapter string) error {
+	if !c.params.TitlePages || chapter == "" || chapter == prev {
+		return nil
+	}
+	img := renderTitle(chapterTitle(chapter), c.params.Width, c.params.Height)
+	select {
+	case pages <- rawPage{Image: img, Index: *i, Chapter: chapter}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	*i++
+	return nil
+}
+
+// baseName returns the file name of a slash or OS separated path, without extension.
+func baseName(p string) string {
+	b := filepath.Base(filepath.FromSlash(p))
+	return strings.TrimSuffix(b, filepath.Ext(b))
+}
+
+// relPath returns the slash separated path of file relative to root.
+func relPath(root, file string) string {
+	rel, err := filepath.Rel(root, file)
+	if err != nil {
+		return filepath.ToSlash(file)
+	}
+	return filepath.ToSlash(rel)
+}
+
 func isImage(fname string) bool {
 	switch filepath.Ext(fname) {
 	case ".png", ".jpg", ".webp":
@@ -1,33 +1,24 @@
 package mangaconv
 
 import (
+	"archive/zip"
 	"context"
-	"fmt"
-	"image"
+	"errors"
 	_ "image/jpeg"
 	_ "image/png"
 	"os"
+	"path/filepath"
+	"sort"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	"golang.org/x/sync/errgroup"
-)
 
-func mustReadImg(path string) image.Image {
-	f, err := os.Open(path)
-	if err != nil {
-		panic(fmt.Sprintf("cannot open %s: %s", path, err))
-	}
-	defer f.Close()
-	i, _, err := image.Decode(f)
-	if err != nil {
-		panic(fmt.Sprintf("cannot decode %s: %s", path, err))
-	}
-	return i
-}
+	"github.com/naisuuuu/mangaconv/imgtest"
+)
 
-func readHelper(path string) ([]page, error) {
-	read, err := selectReader(path)
+func readHelper(p Params, path string) ([]page, error) {
+	read, err := New(p).selectReader(path)
 	if err != nil {
 		return nil, err
 	}
@@ -47,6 +38,8 @@ func readHelper(path string) ([]page, error) {
 	// at this point pages channel is already closed and safe to operate on synchronously.
 	out := make([]page, len(pages))
 	for p := range pages {
+		// Timings differ between runs.
+		p.Timings = PageTimings{}
 		out[p.Index] = p
 	}
 	return out, nil
@@ -63,16 +56,16 @@ func TestReader(t *testing.T) {
 			name: "directory reader",
 			path: "testdata/",
 			want: []page{
-				{mustReadImg("testdata/wikipe-tan-0.png"), 0},
-				{mustReadImg("testdata/wikipe-tan-1.png"), 1},
+				{Image: imgtest.MustRead("testdata/wikipe-tan-0.png"), Index: 0, Name: "wikipe-tan-0", Source: "wikipe-tan-0.png"},
+				{Image: imgtest.MustRead("testdata/wikipe-tan-1.png"), Index: 1, Name: "wikipe-tan-1", Source: "wikipe-tan-1.png"},
 			},
 		},
 		{
 			name: "zip reader",
 			path: "testdata/wikipe-tan.zip",
 			want: []page{
-				{mustReadImg("testdata/wikipe-tan-0.png"), 0},
-				{mustReadImg("testdata/wikipe-tan-1.png"), 1},
+				{Image: imgtest.MustRead("testdata/wikipe-tan-0.png"), Index: 0, Name: "wikipe-tan-0", Source: "wikipe-tan-0.png"},
+				{Image: imgtest.MustRead("testdata/wikipe-tan-1.png"), Index: 1, Name: "wikipe-tan-1", Source: "wikipe-tan-1.png"},
 			},
 		},
 		{
@@ -93,7 +86,7 @@ func TestReader(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := readHelper(tt.path)
+			got, err := readHelper(Params{}, tt.path)
 			if err != tt.err {
 				t.Errorf("reader error %v, want %v", got, tt.want)
 				return
@@ -104,3 +97,220 @@ func TestReader(t *testing.T) {
 		})
 	}
 }
+
+func TestReaderMinPageSize(t *testing.T) {
+	tests := []struct {
+		size int
+		want int
+	}{
+		{239, 2},
+		{240, 0},
+	}
+	for _, tt := range tests {
+		for _, path := range []string{"testdata/", "testdata/wikipe-tan.zip"} {
+			got, err := readHelper(Params{MinPageSize: tt.size}, path)
+			if err != nil {
+				t.Fatalf("%s: reader error %v", path, err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("%s: MinPageSize %d got %d pages, want %d", path, tt.size, len(got), tt.want)
+			}
+		}
+	}
+}
+
+func TestReaderCorruptZip(t *testing.T) {
+	b, err := os.ReadFile("testdata/wikipe-tan-0.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "corrupt.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	zf, err := w.CreateRaw(&zip.FileHeader{
+		Name:               "0.png",
+		Method:             zip.Store,
+		CRC32:              0xdeadbeef,
+		CompressedSize64:   uint64(len(b)),
+		UncompressedSize64: uint64(len(b)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := zf.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if _, err := readHelper(Params{}, path); !errors.Is(err, ErrCorruptEntry) {
+		t.Errorf("reader error %v, want %v", err, ErrCorruptEntry)
+	}
+}
+
+func mustSymlink(t *testing.T, oldname, newname string) {
+	t.Helper()
+	if err := os.Symlink(oldname, newname); err != nil {
+		t.Skipf("cannot create symlink: %v", err)
+	}
+}
+
+func mustCopy(t *testing.T, src, dst string) {
+	t.Helper()
+	b, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReaderSymlinks(t *testing.T) {
+	img0, err := filepath.Abs("testdata/wikipe-tan-0.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img1, err := filepath.Abs("testdata/wikipe-tan-1.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// root/0.png
+	// root/ch/1.png -> testdata/wikipe-tan-1.png
+	// root/ch/loop -> root
+	// root/link -> external
+	// external/2.png
+	root, external := t.TempDir(), t.TempDir()
+	mustCopy(t, img0, filepath.Join(root, "0.png"))
+	mustCopy(t, img0, filepath.Join(external, "2.png"))
+	if err := os.Mkdir(filepath.Join(root, "ch"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	mustSymlink(t, img1, filepath.Join(root, "ch", "1.png"))
+	mustSymlink(t, root, filepath.Join(root, "ch", "loop"))
+	mustSymlink(t, external, filepath.Join(root, "link"))
+
+	tests := []struct {
+		name   string
+		policy SymlinkPolicy
+		want   []page
+	}{
+		{
+			name:   "files",
+			policy: SymlinkFiles,
+			want: []page{
+				{Image: imgtest.MustRead(img0), Index: 0, Name: "0", Source: "0.png"},
+				{Image: imgtest.MustRead(img1), Index: 1, Name: "1", Source: "ch/1.png"},
+			},
+		},
+		{
+			name:   "follow",
+			policy: SymlinkFollow,
+			want: []page{
+				{Image: imgtest.MustRead(img0), Index: 0, Name: "0", Source: "0.png"},
+				{Image: imgtest.MustRead(img1), Index: 1, Name: "1", Source: "ch/1.png"},
+				{Image: imgtest.MustRead(img0), Index: 2, Name: "2", Source: "link/2.png"},
+			},
+		},
+		{
+			name:   "ignore",
+			policy: SymlinkIgnore,
+			want: []page{
+				{Image: imgtest.MustRead(img0), Index: 0, Name: "0", Source: "0.png"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readHelper(Params{Symlinks: tt.policy}, root)
+			if err != nil {
+				t.Fatalf("reader error %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("reader mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func mustWriteZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	// Sort names for a deterministic archive order.
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b, err := os.ReadFile(files[name])
+		if err != nil {
+			t.Fatal(err)
+		}
+		zf, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := zf.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReaderChapters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chapters.zip")
+	mustWriteZip(t, path, map[string]string{
+		"c10/0.png": "testdata/wikipe-tan-0.png",
+		"c2/0.png":  "testdata/wikipe-tan-1.png",
+		"c2/1.png":  "testdata/wikipe-tan-0.png",
+	})
+
+	got, err := readHelper(Params{Chapters: true}, path)
+	if err != nil {
+		t.Fatalf("reader error %v", err)
+	}
+	want := []page{
+		{Image: imgtest.MustRead("testdata/wikipe-tan-1.png"), Index: 0, Chapter: "c2", Name: "0", Source: "c2/0.png"},
+		{Image: imgtest.MustRead("testdata/wikipe-tan-0.png"), Index: 1, Chapter: "c2", Name: "1", Source: "c2/1.png"},
+		{Image: imgtest.MustRead("testdata/wikipe-tan-0.png"), Index: 2, Chapter: "c10", Name: "0", Source: "c10/0.png"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("reader mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestReaderTitlePages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chapters.zip")
+	mustWriteZip(t, path, map[string]string{
+		"c010/0.png": "testdata/wikipe-tan-0.png",
+		"c2/0.png":   "testdata/wikipe-tan-1.png",
+	})
+
+	got, err := readHelper(Params{Chapters: true, TitlePages: true, Width: 60, Height: 80}, path)
+	if err != nil {
+		t.Fatalf("reader error %v", err)
+	}
+	want := []page{
+		{Image: renderTitle("Chapter 2", 60, 80), Index: 0, Chapter: "c2"},
+		{Image: imgtest.MustRead("testdata/wikipe-tan-1.png"), Index: 1, Chapter: "c2", Name: "0", Source: "c2/0.png"},
+		{Image: renderTitle("Chapter 10", 60, 80), Index: 2, Chapter: "c010"},
+		{Image: imgtest.MustRead("testdata/wikipe-tan-0.png"), Index: 3, Chapter: "c010", Name: "0", Source: "c010/0.png"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("reader mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,117 @@
+package mangaconv
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+)
+
+// scheduler hands out a fixed number of worker slots to pages of all conversions sharing a
+// Converter. Waiting pages get slots in order of the priority of their conversion, then in order of
+// arrival, so that pages of files started earlier are converted first and later files only use
+// otherwise idle workers.
+type scheduler struct {
+	mu      sync.Mutex
+	free    int
+	seq     uint64
+	waiting waitQueue
+}
+
+// newScheduler creates a scheduler with n slots.
+func newScheduler(n int) *scheduler {
+	return &scheduler{free: n}
+}
+
+type priorityKey struct{}
+
+// withPriority returns a context under which pages wait for slots with priority prio, with lower
+// values served first. Pages of contexts without a priority have priority 0.
+func withPriority(ctx context.Context, prio int) context.Context {
+	return context.WithValue(ctx, priorityKey{}, prio)
+}
+
+// acquire blocks until a slot is available or ctx is done.
+func (s *scheduler) acquire(ctx context.Context) error {
+	prio, _ := ctx.Value(priorityKey{}).(int)
+	s.mu.Lock()
+	if s.free > 0 && len(s.waiting) == 0 {
+		s.free--
+		s.mu.Unlock()
+		return nil
+	}
+	w := &waiter{prio: prio, seq: s.seq, ready: make(chan struct{})}
+	s.seq++
+	heap.Push(&s.waiting, w)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		// The slot may have been handed over right as ctx was done.
+		granted := w.index < 0
+		if !granted {
+			heap.Remove(&s.waiting, w.index)
+		}
+		s.mu.Unlock()
+		if granted {
+			s.release()
+		}
+		return ctx.Err()
+	}
+}
+
+// release frees a slot taken by acquire, handing it over to the first waiting page if any.
+func (s *scheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.waiting) == 0 {
+		s.free++
+		return
+	}
+	w := heap.Pop(&s.waiting).(*waiter)
+	close(w.ready)
+}
+
+// waiter is a page waiting for a slot. index is its position in the queue, or -1 once it's been
+// handed a slot.
+type waiter struct {
+	prio  int
+	seq   uint64
+	ready chan struct{}
+	index int
+}
+
+// waitQueue is a heap of waiters implementing heap.Interface.
+type waitQueue []*waiter
+
+func (q waitQueue) Len() int { return len(q) }
+
+func (q waitQueue) Less(i, j int) bool {
+	if q[i].prio != q[j].prio {
+		return q[i].prio < q[j].prio
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q waitQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *waitQueue) Push(x interface{}) {
+	w := x.(*waiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *waitQueue) Pop() interface{} {
+	old := *q
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*q = old[:len(old)-1]
+	return w
+}
@@ -0,0 +1,75 @@
+package mangaconv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// waitQueued waits until n pages are waiting for a slot of s.
+func waitQueued(t *testing.T, s *scheduler, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		s.mu.Lock()
+		queued := len(s.waiting)
+		s.mu.Unlock()
+		if queued == n {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %d queued pages", n)
+}
+
+func TestSchedulerPriority(t *testing.T) {
+	s := newScheduler(1)
+	if err := s.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Priorities are queued in this order, with later pages of equal priority served last.
+	prios := []int{3, 1, 2, 1, 0}
+	order := make(chan int, len(prios))
+	for i, p := range prios {
+		i, p := i, p
+		go func() {
+			if err := s.acquire(withPriority(context.Background(), p)); err != nil {
+				t.Error(err)
+				return
+			}
+			order <- i
+			s.release()
+		}()
+		waitQueued(t, s, i+1)
+	}
+	s.release()
+
+	var got []int
+	for range prios {
+		got = append(got, <-order)
+	}
+	if diff := cmp.Diff([]int{4, 1, 3, 2, 0}, got); diff != "" {
+		t.Errorf("acquire order mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	s := newScheduler(1)
+	if err := s.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.acquire(ctx) }()
+	waitQueued(t, s, 1)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("acquire() error %v, want %v", err, context.Canceled)
+	}
+
+	s.release()
+	if len(s.waiting) != 0 || s.free != 1 {
+		t.Errorf("got %d waiting and %d free slots, want 0 and 1", len(s.waiting), s.free)
+	}
+}
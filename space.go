@@ -0,0 +1,82 @@
+package mangaconv
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+var (
+	// ErrInsufficientSpace is returned when there is not enough free space to write the outputs.
+	ErrInsufficientSpace = errors.New("insufficient disk space")
+	// ErrInputTooLarge is returned for inputs larger than BatchOptions.MaxInputSize.
+	ErrInputTooLarge = errors.New("input too large")
+)
+
+// diskFree returns the number of bytes available to the current user on the filesystem containing
+// dir. It's a variable so that tests can fake it.
+var diskFree = freeSpace
+
+// checkSpace estimates the space needed to convert in into outs and returns ErrInsufficientSpace if
+// any output directory doesn't have enough free space. Outputs are estimated to be as large as the
+// input, as grayscale pages rarely end up larger than their source. Directories whose free space
+// can't be determined are not checked.
+func checkSpace(in string, outs []string) error {
+	size, err := inputSize(LongPath(in))
+	if err != nil {
+		// Reading the input will fail with a more relevant error.
+		return nil
+	}
+	need := make(map[string]int64)
+	for _, out := range outs {
+		need[filepath.Dir(out)] += size
+	}
+	for dir, n := range need {
+		free, err := diskFree(LongPath(dir))
+		if err != nil {
+			continue
+		}
+		if uint64(n) > free {
+			return fmt.Errorf("%w in %s: need about %d MiB, %d MiB available",
+				ErrInsufficientSpace, dir, n>>20, free>>20)
+		}
+	}
+	return nil
+}
+
+// checkInputSize returns ErrInputTooLarge if the input at in is larger than max bytes.
+func checkInputSize(in string, max int64) error {
+	size, err := inputSize(LongPath(in))
+	if err != nil {
+		// Reading the input will fail with a more relevant error.
+		return nil
+	}
+	if size > max {
+		return fmt.Errorf("%w: %d MiB, limit is %d MiB", ErrInputTooLarge, size>>20, max>>20)
+	}
+	return nil
+}
+
+// inputSize returns the size of a file, or the total size of all files in a directory.
+func inputSize(path string) (int64, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	if !fi.IsDir() {
+		return fi.Size(), nil
+	}
+	var size int64
+	err = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,75 @@
+package mangaconv
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckSpace(t *testing.T) {
+	defer func(f func(string) (uint64, error)) { diskFree = f }(diskFree)
+
+	in := "testdata/wikipe-tan.zip"
+	size, err := inputSize(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		free    uint64
+		freeErr error
+		outs    []string
+		want    error
+	}{
+		{"enough", uint64(size), nil, []string{filepath.Join(dir, "a.cbz")}, nil},
+		{"too little", uint64(size) - 1, nil, []string{filepath.Join(dir, "a.cbz")}, ErrInsufficientSpace},
+		{"outputs add up", uint64(size) * 3 / 2, nil,
+			[]string{filepath.Join(dir, "a.cbz"), filepath.Join(dir, "b.cbz")}, ErrInsufficientSpace},
+		{"unknown", 0, errors.New("unsupported"), []string{filepath.Join(dir, "a.cbz")}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diskFree = func(string) (uint64, error) { return tt.free, tt.freeErr }
+			if err := checkSpace(in, tt.outs); !errors.Is(err, tt.want) {
+				t.Errorf("checkSpace() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestInputSizeDir(t *testing.T) {
+	got, err := inputSize("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zip, _ := inputSize("testdata/wikipe-tan.zip")
+	if got <= zip {
+		t.Errorf("inputSize(testdata) = %d, want more than %d", got, zip)
+	}
+}
+
+func TestCheckInputSize(t *testing.T) {
+	in := "testdata/wikipe-tan.zip"
+	size, err := inputSize(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		in   string
+		max  int64
+		want error
+	}{
+		{"fits", in, size, nil},
+		{"too large", in, size - 1, ErrInputTooLarge},
+		{"missing", "testdata/missing.zip", 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkInputSize(tt.in, tt.max); !errors.Is(err, tt.want) {
+				t.Errorf("checkInputSize() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
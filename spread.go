@@ -0,0 +1,120 @@
+package mangaconv
+
+import (
+	"image"
+
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+// SpreadPolicy controls how double page spreads, pages wider than they are high, are handled.
+type SpreadPolicy int
+
+const (
+	// SpreadKeep keeps spreads as they are.
+	SpreadKeep SpreadPolicy = iota
+	// SpreadSplit splits spreads into two pages in reading order.
+	SpreadSplit
+	// SpreadRotate rotates spreads to fill portrait screens. The first half in reading order ends up
+	// at the top.
+	SpreadRotate
+	// SpreadBoth emits the rotated spread, immediately followed by its split halves, letting the
+	// reader choose how to view it.
+	SpreadBoth
+)
+
+// view is an image an input page is converted into. If owned is true, its pixel slice was taken
+// from the pool and has to be returned once the view is converted.
+type view struct {
+	img     *image.Gray
+	owned   bool
+	rotated bool
+}
+
+// spreadViews returns the views a page read left to right if ltr is true is converted into, as
+// described by policy. Pages which are not spreads are returned as is.
+func (c *Converter) spreadViews(src *image.Gray, policy SpreadPolicy, ltr bool) []view {
+	if policy == SpreadKeep || src.Rect.Dx() <= src.Rect.Dy() {
+		return []view{{img: src}}
+	}
+
+	var views []view
+	if policy == SpreadRotate || policy == SpreadBoth {
+		views = append(views, view{img: c.rotate(src, ltr), owned: true, rotated: true})
+	}
+	if policy == SpreadSplit || policy == SpreadBoth {
+		first, second := splitSpread(src, c.params.SplitOverlap, c.params.SplitOffset)
+		if !ltr {
+			first, second = second, first
+		}
+		views = append(views, view{img: first}, view{img: second})
+	}
+	return views
+}
+
+// rotate returns src rotated a quarter turn, so that the first half in reading order ends up at
+// the top when read left to right if ltr is true. The returned image's pixel slice is taken from
+// the pool.
+func (c *Converter) rotate(src *image.Gray, ltr bool) *image.Gray {
+	dst := c.pool.Get(src.Rect.Dy(), src.Rect.Dx())
+	if ltr {
+		imgutil.Rotate90(dst, src)
+	} else {
+		imgutil.Rotate270(dst, src)
+	}
+	return dst
+}
+
+// autoRotateGain is how many times larger a page has to be displayed when rotated for AutoRotate
+// to rotate it.
+const autoRotateGain = 1.5
+
+// autoRotate rotates a view if AutoRotate is enabled and the page would be displayed considerably
+// larger when rotated. If the view is replaced, its pixel slice is returned to the pool.
+func (c *Converter) autoRotate(v view) view {
+	if v.rotated || !c.rotates(v.img.Rect) {
+		return v
+	}
+	dst := c.rotate(v.img, c.params.LeftToRight)
+	if v.owned {
+		c.pool.Put(v.img)
+	}
+	return view{img: dst, owned: true, rotated: true}
+}
+
+// rotates reports whether autoRotate rotates a page with bounds r.
+func (c *Converter) rotates(r image.Rectangle) bool {
+	if !c.params.AutoRotate || c.params.Fit == FitWidth {
+		return false
+	}
+	w, h := c.params.Width, c.params.Height
+	fit := imgutil.FitRect(r, w, h)
+	rotated := imgutil.FitRect(image.Rect(0, 0, r.Dy(), r.Dx()), w, h)
+	return float64(rotated.Dx()*rotated.Dy()) >= float64(fit.Dx()*fit.Dy())*autoRotateGain
+}
+
+// wholePage reports whether a page with bounds r is converted as a single page as is, neither
+// split nor rotated.
+func (c *Converter) wholePage(r image.Rectangle, policy SpreadPolicy) bool {
+	return (policy == SpreadKeep || r.Dx() <= r.Dy()) && !c.rotates(r)
+}
+
+// splitSpread returns the left and right halves of a spread. Both share pixels with src.
+//
+// The seam is moved offset % of the spread's width to the right of its center, and each half
+// extends overlap % of the width past the seam.
+func splitSpread(src *image.Gray, overlap, offset float64) (left, right *image.Gray) {
+	w := float64(src.Rect.Dx())
+	mid := src.Rect.Min.X + int(w/2+w*offset/100+0.5)
+	// Keep at least one column in each half.
+	if mid <= src.Rect.Min.X {
+		mid = src.Rect.Min.X + 1
+	}
+	if mid >= src.Rect.Max.X {
+		mid = src.Rect.Max.X - 1
+	}
+	ov := int(w*overlap/100 + 0.5)
+	l, r := src.Rect, src.Rect
+	l.Max.X, r.Min.X = mid+ov, mid-ov
+	// SubImage clips both halves to the spread.
+	return src.SubImage(l).(*image.Gray), src.SubImage(r).(*image.Gray)
+}
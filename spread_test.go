@@ -0,0 +1,109 @@
+package mangaconv
+
+import (
+	"image"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSpreadViews(t *testing.T) {
+	spread := &image.Gray{
+		Rect:   image.Rect(0, 0, 4, 2),
+		Stride: 4,
+		Pix: []uint8{
+			0x01, 0x02, 0x03, 0x04,
+			0x05, 0x06, 0x07, 0x08,
+		},
+	}
+	left := []uint8{0x01, 0x02, 0x05, 0x06}
+	right := []uint8{0x03, 0x04, 0x07, 0x08}
+	rotated := []uint8{
+		0x04, 0x08,
+		0x03, 0x07,
+		0x02, 0x06,
+		0x01, 0x05,
+	}
+	tests := []struct {
+		name string
+		p    Params
+		want [][]uint8
+	}{
+		{"keep", Params{Spreads: SpreadKeep}, [][]uint8{spread.Pix}},
+		{"split rtl", Params{Spreads: SpreadSplit}, [][]uint8{right, left}},
+		{"split ltr", Params{Spreads: SpreadSplit, LeftToRight: true}, [][]uint8{left, right}},
+		{"rotate", Params{Spreads: SpreadRotate}, [][]uint8{rotated}},
+		{"both", Params{Spreads: SpreadBoth}, [][]uint8{rotated, right, left}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][]uint8
+			for _, v := range New(tt.p).spreadViews(spread, tt.p.Spreads, tt.p.LeftToRight) {
+				got = append(got, pixels(v.img))
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("spreadViews() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// pixels returns pixels of img in row order, without any padding.
+func pixels(img *image.Gray) []uint8 {
+	var p []uint8
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		i := img.PixOffset(img.Rect.Min.X, y)
+		p = append(p, img.Pix[i:i+img.Rect.Dx()]...)
+	}
+	return p
+}
+
+func TestSplitSpread(t *testing.T) {
+	spread := &image.Gray{
+		Rect:   image.Rect(0, 0, 10, 1),
+		Stride: 10,
+		Pix:    []uint8{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+	}
+	tests := []struct {
+		name            string
+		overlap, offset float64
+		left, right     []uint8
+	}{
+		{"center", 0, 0, []uint8{0, 1, 2, 3, 4}, []uint8{5, 6, 7, 8, 9}},
+		{"overlap", 10, 0, []uint8{0, 1, 2, 3, 4, 5}, []uint8{4, 5, 6, 7, 8, 9}},
+		{"offset", 0, -20, []uint8{0, 1, 2}, []uint8{3, 4, 5, 6, 7, 8, 9}},
+		{"clipped", 30, 30, []uint8{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, []uint8{5, 6, 7, 8, 9}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, r := splitSpread(spread, tt.overlap, tt.offset)
+			if diff := cmp.Diff(tt.left, pixels(l)); diff != "" {
+				t.Errorf("left half mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.right, pixels(r)); diff != "" {
+				t.Errorf("right half mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAutoRotate(t *testing.T) {
+	tests := []struct {
+		name string
+		w, h int
+		want bool
+	}{
+		{"portrait", 100, 150, false},
+		{"slightly wide", 110, 100, false},
+		{"wide", 300, 100, true},
+	}
+	c := New(Params{AutoRotate: true, Width: 100, Height: 150})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := c.autoRotate(view{img: image.NewGray(image.Rect(0, 0, tt.w, tt.h))})
+			if v.rotated != tt.want {
+				t.Errorf("autoRotate() rotated = %t, want %t", v.rotated, tt.want)
+			}
+		})
+	}
+}
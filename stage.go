@@ -0,0 +1,76 @@
+package mangaconv
+
+import (
+	"context"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// job produces the pages of a single input of a concurrent stage. It may return no pages.
+type job func() ([]page, error)
+
+// ordered runs the jobs returned by next on n workers until next reports there are none left, and
+// sends the pages of each job to out in the order the jobs were returned, so that pages leave a
+// concurrent stage in reading order and are stored in that order. At most 2n finished jobs wait
+// for an earlier one.
+func ordered(ctx context.Context, n int, out chan<- page, next func() (job, bool)) error {
+	type task struct {
+		run  job
+		done chan []page
+	}
+	errg, ctx := errgroup.WithContext(ctx)
+	tasks := make(chan task)
+	queue := make(chan chan []page, 2*n)
+	errg.Go(func() error {
+		defer close(queue)
+		defer close(tasks)
+		for {
+			run, ok := next()
+			if !ok {
+				return nil
+			}
+			done := make(chan []page, 1)
+			select {
+			case queue <- done:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			select {
+			case tasks <- task{run, done}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	})
+	for i := 0; i < n; i++ {
+		errg.Go(func() error {
+			for t := range tasks {
+				pages, err := t.run()
+				if err != nil {
+					return err
+				}
+				t.done <- pages
+			}
+			return nil
+		})
+	}
+	errg.Go(func() error {
+		for done := range queue {
+			var pages []page
+			select {
+			case pages = <-done:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			for _, pg := range pages {
+				select {
+				case out <- pg:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+		return nil
+	})
+	return errg.Wait()
+}
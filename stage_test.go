@@ -0,0 +1,39 @@
+package mangaconv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOrdered(t *testing.T) {
+	const n = 8
+	i := 0
+	next := func() (job, bool) {
+		index := i
+		i++
+		return func() ([]page, error) {
+			// Earlier jobs finish last, and odd jobs emit no pages.
+			time.Sleep(time.Duration(n-index) * time.Millisecond)
+			if index%2 == 1 {
+				return nil, nil
+			}
+			return []page{{Index: index}, {Index: index, Sub: 1}}, nil
+		}, index < n
+	}
+	out := make(chan page, 2*n)
+	if err := ordered(context.Background(), 4, out, next); err != nil {
+		t.Fatalf("ordered() error %v", err)
+	}
+	close(out)
+	var got [][2]int
+	for pg := range out {
+		got = append(got, [2]int{pg.Index, pg.Sub})
+	}
+	want := [][2]int{{0, 0}, {0, 1}, {2, 0}, {2, 1}, {4, 0}, {4, 1}, {6, 0}, {6, 1}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ordered() pages mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,130 @@
+package mangaconv
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ErrSuspiciousInput is returned with Params.Strict for inputs which look mis-packaged, such as an
+// archive of the wrong files.
+var ErrSuspiciousInput = errors.New("suspicious input")
+
+// inputFile is an image of an input checked by Strict. name is its path relative to the input root.
+type inputFile struct {
+	name string
+	open func() (io.ReadCloser, error)
+}
+
+// isMetadataFile reports whether the file name belongs with comics without being a page, such as
+// ComicInfo.xml, or is hidden. These files don't count as non-images for Strict.
+func isMetadataFile(name string) bool {
+	base := path.Base(name)
+	return strings.EqualFold(base, "ComicInfo.xml") || strings.HasPrefix(base, ".") ||
+		strings.EqualFold(base, "Thumbs.db")
+}
+
+// checkZip implements Strict for the zip archive r.
+func (c *Converter) checkZip(r *zip.Reader) error {
+	if !c.params.Strict {
+		return nil
+	}
+	var images []inputFile
+	others := 0
+	for _, f := range r.File {
+		switch {
+		case f.FileInfo().IsDir() || isMetadataFile(f.Name):
+		case isImage(f.Name):
+			images = append(images, inputFile{f.Name, f.Open})
+		default:
+			others++
+		}
+	}
+	return c.checkInput(images, others)
+}
+
+// checkDir implements Strict for the directory root.
+func (c *Converter) checkDir(root string) error {
+	if !c.params.Strict {
+		return nil
+	}
+	var images []inputFile
+	others := 0
+	err := c.walkDir(root, make(map[string]bool), func(p string) error {
+		switch {
+		case isMetadataFile(p):
+		case isImage(p):
+			images = append(images, inputFile{relPath(root, p), func() (io.ReadCloser, error) {
+				return os.Open(p)
+			}})
+		default:
+			others++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return c.checkInput(images, others)
+}
+
+// checkInput fails inputs with no images, with more than StrictNonImages % of other files, or with
+// an image more than StrictSizeRatio times higher or lower than the median image. Images whose
+// headers can't be decoded are left for the conversion to report.
+func (c *Converter) checkInput(images []inputFile, others int) error {
+	if len(images) == 0 {
+		return fmt.Errorf("%w: no images", ErrSuspiciousInput)
+	}
+	if limit := c.params.StrictNonImages; limit > 0 {
+		if pct := 100 * float64(others) / float64(others+len(images)); pct > limit {
+			return fmt.Errorf("%w: %d of %d files aren't images", ErrSuspiciousInput, others, others+len(images))
+		}
+	}
+	if c.params.StrictSizeRatio <= 0 {
+		return nil
+	}
+
+	type size struct {
+		name string
+		h    int
+	}
+	var sizes []size
+	for _, f := range images {
+		if h, err := imageHeight(f.open); err == nil && h > 0 {
+			sizes = append(sizes, size{f.name, h})
+		}
+	}
+	if len(sizes) == 0 {
+		return nil
+	}
+	sorted := make([]int, len(sizes))
+	for i, s := range sizes {
+		sorted[i] = s.h
+	}
+	sort.Ints(sorted)
+	median := float64(sorted[len(sorted)/2])
+	for _, s := range sizes {
+		if h := float64(s.h); h > median*c.params.StrictSizeRatio || h*c.params.StrictSizeRatio < median {
+			return fmt.Errorf("%w: %s is %dpx high, while most pages are %.0fpx high",
+				ErrSuspiciousInput, s.name, s.h, median)
+		}
+	}
+	return nil
+}
+
+// imageHeight returns the height of the image opened by open, only decoding its header.
+func imageHeight(open func() (io.ReadCloser, error)) (int, error) {
+	r, err := open()
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	cfg, _, err := image.DecodeConfig(r)
+	return cfg.Height, err
+}
@@ -0,0 +1,90 @@
+package mangaconv_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/naisuuuu/mangaconv"
+)
+
+// testZip returns a zip archive holding a PNG image of the given height for each image entry and
+// a small text file for every other entry.
+func testZip(t *testing.T, entries map[string]int) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	for name, h := range entries {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h == 0 {
+			f.Write([]byte("not an image"))
+			continue
+		}
+		if err := png.Encode(f, image.NewGray(image.Rect(0, 0, 10, h))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestStrict(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries map[string]int
+		wantErr bool
+	}{
+		{
+			name:    "ok",
+			entries: map[string]int{"1.png": 20, "2.png": 22, "3.png": 18, "ComicInfo.xml": 0, "notes.txt": 0},
+		},
+		{
+			name:    "no images",
+			entries: map[string]int{"ComicInfo.xml": 0, "readme.txt": 0},
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			entries: map[string]int{},
+			wantErr: true,
+		},
+		{
+			name:    "non-images",
+			entries: map[string]int{"1.png": 20, "2.png": 20, "a.txt": 0, "b.txt": 0},
+			wantErr: true,
+		},
+		{
+			name:    "tall page",
+			entries: map[string]int{"1.png": 20, "2.png": 20, "3.png": 70},
+			wantErr: true,
+		},
+		{
+			name:    "short page",
+			entries: map[string]int{"1.png": 20, "2.png": 20, "3.png": 5},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := testZip(t, tt.entries)
+			p := mangaconv.Params{Gamma: 1, Height: 50, Width: 50, StrictNonImages: 25, StrictSizeRatio: 3}
+			if _, err := mangaconv.ConvertBytes(context.Background(), in, "in.cbz", p); err != nil {
+				t.Fatalf("ConvertBytes() without Strict error %v", err)
+			}
+			p.Strict = true
+			_, err := mangaconv.ConvertBytes(context.Background(), in, "in.cbz", p)
+			if got := errors.Is(err, mangaconv.ErrSuspiciousInput); got != tt.wantErr {
+				t.Errorf("ConvertBytes() error = %v, want ErrSuspiciousInput: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
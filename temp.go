@@ -0,0 +1,95 @@
+package mangaconv
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// outputFile is a scratch file an output is written to by convertTarget, moved to path by close
+// once complete.
+type outputFile struct {
+	*os.File
+	c    *Converter
+	path string
+}
+
+// createOutput creates the scratch file the output at path is written to, in TempDir if it's set
+// or next to path otherwise. Half-written outputs thus never show up at path, such as in folders
+// watched by library servers, and an existing output is only replaced once the new one is complete.
+func (c *Converter) createOutput(path string) (*outputFile, error) {
+	dir := c.params.TempDir
+	if dir == "" {
+		dir = filepath.Dir(path)
+	}
+	f, err := createTemp(LongPath(dir), "."+filepath.Base(path)+".")
+	if err != nil {
+		return nil, fmt.Errorf("cannot create temp file: %w", err)
+	}
+	return &outputFile{File: f, c: c, path: path}, nil
+}
+
+// createTemp is like os.CreateTemp with a ".tmp" suffix, but creates the file with mode 0666 before
+// the umask like os.Create instead of 0600, as it's moved into place as an output readable by others.
+func createTemp(dir, prefix string) (*os.File, error) {
+	for i := 0; i < 10000; i++ {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10)+".tmp")
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		return f, err
+	}
+	return nil, &fs.PathError{Op: "createtemp", Path: filepath.Join(dir, prefix+"*.tmp"), Err: fs.ErrExist}
+}
+
+// close closes the scratch file and moves it to the output path, unless converting into it failed
+// with err and its Converter aborts on errors, in which case it's removed. It reports whether the
+// output was written to its path.
+func (f *outputFile) close(err error) (bool, error) {
+	closeErr := f.File.Close()
+	if !f.c.keepOutput(err) {
+		os.Remove(f.Name())
+		return false, nil
+	}
+	if closeErr != nil {
+		os.Remove(f.Name())
+		return false, closeErr
+	}
+	if err := moveFile(f.Name(), LongPath(f.path)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// moveFile moves the file src to dst, copying it if they're on different file systems.
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	defer os.Remove(src)
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package mangaconv
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		tempDir bool
+		err     error
+		policy  ErrorPolicy
+		want    bool
+	}{
+		{"next to output", false, nil, ErrorsAbort, true},
+		{"temp dir", true, nil, ErrorsAbort, true},
+		{"failed", false, errors.New("fail"), ErrorsAbort, false},
+		{"partial", true, &PartialError{}, ErrorsAbort, true},
+		{"failed skipping", false, errors.New("fail"), ErrorsSkip, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outDir, tempDir := t.TempDir(), t.TempDir()
+			p := Params{Errors: tt.policy}
+			if tt.tempDir {
+				p.TempDir = tempDir
+			}
+			path := filepath.Join(outDir, "out.cbz")
+			f, err := New(p).createOutput(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantDir := outDir
+			if tt.tempDir {
+				wantDir = tempDir
+			}
+			if got := filepath.Dir(f.Name()); got != wantDir {
+				t.Errorf("createOutput() created file in %s, want %s", got, wantDir)
+			}
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("output exists before close, stat error = %v", err)
+			}
+			if _, err := f.WriteString("data"); err != nil {
+				t.Fatal(err)
+			}
+			kept, err := f.close(tt.err)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kept != tt.want {
+				t.Errorf("close() = %v, want %v", kept, tt.want)
+			}
+			if _, err := os.Stat(f.Name()); !os.IsNotExist(err) {
+				t.Errorf("scratch file left behind, stat error = %v", err)
+			}
+			info, err := os.Stat(path)
+			if got := err == nil; got != tt.want {
+				t.Errorf("output kept = %v, want %v", got, tt.want)
+			}
+			if err != nil {
+				return
+			}
+			// Outputs get the same mode as files created by os.Create, as the umask allows.
+			ref, err := os.Create(filepath.Join(outDir, "ref"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ref.Close()
+			want, err := os.Stat(ref.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := info.Mode().Perm(); got != want.Mode().Perm() {
+				t.Errorf("output mode = %v, want %v", got, want.Mode().Perm())
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package mangaconv
+
+import (
+	"image"
+
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+// Thumbnail crops img to the aspect ratio of w by h and scales it to w by h pixels, such as to make
+// a series thumbnail for library apps out of a cover. The most detailed area of img is kept with
+// imgutil.SalientCrop rather than its center, so that characters and titles drawn near the edges of
+// wide covers aren't cut off.
+func (c *Converter) Thumbnail(img image.Image, w, h int) *image.Gray {
+	src, ok := imgutil.Luma(img)
+	if !ok {
+		src = imgutil.Grayscale(img)
+	}
+	crop := src.SubImage(imgutil.SalientCrop(src, w, h)).(*image.Gray)
+	dst := image.NewGray(image.Rect(0, 0, w, h))
+	c.scaler.Scale(dst, crop)
+	return dst
+}
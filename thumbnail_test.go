@@ -0,0 +1,29 @@
+package mangaconv
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestThumbnail(t *testing.T) {
+	// A wide cover with a figure near its right edge, which a center crop would cut off.
+	img := image.NewGray(image.Rect(0, 0, 200, 100))
+	draw.Draw(img, img.Rect, image.White, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(160, 20, 190, 80), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	got := New(Params{}).Thumbnail(img, 25, 50)
+	if want := image.Rect(0, 0, 25, 50); got.Rect != want {
+		t.Fatalf("Thumbnail() bounds = %v, want %v", got.Rect, want)
+	}
+	darkest := uint8(0xff)
+	for _, v := range got.Pix {
+		if v < darkest {
+			darkest = v
+		}
+	}
+	if darkest > 0x40 {
+		t.Errorf("Thumbnail() darkest pixel = %#x, want the figure kept", darkest)
+	}
+}
@@ -0,0 +1,37 @@
+package mangaconv
+
+import "time"
+
+// PageTimings are the durations of the conversion stages of a single output page, passed to
+// Output.Timings once the page is written.
+//
+// Index, Sub and Source identify the page as in Page. Decode and Grayscale are spent once for each
+// input page and reported for every page made from it, such as both halves of a split spread.
+// Contrast includes all lookup table adjustments, such as brightness and gamma. Encode is zero for
+// pages passed to Output.Pages.
+type PageTimings struct {
+	Index     int
+	Sub       int
+	Source    string
+	Decode    time.Duration
+	Grayscale time.Duration
+	Scale     time.Duration
+	Contrast  time.Duration
+	Encode    time.Duration
+}
+
+// Total returns the sum of all stage durations.
+func (t PageTimings) Total() time.Duration {
+	return t.Decode + t.Grayscale + t.Scale + t.Contrast + t.Encode
+}
+
+// reportTimings passes the timings of the written page p, which took encode to encode, to fn if
+// it's not nil.
+func reportTimings(fn func(PageTimings), p page, encode time.Duration) {
+	if fn == nil {
+		return
+	}
+	t := p.Timings
+	t.Index, t.Sub, t.Source, t.Encode = p.Index, p.Sub, p.Source, encode
+	fn(t)
+}
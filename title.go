@@ -0,0 +1,82 @@
+package mangaconv
+
+import (
+	"image"
+	"image/draw"
+	"path"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/naisuuuu/mangaconv/imgutil"
+)
+
+// chapterTitle returns a human readable title for a chapter folder, e.g. "Chapter 12" for
+// "vol1/c012". If no chapter number can be found, the folder name is used.
+func chapterTitle(chapter string) string {
+	name := path.Base(chapter)
+	num := ParseFilename(name).Chapter
+	if num == "" {
+		return name
+	}
+	if trimmed := strings.TrimLeft(num, "0"); trimmed != "" && trimmed[0] != '.' {
+		num = trimmed
+	}
+	return "Chapter " + num
+}
+
+// renderText renders black text on a white background using the embedded 7x13 bitmap font,
+// scaled up so that a line of text is height pixels tall.
+func renderText(text string, height int) *image.Gray {
+	face := basicfont.Face7x13
+	d := &font.Drawer{Src: image.Black, Face: face}
+	w := d.MeasureString(text).Ceil()
+	if w == 0 {
+		w = 1
+	}
+	src := image.NewGray(image.Rect(0, 0, w, face.Height))
+	draw.Draw(src, src.Bounds(), image.White, image.Point{}, draw.Src)
+	d.Dst = src
+	d.Dot = fixed.P(0, face.Ascent)
+	d.DrawString(text)
+
+	if height == face.Height {
+		return src
+	}
+	dw := w * height / face.Height
+	if dw < 1 {
+		dw = 1
+	}
+	dst := image.NewGray(image.Rect(0, 0, dw, height))
+	imgutil.CatmullRom.Scale(dst, src)
+	return dst
+}
+
+// renderTitle renders a white page of width by height pixels with text centered on it.
+func renderTitle(text string, width, height int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	size := width
+	if height < width {
+		size = height
+	}
+	// Text on tiny pages is at least a pixel tall, as the scalers need a non-empty image.
+	textHeight := size / 12
+	if textHeight < 1 {
+		textHeight = 1
+	}
+	t := renderText(text, textHeight)
+	if t.Rect.Dx() > width*9/10 {
+		// Long titles would not fit, shrink them down to 90% of page width.
+		r := imgutil.FitRect(t.Rect, width*9/10, t.Rect.Dy())
+		small := image.NewGray(r)
+		imgutil.CatmullRom.Scale(small, t)
+		t = small
+	}
+	at := image.Pt((width-t.Rect.Dx())/2, (height-t.Rect.Dy())/2)
+	draw.Draw(img, t.Rect.Add(at), t, image.Point{}, draw.Src)
+	return img
+}
@@ -0,0 +1,25 @@
+package mangaconv
+
+import (
+	"image"
+	"testing"
+)
+
+func TestRenderTitle(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+	}{
+		{"page", 60, 80},
+		{"tiny", 5, 7},
+		{"single pixel", 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := renderTitle("Chapter 12", tt.width, tt.height)
+			if want := image.Rect(0, 0, tt.width, tt.height); img.Rect != want {
+				t.Errorf("renderTitle() bounds = %v, want %v", img.Rect, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package mangaconv
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Problem is an issue found by Validate. Entry is the archive entry it concerns, empty for issues
+// with the archive as a whole.
+type Problem struct {
+	Entry string
+	Err   error
+}
+
+func (p Problem) String() string {
+	if p.Entry == "" {
+		return p.Err.Error()
+	}
+	return p.Entry + ": " + p.Err.Error()
+}
+
+// Validate checks an archive written by c, such as after copying outputs to unreliable storage. It
+// returns an error if the archive can't be opened and a Problem for each issue found within it:
+//
+//   - pages must be stored in reading order, with names sorting the same way, without duplicates,
+//   - every page must be readable and decodable,
+//   - page sizes must fit Width and Height, as configured by Fit, Margin and ExactSize,
+//   - pages without color must be single channel grayscale JPEGs, unless GrayRGB is set,
+//   - ComicInfo.xml and manifest.json must be present if enabled and describe the pages.
+func (c *Converter) Validate(in string) ([]Problem, error) {
+	r, err := zip.OpenReader(LongPath(in))
+	if err != nil {
+		return nil, fmt.Errorf("cannot open %s: %w", in, err)
+	}
+	defer r.Close()
+
+	var problems []Problem
+	report := func(entry string, err error) {
+		problems = append(problems, Problem{entry, err})
+	}
+
+	var pages []pageInfo
+	var comicInfo, manifest *zip.File
+	for _, f := range r.File {
+		switch {
+		case f.Name == "ComicInfo.xml":
+			comicInfo = f
+		case f.Name == manifestName:
+			manifest = f
+		case isImage(f.Name):
+			p, img, err := readPage(f)
+			if err != nil {
+				report(f.Name, err)
+				continue
+			}
+			if err := c.validateSize(p); err != nil {
+				report(f.Name, err)
+			}
+			if err := c.validateChannels(img); err != nil {
+				report(f.Name, err)
+			}
+			pages = append(pages, p)
+		}
+	}
+	if len(pages) == 0 {
+		report("", errors.New("no pages"))
+	}
+
+	// Readers show pages either in the order they're stored or sorted by name, so both must be the
+	// reading order.
+	seen := make(map[string]bool)
+	for i, p := range pages {
+		if seen[p.Name] {
+			report(p.Name, errors.New("duplicate page"))
+			continue
+		}
+		seen[p.Name] = true
+		if i == 0 {
+			continue
+		}
+		switch prev := pages[i-1]; {
+		case p.Name < prev.Name:
+			report(p.Name, fmt.Errorf("stored after %s, out of name order", prev.Name))
+		case p.Index < prev.Index || (p.Index == prev.Index && p.Sub <= prev.Sub):
+			report(p.Name, fmt.Errorf("out of reading order after %s", prev.Name))
+		}
+	}
+	sort.SliceStable(pages, func(i, j int) bool { return pages[i].Name < pages[j].Name })
+
+	if c.params.ComicInfo {
+		if err := validateComicInfo(comicInfo, pages); err != nil {
+			report("ComicInfo.xml", err)
+		}
+	}
+	if c.params.Manifest {
+		if err := validateManifest(manifest, pages); err != nil {
+			report(manifestName, err)
+		}
+	}
+	return problems, nil
+}
+
+// readPage reads and decodes a page entry named by pageName, returning its decoded image along with
+// its description.
+func readPage(f *zip.File) (pageInfo, image.Image, error) {
+	index, sub, err := parsePageName(f.Name)
+	if err != nil {
+		return pageInfo{}, nil, err
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return pageInfo{}, nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return pageInfo{}, nil, fmt.Errorf("%w: %v", ErrCorruptEntry, err)
+	}
+	img, err := decodeImage(data, func(image.Config) bool { return true })
+	if err != nil {
+		return pageInfo{}, nil, fmt.Errorf("cannot decode: %w", err)
+	}
+	size := img.Bounds().Size()
+	sum := sha256.Sum256(data)
+	return pageInfo{
+		Index:  index,
+		Sub:    sub,
+		Name:   f.Name,
+		Size:   int64(len(data)),
+		SHA256: hex.EncodeToString(sum[:]),
+		Width:  size.X,
+		Height: size.Y,
+	}, img, nil
+}
+
+// validateChannels checks whether a page decoded as img was encoded with the channels c writes.
+// image/jpeg decodes single channel JPEGs as grayscale images and three channel ones as YCbCr.
+func (c *Converter) validateChannels(img image.Image) error {
+	if _, ok := img.(*image.Gray); ok || c.params.GrayRGB || isColor(img) {
+		return nil
+	}
+	return errors.New("grayscale page encoded with three channels")
+}
+
+// validateSize checks whether a page of its size could have been written by c.
+func (c *Converter) validateSize(p pageInfo) error {
+	size := image.Pt(p.Width, p.Height)
+	w, h := c.params.Width, c.params.Height
+	if c.params.Fit == FitWidth {
+		h = 0
+	}
+	exact := c.params.ExactSize
+	switch {
+	case w > 0 && (size.X > w || exact && size.X != w):
+		return fmt.Errorf("width %d doesn't fit %d", size.X, w)
+	case h > 0 && (size.Y > h || exact && size.Y != h):
+		return fmt.Errorf("height %d doesn't fit %d", size.Y, h)
+	}
+	return nil
+}
+
+// parsePageName parses the index and sub page number of a page entry named by pageName.
+func parsePageName(name string) (index, sub int, err error) {
+	base := strings.TrimSuffix(path.Base(name), path.Ext(name))
+	if i := strings.IndexByte(base, '-'); i >= 0 {
+		base = base[:i]
+	}
+	num, subNum := base, "0"
+	if i := strings.IndexByte(base, '_'); i >= 0 {
+		num, subNum = base[:i], base[i+1:]
+	}
+	index, err = strconv.Atoi(num)
+	if err != nil || len(num) != 9 || path.Dir(name) != "." {
+		return 0, 0, errors.New("not named by page number")
+	}
+	if sub, err = strconv.Atoi(subNum); err != nil {
+		return 0, 0, errors.New("not named by page number")
+	}
+	return index, sub, nil
+}
+
+// validateComicInfo checks whether ComicInfo.xml describes pages.
+func validateComicInfo(f *zip.File, pages []pageInfo) error {
+	if f == nil {
+		return errors.New("missing")
+	}
+	var ci comicInfo
+	if err := decodeEntry(f, func(r io.Reader) error { return xml.NewDecoder(r).Decode(&ci) }); err != nil {
+		return err
+	}
+	if ci.PageCount != len(pages) || len(ci.Pages) != len(pages) {
+		return fmt.Errorf("describes %d pages, archive has %d", ci.PageCount, len(pages))
+	}
+	for i, p := range pages {
+		cp := ci.Pages[i]
+		if cp.ImageSize != p.Size || cp.ImageWidth != p.Width || cp.ImageHeight != p.Height {
+			return fmt.Errorf("page %d doesn't match %s", i, p.Name)
+		}
+	}
+	return nil
+}
+
+// validateManifest checks whether manifest.json lists pages with their checksums.
+func validateManifest(f *zip.File, pages []pageInfo) error {
+	if f == nil {
+		return errors.New("missing")
+	}
+	var m manifest
+	if err := decodeEntry(f, func(r io.Reader) error { return json.NewDecoder(r).Decode(&m) }); err != nil {
+		return err
+	}
+	if len(m.Pages) != len(pages) {
+		return fmt.Errorf("lists %d pages, archive has %d", len(m.Pages), len(pages))
+	}
+	for i, p := range pages {
+		if mp := m.Pages[i]; mp.Name != p.Name || mp.SHA256 != p.SHA256 {
+			return fmt.Errorf("checksum of %s doesn't match", p.Name)
+		}
+	}
+	return nil
+}
+
+// decodeEntry opens an archive entry and decodes it with decode.
+func decodeEntry(f *zip.File, decode func(io.Reader) error) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := decode(rc); err != nil {
+		return fmt.Errorf("cannot decode: %w", err)
+	}
+	return nil
+}
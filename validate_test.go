@@ -0,0 +1,121 @@
+package mangaconv
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	params := Params{AutoContrast: true, Cutoff: 1, Gamma: 1, Width: 100, Height: 100, ComicInfo: true, Manifest: true}
+	out := filepath.Join(dir, "out.cbz")
+	if err := New(params).Convert("testdata/wikipe-tan.zip", out); err != nil {
+		t.Fatal(err)
+	}
+	rgbParams := params
+	rgbParams.GrayRGB = true
+	rgb := filepath.Join(dir, "rgb.cbz")
+	if err := New(rgbParams).Convert("testdata/wikipe-tan.zip", rgb); err != nil {
+		t.Fatal(err)
+	}
+	page, err := os.ReadFile("testdata/wikipe-tan-0.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := writeEntries(t, filepath.Join(dir, "tampered.cbz"), []entry{
+		{"000000000.png", page},
+		{"cover.png", page},
+		{"000000001.jpg", []byte("not an image")},
+	})
+	reordered := writeEntries(t, filepath.Join(dir, "reordered.cbz"), []entry{
+		{"000000000.png", page},
+		{"000000002.png", page},
+		{"000000001.png", page},
+		{"000000002.png", page},
+	})
+
+	small := params
+	small.Width, small.Height = 50, 50
+	exact := params
+	exact.ExactSize = true
+
+	tests := []struct {
+		name   string
+		params Params
+		in     string
+		want   []string
+	}{
+		{"valid", params, out, nil},
+		{"too large", small, out, []string{
+			"000000000.jpg: width 82 doesn't fit 50",
+			"000000001.jpg: width 82 doesn't fit 50",
+		}},
+		{"not exact", exact, out, []string{
+			"000000000.jpg: width 82 doesn't fit 100",
+			"000000001.jpg: width 82 doesn't fit 100",
+		}},
+		{"three channels", params, rgb, []string{
+			"000000000.jpg: grayscale page encoded with three channels",
+			"000000001.jpg: grayscale page encoded with three channels",
+		}},
+		{"three channels allowed", rgbParams, rgb, nil},
+		{"tampered", Params{ComicInfo: true}, tampered, []string{
+			"cover.png: not named by page number",
+			"000000001.jpg: cannot decode: image: unknown format",
+			"ComicInfo.xml: missing",
+		}},
+		{"reordered", Params{}, reordered, []string{
+			"000000001.png: stored after 000000002.png, out of name order",
+			"000000002.png: duplicate page",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems, err := New(tt.params).Validate(tt.in)
+			if err != nil {
+				t.Fatalf("Validate() error %v", err)
+			}
+			var got []string
+			for _, p := range problems {
+				got = append(got, p.String())
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Validate() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// entry is a file stored in an archive written by writeEntries.
+type entry struct {
+	name string
+	data []byte
+}
+
+// writeEntries writes an archive of entries, in order, to path and returns path.
+func writeEntries(t *testing.T, path string, entries []entry) string {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	for _, e := range entries {
+		ew, err := w.Create(e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ew.Write(e.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -2,44 +2,335 @@ package mangaconv
 
 import (
 	"archive/zip"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"image"
+	"image/draw"
 	"image/jpeg"
 
 	// for image decoding.
 	_ "image/png"
 	"io"
+	"path"
+	"strings"
+	"sync"
+	"time"
 )
 
-func (c *Converter) writeZip(writer io.Writer, deflate bool, pages <-chan page) error {
-	method := zip.Store
-	if deflate {
-		method = zip.Deflate
+// Compression controls which compression method is used for entries of output archives.
+type Compression int
+
+const (
+	// CompressionAuto stores already compressed JPEG and WebP images as is and deflates all other
+	// entries, such as PNG images and ComicInfo.xml.
+	CompressionAuto Compression = iota
+	// CompressionStore stores all entries uncompressed, for readers which don't support deflate.
+	CompressionStore
+	// CompressionDeflate deflates all entries.
+	CompressionDeflate
+)
+
+// method returns the zip compression method for an entry with the given name.
+func (c Compression) method(name string) uint16 {
+	switch c {
+	case CompressionStore:
+		return zip.Store
+	case CompressionDeflate:
+		return zip.Deflate
+	}
+	switch strings.ToLower(path.Ext(name)) {
+	case ".jpg", ".jpeg", ".webp":
+		return zip.Store
+	default:
+		return zip.Deflate
+	}
+}
+
+// WrittenPage is a page written to an output archive, passed to Output.Written. Index, Sub, Chapter
+// and Source are as in Page, and Entry is the name of the page's entry in the archive.
+type WrittenPage struct {
+	Index   int
+	Sub     int
+	Chapter string
+	Source  string
+	Entry   string
+}
+
+// pageInfo describes a page written to an output archive. Name is the page's entry name, Size and
+// SHA256 are the size in bytes and hex encoded checksum of the encoded image.
+type pageInfo struct {
+	Index    int
+	Sub      int
+	Chapter  string
+	Name     string
+	Source   string
+	Size     int64
+	SHA256   string
+	Width    int
+	Height   int
+	Credits  bool
+	Bookmark string
+}
+
+// writeZip writes pages to a single archive, to o.Writer, reporting each page to o's Timings and
+// Written.
+func (c *Converter) writeZip(o Output, meta Metadata, pages <-chan page) error {
+	a, err := c.newArchive(o.Writer, o)
+	if err != nil {
+		return err
+	}
+	defer a.release()
+	for p := range pages {
+		if err := a.add(p); err != nil {
+			return err
+		}
 	}
+	return a.finish(meta)
+}
 
-	w := zip.NewWriter(writer)
-	defer w.Close()
+// writeChapters writes pages to a separate archive for each chapter, created by calling o.Chapters
+// with the chapter folder on its first page. Each archive's metadata is meta with the chapter number
+// parsed from its folder. Each page is reported to o's Timings and Written.
+func (c *Converter) writeChapters(o Output, meta Metadata, pages <-chan page) error {
+	archives := make(map[string]*archive)
+	defer func() {
+		for _, a := range archives {
+			a.release()
+		}
+	}()
 	for p := range pages {
-		f, err := w.CreateHeader(&zip.FileHeader{
-			Name:   fmt.Sprintf("%09d.jpg", p.Index),
-			Method: method,
+		a, ok := archives[p.Chapter]
+		if !ok {
+			w, err := o.Chapters(p.Chapter)
+			if err != nil {
+				return err
+			}
+			if a, err = c.newArchive(w, o); err != nil {
+				return err
+			}
+			archives[p.Chapter] = a
+		}
+		if err := a.add(p); err != nil {
+			return err
+		}
+	}
+	for chapter, a := range archives {
+		if err := a.finish(chapterMetadata(meta, chapter)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chapterMetadata returns the metadata of a single chapter of an input with metadata m.
+func chapterMetadata(m Metadata, chapter string) Metadata {
+	if num := ParseFilename(path.Base(chapter)).Chapter; chapter != "" && num != "" {
+		m.Chapter = num
+	}
+	return m
+}
+
+// archive is an output archive pages are added to one by one.
+type archive struct {
+	c       *Converter
+	w       *zip.Writer
+	infos   []pageInfo
+	timings func(PageTimings)
+	written func(WrittenPage)
+	// The hash and encode buffer are reused for all pages.
+	h   hash.Hash
+	buf *bufio.Writer
+}
+
+// newArchive creates an archive in the output Format writing to w, reporting each added page to o's
+// Timings and Written. It must be released once done.
+func (c *Converter) newArchive(w io.Writer, o Output) (*archive, error) {
+	zw := zip.NewWriter(w)
+	if c.params.Format.isEPUB() {
+		if err := writeMimetype(zw); err != nil {
+			return nil, err
+		}
+	}
+	return &archive{
+		c:       c,
+		w:       zw,
+		timings: o.Timings,
+		written: o.Written,
+		h:       sha256.New(),
+		buf:     encodeBuffers.Get().(*bufio.Writer),
+	}, nil
+}
+
+// add encodes and writes a page, putting its image back into the pool.
+func (a *archive) add(p page) error {
+	name := a.c.pageName(p)
+	f, err := a.w.CreateHeader(&zip.FileHeader{
+		Name:   name,
+		Method: a.c.params.Compression.method(name),
+	})
+	if err != nil {
+		return err
+	}
+	a.h.Reset()
+	cw := &countingWriter{w: io.MultiWriter(f, a.h)}
+	a.buf.Reset(cw)
+	start := time.Now()
+	img, tmp := a.c.jpegImage(p.Image)
+	err = saveImg(a.buf, img)
+	encode := time.Since(start)
+	if tmp != nil {
+		a.c.pool.Put(tmp)
+	}
+	size := p.Image.Bounds().Size()
+	if v, ok := p.Image.(*image.Gray); ok {
+		a.c.pool.Put(v)
+	}
+	if err != nil {
+		return err
+	}
+	// Sub pages share the bookmark of their input page, which belongs on the first one.
+	bookmark := p.Bookmark
+	if p.Sub > 0 {
+		bookmark = ""
+	}
+	a.infos = append(a.infos, pageInfo{
+		Index:    p.Index,
+		Sub:      p.Sub,
+		Chapter:  p.Chapter,
+		Name:     name,
+		Source:   p.Source,
+		Size:     cw.n,
+		SHA256:   hex.EncodeToString(a.h.Sum(nil)),
+		Width:    size.X,
+		Height:   size.Y,
+		Credits:  p.Credits,
+		Bookmark: bookmark,
+	})
+	reportTimings(a.timings, p, encode)
+	if a.written != nil {
+		a.written(WrittenPage{Index: p.Index, Sub: p.Sub, Chapter: p.Chapter, Source: p.Source, Entry: name})
+	}
+	return nil
+}
+
+// finish writes metadata files describing the added pages, and the files making up an EPUB book
+// if enabled, and closes the archive.
+func (a *archive) finish(meta Metadata) error {
+	// Pages are converted and written in reading order.
+	infos := a.infos
+
+	if a.c.params.ComicInfo {
+		f, err := a.w.CreateHeader(&zip.FileHeader{
+			Name:   "ComicInfo.xml",
+			Method: a.c.params.Compression.method("ComicInfo.xml"),
 		})
 		if err != nil {
 			return err
 		}
-		err = saveImg(f, p.Image)
-		if v, ok := p.Image.(*image.Gray); ok {
-			c.pool.Put(v)
+		if err := newComicInfo(meta, infos).encode(f); err != nil {
+			return err
 		}
+	}
+	if a.c.params.Manifest {
+		f, err := a.w.CreateHeader(&zip.FileHeader{
+			Name:   manifestName,
+			Method: a.c.params.Compression.method(manifestName),
+		})
 		if err != nil {
 			return err
 		}
+		if err := newManifest(infos).encode(f); err != nil {
+			return err
+		}
 	}
-	return nil
+	if a.c.params.Format.isEPUB() {
+		if err := a.writeEPUB(meta, infos); err != nil {
+			return err
+		}
+	}
+	return a.w.Close()
+}
+
+// release closes the archive if it isn't finished yet and returns its encode buffer to the pool.
+func (a *archive) release() {
+	if a.buf == nil {
+		return
+	}
+	a.w.Close()
+	a.buf.Reset(nil)
+	encodeBuffers.Put(a.buf)
+	a.buf = nil
 }
 
-func saveImg(target io.Writer, img image.Image) error {
-	if err := jpeg.Encode(target, img, &jpeg.Options{Quality: 75}); err != nil {
+// pageName returns the archive entry name of a page. Sub pages sort right after their main page.
+// If KeepNames is enabled, the sanitized original name is appended after the page number.
+func (c *Converter) pageName(p page) string {
+	name := fmt.Sprintf("%09d", p.Index)
+	if p.Sub > 0 {
+		name += fmt.Sprintf("_%d", p.Sub)
+	}
+	if c.params.KeepNames && p.Name != "" {
+		name += "-" + SafeName(p.Name, "_")
+	}
+	return name + ".jpg"
+}
+
+// countingWriter counts bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// encodeBufferSize is the size of buffers pages are encoded into before being written to archives.
+const encodeBufferSize = 64 << 10
+
+// encodeBuffers holds buffered writers reused across archives. image/jpeg writes to targets which
+// implement Flush and WriteByte directly, instead of allocating a buffer for each page.
+var encodeBuffers = sync.Pool{
+	New: func() interface{} {
+		return bufio.NewWriterSize(nil, encodeBufferSize)
+	},
+}
+
+// jpegOptions are the options all pages are encoded with.
+var jpegOptions = &jpeg.Options{Quality: 75}
+
+// jpegImage returns img in the form it's encoded as: pages without noticeable color as grayscale
+// images, which image/jpeg encodes as single channel JPEGs, and grayscale pages as RGBA images with
+// GrayRGB. If the returned grayscale image is a copy, it's also returned as tmp, with its pixel slice
+// taken from the pool.
+func (c *Converter) jpegImage(img image.Image) (enc image.Image, tmp *image.Gray) {
+	switch img := img.(type) {
+	case *image.Gray:
+		if c.params.GrayRGB {
+			rgba := image.NewRGBA(img.Rect)
+			draw.Draw(rgba, img.Rect, img, img.Rect.Min, draw.Src)
+			return rgba, nil
+		}
+	case *image.RGBA:
+		if !c.params.GrayRGB && !isColor(img) {
+			gray := c.pool.GetFromImage(img)
+			return gray, gray
+		}
+	}
+	return img, nil
+}
+
+// saveImg encodes img to target, flushing it once done.
+func saveImg(target *bufio.Writer, img image.Image) error {
+	if err := jpeg.Encode(target, img, jpegOptions); err != nil {
+		return fmt.Errorf("cannot encode: %w", err)
+	}
+	if err := target.Flush(); err != nil {
 		return fmt.Errorf("cannot encode: %w", err)
 	}
 	return nil
@@ -0,0 +1,193 @@
+package mangaconv
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompressionMethod(t *testing.T) {
+	tests := []struct {
+		c    Compression
+		name string
+		want uint16
+	}{
+		{CompressionAuto, "000000001.jpg", zip.Store},
+		{CompressionAuto, "000000001.WEBP", zip.Store},
+		{CompressionAuto, "000000001.png", zip.Deflate},
+		{CompressionAuto, "ComicInfo.xml", zip.Deflate},
+		{CompressionStore, "ComicInfo.xml", zip.Store},
+		{CompressionDeflate, "000000001.jpg", zip.Deflate},
+	}
+	for _, tt := range tests {
+		if got := tt.c.method(tt.name); got != tt.want {
+			t.Errorf("Compression(%d).method(%q) = %d, want %d", tt.c, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSplitChapters(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.zip")
+	mustWriteZip(t, in, map[string]string{
+		"0.png":     "testdata/wikipe-tan-0.png",
+		"c10/0.png": "testdata/wikipe-tan-0.png",
+		"c2/0.png":  "testdata/wikipe-tan-1.png",
+		"c2/1.png":  "testdata/wikipe-tan-0.png",
+	})
+	p := Params{AutoContrast: true, Cutoff: 1, Gamma: 1, Width: 50, Height: 50,
+		Chapters: true, SplitChapters: true, ComicInfo: true}
+	target := Target{In: in, Out: []string{filepath.Join(dir, "out.cbz")}}
+	written := make(map[string][]string)
+	r := ConvertAll(context.Background(), []Target{target}, BatchOptions{
+		Converters: []*Converter{New(p)},
+		Written: func(_ Target, out string, wp WrittenPage) {
+			written[filepath.Base(out)] = append(written[filepath.Base(out)], wp.Entry)
+		},
+	})[0]
+	if r.Err != nil {
+		t.Fatalf("ConvertAll() error %v", r.Err)
+	}
+	gotOutputs := append([]string(nil), r.Outputs...)
+	sort.Strings(gotOutputs)
+	wantOutputs := []string{
+		filepath.Join(dir, "out - c10.cbz"),
+		filepath.Join(dir, "out - c2.cbz"),
+		filepath.Join(dir, "out.cbz"),
+	}
+	if diff := cmp.Diff(wantOutputs, gotOutputs); diff != "" {
+		t.Errorf("Result.Outputs mismatch (-want +got):\n%s", diff)
+	}
+
+	want := map[string][]string{
+		"out.cbz":       {"000000000.jpg"},
+		"out - c2.cbz":  {"000000001.jpg", "000000002.jpg"},
+		"out - c10.cbz": {"000000003.jpg"},
+	}
+	if diff := cmp.Diff(want, written); diff != "" {
+		t.Errorf("written pages mismatch (-want +got):\n%s", diff)
+	}
+	for name, pages := range want {
+		r, err := zip.OpenReader(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		var got []string
+		for _, f := range r.File {
+			got = append(got, f.Name)
+		}
+		r.Close()
+		sort.Strings(got)
+		if diff := cmp.Diff(append(pages, "ComicInfo.xml"), got); diff != "" {
+			t.Errorf("%s entries mismatch (-want +got):\n%s", name, diff)
+		}
+	}
+}
+
+func TestChapterPath(t *testing.T) {
+	tests := []struct {
+		out     string
+		chapter string
+		want    string
+	}{
+		{"out/a.cbz", "", "out/a.cbz"},
+		{"out/a.cbz", "c001", "out/a - c001.cbz"},
+		{"out/a.cbz", "vol1/c001", "out/a - vol1 - c001.cbz"},
+		{"out/a.cbz", "what?", "out/a - what_.cbz"},
+		{"out/a.kepub.epub", "c001", "out/a - c001.kepub.epub"},
+	}
+	for _, tt := range tests {
+		if got := chapterPath(tt.out, tt.chapter); got != tt.want {
+			t.Errorf("chapterPath(%q, %q) = %q, want %q", tt.out, tt.chapter, got, tt.want)
+		}
+	}
+}
+
+func TestJPEGImage(t *testing.T) {
+	r := image.Rect(0, 0, 4, 4)
+	colored := image.NewRGBA(r)
+	draw.Draw(colored, r, image.NewUniform(color.RGBA{0xff, 0, 0, 0xff}), image.Point{}, draw.Src)
+	gray := image.NewRGBA(r)
+	draw.Draw(gray, r, image.NewUniform(color.RGBA{0x80, 0x80, 0x80, 0xff}), image.Point{}, draw.Src)
+
+	tests := []struct {
+		name    string
+		img     image.Image
+		grayRGB bool
+		want    string
+	}{
+		{"gray", image.NewGray(r), false, "*image.Gray"},
+		{"gray rgb", image.NewGray(r), true, "*image.RGBA"},
+		{"colored", colored, false, "*image.RGBA"},
+		{"kept in color without color", gray, false, "*image.Gray"},
+		{"kept in color without color rgb", gray, true, "*image.RGBA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(Params{GrayRGB: tt.grayRGB})
+			enc, tmp := c.jpegImage(tt.img)
+			if got := fmt.Sprintf("%T", enc); got != tt.want {
+				t.Errorf("jpegImage() = %s, want %s", got, tt.want)
+			}
+			if tmp != nil && enc != image.Image(tmp) {
+				t.Errorf("jpegImage() returned a temporary image it doesn't encode")
+			}
+		})
+	}
+}
+
+func TestDeflate(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Params
+		want Compression
+	}{
+		{"auto", Params{Deflate: true}, CompressionDeflate},
+		{"explicit compression", Params{Deflate: true, Compression: CompressionStore}, CompressionStore},
+		{"disabled", Params{}, CompressionAuto},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := New(tt.p).params.Compression; got != tt.want {
+				t.Errorf("New() Compression = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStoredOrder(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.zip")
+	files := make(map[string]string)
+	for i := 0; i < 16; i++ {
+		files[fmt.Sprintf("%02d.png", i)] = fmt.Sprintf("testdata/wikipe-tan-%d.png", i%2)
+	}
+	mustWriteZip(t, in, files)
+	out := filepath.Join(dir, "out.cbz")
+	if err := New(Params{Gamma: 1, Width: 50, Height: 50}).Convert(in, out); err != nil {
+		t.Fatalf("Convert() error %v", err)
+	}
+	r, err := zip.OpenReader(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var got []string
+	for _, f := range r.File {
+		got = append(got, f.Name)
+	}
+	want := append([]string(nil), got...)
+	sort.Strings(want)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("stored order mismatch (-want +got):\n%s", diff)
+	}
+}